@@ -0,0 +1,6 @@
+package constants
+
+import "time"
+
+// time an order has to be placed with a quote before the fee is computed again
+const DELIVERY_QUOTE_TTL = 15 * time.Minute
@@ -0,0 +1,13 @@
+package constants
+
+// uploads above this are rejected, the http body limit leaves room for the multipart envelope
+const IMAGE_MAX_BYTES = 5 * 1024 * 1024
+const IMAGE_BODY_LIMIT = IMAGE_MAX_BYTES + 1024*1024
+
+// decoded size limit, a small file can still decode to a huge bitmap
+const IMAGE_MAX_PIXELS = 40 * 1000 * 1000
+
+const IMAGE_JPEG_QUALITY = 85
+
+// images are content addressed so they never change once served
+const IMAGE_CACHE_CONTROL = "public, max-age=31536000, immutable"
@@ -1,3 +1,19 @@
 package constants
 
 const INFLUENCE_RADIUS = 2.0
+
+// known peers above which a peer stops keeping the full table and switches to the geohash overlay
+const OVERLAY_FULL_TABLE_LIMIT = 256
+
+// peers kept for every geohash subtree of the routing table
+const OVERLAY_SUBTREE_CONTACTS = 2
+
+const OVERLAY_LOOKUP_SIZE = 8
+const OVERLAY_MAX_HOPS = 16
+
+// in area peers receiving a copy of the restaurants when no buddies are configured
+const REPLICATION_BUDDY_COUNT = 1
+const REPLICATION_INTERVAL = "5s"
+
+// an origin silent for longer can be promoted by its buddies
+const REPLICATION_DEAD_AFTER = "10m"
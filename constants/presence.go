@@ -0,0 +1,11 @@
+package constants
+
+// the presence stream sends a ping this often, clients posting heartbeats
+// instead should use the same rate
+const PRESENCE_HEARTBEAT = "15s"
+
+// a restaurant sending heartbeats is disconnected after missing them for this long
+const PRESENCE_TIMEOUT = "45s"
+
+// disconnected restaurants are paused after this, PRESENCE_GRACE_PERIOD overrides it
+const PRESENCE_GRACE_PERIOD = "2m"
@@ -0,0 +1,6 @@
+package constants
+
+import "time"
+
+// time a new restaurant has to set its credentials with the setup token
+const RESTAURANT_SETUP_TTL = 7 * 24 * time.Hour
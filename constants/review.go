@@ -0,0 +1,3 @@
+package constants
+
+const REVIEW_PAGE_SIZE = 20
@@ -0,0 +1,14 @@
+package constants
+
+// time a delivery area peer has to answer a search before it's reported as failed
+const SEARCH_PEER_TIMEOUT = "3s"
+
+// ranking weights, a star of rating is worth a km of distance or ten minutes of delivery
+const SEARCH_RATING_WEIGHT = 1.0
+const SEARCH_DISTANCE_WEIGHT = 1.0
+const SEARCH_ETA_WEIGHT = 0.1
+
+// a full text match is worth five stars of rating
+const SEARCH_TEXT_WEIGHT = 5.0
+
+const TEXT_SEARCH_PAGE_SIZE = 20
@@ -0,0 +1,6 @@
+package constants
+
+import "time"
+
+// time an invited staff user has to set its credentials
+const STAFF_INVITE_TTL = 7 * 24 * time.Hour
@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/services"
+	"github.com/nicodeheza/peersEat/services/validations"
+	"github.com/nicodeheza/peersEat/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type DeliveryController struct {
+	service    services.DeliveryServiceI
+	validators validations.ValidateI
+}
+
+type DeliveryControllerI interface {
+	UpdatePricing(c *fiber.Ctx) error
+	Quote(c *fiber.Ctx) error
+	GetQuote(c *fiber.Ctx) error
+}
+
+func NewDeliveryController(service services.DeliveryServiceI, validators validations.ValidateI) *DeliveryController {
+	return &DeliveryController{service, validators}
+}
+
+func deliveryErrorResponse(c *fiber.Ctx, err error) error {
+	if err == mongo.ErrNoDocuments {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "restaurant not found"})
+	}
+	if err == services.ErrQuoteNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": err.Error()})
+	}
+	if err == services.ErrRestaurantNotActive || err == services.ErrRestaurantPaused {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": err.Error()})
+	}
+	if err == services.ErrQuoteExpired {
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{"message": err.Error()})
+	}
+	if err == services.ErrOutOfDeliveryArea || errors.Is(err, services.ErrInvalidDeliveryPricing) || errors.Is(err, models.ErrCurrencyMismatch) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
+	var minimumError *services.MinimumOrderError
+	if errors.As(err, &minimumError) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error(), "minOrder": minimumError.MinOrder})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+}
+
+func (d *DeliveryController) UpdatePricing(c *fiber.Ctx) error {
+	id, err := sessionRestaurantId(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "unauthorized"})
+	}
+
+	body := new(types.DeliveryPricingData)
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := d.validators.ValidateDeliveryPricing(*body)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	pricing, err := d.service.UpdatePricing(id, *body)
+	if err != nil {
+		return deliveryErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(pricing)
+}
+
+func (d *DeliveryController) Quote(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": errInvalidRestaurantId.Error()})
+	}
+
+	body := new(types.DeliveryQuoteRequest)
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := d.validators.ValidateDeliveryQuoteRequest(*body)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	quote, err := d.service.Quote(id, *body)
+	if err != nil {
+		return deliveryErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(quote)
+}
+
+func (d *DeliveryController) GetQuote(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": errInvalidRestaurantId.Error()})
+	}
+	quoteId, err := primitive.ObjectIDFromHex(c.Params("quoteId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid quote id"})
+	}
+
+	quote, err := d.service.GetQuote(id, quoteId)
+	if err != nil {
+		return deliveryErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(quote)
+}
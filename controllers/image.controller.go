@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nicodeheza/peersEat/constants"
+	"github.com/nicodeheza/peersEat/services"
+	"github.com/nicodeheza/peersEat/services/images"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type ImageController struct {
+	service services.ImageServiceI
+}
+
+type ImageControllerI interface {
+	UploadRestaurantImage(c *fiber.Ctx) error
+	UploadDishImage(c *fiber.Ctx) error
+	ServeImage(c *fiber.Ctx) error
+}
+
+func NewImageController(service services.ImageServiceI) *ImageController {
+	return &ImageController{service}
+}
+
+func imageErrorResponse(c *fiber.Ctx, err error) error {
+	if err == images.ErrImageTooLarge {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{"message": err.Error()})
+	}
+	if err == images.ErrUnsupportedImage {
+		return c.Status(fiber.StatusUnsupportedMediaType).JSON(fiber.Map{"message": err.Error()})
+	}
+	if err == images.ErrImageNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": err.Error()})
+	}
+	if err == mongo.ErrNoDocuments {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "restaurant not found"})
+	}
+	return menuErrorResponse(c, err)
+}
+
+// the image comes in the "image" field of a multipart form
+func readUpload(c *fiber.Ctx) ([]byte, error) {
+	file, err := c.FormFile("image")
+	if err != nil {
+		return nil, err
+	}
+	if file.Size > constants.IMAGE_MAX_BYTES {
+		return nil, images.ErrImageTooLarge
+	}
+
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(io.LimitReader(reader, constants.IMAGE_MAX_BYTES+1))
+}
+
+func (i *ImageController) UploadRestaurantImage(c *fiber.Ctx) error {
+	id, err := sessionRestaurantId(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "unauthorized"})
+	}
+
+	data, err := readUpload(c)
+	if err == images.ErrImageTooLarge {
+		return imageErrorResponse(c, err)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "missing image"})
+	}
+
+	upload, err := i.service.SetRestaurantImage(id, data)
+	if err != nil {
+		return imageErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(upload)
+}
+
+func (i *ImageController) UploadDishImage(c *fiber.Ctx) error {
+	id, err := sessionRestaurantId(c)
+	if err != nil {
+		return menuErrorResponse(c, errUnauthorized)
+	}
+	path, err := menuPath(c)
+	if err != nil {
+		return menuErrorResponse(c, errInvalidMenuItemId)
+	}
+
+	data, err := readUpload(c)
+	if err == images.ErrImageTooLarge {
+		return imageErrorResponse(c, err)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "missing image"})
+	}
+
+	upload, err := i.service.SetDishImage(id, path, data)
+	if err != nil {
+		return imageErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(upload)
+}
+
+// keys are the hash of the image so a key never changes content and can be
+// cached forever
+func (i *ImageController) ServeImage(c *fiber.Ctx) error {
+	key := c.Params("hash") + "/" + c.Params("file")
+	data, contentType, modTime, err := i.service.Get(key)
+	if err != nil {
+		return imageErrorResponse(c, err)
+	}
+
+	etag := fmt.Sprintf("\"%v\"", key)
+	c.Set(fiber.HeaderCacheControl, constants.IMAGE_CACHE_CONTROL)
+	c.Set(fiber.HeaderETag, etag)
+	c.Set(fiber.HeaderLastModified, modTime.UTC().Format(http.TimeFormat))
+	if c.Get(fiber.HeaderIfNoneMatch) == etag {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	c.Set(fiber.HeaderContentType, contentType)
+	return c.Status(fiber.StatusOK).Send(data)
+}
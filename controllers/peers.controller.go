@@ -1,22 +1,48 @@
 package controllers
 
 import (
+	"errors"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/nicodeheza/peersEat/models"
 	"github.com/nicodeheza/peersEat/services"
 	"github.com/nicodeheza/peersEat/services/geo"
+	"github.com/nicodeheza/peersEat/services/geocoder"
 	"github.com/nicodeheza/peersEat/services/validations"
 	"github.com/nicodeheza/peersEat/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type PeerControllerI interface {
 	SendAllPeers(c *fiber.Ctx) error
 	HaveRestaurant(c *fiber.Ctx) error
 	AddNewRestaurant(c *fiber.Ctx) error
+	NewRestaurantSetupToken(c *fiber.Ctx) error
 	EventReceiver(c *fiber.Ctx) error
+	UpdateInfluenceRadius(c *fiber.Ctx) error
+	UpdateInfluenceZone(c *fiber.Ctx) error
+	OverrideRestaurantCoord(c *fiber.Ctx) error
+	ClearRestaurantCoordOverride(c *fiber.Ctx) error
+	PeersServing(c *fiber.Ctx) error
+	ClosestPeers(c *fiber.Ctx) error
+	Handoff(c *fiber.Ctx) error
+	HandoffOffer(c *fiber.Ctx) error
+	HandoffRestaurants(c *fiber.Ctx) error
+	ReplicationSubscribe(c *fiber.Ctx) error
+	ReplicationHandshake(c *fiber.Ctx) error
+	ReplicationStream(c *fiber.Ctx) error
+	GetReplica(c *fiber.Ctx) error
+	GetReplicas(c *fiber.Ctx) error
+	PromoteReplicas(c *fiber.Ctx) error
+	GetRestaurantStatus(c *fiber.Ctx) error
+	ApproveRestaurant(c *fiber.Ctx) error
+	RejectRestaurant(c *fiber.Ctx) error
+	SuspendRestaurant(c *fiber.Ctx) error
+	CloseRestaurant(c *fiber.Ctx) error
 }
 
 type PeerController struct {
@@ -24,10 +50,23 @@ type PeerController struct {
 	validate    validations.ValidateI
 	restaurants services.RestaurantServiceI
 	geo         geo.GeoServiceI
+	handoff     services.HandoffServiceI
+	replication services.ReplicationServiceI
 }
 
-func NewPeerController(service services.PeerServiceI, validate validations.ValidateI, restaurants services.RestaurantServiceI, geo geo.GeoServiceI) *PeerController {
-	return &PeerController{service, validate, restaurants, geo}
+func NewPeerController(service services.PeerServiceI, validate validations.ValidateI, restaurants services.RestaurantServiceI, geo geo.GeoServiceI, handoff services.HandoffServiceI, replication services.ReplicationServiceI) *PeerController {
+	return &PeerController{service, validate, restaurants, geo, handoff, replication}
+}
+
+func geocodeErrorResponse(c *fiber.Ctx, err error) error {
+	var ambiguous *geocoder.AmbiguousError
+	if errors.As(err, &ambiguous) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error(), "candidates": ambiguous.Candidates})
+	}
+	if geocoder.IsNoMatch(err) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
 }
 
 func (p *PeerController) EventReceiver(c *fiber.Ctx) error {
@@ -72,9 +111,9 @@ func (p *PeerController) AddNewRestaurant(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(errors)
 	}
 
-	password, err := p.restaurants.CompleteRestaurantInitialData(&newRestaurant)
+	setupToken, err := p.restaurants.CompleteRestaurantInitialData(&newRestaurant)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+		return geocodeErrorResponse(c, err)
 	}
 
 	self, err := p.service.GetLocalPeer()
@@ -82,7 +121,7 @@ func (p *PeerController) AddNewRestaurant(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
 	}
 
-	isInInfluenceArea := p.geo.IsInInfluenceArea(self.Center, newRestaurant.Coord)
+	isInInfluenceArea := p.geo.IsInInfluenceArea(self, newRestaurant.Coord)
 
 	if !isInInfluenceArea {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "restaurant out of area"})
@@ -147,12 +186,36 @@ func (p *PeerController) AddNewRestaurant(c *fiber.Ctx) error {
 
 	newRestaurant.Id = id
 
+	// the setup token is only shown once, the peer owner sends it to the restaurant
+	// owner so it sets its own credentials
+	c.Set(fiber.HeaderCacheControl, "no-store")
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"newRestaurant": newRestaurant,
-		"tempPassword":  password,
+		"newRestaurant": services.NewPublicRestaurant(newRestaurant, time.Now()),
+		"setupToken":    setupToken,
 	})
 }
 
+func (p *PeerController) NewRestaurantSetupToken(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	setupToken, err := p.restaurants.NewSetupToken(id)
+	if err == mongo.ErrNoDocuments {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "restaurant not found"})
+	}
+	if err == services.ErrAccountAlreadySetUp {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"setupToken": setupToken})
+}
+
 func (p *PeerController) HaveRestaurant(c *fiber.Ctx) error {
 	restaurantQuery := make(map[string]interface{})
 	querySrt := string(c.Request().URI().QueryString())
@@ -168,3 +231,346 @@ func (p *PeerController) HaveRestaurant(c *fiber.Ctx) error {
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{"result": have})
 }
+
+func (p *PeerController) UpdateInfluenceRadius(c *fiber.Ctx) error {
+	body := new(types.InfluenceRadiusData)
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := p.validate.ValidateInfluenceRadiusData(*body)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	self, err := p.service.GetLocalPeer()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	err = p.service.UpdateInfluenceArea(self, body.InfluenceRadius)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "success"})
+}
+
+func (p *PeerController) UpdateInfluenceZone(c *fiber.Ctx) error {
+	body := new(types.InfluenceZoneData)
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := p.validate.ValidateInfluenceZoneData(*body)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	self, err := p.service.GetLocalPeer()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	err = p.service.UpdateInfluenceZone(self, body.InfluenceZone)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "success"})
+}
+
+func (p *PeerController) OverrideRestaurantCoord(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	body := new(types.CoordOverrideData)
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := p.validate.ValidateCoordOverrideData(*body)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	self, err := p.service.GetLocalPeer()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	restaurant, err := p.restaurants.OverrideCoord(id, body.Coord, self)
+	return coordUpdateResponse(c, p.service, restaurant, err)
+}
+
+func (p *PeerController) ClearRestaurantCoordOverride(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	self, err := p.service.GetLocalPeer()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	restaurant, err := p.restaurants.ClearCoordOverride(id, self)
+	return coordUpdateResponse(c, p.service, restaurant, err)
+}
+
+func (p *PeerController) PeersServing(c *fiber.Ctx) error {
+	query := new(types.ServingQuery)
+	if err := c.QueryParser(query); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := p.validate.ValidateServingQuery(*query)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	geoPoint := models.GeoCoords{Long: *query.Long, Lat: *query.Lat}
+	response, err := p.service.FindPeersServing(geoPoint, query.Area, query.Follow)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+func (p *PeerController) ClosestPeers(c *fiber.Ctx) error {
+	query := new(types.ClosestPeersQuery)
+	if err := c.QueryParser(query); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := p.validate.ValidateClosestPeersQuery(*query)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	peers, err := p.service.ClosestPeers(query.Geohash, query.Limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(peers)
+}
+
+func (p *PeerController) Handoff(c *fiber.Ctx) error {
+	body := new(types.HandoffRequest)
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := p.validate.ValidateHandoffRequest(*body)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	result, err := p.handoff.Execute(*body)
+	if err == services.ErrUnknownPeer {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "target is not an in area peer"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
+func (p *PeerController) HandoffOffer(c *fiber.Ctx) error {
+	body := new(types.HandoffOffer)
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := p.validate.ValidateHandoffOffer(*body)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	accepted, err := p.handoff.ReceiveOffer(*body)
+	if err == services.ErrUnknownPeer {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "unauthorized"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(types.HandoffAccept{Accepted: accepted})
+}
+
+func (p *PeerController) HandoffRestaurants(c *fiber.Ctx) error {
+	restaurants, err := p.handoff.GetOffered(c.Params("id"), c.Query("token"))
+	if err == services.ErrHandoffNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(restaurants)
+}
+
+func (p *PeerController) ReplicationSubscribe(c *fiber.Ctx) error {
+	body := new(types.ReplicationSubscribe)
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := p.validate.ValidateReplicationSubscribe(*body)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	err := p.replication.Subscribe(*body)
+	if err == services.ErrUnknownPeer || err == services.ErrReplicationUnauthorized {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "unauthorized"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+func (p *PeerController) ReplicationHandshake(c *fiber.Ctx) error {
+	secret, err := p.replication.GetHandshakeSecret(c.Params("id"), c.Query("token"))
+	if err == services.ErrHandoffNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(types.ReplicationHandshake{Secret: secret})
+}
+
+func (p *PeerController) ReplicationStream(c *fiber.Ctx) error {
+	err := p.replication.ReceiveBatch(c.Get(services.PEER_URL_HEADER), c.Get(services.REPLICATION_SIGNATURE_HEADER), c.Body())
+	if err == services.ErrReplicationUnauthorized {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "unauthorized"})
+	}
+	if err == services.ErrReplicationReplay {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+func (p *PeerController) GetReplica(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid restaurant id"})
+	}
+
+	restaurant, err := p.replication.GetReplica(id)
+	if err == mongo.ErrNoDocuments {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "replica not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(restaurant)
+}
+
+func (p *PeerController) GetReplicas(c *fiber.Ctx) error {
+	query := new(types.ReplicasQuery)
+	if err := c.QueryParser(query); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := p.validate.ValidateReplicasQuery(*query)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	restaurants, err := p.replication.GetReplicas(query.Origin)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(restaurants)
+}
+
+func (p *PeerController) PromoteReplicas(c *fiber.Ctx) error {
+	body := new(types.PromoteRequest)
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := p.validate.ValidatePromoteRequest(*body)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	promoted, err := p.replication.Promote(body.Origin, body.Force)
+	if err == services.ErrUnknownPeer {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "this peer is not a buddy of origin"})
+	}
+	if err == services.ErrOriginAlive {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	// also when nothing was promoted, so retrying repairs a recompute that failed
+	if err := p.service.RecomputeDeliveryArea(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"promoted": promoted})
+}
+
+func (p *PeerController) GetRestaurantStatus(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	restaurant, err := p.restaurants.GetById(id)
+	if err == mongo.ErrNoDocuments {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "restaurant not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(types.RestaurantStatus{Status: restaurant.CurrentStatus(), History: restaurant.StatusHistory})
+}
+
+func (p *PeerController) changeRestaurantStatus(c *fiber.Ctx, status string) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	body := new(types.StatusChangeData)
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+		}
+	}
+	errors := p.validate.ValidateStatusChange(*body)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	restaurant, err := p.restaurants.ChangeStatus(id, status, body.Reason, models.STATUS_BY_PEER_OWNER)
+	return statusChangeResponse(c, p.service, restaurant, err)
+}
+
+// approves a pending restaurant or reinstates a suspended one
+func (p *PeerController) ApproveRestaurant(c *fiber.Ctx) error {
+	return p.changeRestaurantStatus(c, models.RESTAURANT_ACTIVE)
+}
+
+// sends a pending restaurant back to draft
+func (p *PeerController) RejectRestaurant(c *fiber.Ctx) error {
+	return p.changeRestaurantStatus(c, models.RESTAURANT_DRAFT)
+}
+
+func (p *PeerController) SuspendRestaurant(c *fiber.Ctx) error {
+	return p.changeRestaurantStatus(c, models.RESTAURANT_SUSPENDED)
+}
+
+func (p *PeerController) CloseRestaurant(c *fiber.Ctx) error {
+	return p.changeRestaurantStatus(c, models.RESTAURANT_CLOSED)
+}
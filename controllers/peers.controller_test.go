@@ -29,7 +29,9 @@ func initTest() (*PeerController, *mocks.PeerServiceMock, *mocks.RestaurantServi
 	validate := validations.NewValidator(validator.New())
 	restaurantService := mocks.NewRestaurantServiceMock()
 	geo := mocks.NewGeo()
-	peerController := NewPeerController(service, validate, restaurantService, geo)
+	handoff := mocks.NewHandoffServiceMock()
+	replication := mocks.NewReplicationServiceMock()
+	peerController := NewPeerController(service, validate, restaurantService, geo, handoff, replication)
 	app := fiber.New()
 
 	return peerController, service, restaurantService, app
@@ -205,7 +207,7 @@ func TestAddNewRestaurant(t *testing.T) {
 				Country: "testCountry",
 			},
 			Status:   200,
-			Json:     "map[newRestaurant:map[Address:testAddress City:testCity Coord:map[Lat:1 Long:1] Country:testCountry Name:test id:000000000000000000000000 menu:map[Sections:<nil>] password:testHash rate:map[Stars:0 Votes:0] userName:testUsername] tempPassword:testPassword]",
+			Json:     "map[newRestaurant:map[address:testAddress city:testCity coord:map[Lat:1 Long:1] country:testCountry currency:USD deliveryCost:map[amount:0 currency:USD] id:000000000000000000000000 isConnected:false isDeliveryFixCost:false isOpen:false isPaused:false name:test openingHours:map[weekly:[]] rate:map[Stars:0 Votes:0] rating:3.5 status:draft] setupToken:testToken]",
 			WasAdded: true,
 		},
 	}
@@ -251,9 +253,10 @@ func TestAddNewRestaurant(t *testing.T) {
 				City:            "testCity",
 				Country:         "testCountry",
 				Coord:           models.GeoCoords{Long: 1, Lat: 1},
-				Password:        "testHash",
 				UserName:        "testUsername",
 				IsFinalPassword: false,
+				SetupTokenHash:  "testHash",
+				Status:          models.RESTAURANT_DRAFT,
 			}
 			savedRestaurant := restaurantService.Calls["AddNewRestaurant"][0][0]
 			if !reflect.DeepEqual(expectRestaurant, savedRestaurant) {
@@ -269,3 +272,68 @@ func TestAddNewRestaurant(t *testing.T) {
 		service.ClearCalls()
 	}
 }
+
+func TestRestaurantStatusEndpoints(t *testing.T) {
+	controller, service, restaurantService, app := initTest()
+	app.Post("/:id/approve", controller.ApproveRestaurant)
+	app.Post("/:id/suspend", controller.SuspendRestaurant)
+	id := primitive.NewObjectID()
+
+	resp, _ := app.Test(httptest.NewRequest("POST", "/"+id.Hex()+"/approve", nil), -1)
+	if resp.StatusCode != 200 {
+		t.Errorf("expecting 200 approving without a body but got %v", resp.StatusCode)
+	}
+
+	body := strings.NewReader(`{"reason":"health inspection"}`)
+	req := httptest.NewRequest("POST", "/"+id.Hex()+"/suspend", body)
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ = app.Test(req, -1)
+	var status map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&status)
+	if resp.StatusCode != 200 || status["status"] != models.RESTAURANT_SUSPENDED {
+		t.Errorf("unexpected suspend response %v %v", resp.StatusCode, status)
+	}
+
+	calls := restaurantService.Calls["ChangeStatus"]
+	if len(calls) != 2 || calls[0][1] != models.RESTAURANT_ACTIVE || calls[1][2] != "health inspection" || calls[1][3] != models.STATUS_BY_PEER_OWNER {
+		t.Errorf("unexpected status changes %v", calls)
+	}
+	if len(service.Calls["PropagateRestaurantStatus"]) != 2 {
+		t.Errorf("expecting every change to be propagated but got %v", service.Calls["PropagateRestaurantStatus"])
+	}
+
+	resp, _ = app.Test(httptest.NewRequest("POST", "/invalid/approve", nil), -1)
+	if resp.StatusCode != 400 {
+		t.Errorf("expecting 400 for an invalid id but got %v", resp.StatusCode)
+	}
+}
+
+func TestPeersServing(t *testing.T) {
+	controller, service, _, app := initTest()
+	app.Get("/serving", controller.PeersServing)
+
+	tests := []struct {
+		query  string
+		status int
+	}{
+		{"?long=0&lat=0", 200},
+		{"?long=-58.4&lat=-34.5&area=influence", 200},
+		{"?long=-58.4", 400},
+		{"?lat=-34.5", 400},
+		{"", 400},
+		{"?long=181&lat=0", 400},
+		{"?long=0&lat=-91", 400},
+	}
+
+	for _, test := range tests {
+		resp, _ := app.Test(httptest.NewRequest("GET", "/serving"+test.query, nil), -1)
+		if resp.StatusCode != test.status {
+			t.Errorf("expecting %d for %q but got %d", test.status, test.query, resp.StatusCode)
+		}
+	}
+
+	calls := service.Calls["FindPeersServing"]
+	if len(calls) != 2 || calls[0][0] != (models.GeoCoords{Long: 0, Lat: 0}) {
+		t.Errorf("unexpected FindPeersServing calls %v", calls)
+	}
+}
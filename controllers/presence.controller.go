@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nicodeheza/peersEat/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type PresenceController struct {
+	service services.PresenceServiceI
+}
+
+type PresenceControllerI interface {
+	Stream(c *fiber.Ctx) error
+	Heartbeat(c *fiber.Ctx) error
+}
+
+func NewPresenceController(service services.PresenceServiceI) *PresenceController {
+	return &PresenceController{service}
+}
+
+// the restaurant app keeps this event stream open while it's listening for
+// orders, it's connected until a ping can't be delivered
+func (p *PresenceController) Stream(c *fiber.Ctx) error {
+	id, err := sessionRestaurantId(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "unauthorized"})
+	}
+	if err := p.service.Connect(id); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-store")
+	c.Set("Connection", "keep-alive")
+	interval := p.service.HeartbeatInterval()
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer p.disconnect(id)
+		for {
+			fmt.Fprintf(w, "event: ping\ndata: %v\n\n", time.Now().Unix())
+			if err := w.Flush(); err != nil {
+				return
+			}
+			time.Sleep(interval)
+		}
+	})
+	return nil
+}
+
+func (p *PresenceController) disconnect(id primitive.ObjectID) {
+	if err := p.service.Disconnect(id); err != nil {
+		log.Println(err.Error())
+	}
+}
+
+// for apps that can't hold a stream open, they have to post before the timeout
+func (p *PresenceController) Heartbeat(c *fiber.Ctx) error {
+	id, err := sessionRestaurantId(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "unauthorized"})
+	}
+	if err := p.service.Heartbeat(id); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"heartbeatInterval": p.service.HeartbeatInterval().Seconds()})
+}
@@ -0,0 +1,241 @@
+package controllers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/services"
+	"github.com/nicodeheza/peersEat/services/validations"
+	"github.com/nicodeheza/peersEat/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type PromotionController struct {
+	service    services.PromotionServiceI
+	validators validations.ValidateI
+}
+
+type PromotionControllerI interface {
+	CreateRestaurantPromotion(c *fiber.Ctx) error
+	ListRestaurantPromotions(c *fiber.Ctx) error
+	UpdateRestaurantPromotion(c *fiber.Ctx) error
+	DeleteRestaurantPromotion(c *fiber.Ctx) error
+	CreatePeerPromotion(c *fiber.Ctx) error
+	ListPeerPromotions(c *fiber.Ctx) error
+	UpdatePeerPromotion(c *fiber.Ctx) error
+	DeletePeerPromotion(c *fiber.Ctx) error
+	EvaluateCart(c *fiber.Ctx) error
+	RedeemCart(c *fiber.Ctx) error
+	IssueCustomerToken(c *fiber.Ctx) error
+}
+
+func NewPromotionController(service services.PromotionServiceI, validators validations.ValidateI) *PromotionController {
+	return &PromotionController{service, validators}
+}
+
+func promotionErrorResponse(c *fiber.Ctx, err error) error {
+	if err == mongo.ErrNoDocuments {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "restaurant not found"})
+	}
+	if err == services.ErrPromotionNotFound || err == services.ErrQuoteNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": err.Error()})
+	}
+	if err == services.ErrMenuItemNotFound || err == services.ErrNoMenuAvailable {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": err.Error()})
+	}
+	if errors.Is(err, services.ErrInvalidPromotion) || errors.Is(err, models.ErrCurrencyMismatch) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
+	if err == services.ErrPromotionCodeTaken || err == services.ErrPromotionUnavailable || err == services.ErrQuoteMismatch {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": err.Error()})
+	}
+	if err == services.ErrRestaurantNotActive || err == services.ErrRestaurantPaused || err == services.ErrRestaurantClosed {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": err.Error()})
+	}
+	if err == services.ErrInvalidCustomerToken {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": err.Error()})
+	}
+	if err == services.ErrCustomerTokensDisabled {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"message": err.Error()})
+	}
+	if err == services.ErrQuoteExpired {
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{"message": err.Error()})
+	}
+	var rulesError *services.OptionRulesError
+	if errors.As(err, &rulesError) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid options", "problems": rulesError.Problems})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+}
+
+// the restaurant routes manage the promotions of the session restaurant and the
+// peer routes the ones of every restaurant of the peer, with a nil scope
+func (p *PromotionController) create(c *fiber.Ctx, scope *primitive.ObjectID) error {
+	body := new(types.PromotionData)
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := p.validators.ValidatePromotion(*body)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	promotion, err := p.service.Create(scope, *body)
+	if err != nil {
+		return promotionErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(promotion)
+}
+
+func (p *PromotionController) list(c *fiber.Ctx, scope *primitive.ObjectID) error {
+	promotions, err := p.service.List(scope)
+	if err != nil {
+		return promotionErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(promotions)
+}
+
+func (p *PromotionController) update(c *fiber.Ctx, scope *primitive.ObjectID) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("promotionId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid promotion id"})
+	}
+
+	body := new(types.PromotionData)
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := p.validators.ValidatePromotion(*body)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	promotion, err := p.service.Update(scope, id, *body)
+	if err != nil {
+		return promotionErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(promotion)
+}
+
+func (p *PromotionController) delete(c *fiber.Ctx, scope *primitive.ObjectID) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("promotionId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid promotion id"})
+	}
+
+	if err := p.service.Delete(scope, id); err != nil {
+		return promotionErrorResponse(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (p *PromotionController) CreateRestaurantPromotion(c *fiber.Ctx) error {
+	id, err := sessionRestaurantId(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "unauthorized"})
+	}
+	return p.create(c, &id)
+}
+
+func (p *PromotionController) ListRestaurantPromotions(c *fiber.Ctx) error {
+	id, err := sessionRestaurantId(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "unauthorized"})
+	}
+	return p.list(c, &id)
+}
+
+func (p *PromotionController) UpdateRestaurantPromotion(c *fiber.Ctx) error {
+	id, err := sessionRestaurantId(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "unauthorized"})
+	}
+	return p.update(c, &id)
+}
+
+func (p *PromotionController) DeleteRestaurantPromotion(c *fiber.Ctx) error {
+	id, err := sessionRestaurantId(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "unauthorized"})
+	}
+	return p.delete(c, &id)
+}
+
+func (p *PromotionController) CreatePeerPromotion(c *fiber.Ctx) error {
+	return p.create(c, nil)
+}
+
+func (p *PromotionController) ListPeerPromotions(c *fiber.Ctx) error {
+	return p.list(c, nil)
+}
+
+func (p *PromotionController) UpdatePeerPromotion(c *fiber.Ctx) error {
+	return p.update(c, nil)
+}
+
+func (p *PromotionController) DeletePeerPromotion(c *fiber.Ctx) error {
+	return p.delete(c, nil)
+}
+
+func (p *PromotionController) EvaluateCart(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": errInvalidRestaurantId.Error()})
+	}
+
+	body := new(types.Cart)
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := p.validators.ValidateCart(*body)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	evaluation, err := p.service.Evaluate(id, *body)
+	if err != nil {
+		return promotionErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(evaluation)
+}
+
+func (p *PromotionController) RedeemCart(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": errInvalidRestaurantId.Error()})
+	}
+
+	body := new(types.Cart)
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := p.validators.ValidateCart(*body)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	evaluation, err := p.service.Redeem(id, *body)
+	if err != nil {
+		return promotionErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(evaluation)
+}
+
+func (p *PromotionController) IssueCustomerToken(c *fiber.Ctx) error {
+	body := new(types.CustomerTokenRequest)
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := p.validators.ValidateCustomerTokenRequest(*body)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	token, err := p.service.IssueCustomerToken(body.CustomerId)
+	if err != nil {
+		return promotionErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(types.CustomerToken{CustomerToken: token})
+}
@@ -1,40 +1,150 @@
 package controllers
 
 import (
+	"errors"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/nicodeheza/peersEat/models"
 	"github.com/nicodeheza/peersEat/services"
 	"github.com/nicodeheza/peersEat/services/validations"
 	"github.com/nicodeheza/peersEat/types"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type RestaurantController struct {
-	Service     services.RestaurantServiceI
-	peerService services.PeerServiceI
-	validators  validations.ValidateI
+	Service      services.RestaurantServiceI
+	peerService  services.PeerServiceI
+	validators   validations.ValidateI
+	menu         services.MenuServiceI
+	menuVersions services.MenuVersionServiceI
 }
 
 type RestaurantControllerI interface {
 	UpdatePassword(c *fiber.Ctx) error
+	SetupAccount(c *fiber.Ctx) error
 	RetuneOk(c *fiber.Ctx) error
 	UpdateRestaurantData(c *fiber.Ctx) error
+	UpdateAddress(c *fiber.Ctx) error
+	OverrideCoord(c *fiber.Ctx) error
+	ClearCoordOverride(c *fiber.Ctx) error
+	GetMenu(c *fiber.Ctx) error
+	AddMenuSection(c *fiber.Ctx) error
+	UpdateMenuSection(c *fiber.Ctx) error
+	AddDish(c *fiber.Ctx) error
+	UpdateDish(c *fiber.Ctx) error
+	AddDishOption(c *fiber.Ctx) error
+	UpdateDishOption(c *fiber.Ctx) error
+	DeleteMenuItem(c *fiber.Ctx) error
+	ReorderMenu(c *fiber.Ctx) error
+	SetDishOptionGroups(c *fiber.Ctx) error
+	PriceDishSelection(c *fiber.Ctx) error
+	PublishMenu(c *fiber.Ctx) error
+	GetMenuVersions(c *fiber.Ctx) error
+	CheckoutMenuVersion(c *fiber.Ctx) error
+	ArchiveMenu(c *fiber.Ctx) error
+	GetCurrentMenu(c *fiber.Ctx) error
+	GetMenuVersion(c *fiber.Ctx) error
+	PriceMenuVersionSelection(c *fiber.Ctx) error
+	SetItemAvailability(c *fiber.Ctx) error
+	UpdateOpeningHours(c *fiber.Ctx) error
+	GetOpeningStatus(c *fiber.Ctx) error
+	GetRestaurant(c *fiber.Ctx) error
+	ListRestaurants(c *fiber.Ctx) error
+	SubmitForReview(c *fiber.Ctx) error
+}
+
+func NewRestaurantController(service services.RestaurantServiceI, peerService services.PeerServiceI, validators validations.ValidateI, menu services.MenuServiceI, menuVersions services.MenuVersionServiceI) *RestaurantController {
+	return &RestaurantController{service, peerService, validators, menu, menuVersions}
+}
+
+func sessionRestaurantId(c *fiber.Ctx) (primitive.ObjectID, error) {
+	id, _ := c.Locals("restaurantId").(string)
+	return primitive.ObjectIDFromHex(id)
+}
+
+// status changes are announced to the in area peers before answering
+func statusChangeResponse(c *fiber.Ctx, peerService services.PeerServiceI, restaurant models.Restaurant, err error) error {
+	if err == mongo.ErrNoDocuments {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "restaurant not found"})
+	}
+	if errors.Is(err, services.ErrInvalidStatusChange) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": err.Error()})
+	}
+	if err == services.ErrStatusReasonRequired {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	if err := peerService.PropagateRestaurantStatus(restaurant); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(types.RestaurantStatus{Status: restaurant.CurrentStatus(), History: restaurant.StatusHistory})
 }
 
-func NewRestaurantController(service services.RestaurantServiceI, peerService services.PeerServiceI, validators validations.ValidateI) *RestaurantController {
-	return &RestaurantController{service, peerService, validators}
+func coordUpdateResponse(c *fiber.Ctx, peerService services.PeerServiceI, restaurant models.Restaurant, err error) error {
+	if err == services.ErrOutOfArea {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
+	if err != nil {
+		return geocodeErrorResponse(c, err)
+	}
+
+	err = peerService.RecomputeDeliveryArea()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"coord":             restaurant.Coord,
+		"isCoordOverridden": restaurant.IsCoordOverridden,
+	})
 }
 
 func (r *RestaurantController) UpdatePassword(c *fiber.Ctx) error {
+	id, err := sessionRestaurantId(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "unauthorized"})
+	}
+
 	body := new(types.UpdateRestaurantPassword)
 	if err := c.BodyParser(&body); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
 	}
 
-	id, err := primitive.ObjectIDFromHex(body.Id)
+	err = r.Service.UpdateRestaurantUsernameAndPassword(id, body.NewPassword, body.NewUsername)
+	if err == services.ErrUserNameTaken {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": err.Error()})
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
 	}
-	err = r.Service.UpdateRestaurantUsernameAndPassword(id, body.NewPassword, body.NewUsername)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "success"})
+}
+
+func (r *RestaurantController) SetupAccount(c *fiber.Ctx) error {
+	body := new(types.RestaurantSetupData)
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	if invalid := r.validators.ValidateRestaurantSetup(*body); invalid != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(invalid)
+	}
+
+	err := r.Service.SetupAccount(*body)
+	if err == services.ErrSetupTokenNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": err.Error()})
+	}
+	if err == services.ErrSetupTokenExpired {
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{"message": err.Error()})
+	}
+	if err == services.ErrUserNameTaken {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": err.Error()})
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
 	}
@@ -48,31 +158,686 @@ func (r *RestaurantController) RetuneOk(c *fiber.Ctx) error {
 
 // update restaurant data
 func (r *RestaurantController) UpdateRestaurantData(c *fiber.Ctx) error {
+	id, err := sessionRestaurantId(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "unauthorized"})
+	}
+
 	restaurantData := new(types.RestaurantData)
 	if err := c.BodyParser(&restaurantData); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
 	}
+	if invalid := r.validators.ValidateRestaurantData(*restaurantData); invalid != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(invalid)
+	}
 
-	deliveryUpdated, coords, radius, err := r.Service.UpdateData(*restaurantData)
+	deliveryUpdated, _, _, err := r.Service.UpdateData(id, *restaurantData)
+	if errors.Is(err, models.ErrCurrencyMismatch) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
 	}
 
 	if deliveryUpdated {
-		selfPeer, err := r.peerService.GetLocalPeer()
+		err = r.peerService.RecomputeDeliveryArea()
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
 		}
+	}
+
+	return c.SendStatus(200)
+}
+
+func (r *RestaurantController) UpdateAddress(c *fiber.Ctx) error {
+	id, err := sessionRestaurantId(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "unauthorized"})
+	}
+
+	body := new(types.RestaurantAddressData)
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := r.validators.ValidateRestaurantAddressData(*body)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
 
-		newDeliveryArea := r.peerService.GetNewDeliveryArea(selfPeer.Center, coords, radius)
+	selfPeer, err := r.peerService.GetLocalPeer()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	restaurant, err := r.Service.UpdateAddress(id, *body, selfPeer)
+	return coordUpdateResponse(c, r.peerService, restaurant, err)
+}
+
+func (r *RestaurantController) OverrideCoord(c *fiber.Ctx) error {
+	id, err := sessionRestaurantId(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "unauthorized"})
+	}
 
-		err = r.peerService.UpdateDeliveryArea(selfPeer, newDeliveryArea)
+	body := new(types.CoordOverrideData)
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := r.validators.ValidateCoordOverrideData(*body)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	selfPeer, err := r.peerService.GetLocalPeer()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	restaurant, err := r.Service.OverrideCoord(id, body.Coord, selfPeer)
+	return coordUpdateResponse(c, r.peerService, restaurant, err)
+}
+
+func (r *RestaurantController) ClearCoordOverride(c *fiber.Ctx) error {
+	id, err := sessionRestaurantId(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "unauthorized"})
+	}
+
+	selfPeer, err := r.peerService.GetLocalPeer()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	restaurant, err := r.Service.ClearCoordOverride(id, selfPeer)
+	return coordUpdateResponse(c, r.peerService, restaurant, err)
+}
+
+// reads the menu entity ids from the route params, from the section down
+func menuPath(c *fiber.Ctx) (models.MenuPath, error) {
+	path := models.MenuPath{}
+	params := []struct {
+		name string
+		id   *primitive.ObjectID
+	}{{"sectionId", &path.SectionId}, {"dishId", &path.DishId}, {"optionId", &path.OptionId}}
+
+	for _, param := range params {
+		value := c.Params(param.name)
+		if value == "" {
+			break
+		}
+		id, err := primitive.ObjectIDFromHex(value)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+			return path, err
 		}
+		*param.id = id
 	}
+	return path, nil
+}
 
-	return c.SendStatus(200)
+var errUnauthorized = errors.New("unauthorized")
+var errInvalidMenuItemId = errors.New("invalid menu item id")
+var errInvalidRestaurantId = errors.New("invalid restaurant id")
+
+func menuErrorResponse(c *fiber.Ctx, err error) error {
+	if err == errUnauthorized {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": err.Error()})
+	}
+	if err == errInvalidMenuItemId || err == errInvalidRestaurantId {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
+	if err == services.ErrMenuItemNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": err.Error()})
+	}
+	if err == services.ErrInvalidMenuOrder {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
+	if err == services.ErrMenuVersionNotFound || err == services.ErrNoMenuAvailable {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": err.Error()})
+	}
+	if err == services.ErrEmptyMenu || errors.Is(err, models.ErrCurrencyMismatch) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
+	var rulesError *services.OptionRulesError
+	if errors.As(err, &rulesError) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "invalid options", "problems": rulesError.Problems})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+}
+
+func (r *RestaurantController) menuRequest(c *fiber.Ctx) (primitive.ObjectID, models.MenuPath, error) {
+	id, err := sessionRestaurantId(c)
+	if err != nil {
+		return id, models.MenuPath{}, errUnauthorized
+	}
+	path, err := menuPath(c)
+	if err != nil {
+		return id, path, errInvalidMenuItemId
+	}
+	return id, path, nil
+}
+
+func (r *RestaurantController) GetMenu(c *fiber.Ctx) error {
+	id, err := sessionRestaurantId(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "unauthorized"})
+	}
+
+	menu, err := r.menu.GetMenu(id)
+	if err != nil {
+		return menuErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(menu)
+}
+
+func (r *RestaurantController) AddMenuSection(c *fiber.Ctx) error {
+	id, _, err := r.menuRequest(c)
+	if err != nil {
+		return menuErrorResponse(c, err)
+	}
+
+	body := new(types.MenuSectionData)
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := r.validators.ValidateMenuSection(*body)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	section, err := r.menu.AddSection(id, *body)
+	if err != nil {
+		return menuErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(section)
+}
+
+func (r *RestaurantController) UpdateMenuSection(c *fiber.Ctx) error {
+	id, path, err := r.menuRequest(c)
+	if err != nil {
+		return menuErrorResponse(c, err)
+	}
+
+	body := new(types.MenuSectionUpdate)
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := r.validators.ValidateMenuSectionUpdate(*body)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	if err := r.menu.UpdateSection(id, path, *body); err != nil {
+		return menuErrorResponse(c, err)
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
+
+func (r *RestaurantController) AddDish(c *fiber.Ctx) error {
+	id, path, err := r.menuRequest(c)
+	if err != nil {
+		return menuErrorResponse(c, err)
+	}
+
+	body := new(types.DishData)
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := r.validators.ValidateDish(*body)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	dish, err := r.menu.AddDish(id, path, *body)
+	if err != nil {
+		return menuErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(dish)
+}
+
+func (r *RestaurantController) UpdateDish(c *fiber.Ctx) error {
+	id, path, err := r.menuRequest(c)
+	if err != nil {
+		return menuErrorResponse(c, err)
+	}
+
+	body := new(types.DishUpdate)
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := r.validators.ValidateDishUpdate(*body)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	if err := r.menu.UpdateDish(id, path, *body); err != nil {
+		return menuErrorResponse(c, err)
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
+
+func (r *RestaurantController) AddDishOption(c *fiber.Ctx) error {
+	id, path, err := r.menuRequest(c)
+	if err != nil {
+		return menuErrorResponse(c, err)
+	}
+
+	body := new(types.DishOptionData)
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := r.validators.ValidateDishOption(*body)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	option, err := r.menu.AddOption(id, path, *body)
+	if err != nil {
+		return menuErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(option)
+}
+
+func (r *RestaurantController) UpdateDishOption(c *fiber.Ctx) error {
+	id, path, err := r.menuRequest(c)
+	if err != nil {
+		return menuErrorResponse(c, err)
+	}
+
+	body := new(types.DishOptionUpdate)
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := r.validators.ValidateDishOptionUpdate(*body)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	if err := r.menu.UpdateOption(id, path, *body); err != nil {
+		return menuErrorResponse(c, err)
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
+
+func (r *RestaurantController) DeleteMenuItem(c *fiber.Ctx) error {
+	id, path, err := r.menuRequest(c)
+	if err != nil {
+		return menuErrorResponse(c, err)
+	}
+
+	if err := r.menu.DeleteItem(id, path); err != nil {
+		return menuErrorResponse(c, err)
+	}
+	return c.SendStatus(fiber.StatusOK)
 }
 
-// update menu
+// reorders the children of the entity in the route
+func (r *RestaurantController) ReorderMenu(c *fiber.Ctx) error {
+	id, path, err := r.menuRequest(c)
+	if err != nil {
+		return menuErrorResponse(c, err)
+	}
+
+	body := new(types.MenuOrder)
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := r.validators.ValidateMenuOrder(*body)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	if err := r.menu.Reorder(id, path, body.Ids); err != nil {
+		return menuErrorResponse(c, err)
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
+
+func (r *RestaurantController) SetDishOptionGroups(c *fiber.Ctx) error {
+	id, path, err := r.menuRequest(c)
+	if err != nil {
+		return menuErrorResponse(c, err)
+	}
+
+	body := new(types.DishOptionGroups)
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := r.validators.ValidateDishOptionGroups(*body)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	groups, err := r.menu.SetOptionGroups(id, path, *body)
+	if err != nil {
+		return menuErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(groups)
+}
+
+// public, customers check their choices and get the final price of a dish
+func (r *RestaurantController) PriceDishSelection(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return menuErrorResponse(c, errInvalidRestaurantId)
+	}
+
+	body := new(types.DishSelection)
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := r.validators.ValidateDishSelection(*body)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	priced, err := r.menu.PriceSelection(id, *body)
+	if err != nil {
+		return menuErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(priced)
+}
+
+func (r *RestaurantController) PublishMenu(c *fiber.Ctx) error {
+	id, err := sessionRestaurantId(c)
+	if err != nil {
+		return menuErrorResponse(c, errUnauthorized)
+	}
+
+	body := new(types.PublishMenu)
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := r.validators.ValidatePublishMenu(*body)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	version, err := r.menuVersions.Publish(id, *body)
+	if err != nil {
+		return menuErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(version)
+}
+
+func (r *RestaurantController) GetMenuVersions(c *fiber.Ctx) error {
+	id, err := sessionRestaurantId(c)
+	if err != nil {
+		return menuErrorResponse(c, errUnauthorized)
+	}
+
+	query := new(types.MenuVersionsQuery)
+	if err := c.QueryParser(query); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	versions, err := r.menuVersions.GetVersions(id, query.Name)
+	if err != nil {
+		return menuErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(versions)
+}
+
+func (r *RestaurantController) CheckoutMenuVersion(c *fiber.Ctx) error {
+	id, err := sessionRestaurantId(c)
+	if err != nil {
+		return menuErrorResponse(c, errUnauthorized)
+	}
+	versionId, err := primitive.ObjectIDFromHex(c.Params("versionId"))
+	if err != nil {
+		return menuErrorResponse(c, services.ErrMenuVersionNotFound)
+	}
+
+	menu, err := r.menuVersions.Checkout(id, versionId)
+	if err != nil {
+		return menuErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(menu)
+}
+
+func (r *RestaurantController) ArchiveMenu(c *fiber.Ctx) error {
+	id, err := sessionRestaurantId(c)
+	if err != nil {
+		return menuErrorResponse(c, errUnauthorized)
+	}
+
+	if err := r.menuVersions.Archive(id, c.Params("name")); err != nil {
+		return menuErrorResponse(c, err)
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
+
+func (r *RestaurantController) GetCurrentMenu(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return menuErrorResponse(c, errInvalidRestaurantId)
+	}
+
+	query := new(types.CurrentMenuQuery)
+	if err := c.QueryParser(query); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := r.validators.ValidateCurrentMenuQuery(*query)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	at := time.Now()
+	if query.At != "" {
+		at, _ = time.Parse(time.RFC3339, query.At)
+	}
+
+	version, err := r.menuVersions.CurrentMenu(id, at)
+	if err != nil {
+		return menuErrorResponse(c, err)
+	}
+	version.Menu = version.Menu.Filter(query.Filter())
+	return c.Status(fiber.StatusOK).JSON(version)
+}
+
+func (r *RestaurantController) GetMenuVersion(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return menuErrorResponse(c, errInvalidRestaurantId)
+	}
+	versionId, err := primitive.ObjectIDFromHex(c.Params("versionId"))
+	if err != nil {
+		return menuErrorResponse(c, services.ErrMenuVersionNotFound)
+	}
+
+	query := new(types.MenuFilterQuery)
+	if err := c.QueryParser(query); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := r.validators.ValidateMenuFilterQuery(*query)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	version, err := r.menuVersions.GetVersion(id, versionId)
+	if err != nil {
+		return menuErrorResponse(c, err)
+	}
+	version.Menu = version.Menu.Filter(query.Filter())
+	return c.Status(fiber.StatusOK).JSON(version)
+}
+
+func (r *RestaurantController) PriceMenuVersionSelection(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return menuErrorResponse(c, errInvalidRestaurantId)
+	}
+	versionId, err := primitive.ObjectIDFromHex(c.Params("versionId"))
+	if err != nil {
+		return menuErrorResponse(c, services.ErrMenuVersionNotFound)
+	}
+
+	body := new(types.DishSelection)
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := r.validators.ValidateDishSelection(*body)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	priced, err := r.menuVersions.PriceSelection(id, versionId, *body)
+	if err != nil {
+		return menuErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(priced)
+}
+
+func (r *RestaurantController) SetItemAvailability(c *fiber.Ctx) error {
+	id, err := sessionRestaurantId(c)
+	if err != nil {
+		return menuErrorResponse(c, errUnauthorized)
+	}
+	itemId, err := primitive.ObjectIDFromHex(c.Params("itemId"))
+	if err != nil {
+		return menuErrorResponse(c, errInvalidMenuItemId)
+	}
+
+	body := new(types.ItemAvailability)
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := r.validators.ValidateItemAvailability(*body)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	unavailable, err := r.menu.SetAvailability(id, itemId, *body)
+	if err != nil {
+		return menuErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(unavailable)
+}
+
+func (r *RestaurantController) UpdateOpeningHours(c *fiber.Ctx) error {
+	id, err := sessionRestaurantId(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "unauthorized"})
+	}
+
+	body := new(types.OpeningHoursData)
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := r.validators.ValidateOpeningHours(*body)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	hours, err := r.Service.UpdateOpeningHours(id, *body)
+	if err != nil {
+		if isInvalidOpeningHours(err) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(hours)
+}
+
+func isInvalidOpeningHours(err error) bool {
+	return errors.Is(err, services.ErrInvalidOpeningHours)
+}
+
+func (r *RestaurantController) GetOpeningStatus(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": errInvalidRestaurantId.Error()})
+	}
+
+	query := new(types.OpeningStatusQuery)
+	if err := c.QueryParser(query); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := r.validators.ValidateOpeningStatusQuery(*query)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	at := time.Now()
+	if query.At != "" {
+		at, _ = time.Parse(time.RFC3339, query.At)
+	}
+
+	restaurant, err := r.Service.GetById(id)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "restaurant not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	schedule := restaurant.Schedule()
+	status := types.OpeningStatus{
+		Timezone:     schedule.Location().String(),
+		IsOpen:       schedule.IsOpenAt(at),
+		OpeningHours: schedule,
+	}
+	if next, ok := schedule.NextOpening(at); ok {
+		status.NextOpening = &next
+	}
+	return c.Status(fiber.StatusOK).JSON(status)
+}
+
+// public restaurant with its current menu, the menu is omitted when none is available
+func (r *RestaurantController) GetRestaurant(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": errInvalidRestaurantId.Error()})
+	}
+
+	restaurant, err := r.Service.GetById(id)
+	if err == mongo.ErrNoDocuments || (err == nil && !restaurant.IsActive()) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "restaurant not found"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	now := time.Now()
+	public := services.NewPublicRestaurant(restaurant, now)
+	menu, err := r.menuVersions.CurrentMenu(id, now)
+	if err != nil && err != services.ErrNoMenuAvailable {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	if err == nil {
+		public.Menu = &menu
+	}
+	return c.Status(fiber.StatusOK).JSON(public)
+}
+
+func (r *RestaurantController) ListRestaurants(c *fiber.Ctx) error {
+	query := new(types.RestaurantListQuery)
+	if err := c.QueryParser(query); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := r.validators.ValidateRestaurantListQuery(*query)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	at := time.Now()
+	if query.At != "" {
+		at, _ = time.Parse(time.RFC3339, query.At)
+	}
+
+	restaurants, err := r.Service.List(*query, at)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(restaurants)
+}
+
+// a draft is sent to the peer owner for review once the restaurant completed it
+func (r *RestaurantController) SubmitForReview(c *fiber.Ctx) error {
+	id, err := sessionRestaurantId(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "unauthorized"})
+	}
+
+	restaurant, err := r.Service.ChangeStatus(id, models.RESTAURANT_PENDING, "", models.STATUS_BY_RESTAURANT)
+	return statusChangeResponse(c, r.peerService, restaurant, err)
+}
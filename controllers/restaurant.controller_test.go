@@ -9,38 +9,55 @@ import (
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
 	"github.com/nicodeheza/peersEat/mocks"
+	"github.com/nicodeheza/peersEat/models"
 	"github.com/nicodeheza/peersEat/services/validations"
 	"github.com/nicodeheza/peersEat/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 func initTestRestaurant() (*RestaurantController, *mocks.RestaurantServiceMock, *fiber.App) {
 	service := mocks.NewRestaurantServiceMock()
 	peerServices := mocks.NewPeerServiceMock()
 	validations := validations.NewValidator(validator.New())
-	controller := NewRestaurantController(service, peerServices, validations)
+	menu := mocks.NewMenuServiceMock()
+	controller := NewRestaurantController(service, peerServices, validations, menu, mocks.NewMenuVersionServiceMock())
 	app := fiber.New()
 
 	return controller, service, app
 }
 
-func TestUpdatePassword(t *testing.T) {
-	controller, _, app := initTestRestaurant()
+// sets the session restaurant of the requests
+func withSessionRestaurant(app *fiber.App, restaurantId string) {
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("restaurantId", restaurantId)
+		return c.Next()
+	})
+}
 
+func TestUpdatePassword(t *testing.T) {
+	controller, service, app := initTestRestaurant()
+	unauthenticated := fiber.New()
+	unauthenticated.Patch("/", controller.UpdatePassword)
+	withSessionRestaurant(app, "5f9a8a5c7c9d440000a9a8c7")
 	app.Patch("/", controller.UpdatePassword)
 
-	sBody := types.UpdateRestaurantPassword{
-		NewPassword: "testPassword",
-		Id:          "5f9a8a5c7c9d440000a9a8c7",
-	}
-	body, err := json.Marshal(sBody)
+	// an id in the body is ignored, the account is the one of the session
+	body := []byte(`{"newPassword":"testPassword","id":"5f9a8a5c7c9d440000a9a8c9"}`)
+
+	req := httptest.NewRequest("PATCH", "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := unauthenticated.Test(req, 1)
 	if err != nil {
-		t.Error(err.Error())
+		t.Fatal(err.Error())
+	}
+	if resp.StatusCode != 401 {
+		t.Errorf("Expected status code 401 without a session, got %d", resp.StatusCode)
 	}
 
-	req := httptest.NewRequest("PATCH", "/", bytes.NewReader(body))
+	req = httptest.NewRequest("PATCH", "/", bytes.NewReader(body))
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := app.Test(req, 1)
+	resp, err = app.Test(req, 1)
 	if err != nil {
 		t.Errorf("%s", err.Error())
 	}
@@ -55,4 +72,194 @@ func TestUpdatePassword(t *testing.T) {
 	if b["message"] != "success" {
 		t.Errorf("Expected message success, got %s", b["message"])
 	}
+
+	calls := service.Calls["UpdateRestaurantUsernameAndPassword"]
+	if len(calls) != 1 || calls[0][0].(primitive.ObjectID).Hex() != "5f9a8a5c7c9d440000a9a8c7" {
+		t.Errorf("expecting the session account to be updated but got %v", calls)
+	}
+}
+
+func TestUpdateRestaurantData(t *testing.T) {
+	controller, service, app := initTestRestaurant()
+	withSessionRestaurant(app, "5f9a8a5c7c9d440000a9a8c7")
+	app.Put("/", controller.UpdateRestaurantData)
+
+	data := types.RestaurantData{
+		Name:           "restaurant",
+		OpenTime:       "9:00AM",
+		CloseTime:      "5:00PM",
+		Phone:          "+14155552671",
+		DeliveryRadius: 2,
+	}
+	update := func(data types.RestaurantData) int {
+		body, _ := json.Marshal(data)
+		req := httptest.NewRequest("PUT", "/", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, 1)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		return resp.StatusCode
+	}
+
+	if status := update(data); status != 200 {
+		t.Errorf("expecting status 200 but got %v", status)
+	}
+	calls := service.Calls["UpdateData"]
+	if len(calls) != 1 || calls[0][0].(primitive.ObjectID).Hex() != "5f9a8a5c7c9d440000a9a8c7" {
+		t.Errorf("expecting the session restaurant to be updated but got %v", calls)
+	}
+
+	// the ring isn't closed
+	data.DeliveryZone = models.NewGeoZone(models.GeoPolygon{models.GeoRing{{0, 0}, {0, 1}, {1, 1}, {1, 0}}})
+	if status := update(data); status != 400 {
+		t.Errorf("expecting a malformed zone to be rejected but got %v", status)
+	}
+}
+
+func TestAddDish(t *testing.T) {
+	service := mocks.NewRestaurantServiceMock()
+	menu := mocks.NewMenuServiceMock()
+	controller := NewRestaurantController(service, mocks.NewPeerServiceMock(), validations.NewValidator(validator.New()), menu, mocks.NewMenuVersionServiceMock())
+	app := fiber.New()
+
+	restaurantId := "5f9a8a5c7c9d440000a9a8c7"
+	withSessionRestaurant(app, restaurantId)
+	app.Post("/menu/sections/:sectionId/dishes", controller.AddDish)
+
+	type Test struct {
+		Title   string
+		Section string
+		Body    types.DishData
+		Status  int
+	}
+	sectionId := "5f9a8a5c7c9d440000a9a8c8"
+	tests := []Test{
+		{"negative price", sectionId, types.DishData{Name: "dish", Price: models.NewMoney(-100, "USD")}, 400},
+		{"empty name", sectionId, types.DishData{Price: models.NewMoney(100, "USD")}, 400},
+		{"invalid image url", sectionId, types.DishData{Name: "dish", ImageUrl: "not an url"}, 400},
+		{"invalid option", sectionId, types.DishData{Name: "dish", Options: []types.DishOptionData{{Name: "", Price: models.NewMoney(100, "USD")}}}, 400},
+		{"invalid section id", "invalid", types.DishData{Name: "dish"}, 400},
+		{"valid dish", sectionId, types.DishData{Name: "dish", Price: models.NewMoney(0, "USD"), ImageUrl: "https://test.com/dish.png"}, 201},
+	}
+
+	for _, test := range tests {
+		body, _ := json.Marshal(test.Body)
+		req := httptest.NewRequest("POST", "/menu/sections/"+test.Section+"/dishes", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if resp.StatusCode != test.Status {
+			t.Errorf("%s: expecting status %d but got %d", test.Title, test.Status, resp.StatusCode)
+		}
+	}
+
+	calls := menu.Calls["AddDish"]
+	if len(calls) != 1 {
+		t.Fatalf("expecting one call to AddDish but got %v", calls)
+	}
+	if path := calls[0][1].(models.MenuPath); path.SectionId.Hex() != sectionId || !path.DishId.IsZero() {
+		t.Errorf("expecting the section from the route but got %v", path)
+	}
+}
+
+func TestPublicRestaurantEndpoints(t *testing.T) {
+	controller, service, app := initTestRestaurant()
+
+	app.Get("/restaurant", controller.ListRestaurants)
+	app.Get("/restaurant/:id", controller.GetRestaurant)
+
+	id := "5f9a8a5c7c9d440000a9a8c7"
+	resp, err := app.Test(httptest.NewRequest("GET", "/restaurant/"+id, nil), 1)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	var restaurant map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&restaurant)
+	if resp.StatusCode != 200 || restaurant["id"] != id || restaurant["menu"] == nil {
+		t.Errorf("expecting restaurant with its menu but got %d %v", resp.StatusCode, restaurant)
+	}
+	if _, ok := restaurant["password"]; ok {
+		t.Errorf("expecting password to be stripped but got %v", restaurant)
+	}
+
+	resp, _ = app.Test(httptest.NewRequest("GET", "/restaurant?lat=1", nil), 1)
+	if resp.StatusCode != 400 {
+		t.Errorf("expecting 400 for lat without long but got %d", resp.StatusCode)
+	}
+
+	resp, _ = app.Test(httptest.NewRequest("GET", "/restaurant?city=Rosario&open=true&lat=1&long=2", nil), 1)
+	if resp.StatusCode != 200 {
+		t.Errorf("expecting 200 but got %d", resp.StatusCode)
+	}
+	query := service.Calls["List"][0][0].(types.RestaurantListQuery)
+	if query.City != "Rosario" || !query.Open || *query.Lat != 1 || *query.Long != 2 {
+		t.Errorf("unexpected list query %v", query)
+	}
+}
+
+func TestMenuDietaryFilters(t *testing.T) {
+	_, _, app := initTestRestaurant()
+	menuVersions := mocks.NewMenuVersionServiceMock()
+	controller := NewRestaurantController(mocks.NewRestaurantServiceMock(), mocks.NewPeerServiceMock(), validations.NewValidator(validator.New()), mocks.NewMenuServiceMock(), menuVersions)
+	app.Get("/restaurant/:id/menu/current", controller.GetCurrentMenu)
+
+	id := primitive.NewObjectID()
+	menuVersions.Menus = map[primitive.ObjectID]models.Menu{id: {Sections: []models.MenuSection{{Name: "mains", Dishes: []models.Dish{
+		{Name: "omelette", Dietary: models.Dietary{Allergens: []string{models.ALLERGEN_EGGS}}},
+		{Name: "salad", Dietary: models.Dietary{DietaryLabels: []string{models.DIET_VEGAN}, MayContain: []string{models.ALLERGEN_NUTS}}},
+		{Name: "hummus", Dietary: models.Dietary{DietaryLabels: []string{models.DIET_VEGAN}, Allergens: []string{models.ALLERGEN_SESAME}}},
+	}}}}}
+
+	tests := []struct {
+		query  string
+		status int
+		dishes int
+	}{
+		{"", 200, 3},
+		{"?exclude=eggs,sesame", 200, 1},
+		{"?include=vegan&exclude=nuts&excludeTraces=true", 200, 1},
+		{"?include=vegan&exclude=nuts", 200, 2},
+		{"?exclude=shellfish", 400, 0},
+		{"?include=keto", 400, 0},
+	}
+	for _, test := range tests {
+		resp, err := app.Test(httptest.NewRequest("GET", "/restaurant/"+id.Hex()+"/menu/current"+test.query, nil), 1)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if resp.StatusCode != test.status {
+			t.Errorf("%v: expecting %v but got %v", test.query, test.status, resp.StatusCode)
+			continue
+		}
+		if test.status != 200 {
+			continue
+		}
+		var version models.MenuVersion
+		json.NewDecoder(resp.Body).Decode(&version)
+		dishes := 0
+		for _, section := range version.Menu.Sections {
+			dishes += len(section.Dishes)
+		}
+		if dishes != test.dishes {
+			t.Errorf("%v: expecting %v dishes but got %v", test.query, test.dishes, version.Menu)
+		}
+	}
+
+	validate := validations.NewValidator(validator.New())
+	contradicting := types.DishData{Name: "cheese pizza", DietaryData: types.DietaryData{Allergens: []string{models.ALLERGEN_MILK}, DietaryLabels: []string{models.DIET_VEGAN}}}
+	if errors := validate.ValidateDish(contradicting); errors == nil {
+		t.Error("expecting a vegan dish with milk to be invalid")
+	}
+	repeated := types.DishData{Name: "bread", DietaryData: types.DietaryData{Allergens: []string{models.ALLERGEN_GLUTEN}, MayContain: []string{models.ALLERGEN_GLUTEN}}}
+	if errors := validate.ValidateDish(repeated); errors == nil {
+		t.Error("expecting an allergen to be either contained or a trace")
+	}
+	valid := types.DishData{Name: "bread", DietaryData: types.DietaryData{Allergens: []string{models.ALLERGEN_GLUTEN}, MayContain: []string{models.ALLERGEN_SESAME}, DietaryLabels: []string{models.DIET_VEGAN}}}
+	if errors := validate.ValidateDish(valid); errors != nil {
+		t.Errorf("expecting a valid dish but got %v", errors)
+	}
 }
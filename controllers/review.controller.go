@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/nicodeheza/peersEat/services"
+	"github.com/nicodeheza/peersEat/services/validations"
+	"github.com/nicodeheza/peersEat/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type ReviewController struct {
+	service    services.ReviewServiceI
+	validators validations.ValidateI
+}
+
+type ReviewControllerI interface {
+	SubmitReview(c *fiber.Ctx) error
+	ListReviews(c *fiber.Ctx) error
+	ReplyReview(c *fiber.Ctx) error
+	MarkReviewHelpful(c *fiber.Ctx) error
+}
+
+func NewReviewController(service services.ReviewServiceI, validators validations.ValidateI) *ReviewController {
+	return &ReviewController{service, validators}
+}
+
+func reviewErrorResponse(c *fiber.Ctx, err error) error {
+	if err == mongo.ErrNoDocuments {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "restaurant not found"})
+	}
+	if err == services.ErrReviewNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": err.Error()})
+	}
+	if err == services.ErrUnknownDish || err == services.ErrOwnReview {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
+	if err == services.ErrReviewNotAllowed {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": err.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+}
+
+func (r *ReviewController) SubmitReview(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": errInvalidRestaurantId.Error()})
+	}
+
+	body := new(types.ReviewData)
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := r.validators.ValidateReview(*body)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	review, err := r.service.Submit(id, *body)
+	if err != nil {
+		return reviewErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(review)
+}
+
+func (r *ReviewController) ListReviews(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": errInvalidRestaurantId.Error()})
+	}
+
+	query := new(types.ReviewListQuery)
+	if err := c.QueryParser(query); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := r.validators.ValidateReviewListQuery(*query)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	page, err := r.service.List(id, *query)
+	if err != nil {
+		return reviewErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(page)
+}
+
+func (r *ReviewController) ReplyReview(c *fiber.Ctx) error {
+	id, err := sessionRestaurantId(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "unauthorized"})
+	}
+	reviewId, err := primitive.ObjectIDFromHex(c.Params("reviewId"))
+	if err != nil {
+		return reviewErrorResponse(c, services.ErrReviewNotFound)
+	}
+
+	body := new(types.ReviewReplyData)
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := r.validators.ValidateReviewReply(*body)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	review, err := r.service.Reply(id, reviewId, *body)
+	if err != nil {
+		return reviewErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(review)
+}
+
+func (r *ReviewController) MarkReviewHelpful(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": errInvalidRestaurantId.Error()})
+	}
+	reviewId, err := primitive.ObjectIDFromHex(c.Params("reviewId"))
+	if err != nil {
+		return reviewErrorResponse(c, services.ErrReviewNotFound)
+	}
+
+	body := new(types.HelpfulVote)
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := r.validators.ValidateHelpfulVote(*body)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	err = r.service.MarkHelpful(id, reviewId, body.ReviewToken)
+	if err != nil {
+		return reviewErrorResponse(c, err)
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/nicodeheza/peersEat/services"
+	"github.com/nicodeheza/peersEat/services/validations"
+	"github.com/nicodeheza/peersEat/types"
+)
+
+type SearchController struct {
+	service    services.SearchServiceI
+	textSearch services.TextSearchServiceI
+	validators validations.ValidateI
+}
+
+type SearchControllerI interface {
+	Search(c *fiber.Ctx) error
+	PeerSearch(c *fiber.Ctx) error
+	TextSearch(c *fiber.Ctx) error
+}
+
+func NewSearchController(service services.SearchServiceI, textSearch services.TextSearchServiceI, validators validations.ValidateI) *SearchController {
+	return &SearchController{service, textSearch, validators}
+}
+
+func (s *SearchController) parseQuery(c *fiber.Ctx) (*types.SearchQuery, []*validations.ErrorResponse, error) {
+	query := new(types.SearchQuery)
+	if err := c.QueryParser(query); err != nil {
+		return nil, nil, err
+	}
+	return query, s.validators.ValidateSearchQuery(*query), nil
+}
+
+// restaurants delivering to a point from this peer and its delivery area peers
+func (s *SearchController) Search(c *fiber.Ctx) error {
+	query, errors, err := s.parseQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	result, err := s.service.Search(*query)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
+// answers other peers searches with the local restaurants only
+func (s *SearchController) PeerSearch(c *fiber.Ctx) error {
+	query, errors, err := s.parseQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	hits, err := s.service.LocalSearch(*query)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(hits)
+}
+
+// searches restaurants and dishes of this peer by name, description and tags
+func (s *SearchController) TextSearch(c *fiber.Ctx) error {
+	query := new(types.TextSearchQuery)
+	if err := c.QueryParser(query); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := s.validators.ValidateTextSearchQuery(*query)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	result, err := s.textSearch.Search(*query)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(result)
+}
@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/services"
+	"github.com/nicodeheza/peersEat/services/validations"
+	"github.com/nicodeheza/peersEat/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type StaffController struct {
+	service    services.StaffServiceI
+	validators validations.ValidateI
+}
+
+type StaffControllerI interface {
+	ListStaff(c *fiber.Ctx) error
+	InviteStaff(c *fiber.Ctx) error
+	AcceptInvitation(c *fiber.Ctx) error
+	ChangeStaffRole(c *fiber.Ctx) error
+	RemoveStaff(c *fiber.Ctx) error
+	UpdateStaffPassword(c *fiber.Ctx) error
+}
+
+func NewStaffController(service services.StaffServiceI, validators validations.ValidateI) *StaffController {
+	return &StaffController{service, validators}
+}
+
+// the session user, set by the Require middleware
+func sessionActor(c *fiber.Ctx) models.StaffActor {
+	actor := models.StaffActor{}
+	actor.Role, _ = c.Locals("staffRole").(string)
+	if staffId, _ := c.Locals("staffId").(string); staffId != "" {
+		actor.Id, _ = primitive.ObjectIDFromHex(staffId)
+	}
+	return actor
+}
+
+func staffErrorResponse(c *fiber.Ctx, err error) error {
+	if err == mongo.ErrNoDocuments {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "staff user not found"})
+	}
+	if err == services.ErrInvitationNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": err.Error()})
+	}
+	if err == services.ErrInvitationExpired {
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{"message": err.Error()})
+	}
+	if err == services.ErrRoleNotAllowed {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": err.Error()})
+	}
+	if err == services.ErrStaffSelfChange || err == services.ErrUserNameTaken {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": err.Error()})
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": services.ErrUserNameTaken.Error()})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+}
+
+func (s *StaffController) ListStaff(c *fiber.Ctx) error {
+	id, err := sessionRestaurantId(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "unauthorized"})
+	}
+
+	users, err := s.service.List(id)
+	if err != nil {
+		return staffErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(users)
+}
+
+func (s *StaffController) InviteStaff(c *fiber.Ctx) error {
+	id, err := sessionRestaurantId(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "unauthorized"})
+	}
+
+	body := new(types.StaffInvitationData)
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := s.validators.ValidateStaffInvitation(*body)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	invitation, err := s.service.Invite(id, sessionActor(c), *body)
+	if err != nil {
+		return staffErrorResponse(c, err)
+	}
+	c.Set("Cache-Control", "no-store")
+	return c.Status(fiber.StatusCreated).JSON(invitation)
+}
+
+func (s *StaffController) AcceptInvitation(c *fiber.Ctx) error {
+	body := new(types.AcceptInvitationData)
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := s.validators.ValidateAcceptInvitation(*body)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	user, err := s.service.AcceptInvitation(*body)
+	if err != nil {
+		return staffErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(user)
+}
+
+func (s *StaffController) ChangeStaffRole(c *fiber.Ctx) error {
+	id, err := sessionRestaurantId(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "unauthorized"})
+	}
+	staffId, err := primitive.ObjectIDFromHex(c.Params("staffId"))
+	if err != nil {
+		return staffErrorResponse(c, mongo.ErrNoDocuments)
+	}
+
+	body := new(types.StaffRoleData)
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := s.validators.ValidateStaffRole(*body)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	user, err := s.service.ChangeRole(id, sessionActor(c), staffId, body.Role)
+	if err != nil {
+		return staffErrorResponse(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(user)
+}
+
+func (s *StaffController) RemoveStaff(c *fiber.Ctx) error {
+	id, err := sessionRestaurantId(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "unauthorized"})
+	}
+	staffId, err := primitive.ObjectIDFromHex(c.Params("staffId"))
+	if err != nil {
+		return staffErrorResponse(c, mongo.ErrNoDocuments)
+	}
+
+	if err := s.service.Remove(id, sessionActor(c), staffId); err != nil {
+		return staffErrorResponse(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// the restaurant account changes its credentials with PATCH /restaurant/password
+func (s *StaffController) UpdateStaffPassword(c *fiber.Ctx) error {
+	actor := sessionActor(c)
+	if actor.Id.IsZero() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "not logged in as a staff user"})
+	}
+
+	body := new(types.StaffPasswordData)
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	errors := s.validators.ValidateStaffPassword(*body)
+	if errors != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errors)
+	}
+
+	if err := s.service.UpdatePassword(actor.Id, body.NewPassword); err != nil {
+		return staffErrorResponse(c, err)
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
@@ -7,6 +7,8 @@ import (
 
 const ADD_NEW_PEER = "addPeer"
 const DELIVERY_AREA_UPDATED = "deliveryAreaUpdated"
+const INFLUENCE_AREA_UPDATED = "influenceAreaUpdated"
+const RESTAURANT_STATUS_UPDATED = "restaurantStatusUpdated"
 
 func NewAddPeerEvent(peer models.Peer, sendTo []string) types.Event {
 	return types.Event{
@@ -23,3 +25,19 @@ func NewUpdateDeliveryAreaEvent(peer models.Peer, sendTo []string) types.Event {
 		SendTo:  sendTo,
 	}
 }
+
+func NewUpdateInfluenceAreaEvent(peer models.Peer, sendTo []string) types.Event {
+	return types.Event{
+		Name:    INFLUENCE_AREA_UPDATED,
+		Payload: peer,
+		SendTo:  sendTo,
+	}
+}
+
+func NewRestaurantStatusEvent(status models.RemoteRestaurantStatus, sendTo []string) types.Event {
+	return types.Event{
+		Name:    RESTAURANT_STATUS_UPDATED,
+		Payload: status,
+		SendTo:  sendTo,
+	}
+}
@@ -5,34 +5,45 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"strings"
 	"sync"
 
+	"github.com/nicodeheza/peersEat/constants"
 	"github.com/nicodeheza/peersEat/models"
 	"github.com/nicodeheza/peersEat/repositories"
 	"github.com/nicodeheza/peersEat/services/geo"
+	"github.com/nicodeheza/peersEat/services/overlay"
 	"github.com/nicodeheza/peersEat/services/validations"
 	"github.com/nicodeheza/peersEat/types"
+	"github.com/nicodeheza/peersEat/utils"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type Handlers struct {
-	peerRepo   repositories.PeerRepositoryI
-	validation validations.ValidateI
-	geo        geo.GeoServiceI
+	peerRepo           repositories.PeerRepositoryI
+	validation         validations.ValidateI
+	geo                geo.GeoServiceI
+	overlay            overlay.OverlayServiceI
+	remoteStatusesRepo repositories.RemoteRestaurantStatusRepositoryI
 }
 
 type HandlersI interface {
 	PropagateEvent(event types.Event)
 	HandleAddPeer(event types.Event)
 	PeerUpdatedDeliveryArea(event types.Event)
+	PeerUpdatedInfluenceArea(event types.Event)
+	RestaurantStatusUpdated(event types.Event)
 }
 
 func NewEventHandlers(
 	peerRepo repositories.PeerRepositoryI,
 	validation validations.ValidateI,
 	geo geo.GeoServiceI,
+	overlay overlay.OverlayServiceI,
+	remoteStatusesRepo repositories.RemoteRestaurantStatusRepositoryI,
 ) *Handlers {
-	return &Handlers{peerRepo, validation, geo}
+	return &Handlers{peerRepo, validation, geo, overlay, remoteStatusesRepo}
 }
 
 func (h *Handlers) createSendMap(urls []string, sendMap map[string][]string) {
@@ -81,6 +92,10 @@ func (h *Handlers) sendEvent(peerUrl string, event types.Event, wg *sync.WaitGro
 }
 
 func (h *Handlers) PropagateEvent(event types.Event) {
+	if event.Broadcast {
+		h.propagateBroadcast(event)
+		return
+	}
 	if len(event.SendTo) == 0 {
 		return
 	}
@@ -100,6 +115,103 @@ func (h *Handlers) PropagateEvent(event types.Event) {
 	}()
 }
 
+func (h *Handlers) propagateBroadcast(event types.Event) {
+	if strings.HasSuffix(event.Scope, overlay.TERMINAL_SCOPE) {
+		return
+	}
+
+	selfPeer, err := h.peerRepo.GetSelf()
+	if err != nil {
+		log.Println(err.Error())
+		return
+	}
+
+	// peers keeping the full table already know every peer of the scope
+	if !h.overlay.IsEnabled() {
+		peers, err := h.peerRepo.FindByGeohashPrefix(event.Scope, "", 0)
+		if err != nil {
+			log.Println(err.Error())
+			return
+		}
+		sendTo := []string{}
+		for _, peer := range peers {
+			if peer.Url != selfPeer.Url {
+				sendTo = append(sendTo, peer.Url)
+			}
+		}
+		event.Broadcast = false
+		event.Scope = ""
+		event.SendTo = sendTo
+		h.PropagateEvent(event)
+		return
+	}
+
+	var wg sync.WaitGroup
+
+	// scopes outside of this peer are delegated to a peer inside them
+	if !strings.HasPrefix(utils.PeerGeohash(selfPeer), event.Scope) {
+		contacts, err := h.overlay.Lookup(selfPeer, event.Scope, constants.OVERLAY_LOOKUP_SIZE)
+		if err != nil {
+			log.Println(err.Error())
+			return
+		}
+		for _, contact := range contacts {
+			if strings.HasPrefix(utils.PeerGeohash(contact), event.Scope) {
+				wg.Add(1)
+				go h.sendEvent(contact.Url, event, &wg)
+				return
+			}
+		}
+		log.Printf("no peer found in scope %v\n", event.Scope)
+		return
+	}
+
+	targets, err := h.overlay.BroadcastTargets(selfPeer, event.Scope)
+	if err != nil {
+		log.Println(err.Error())
+		return
+	}
+
+	for url, scope := range targets {
+		wg.Add(1)
+		eventToSend := event
+		eventToSend.Scope = scope
+		eventToSend.SendTo = nil
+		go h.sendEvent(url, eventToSend, &wg)
+	}
+}
+
+// peers overlapping this peer areas are always stored, the rest only while
+// the routing table has room for them
+func (h *Handlers) keepPeer(selfPeer models.Peer, peer models.Peer) bool {
+	if h.geo.AreInfluenceAreasOverlaying(selfPeer, peer) || h.geo.IsInDeliveryArea(selfPeer, peer) {
+		return true
+	}
+	keep, err := h.overlay.ShouldStore(selfPeer, peer)
+	if err != nil {
+		log.Println(err.Error())
+		return false
+	}
+	return keep
+}
+
+// updates of peers missing from the routing table are stored if they became relevant
+func (h *Handlers) updateOrStorePeer(selfPeer models.Peer, sendPeer models.Peer, updates map[string]interface{}) (models.Peer, error) {
+	peer, err := h.peerRepo.FindByUrlAndUpdate(sendPeer.Url, updates)
+	if err != mongo.ErrNoDocuments {
+		return peer, err
+	}
+	if !h.keepPeer(selfPeer, sendPeer) {
+		return models.Peer{}, err
+	}
+	id, err := h.peerRepo.Insert(sendPeer)
+	if err != nil {
+		return models.Peer{}, err
+	}
+	sendPeer.Id = id
+	return sendPeer, nil
+}
+
 func (h *Handlers) HandleAddPeer(event types.Event) {
 	defer h.PropagateEvent(event)
 
@@ -126,6 +238,10 @@ func (h *Handlers) HandleAddPeer(event types.Event) {
 		return
 	}
 
+	if newPeer.Url == selfPeer.Url || !h.keepPeer(selfPeer, newPeer) {
+		return
+	}
+
 	id, err := h.peerRepo.Insert(newPeer)
 	if err != nil {
 		log.Println(err.Error())
@@ -167,15 +283,22 @@ func (h *Handlers) PeerUpdatedDeliveryArea(event types.Event) {
 		return
 	}
 
-	sendPeer, ok := event.Payload.(models.Peer)
+	sendPeer := models.Peer{}
+	sendPeerBytes, err := json.Marshal(event.Payload)
+	err = json.Unmarshal(sendPeerBytes, &sendPeer)
+	if err != nil {
+		log.Println(err.Error())
+		return
+	}
 	errors := h.validation.ValidatePeer(sendPeer)
-	if !ok || errors != nil {
+	if errors != nil {
 		log.Println("payload don't contains a peer")
 		return
 	}
 
-	peer, err := h.peerRepo.FindByUrlAndUpdate(sendPeer.Url, map[string]interface{}{
+	peer, err := h.updateOrStorePeer(selfPeer, sendPeer, map[string]interface{}{
 		"delivery_radius": sendPeer.DeliveryRadius,
+		"delivery_zone":   sendPeer.DeliveryZone,
 	})
 	if err != nil {
 		log.Println(err.Error())
@@ -219,3 +342,107 @@ func (h *Handlers) PeerUpdatedDeliveryArea(event types.Event) {
 	}
 
 }
+
+func (h *Handlers) PeerUpdatedInfluenceArea(event types.Event) {
+	defer h.PropagateEvent(event)
+
+	if event.Name != INFLUENCE_AREA_UPDATED {
+		return
+	}
+
+	selfPeer, err := h.peerRepo.GetSelf()
+	if err != nil {
+		log.Println(err.Error())
+		return
+	}
+
+	sendPeer := models.Peer{}
+	sendPeerBytes, err := json.Marshal(event.Payload)
+	err = json.Unmarshal(sendPeerBytes, &sendPeer)
+	if err != nil {
+		log.Println(err.Error())
+		return
+	}
+	errors := h.validation.ValidatePeer(sendPeer)
+	if errors != nil {
+		log.Println("payload don't contains a peer")
+		return
+	}
+
+	peer, err := h.updateOrStorePeer(selfPeer, sendPeer, map[string]interface{}{
+		"influence_radius": sendPeer.InfluenceRadius,
+		"influence_zone":   sendPeer.InfluenceZone,
+	})
+	if err != nil {
+		log.Println(err.Error())
+		return
+	}
+
+	isInInfluenceArea := h.geo.AreInfluenceAreasOverlaying(selfPeer, peer)
+
+	var isInInfluenceAreaSlice bool
+	for _, id := range selfPeer.InAreaPeers {
+		if id == peer.Id {
+			isInInfluenceAreaSlice = true
+			break
+		}
+	}
+
+	if isInInfluenceArea == isInInfluenceAreaSlice {
+		return
+	}
+
+	newInAreaSlice := []primitive.ObjectID{}
+	for _, id := range selfPeer.InAreaPeers {
+		if id != peer.Id {
+			newInAreaSlice = append(newInAreaSlice, id)
+		}
+	}
+	if isInInfluenceArea {
+		newInAreaSlice = append(newInAreaSlice, peer.Id)
+	}
+
+	_, err = h.peerRepo.FindByUrlAndUpdate(selfPeer.Url, map[string]interface{}{
+		"in_area_peers": newInAreaSlice,
+	})
+	if err != nil {
+		log.Println(err.Error())
+		return
+	}
+}
+
+func (h *Handlers) RestaurantStatusUpdated(event types.Event) {
+	defer h.PropagateEvent(event)
+
+	if event.Name != RESTAURANT_STATUS_UPDATED {
+		return
+	}
+
+	status := models.RemoteRestaurantStatus{}
+	statusBytes, err := json.Marshal(event.Payload)
+	err = json.Unmarshal(statusBytes, &status)
+	if err != nil {
+		log.Println(err.Error())
+		return
+	}
+	errors := h.validation.ValidateRemoteRestaurantStatus(status)
+	if errors != nil || status.RestaurantId.IsZero() {
+		log.Println("payload don't contains a restaurant status")
+		return
+	}
+
+	// events can arrive out of order, only the newest status is kept
+	stored, err := h.remoteStatusesRepo.FindOne(status.RestaurantId)
+	if err != nil && err != mongo.ErrNoDocuments {
+		log.Println(err.Error())
+		return
+	}
+	if err == nil && !stored.UpdatedAt.Before(status.UpdatedAt) {
+		return
+	}
+
+	err = h.remoteStatusesRepo.Upsert(status)
+	if err != nil {
+		log.Println(err.Error())
+	}
+}
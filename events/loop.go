@@ -77,6 +77,10 @@ func (e *EventLoop) Loop() {
 			e.handlers.HandleAddPeer(event)
 		case DELIVERY_AREA_UPDATED:
 			e.handlers.PeerUpdatedDeliveryArea(event)
+		case INFLUENCE_AREA_UPDATED:
+			e.handlers.PeerUpdatedInfluenceArea(event)
+		case RESTAURANT_STATUS_UPDATED:
+			e.handlers.RestaurantStatusUpdated(event)
 		default:
 			continue
 		}
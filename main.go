@@ -2,11 +2,13 @@ package main
 
 import (
 	"fmt"
+	"log"
 	"os"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/nicodeheza/peersEat/config"
+	"github.com/nicodeheza/peersEat/constants"
 	"github.com/nicodeheza/peersEat/models"
 	"github.com/nicodeheza/peersEat/modules"
 	"github.com/nicodeheza/peersEat/routes"
@@ -14,7 +16,8 @@ import (
 
 func main() {
 	config.LoadEnv()
-	app := fiber.New()
+	// big enough for the image uploads, the other routes keep fiber's default limit
+	app := fiber.New(fiber.Config{BodyLimit: constants.IMAGE_BODY_LIMIT})
 	app.Use(logger.New())
 
 	config.ConnectDB(os.Getenv("MONGO_URI"))
@@ -24,6 +27,14 @@ func main() {
 	app.Use(appModule.AuthMiddleware.Sessions)
 
 	appModule.Peer.Service.InitPeer()
+	if err := appModule.Migration.MigrateMoney(); err != nil {
+		log.Fatal(err)
+	}
+	if err := appModule.SearchIndex.ReindexAll(); err != nil {
+		log.Fatal(err)
+	}
+	appModule.Replication.Start()
+	appModule.PresenceService.Start()
 
 	routes.Register(app, appModule)
 	app.Get("/", func(c *fiber.Ctx) error {
@@ -7,13 +7,17 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/session"
 	"github.com/gofiber/storage/redis"
+	"github.com/nicodeheza/peersEat/models"
 	"github.com/nicodeheza/peersEat/services"
 	"github.com/nicodeheza/peersEat/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type AuthMiddleware struct {
 	store             *session.Store
 	restaurantService services.RestaurantServiceI
+	staffService      services.StaffServiceI
 }
 
 type AuthMiddlewareI interface {
@@ -22,9 +26,10 @@ type AuthMiddlewareI interface {
 	Authenticate(c *fiber.Ctx) error
 	Protect(c *fiber.Ctx) error
 	OnlyPeerOwner(c *fiber.Ctx) error
+	Require(permission string) fiber.Handler
 }
 
-func InitAuthMiddleware(restaurantService services.RestaurantServiceI) *AuthMiddleware {
+func InitAuthMiddleware(restaurantService services.RestaurantServiceI, staffService services.StaffServiceI) *AuthMiddleware {
 	storage := redis.New(redis.Config{
 		URL:   os.Getenv("REDIS_URI"),
 		Reset: false,
@@ -34,7 +39,7 @@ func InitAuthMiddleware(restaurantService services.RestaurantServiceI) *AuthMidd
 		CookieSecure: true,
 	})
 
-	return &AuthMiddleware{store, restaurantService}
+	return &AuthMiddleware{store, restaurantService, staffService}
 }
 
 func (a *AuthMiddleware) Sessions(c *fiber.Ctx) error {
@@ -46,6 +51,8 @@ func (a *AuthMiddleware) Sessions(c *fiber.Ctx) error {
 	id := sess.Get("_id")
 
 	c.Locals("restaurantId", fmt.Sprintf("%v", id))
+	staffId, _ := sess.Get("staffId").(string)
+	c.Locals("staffId", staffId)
 	return c.Next()
 }
 
@@ -67,7 +74,13 @@ func (a *AuthMiddleware) Authenticate(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
 	}
 
-	isAuth, id, err := a.restaurantService.Authenticate(body.Password, body.UserName)
+	// staff users first, the credentials in the restaurant are its owner account
+	isAuth, staff, err := a.staffService.Authenticate(body.Password, body.UserName)
+	id, staffId := staff.RestaurantId.Hex(), staff.Id.Hex()
+	if err == mongo.ErrNoDocuments {
+		isAuth, id, err = a.restaurantService.Authenticate(body.Password, body.UserName)
+		staffId = ""
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
 	}
@@ -79,6 +92,7 @@ func (a *AuthMiddleware) Authenticate(c *fiber.Ctx) error {
 	sess, err := a.store.Get(c)
 
 	sess.Set("_id", id)
+	sess.Set("staffId", staffId)
 	if err := sess.Save(); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
 	}
@@ -102,6 +116,38 @@ func (a *AuthMiddleware) Protect(c *fiber.Ctx) error {
 	return c.Next()
 }
 
+// Require lets through the sessions whose role has the permission, the session of
+// the restaurant account has the owner role
+func (a *AuthMiddleware) Require(permission string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		restaurantId, err := primitive.ObjectIDFromHex(fmt.Sprintf("%v", c.Locals("restaurantId")))
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "unauthorized"})
+		}
+
+		role := models.STAFF_OWNER
+		if staffId, _ := c.Locals("staffId").(string); staffId != "" {
+			id, err := primitive.ObjectIDFromHex(staffId)
+			if err != nil {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "unauthorized"})
+			}
+			role, err = a.staffService.Role(restaurantId, id)
+			if err == mongo.ErrNoDocuments {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "unauthorized"})
+			}
+			if err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+			}
+		}
+
+		if !models.RoleCan(role, permission) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "forbidden"})
+		}
+		c.Locals("staffRole", role)
+		return c.Next()
+	}
+}
+
 func (a *AuthMiddleware) OnlyPeerOwner(c *fiber.Ctx) error {
 	headers := c.GetReqHeaders()
 
@@ -0,0 +1,18 @@
+package middleware
+
+import "github.com/gofiber/fiber/v2"
+
+// BodyLimit rejects the requests with a body over limit, unless skip returns true.
+// The server itself reads bodies up to the largest limit of any route
+func BodyLimit(limit int, skip func(c *fiber.Ctx) bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if skip != nil && skip(c) {
+			return c.Next()
+		}
+		// multipart forms are parsed while they are read, only their content length is left
+		if c.Request().Header.ContentLength() > limit || len(c.Request().Body()) > limit {
+			return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{"message": "request body too large"})
+		}
+		return c.Next()
+	}
+}
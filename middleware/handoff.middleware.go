@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/services"
+	"github.com/nicodeheza/peersEat/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type HandoffMiddleware struct {
+	handoff services.HandoffServiceI
+}
+
+type HandoffMiddlewareI interface {
+	RedirectMoved(c *fiber.Ctx) error
+	RedirectMovedLogin(c *fiber.Ctx) error
+}
+
+func InitHandoffMiddleware(handoff services.HandoffServiceI) *HandoffMiddleware {
+	return &HandoffMiddleware{handoff}
+}
+
+// restaurants handed off to another peer are redirected to it during the grace period
+func (h *HandoffMiddleware) RedirectMoved(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		id, err = primitive.ObjectIDFromHex(fmt.Sprintf("%v", c.Locals("restaurantId")))
+	}
+	if err != nil {
+		return c.Next()
+	}
+
+	return h.redirect(c, map[string]interface{}{"restaurantId": id})
+}
+
+func (h *HandoffMiddleware) RedirectMovedLogin(c *fiber.Ctx) error {
+	body := new(types.AuthReq)
+	if err := c.BodyParser(body); err != nil || body.UserName == "" {
+		return c.Next()
+	}
+
+	// the restaurant account or one of its staff users
+	return h.redirect(c, map[string]interface{}{"userName": body.UserName}, map[string]interface{}{"staffUserNames": body.UserName})
+}
+
+// the first query with a tombstone redirects
+func (h *HandoffMiddleware) redirect(c *fiber.Ctx, queries ...map[string]interface{}) error {
+	var tombstone models.RestaurantTombstone
+	err := mongo.ErrNoDocuments
+	for _, query := range queries {
+		if tombstone, err = h.handoff.FindTombstone(query); err != mongo.ErrNoDocuments {
+			break
+		}
+	}
+	if err == mongo.ErrNoDocuments {
+		return c.Next()
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	c.Set(fiber.HeaderLocation, tombstone.MovedTo+c.OriginalURL())
+	return c.Status(fiber.StatusTemporaryRedirect).JSON(fiber.Map{
+		"message": "restaurant moved",
+		"movedTo": tombstone.MovedTo,
+	})
+}
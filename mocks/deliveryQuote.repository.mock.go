@@ -0,0 +1,34 @@
+package mocks
+
+import (
+	"github.com/nicodeheza/peersEat/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type DeliveryQuoteRepositoryMock struct {
+	Quotes []models.DeliveryQuote
+}
+
+func NewDeliveryQuoteRepositoryMock() *DeliveryQuoteRepositoryMock {
+	return &DeliveryQuoteRepositoryMock{}
+}
+
+func (r *DeliveryQuoteRepositoryMock) Insert(quote models.DeliveryQuote) (primitive.ObjectID, error) {
+	quote.Id = primitive.NewObjectID()
+	r.Quotes = append(r.Quotes, quote)
+	return quote.Id, nil
+}
+
+func (r *DeliveryQuoteRepositoryMock) FindOne(query map[string]interface{}) (models.DeliveryQuote, error) {
+	for _, quote := range r.Quotes {
+		if id, ok := query["_id"]; ok && quote.Id != id {
+			continue
+		}
+		if restaurantId, ok := query["restaurantId"]; ok && quote.RestaurantId != restaurantId {
+			continue
+		}
+		return quote, nil
+	}
+	return models.DeliveryQuote{}, mongo.ErrNoDocuments
+}
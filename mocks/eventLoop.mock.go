@@ -2,7 +2,9 @@ package mocks
 
 import "github.com/nicodeheza/peersEat/types"
 
-type EventLoopMock struct{}
+type EventLoopMock struct {
+	PropagateCalls []types.Event
+}
 
 func NewEventLoopMock() *EventLoopMock {
 	return &EventLoopMock{}
@@ -16,4 +18,6 @@ func (e *EventLoopMock) Dequeue() types.Event {
 	return types.Event{}
 }
 
-func (e *EventLoopMock) PropagateEvent(event types.Event) {}
+func (e *EventLoopMock) PropagateEvent(event types.Event) {
+	e.PropagateCalls = append(e.PropagateCalls, event)
+}
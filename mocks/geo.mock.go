@@ -1,8 +1,6 @@
 package mocks
 
 import (
-	"errors"
-
 	"github.com/nicodeheza/peersEat/models"
 )
 
@@ -25,7 +23,14 @@ func (g *GeoService) IsSameCoord(coord1 models.GeoCoords, coord2 models.GeoCoord
 	return false
 }
 
-func (g *GeoService) IsInInfluenceArea(peerCenter, geoPoint models.GeoCoords) bool {
+func (g *GeoService) GetInfluenceRadius(peer models.Peer) float64 {
+	if peer.InfluenceRadius == 0 {
+		return 2
+	}
+	return peer.InfluenceRadius
+}
+
+func (g *GeoService) IsInInfluenceArea(peer models.Peer, geoPoint models.GeoCoords) bool {
 	if geoPoint.Long == 0 {
 		return false
 	}
@@ -46,10 +51,30 @@ func (g *GeoService) IsInDeliveryArea(selfPeer models.Peer, peer models.Peer) bo
 	return false
 }
 
-func (g *GeoService) GetAddressCoords(address, city, country string) (models.GeoCoords, error) {
-	if address == "error" {
-		return models.GeoCoords{}, errors.New("test error")
+func (g *GeoService) IsInRestaurantDeliveryArea(restaurant models.Restaurant, geoPoint models.GeoCoords) bool {
+	if restaurant.DeliveryRadius == 1 {
+		return true
 	}
+	return false
+}
+
+func (g *GeoService) IsInPeerDeliveryArea(peer models.Peer, geoPoint models.GeoCoords) bool {
+	return peer.DeliveryRadius >= geoPoint.Long
+}
+
+func (g *GeoService) IsPointInZone(zone models.GeoZone, geoPoint models.GeoCoords) bool {
+	return len(zone.Coordinates) > 0
+}
+
+func (g *GeoService) AreZonesIntersecting(zone1 models.GeoZone, zone2 models.GeoZone) bool {
+	return len(zone1.Coordinates) > 0 && len(zone2.Coordinates) > 0
+}
+
+func (g *GeoService) IsZoneIntersectingCircle(zone models.GeoZone, center models.GeoCoords, radius float64) bool {
+	return len(zone.Coordinates) > 0
+}
 
-	return models.GeoCoords{Long: 1.1, Lat: 2.2}, nil
+func (g *GeoService) CircleToZone(center models.GeoCoords, radius float64) models.GeoZone {
+	position := models.GeoPosition{center.Long, center.Lat}
+	return *models.NewGeoZone(models.GeoPolygon{{position, position, position, position}})
 }
@@ -0,0 +1,31 @@
+package mocks
+
+import (
+	"github.com/nicodeheza/peersEat/models"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type GeocodeCacheRepositoryMock struct {
+	Entries map[string]models.GeocodeCache
+}
+
+func NewGeocodeCacheRepositoryMock() *GeocodeCacheRepositoryMock {
+	return &GeocodeCacheRepositoryMock{Entries: make(map[string]models.GeocodeCache)}
+}
+
+func GeocodeCacheKey(provider, query string) string {
+	return provider + ":" + query
+}
+
+func (g *GeocodeCacheRepositoryMock) FindByQuery(provider, query string) (models.GeocodeCache, error) {
+	entry, ok := g.Entries[GeocodeCacheKey(provider, query)]
+	if !ok {
+		return models.GeocodeCache{}, mongo.ErrNoDocuments
+	}
+	return entry, nil
+}
+
+func (g *GeocodeCacheRepositoryMock) Upsert(entry models.GeocodeCache) error {
+	g.Entries[GeocodeCacheKey(entry.Provider, entry.Query)] = entry
+	return nil
+}
@@ -0,0 +1,34 @@
+package mocks
+
+import (
+	"errors"
+
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/types"
+)
+
+type GeocoderMock struct {
+	Calls []string
+	Name  string
+}
+
+func NewGeocoderMock() *GeocoderMock {
+	return &GeocoderMock{Name: "mock"}
+}
+
+func (g *GeocoderMock) Provider() string {
+	return g.Name
+}
+
+func (g *GeocoderMock) Geocode(address, city, country string) (types.GeocodeResult, error) {
+	g.Calls = append(g.Calls, address)
+	if address == "error" {
+		return types.GeocodeResult{}, errors.New("test error")
+	}
+
+	return types.GeocodeResult{
+		Coord:      models.GeoCoords{Long: 1.1, Lat: 2.2},
+		Provider:   g.Name,
+		Confidence: 0.9,
+	}, nil
+}
@@ -0,0 +1,46 @@
+package mocks
+
+import (
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/types"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type HandoffServiceMock struct {
+	Calls      map[string][][]interface{}
+	Tombstones []models.RestaurantTombstone
+}
+
+func NewHandoffServiceMock() *HandoffServiceMock {
+	calls := make(map[string][][]interface{})
+	return &HandoffServiceMock{Calls: calls}
+}
+
+func (h *HandoffServiceMock) Plan(request types.HandoffRequest) ([]types.HandoffPlanItem, error) {
+	h.Calls["Plan"] = append(h.Calls["Plan"], []interface{}{request})
+	return []types.HandoffPlanItem{}, nil
+}
+
+func (h *HandoffServiceMock) Execute(request types.HandoffRequest) (types.HandoffResult, error) {
+	h.Calls["Execute"] = append(h.Calls["Execute"], []interface{}{request})
+	return types.HandoffResult{}, nil
+}
+
+func (h *HandoffServiceMock) GetOffered(id string, token string) ([]types.TransferredRestaurant, error) {
+	h.Calls["GetOffered"] = append(h.Calls["GetOffered"], []interface{}{id, token})
+	return []types.TransferredRestaurant{}, nil
+}
+
+func (h *HandoffServiceMock) ReceiveOffer(offer types.HandoffOffer) ([]string, error) {
+	h.Calls["ReceiveOffer"] = append(h.Calls["ReceiveOffer"], []interface{}{offer})
+	return []string{}, nil
+}
+
+func (h *HandoffServiceMock) FindTombstone(query map[string]interface{}) (models.RestaurantTombstone, error) {
+	for _, tombstone := range h.Tombstones {
+		if tombstone.RestaurantId == query["restaurantId"] || tombstone.UserName == query["userName"] {
+			return tombstone, nil
+		}
+	}
+	return models.RestaurantTombstone{}, mongo.ErrNoDocuments
+}
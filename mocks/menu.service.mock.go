@@ -0,0 +1,77 @@
+package mocks
+
+import (
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type MenuServiceMock struct {
+	Calls map[string][][]interface{}
+	Err   error
+}
+
+func NewMenuServiceMock() *MenuServiceMock {
+	calls := make(map[string][][]interface{})
+	return &MenuServiceMock{Calls: calls}
+}
+
+func (m *MenuServiceMock) GetMenu(restaurantId primitive.ObjectID) (models.Menu, error) {
+	m.Calls["GetMenu"] = append(m.Calls["GetMenu"], []interface{}{restaurantId})
+	return models.Menu{}, m.Err
+}
+
+func (m *MenuServiceMock) AddSection(restaurantId primitive.ObjectID, data types.MenuSectionData) (models.MenuSection, error) {
+	m.Calls["AddSection"] = append(m.Calls["AddSection"], []interface{}{restaurantId, data})
+	return models.MenuSection{Name: data.Name}, m.Err
+}
+
+func (m *MenuServiceMock) UpdateSection(restaurantId primitive.ObjectID, path models.MenuPath, data types.MenuSectionUpdate) error {
+	m.Calls["UpdateSection"] = append(m.Calls["UpdateSection"], []interface{}{restaurantId, path, data})
+	return m.Err
+}
+
+func (m *MenuServiceMock) AddDish(restaurantId primitive.ObjectID, path models.MenuPath, data types.DishData) (models.Dish, error) {
+	m.Calls["AddDish"] = append(m.Calls["AddDish"], []interface{}{restaurantId, path, data})
+	return models.Dish{Name: data.Name, Price: data.Price}, m.Err
+}
+
+func (m *MenuServiceMock) UpdateDish(restaurantId primitive.ObjectID, path models.MenuPath, data types.DishUpdate) error {
+	m.Calls["UpdateDish"] = append(m.Calls["UpdateDish"], []interface{}{restaurantId, path, data})
+	return m.Err
+}
+
+func (m *MenuServiceMock) AddOption(restaurantId primitive.ObjectID, path models.MenuPath, data types.DishOptionData) (models.DishOptions, error) {
+	m.Calls["AddOption"] = append(m.Calls["AddOption"], []interface{}{restaurantId, path, data})
+	return models.DishOptions{Name: data.Name, Price: data.Price}, m.Err
+}
+
+func (m *MenuServiceMock) UpdateOption(restaurantId primitive.ObjectID, path models.MenuPath, data types.DishOptionUpdate) error {
+	m.Calls["UpdateOption"] = append(m.Calls["UpdateOption"], []interface{}{restaurantId, path, data})
+	return m.Err
+}
+
+func (m *MenuServiceMock) DeleteItem(restaurantId primitive.ObjectID, path models.MenuPath) error {
+	m.Calls["DeleteItem"] = append(m.Calls["DeleteItem"], []interface{}{restaurantId, path})
+	return m.Err
+}
+
+func (m *MenuServiceMock) Reorder(restaurantId primitive.ObjectID, path models.MenuPath, ids []string) error {
+	m.Calls["Reorder"] = append(m.Calls["Reorder"], []interface{}{restaurantId, path, ids})
+	return m.Err
+}
+
+func (m *MenuServiceMock) SetOptionGroups(restaurantId primitive.ObjectID, path models.MenuPath, data types.DishOptionGroups) ([]models.OptionGroup, error) {
+	m.Calls["SetOptionGroups"] = append(m.Calls["SetOptionGroups"], []interface{}{restaurantId, path, data})
+	return []models.OptionGroup{}, m.Err
+}
+
+func (m *MenuServiceMock) PriceSelection(restaurantId primitive.ObjectID, selection types.DishSelection) (types.PricedSelection, error) {
+	m.Calls["PriceSelection"] = append(m.Calls["PriceSelection"], []interface{}{restaurantId, selection})
+	return types.PricedSelection{DishId: selection.DishId}, m.Err
+}
+
+func (m *MenuServiceMock) SetAvailability(restaurantId primitive.ObjectID, itemId primitive.ObjectID, data types.ItemAvailability) ([]models.UnavailableItem, error) {
+	m.Calls["SetAvailability"] = append(m.Calls["SetAvailability"], []interface{}{restaurantId, itemId, data})
+	return []models.UnavailableItem{}, m.Err
+}
@@ -0,0 +1,118 @@
+package mocks
+
+import (
+	"github.com/nicodeheza/peersEat/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MenuVersionRepositoryMock struct {
+	Versions []models.MenuVersion
+}
+
+func NewMenuVersionRepositoryMock() *MenuVersionRepositoryMock {
+	return &MenuVersionRepositoryMock{}
+}
+
+func (m *MenuVersionRepositoryMock) Insert(version models.MenuVersion) (primitive.ObjectID, error) {
+	if version.Id.IsZero() {
+		version.Id = primitive.NewObjectID()
+	}
+	for _, stored := range m.Versions {
+		if stored.Id == version.Id {
+			return primitive.ObjectID{}, mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000, Message: "duplicate key"}}}
+		}
+	}
+	m.Versions = append(m.Versions, version)
+	return version.Id, nil
+}
+
+func matchesVersion(version models.MenuVersion, query map[string]interface{}) bool {
+	if id, ok := query["_id"]; ok && version.Id != id {
+		return false
+	}
+	if restaurantId, ok := query["restaurantId"]; ok && version.RestaurantId != restaurantId {
+		return false
+	}
+	if name, ok := query["name"]; ok && version.Name != name {
+		return false
+	}
+	return true
+}
+
+func (m *MenuVersionRepositoryMock) FindOne(query map[string]interface{}) (models.MenuVersion, error) {
+	for _, version := range m.Versions {
+		if matchesVersion(version, query) {
+			return version, nil
+		}
+	}
+	return models.MenuVersion{}, mongo.ErrNoDocuments
+}
+
+func (m *MenuVersionRepositoryMock) FindMany(query map[string]interface{}) ([]models.MenuVersion, error) {
+	versions := []models.MenuVersion{}
+	for _, version := range m.Versions {
+		if matchesVersion(version, query) {
+			versions = append(versions, version)
+		}
+	}
+	return versions, nil
+}
+
+func (m *MenuVersionRepositoryMock) LastVersion(restaurantId primitive.ObjectID, name string) (int, error) {
+	last := 0
+	for _, version := range m.Versions {
+		if version.RestaurantId == restaurantId && version.Name == name && version.Version > last {
+			last = version.Version
+		}
+	}
+	return last, nil
+}
+
+func (m *MenuVersionRepositoryMock) Archive(restaurantId primitive.ObjectID, name string) (int64, error) {
+	var archived int64
+	for i := range m.Versions {
+		if m.Versions[i].RestaurantId == restaurantId && m.Versions[i].Name == name {
+			m.Versions[i].Archived = true
+			archived++
+		}
+	}
+	return archived, nil
+}
+
+func legacyGroups(groups []models.OptionGroup) bool {
+	for _, group := range groups {
+		for _, option := range group.Options {
+			if option.PriceDelta.Currency == "" || legacyGroups(option.Groups) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func legacyMenu(menu models.Menu) bool {
+	for _, section := range menu.Sections {
+		for _, dish := range section.Dishes {
+			if dish.Price.Currency == "" || legacyGroups(dish.OptionGroups) {
+				return true
+			}
+			for _, option := range dish.Options {
+				if option.Price.Currency == "" {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (m *MenuVersionRepositoryMock) MigrateMenuMoney(id primitive.ObjectID, menu models.Menu) (bool, error) {
+	for i := range m.Versions {
+		if m.Versions[i].Id == id && legacyMenu(m.Versions[i].Menu) {
+			m.Versions[i].Menu = menu
+			return true, nil
+		}
+	}
+	return false, nil
+}
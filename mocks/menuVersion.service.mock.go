@@ -0,0 +1,56 @@
+package mocks
+
+import (
+	"time"
+
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type MenuVersionServiceMock struct {
+	Calls map[string][][]interface{}
+	Err   error
+	// current menus by restaurant
+	Menus map[primitive.ObjectID]models.Menu
+}
+
+func NewMenuVersionServiceMock() *MenuVersionServiceMock {
+	calls := make(map[string][][]interface{})
+	return &MenuVersionServiceMock{Calls: calls}
+}
+
+func (m *MenuVersionServiceMock) Publish(restaurantId primitive.ObjectID, data types.PublishMenu) (models.MenuVersion, error) {
+	m.Calls["Publish"] = append(m.Calls["Publish"], []interface{}{restaurantId, data})
+	return models.MenuVersion{RestaurantId: restaurantId, Name: data.Name, Version: 1}, m.Err
+}
+
+func (m *MenuVersionServiceMock) GetVersions(restaurantId primitive.ObjectID, name string) ([]models.MenuVersion, error) {
+	m.Calls["GetVersions"] = append(m.Calls["GetVersions"], []interface{}{restaurantId, name})
+	return []models.MenuVersion{}, m.Err
+}
+
+func (m *MenuVersionServiceMock) GetVersion(restaurantId primitive.ObjectID, versionId primitive.ObjectID) (models.MenuVersion, error) {
+	m.Calls["GetVersion"] = append(m.Calls["GetVersion"], []interface{}{restaurantId, versionId})
+	return models.MenuVersion{Id: versionId, RestaurantId: restaurantId}, m.Err
+}
+
+func (m *MenuVersionServiceMock) Checkout(restaurantId primitive.ObjectID, versionId primitive.ObjectID) (models.Menu, error) {
+	m.Calls["Checkout"] = append(m.Calls["Checkout"], []interface{}{restaurantId, versionId})
+	return models.Menu{}, m.Err
+}
+
+func (m *MenuVersionServiceMock) Archive(restaurantId primitive.ObjectID, name string) error {
+	m.Calls["Archive"] = append(m.Calls["Archive"], []interface{}{restaurantId, name})
+	return m.Err
+}
+
+func (m *MenuVersionServiceMock) CurrentMenu(restaurantId primitive.ObjectID, at time.Time) (models.MenuVersion, error) {
+	m.Calls["CurrentMenu"] = append(m.Calls["CurrentMenu"], []interface{}{restaurantId, at})
+	return models.MenuVersion{RestaurantId: restaurantId, Menu: m.Menus[restaurantId]}, m.Err
+}
+
+func (m *MenuVersionServiceMock) PriceSelection(restaurantId primitive.ObjectID, versionId primitive.ObjectID, selection types.DishSelection) (types.PricedSelection, error) {
+	m.Calls["PriceSelection"] = append(m.Calls["PriceSelection"], []interface{}{restaurantId, versionId, selection})
+	return types.PricedSelection{DishId: selection.DishId, MenuVersionId: versionId.Hex()}, m.Err
+}
@@ -0,0 +1,41 @@
+package mocks
+
+import (
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/utils"
+)
+
+type OverlayServiceMock struct {
+	Enabled     bool
+	Peers       []models.Peer
+	LookupCalls []string
+}
+
+func NewOverlayServiceMock() *OverlayServiceMock {
+	return &OverlayServiceMock{}
+}
+
+func (o *OverlayServiceMock) IsEnabled() bool {
+	return o.Enabled
+}
+
+func (o *OverlayServiceMock) ShouldStore(self models.Peer, peer models.Peer) (bool, error) {
+	return true, nil
+}
+
+func (o *OverlayServiceMock) ClosestPeers(target string, limit int) ([]models.Peer, error) {
+	return o.Peers, nil
+}
+
+func (o *OverlayServiceMock) Lookup(self models.Peer, target string, limit int) ([]models.Peer, error) {
+	o.LookupCalls = append(o.LookupCalls, target)
+	return o.Peers, nil
+}
+
+func (o *OverlayServiceMock) BroadcastTargets(self models.Peer, scope string) (map[string]string, error) {
+	return map[string]string{}, nil
+}
+
+func (o *OverlayServiceMock) AreaScopes(center models.GeoCoords, radius float64) []string {
+	return []string{utils.Geohash(center, 4)}
+}
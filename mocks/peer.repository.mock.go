@@ -1,7 +1,9 @@
 package mocks
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
 	"strconv"
 	"strings"
@@ -16,13 +18,20 @@ type ExpectUpdate struct {
 	Fields []string
 }
 
+type ExpectFindByUrlAndUpdate struct {
+	Url     string
+	Updates map[string]interface{}
+}
+
 type PeerRepositoryMock struct {
-	InsertCalls     []models.Peer
-	GetByIdCalls    []primitive.ObjectID
-	GetAllCalls     [][]string
-	UpdateCalls     []ExpectUpdate
-	GetAllUrlsCalls [][]string
-	InsertManyCalls [][]models.Peer
+	InsertCalls             []models.Peer
+	GetByIdCalls            []primitive.ObjectID
+	GetAllCalls             [][]string
+	UpdateCalls             []ExpectUpdate
+	GetAllUrlsCalls         [][]string
+	InsertManyCalls         [][]models.Peer
+	FindByUrlAndUpdateCalls []ExpectFindByUrlAndUpdate
+	Peers                   []models.Peer
 }
 
 func NewPeerRepository() *PeerRepositoryMock {
@@ -36,6 +45,7 @@ func (p *PeerRepositoryMock) ClearCalls() {
 	p.GetAllUrlsCalls = nil
 	p.GetAllCalls = nil
 	p.InsertManyCalls = nil
+	p.FindByUrlAndUpdateCalls = nil
 }
 
 func (p *PeerRepositoryMock) Insert(peer models.Peer) (id primitive.ObjectID, err error) {
@@ -103,6 +113,21 @@ func (p *PeerRepositoryMock) GetSelf() (models.Peer, error) {
 
 func (p *PeerRepositoryMock) Update(peer models.Peer, fields []string) error {
 	p.UpdateCalls = append(p.UpdateCalls, ExpectUpdate{peer, fields})
+
+	// like the repository, fields are looked up by their json tag
+	mapPeer := make(map[string]interface{})
+	peerBytes, err := json.Marshal(peer)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(peerBytes, &mapPeer); err != nil {
+		return err
+	}
+	for _, field := range fields {
+		if _, ok := mapPeer[field]; !ok {
+			return fmt.Errorf("field %v not exist in peer struct", field)
+		}
+	}
 	return nil
 }
 
@@ -120,12 +145,44 @@ func (p *PeerRepositoryMock) FindUrlsByIds(ids []primitive.ObjectID) ([]string,
 
 func (p PeerRepositoryMock) GetManyByIds(ids []primitive.ObjectID) ([]models.Peer, error) {
 
-	return nil, nil
+	return p.Peers, nil
 }
 func (p *PeerRepositoryMock) FindMany(query map[string]interface{}) ([]models.Peer, error) {
-	return nil, nil
+	url, ok := query["url"]
+	if !ok {
+		return nil, nil
+	}
+	var result []models.Peer
+	for _, peer := range p.Peers {
+		if peer.Url == url {
+			result = append(result, peer)
+		}
+	}
+	return result, nil
 }
 
 func (p *PeerRepositoryMock) FindByUrlAndUpdate(url string, updates map[string]interface{}) (models.Peer, error) {
+	p.FindByUrlAndUpdateCalls = append(p.FindByUrlAndUpdateCalls, ExpectFindByUrlAndUpdate{url, updates})
 	return models.Peer{}, nil
 }
+
+func (p *PeerRepositoryMock) FindByGeohashPrefix(prefix string, excludePrefix string, limit int64) ([]models.Peer, error) {
+	var result []models.Peer
+	for _, peer := range p.Peers {
+		if limit > 0 && int64(len(result)) == limit {
+			break
+		}
+		if strings.HasPrefix(peer.Geohash, prefix) && (excludePrefix == "" || !strings.HasPrefix(peer.Geohash, excludePrefix)) {
+			result = append(result, peer)
+		}
+	}
+	return result, nil
+}
+
+func (p *PeerRepositoryMock) Count() (int64, error) {
+	return int64(len(p.Peers)), nil
+}
+
+func (p *PeerRepositoryMock) SetMissingGeohashes() error {
+	return nil
+}
@@ -11,8 +11,10 @@ import (
 )
 
 type PeerServiceMock struct {
-	Calls          map[string][][]interface{}
-	InAreaPeerHave bool
+	Calls               map[string][][]interface{}
+	InAreaPeerHave      bool
+	InDeliveryAreaPeers []models.Peer
+	RecomputeErr        error
 }
 
 func NewPeerServiceMock() *PeerServiceMock {
@@ -103,7 +105,7 @@ func (p *PeerServiceMock) PeerHaveRestaurant(peerUrl string, restaurantQuery map
 }
 
 func (p *PeerServiceMock) GetInDeliveryAreaPeers(peer models.Peer) ([]models.Peer, error) {
-	return nil, nil
+	return p.InDeliveryAreaPeers, nil
 }
 
 func (p *PeerServiceMock) GetNewDeliveryArea(peerCenter, restaurantCoord models.GeoCoords, restaurantDeliveryRadius float64) float64 {
@@ -113,3 +115,45 @@ func (p *PeerServiceMock) GetNewDeliveryArea(peerCenter, restaurantCoord models.
 func (p *PeerServiceMock) UpdateDeliveryArea(peer models.Peer, newDeliveryRadius float64) error {
 	return nil
 }
+
+func (p *PeerServiceMock) UpdateInfluenceArea(peer models.Peer, newInfluenceRadius float64) error {
+	p.Calls["UpdateInfluenceArea"] = append(p.Calls["UpdateInfluenceArea"], []interface{}{peer, newInfluenceRadius})
+	return nil
+}
+
+func (p *PeerServiceMock) UpdateInfluenceZone(peer models.Peer, newInfluenceZone *models.GeoZone) error {
+	p.Calls["UpdateInfluenceZone"] = append(p.Calls["UpdateInfluenceZone"], []interface{}{peer, newInfluenceZone})
+	return nil
+}
+
+func (p *PeerServiceMock) GetNewDeliveryZone() (*models.GeoZone, error) {
+	return nil, nil
+}
+
+func (p *PeerServiceMock) UpdateDeliveryZone(peer models.Peer, newDeliveryZone *models.GeoZone) error {
+	p.Calls["UpdateDeliveryZone"] = append(p.Calls["UpdateDeliveryZone"], []interface{}{peer, newDeliveryZone})
+	return nil
+}
+
+func (p *PeerServiceMock) RecomputeDeliveryArea() error {
+	p.Calls["RecomputeDeliveryArea"] = append(p.Calls["RecomputeDeliveryArea"], []interface{}{})
+	return p.RecomputeErr
+}
+
+func (p *PeerServiceMock) FindPeersServing(geoPoint models.GeoCoords, area string, follow bool) (types.ServingResponse, error) {
+	p.Calls["FindPeersServing"] = append(p.Calls["FindPeersServing"], []interface{}{geoPoint, area, follow})
+	return types.ServingResponse{
+		Peers:  []types.ServingPeer{{Url: "http://test.com", Distance: 1}},
+		Source: "http://test.com",
+	}, nil
+}
+
+func (p *PeerServiceMock) ClosestPeers(geohash string, limit int) ([]models.Peer, error) {
+	p.Calls["ClosestPeers"] = append(p.Calls["ClosestPeers"], []interface{}{geohash, limit})
+	return []models.Peer{{Url: "http://test.com", Geohash: geohash}}, nil
+}
+
+func (p *PeerServiceMock) PropagateRestaurantStatus(restaurant models.Restaurant) error {
+	p.Calls["PropagateRestaurantStatus"] = append(p.Calls["PropagateRestaurantStatus"], []interface{}{restaurant})
+	return nil
+}
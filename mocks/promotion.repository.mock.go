@@ -0,0 +1,101 @@
+package mocks
+
+import (
+	"github.com/nicodeheza/peersEat/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type PromotionRepositoryMock struct {
+	Promotions []models.Promotion
+}
+
+func NewPromotionRepositoryMock() *PromotionRepositoryMock {
+	return &PromotionRepositoryMock{}
+}
+
+func (r *PromotionRepositoryMock) Insert(promotion models.Promotion) (primitive.ObjectID, error) {
+	promotion.Id = primitive.NewObjectID()
+	r.Promotions = append(r.Promotions, promotion)
+	return promotion.Id, nil
+}
+
+func (r *PromotionRepositoryMock) FindOne(query map[string]interface{}) (models.Promotion, error) {
+	for _, promotion := range r.Promotions {
+		if id, ok := query["_id"]; ok && promotion.Id != id {
+			continue
+		}
+		return promotion, nil
+	}
+	return models.Promotion{}, mongo.ErrNoDocuments
+}
+
+func sameScope(a *primitive.ObjectID, b *primitive.ObjectID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func (r *PromotionRepositoryMock) FindByScope(restaurantId *primitive.ObjectID) ([]models.Promotion, error) {
+	promotions := []models.Promotion{}
+	for _, promotion := range r.Promotions {
+		if sameScope(promotion.RestaurantId, restaurantId) {
+			promotions = append(promotions, promotion)
+		}
+	}
+	return promotions, nil
+}
+
+func (r *PromotionRepositoryMock) FindApplicable(restaurantId primitive.ObjectID) ([]models.Promotion, error) {
+	promotions := []models.Promotion{}
+	for _, promotion := range r.Promotions {
+		if promotion.RestaurantId == nil || *promotion.RestaurantId == restaurantId {
+			promotions = append(promotions, promotion)
+		}
+	}
+	return promotions, nil
+}
+
+func (r *PromotionRepositoryMock) Update(promotion models.Promotion) error {
+	for i := range r.Promotions {
+		if r.Promotions[i].Id == promotion.Id {
+			promotion.RestaurantId = r.Promotions[i].RestaurantId
+			promotion.Uses = r.Promotions[i].Uses
+			promotion.CreatedAt = r.Promotions[i].CreatedAt
+			r.Promotions[i] = promotion
+			return nil
+		}
+	}
+	return mongo.ErrNoDocuments
+}
+
+func (r *PromotionRepositoryMock) Delete(id primitive.ObjectID) error {
+	for i := range r.Promotions {
+		if r.Promotions[i].Id == id {
+			r.Promotions = append(r.Promotions[:i], r.Promotions[i+1:]...)
+			return nil
+		}
+	}
+	return mongo.ErrNoDocuments
+}
+
+func (r *PromotionRepositoryMock) Use(id primitive.ObjectID) (bool, error) {
+	for i := range r.Promotions {
+		promotion := &r.Promotions[i]
+		if promotion.Id == id && (promotion.MaxUses == 0 || promotion.Uses < promotion.MaxUses) {
+			promotion.Uses++
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *PromotionRepositoryMock) Release(id primitive.ObjectID) error {
+	for i := range r.Promotions {
+		if r.Promotions[i].Id == id && r.Promotions[i].Uses > 0 {
+			r.Promotions[i].Uses--
+		}
+	}
+	return nil
+}
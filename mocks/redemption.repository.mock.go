@@ -0,0 +1,60 @@
+package mocks
+
+import (
+	"github.com/nicodeheza/peersEat/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type RedemptionRepositoryMock struct {
+	Redemptions []models.Redemption
+}
+
+func NewRedemptionRepositoryMock() *RedemptionRepositoryMock {
+	return &RedemptionRepositoryMock{}
+}
+
+func (r *RedemptionRepositoryMock) Insert(redemption models.Redemption) (primitive.ObjectID, error) {
+	redemption.Id = primitive.NewObjectID()
+	r.Redemptions = append(r.Redemptions, redemption)
+	return redemption.Id, nil
+}
+
+func (r *RedemptionRepositoryMock) FindOne(query map[string]interface{}) (models.Redemption, error) {
+	for _, redemption := range r.Redemptions {
+		if restaurantId, ok := query["restaurantId"]; ok && redemption.RestaurantId != restaurantId {
+			continue
+		}
+		if hash, ok := query["reviewTokenHash"]; ok && redemption.ReviewTokenHash != hash {
+			continue
+		}
+		return redemption, nil
+	}
+	return models.Redemption{}, mongo.ErrNoDocuments
+}
+
+func appliedPromotion(redemption models.Redemption, promotionId interface{}) bool {
+	for _, id := range redemption.PromotionIds {
+		if id == promotionId {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RedemptionRepositoryMock) Count(query map[string]interface{}) (int64, error) {
+	var count int64
+	for _, redemption := range r.Redemptions {
+		if customerId, ok := query["customerId"]; ok && redemption.CustomerId != customerId {
+			continue
+		}
+		if restaurantId, ok := query["restaurantId"]; ok && redemption.RestaurantId != restaurantId {
+			continue
+		}
+		if promotionId, ok := query["promotionIds"]; ok && !appliedPromotion(redemption, promotionId) {
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
@@ -0,0 +1,38 @@
+package mocks
+
+import (
+	"github.com/nicodeheza/peersEat/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type RemoteRestaurantStatusRepositoryMock struct {
+	Statuses map[primitive.ObjectID]models.RemoteRestaurantStatus
+}
+
+func NewRemoteRestaurantStatusRepositoryMock() *RemoteRestaurantStatusRepositoryMock {
+	return &RemoteRestaurantStatusRepositoryMock{Statuses: map[primitive.ObjectID]models.RemoteRestaurantStatus{}}
+}
+
+func (r *RemoteRestaurantStatusRepositoryMock) Upsert(status models.RemoteRestaurantStatus) error {
+	r.Statuses[status.RestaurantId] = status
+	return nil
+}
+
+func (r *RemoteRestaurantStatusRepositoryMock) FindOne(id primitive.ObjectID) (models.RemoteRestaurantStatus, error) {
+	status, ok := r.Statuses[id]
+	if !ok {
+		return models.RemoteRestaurantStatus{}, mongo.ErrNoDocuments
+	}
+	return status, nil
+}
+
+func (r *RemoteRestaurantStatusRepositoryMock) FindMany(ids []primitive.ObjectID) ([]models.RemoteRestaurantStatus, error) {
+	results := []models.RemoteRestaurantStatus{}
+	for _, id := range ids {
+		if status, ok := r.Statuses[id]; ok {
+			results = append(results, status)
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,117 @@
+package mocks
+
+import (
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/repositories"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type ReplicationRepositoryMock struct {
+	Links    []models.ReplicationLink
+	Replicas []models.RestaurantReplica
+}
+
+func NewReplicationRepositoryMock() *ReplicationRepositoryMock {
+	return &ReplicationRepositoryMock{}
+}
+
+func (r *ReplicationRepositoryMock) FindLink(url string, role string) (models.ReplicationLink, error) {
+	for _, link := range r.Links {
+		if link.Url == url && link.Role == role {
+			return link, nil
+		}
+	}
+	return models.ReplicationLink{}, mongo.ErrNoDocuments
+}
+
+func (r *ReplicationRepositoryMock) FindLinks(role string) ([]models.ReplicationLink, error) {
+	links := []models.ReplicationLink{}
+	for _, link := range r.Links {
+		if link.Role == role {
+			links = append(links, link)
+		}
+	}
+	return links, nil
+}
+
+func (r *ReplicationRepositoryMock) UpsertLink(link models.ReplicationLink) error {
+	for i, stored := range r.Links {
+		if stored.Url == link.Url && stored.Role == link.Role {
+			r.Links[i] = link
+			return nil
+		}
+	}
+	r.Links = append(r.Links, link)
+	return nil
+}
+
+func (r *ReplicationRepositoryMock) DeleteLink(url string, role string) error {
+	links := []models.ReplicationLink{}
+	for _, link := range r.Links {
+		if link.Url != url || link.Role != role {
+			links = append(links, link)
+		}
+	}
+	r.Links = links
+	return nil
+}
+
+func (r *ReplicationRepositoryMock) UpsertReplica(replica models.RestaurantReplica) error {
+	for i, stored := range r.Replicas {
+		if stored.Id == replica.Id {
+			if stored.Origin != replica.Origin {
+				return repositories.ErrReplicaOtherOrigin
+			}
+			r.Replicas[i] = replica
+			return nil
+		}
+	}
+	r.Replicas = append(r.Replicas, replica)
+	return nil
+}
+
+func (r *ReplicationRepositoryMock) DeleteReplica(id primitive.ObjectID, origin string) error {
+	replicas := []models.RestaurantReplica{}
+	for _, replica := range r.Replicas {
+		if replica.Id != id || replica.Origin != origin {
+			replicas = append(replicas, replica)
+		}
+	}
+	r.Replicas = replicas
+	return nil
+}
+
+func (r *ReplicationRepositoryMock) DeleteReplicasNotIn(origin string, ids []primitive.ObjectID) error {
+	replicas := []models.RestaurantReplica{}
+	for _, replica := range r.Replicas {
+		keep := replica.Origin != origin
+		for _, id := range ids {
+			keep = keep || replica.Id == id
+		}
+		if keep {
+			replicas = append(replicas, replica)
+		}
+	}
+	r.Replicas = replicas
+	return nil
+}
+
+func (r *ReplicationRepositoryMock) FindReplica(id primitive.ObjectID) (models.RestaurantReplica, error) {
+	for _, replica := range r.Replicas {
+		if replica.Id == id {
+			return replica, nil
+		}
+	}
+	return models.RestaurantReplica{}, mongo.ErrNoDocuments
+}
+
+func (r *ReplicationRepositoryMock) FindReplicasByOrigin(origin string) ([]models.RestaurantReplica, error) {
+	replicas := []models.RestaurantReplica{}
+	for _, replica := range r.Replicas {
+		if replica.Origin == origin {
+			replicas = append(replicas, replica)
+		}
+	}
+	return replicas, nil
+}
@@ -0,0 +1,59 @@
+package mocks
+
+import (
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ReplicationServiceMock struct {
+	Calls map[string][][]interface{}
+}
+
+func NewReplicationServiceMock() *ReplicationServiceMock {
+	calls := make(map[string][][]interface{})
+	return &ReplicationServiceMock{Calls: calls}
+}
+
+func (r *ReplicationServiceMock) Start() {
+	r.Calls["Start"] = append(r.Calls["Start"], []interface{}{})
+}
+
+func (r *ReplicationServiceMock) RestaurantChanged(id primitive.ObjectID) {
+	r.Calls["RestaurantChanged"] = append(r.Calls["RestaurantChanged"], []interface{}{id})
+}
+
+func (r *ReplicationServiceMock) Sync() error {
+	r.Calls["Sync"] = append(r.Calls["Sync"], []interface{}{})
+	return nil
+}
+
+func (r *ReplicationServiceMock) GetHandshakeSecret(id string, token string) (string, error) {
+	r.Calls["GetHandshakeSecret"] = append(r.Calls["GetHandshakeSecret"], []interface{}{id, token})
+	return "", nil
+}
+
+func (r *ReplicationServiceMock) Subscribe(request types.ReplicationSubscribe) error {
+	r.Calls["Subscribe"] = append(r.Calls["Subscribe"], []interface{}{request})
+	return nil
+}
+
+func (r *ReplicationServiceMock) ReceiveBatch(from string, signature string, body []byte) error {
+	r.Calls["ReceiveBatch"] = append(r.Calls["ReceiveBatch"], []interface{}{from, signature, body})
+	return nil
+}
+
+func (r *ReplicationServiceMock) GetReplica(id primitive.ObjectID) (models.Restaurant, error) {
+	r.Calls["GetReplica"] = append(r.Calls["GetReplica"], []interface{}{id})
+	return models.Restaurant{}, nil
+}
+
+func (r *ReplicationServiceMock) GetReplicas(origin string) ([]models.Restaurant, error) {
+	r.Calls["GetReplicas"] = append(r.Calls["GetReplicas"], []interface{}{origin})
+	return []models.Restaurant{}, nil
+}
+
+func (r *ReplicationServiceMock) Promote(origin string, force bool) ([]string, error) {
+	r.Calls["Promote"] = append(r.Calls["Promote"], []interface{}{origin, force})
+	return []string{}, nil
+}
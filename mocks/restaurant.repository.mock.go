@@ -5,9 +5,27 @@ import (
 
 	"github.com/nicodeheza/peersEat/models"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
+type ExpectRestaurantUpdate struct {
+	Id      primitive.ObjectID
+	Updates map[string]interface{}
+}
+
 type RestaurantRepositoryMock struct {
+	Restaurants []models.Restaurant
+	UpdateCalls []ExpectRestaurantUpdate
+	InsertCalls []models.Restaurant
+	DeleteCalls []primitive.ObjectID
+	MenuCalls   []ExpectMenuUpdate
+}
+
+type ExpectMenuUpdate struct {
+	Method string
+	Id     primitive.ObjectID
+	Path   models.MenuPath
+	Value  interface{}
 }
 
 func NewRestaurantRepositoryMock() *RestaurantRepositoryMock {
@@ -15,12 +33,109 @@ func NewRestaurantRepositoryMock() *RestaurantRepositoryMock {
 }
 
 func (r *RestaurantRepositoryMock) Insert(restaurant models.Restaurant) (id primitive.ObjectID, err error) {
-	return primitive.ObjectID{}, errors.New("not implemented")
+	r.InsertCalls = append(r.InsertCalls, restaurant)
+	if restaurant.Id.IsZero() {
+		return primitive.ObjectID{}, errors.New("not implemented")
+	}
+	return restaurant.Id, nil
 }
 
 func (r *RestaurantRepositoryMock) FindOne(query map[string]interface{}) (models.Restaurant, error) {
-	return models.Restaurant{}, errors.New("not implemented")
+	for _, restaurant := range r.Restaurants {
+		if userName, ok := query["userName"]; ok && restaurant.UserName == userName {
+			return restaurant, nil
+		}
+		if hash, ok := query["setupTokenHash"]; ok && restaurant.SetupTokenHash == hash {
+			return restaurant, nil
+		}
+		if restaurant.Id == query["_id"] {
+			return restaurant, nil
+		}
+	}
+	return models.Restaurant{}, mongo.ErrNoDocuments
 }
+
+// only the "_id" $in filter is applied, the rest return every restaurant
+func (r *RestaurantRepositoryMock) FindMany(query map[string]interface{}) ([]models.Restaurant, error) {
+	in, ok := query["_id"].(map[string]interface{})
+	if !ok {
+		return r.Restaurants, nil
+	}
+	result := []models.Restaurant{}
+	for _, restaurant := range r.Restaurants {
+		for _, id := range in["$in"].([]primitive.ObjectID) {
+			if restaurant.Id == id {
+				result = append(result, restaurant)
+			}
+		}
+	}
+	return result, nil
+}
+
 func (r *RestaurantRepositoryMock) Update(id primitive.ObjectID, updates map[string]interface{}) error {
+	r.UpdateCalls = append(r.UpdateCalls, ExpectRestaurantUpdate{id, updates})
+	return nil
+}
+
+// only the setup fields are matched and applied, besides recording the call
+func (r *RestaurantRepositoryMock) UpdateWhere(id primitive.ObjectID, query map[string]interface{}, set map[string]interface{}, unset []string) (bool, error) {
+	for i := range r.Restaurants {
+		restaurant := &r.Restaurants[i]
+		if restaurant.Id != id {
+			continue
+		}
+		if hash, ok := query["setupTokenHash"]; ok && restaurant.SetupTokenHash != hash {
+			return false, nil
+		}
+		if isFinal, ok := query["isFinalPassword"]; ok && restaurant.IsFinalPassword != isFinal {
+			return false, nil
+		}
+		r.UpdateCalls = append(r.UpdateCalls, ExpectRestaurantUpdate{id, set})
+		if isFinal, ok := set["isFinalPassword"].(bool); ok {
+			restaurant.IsFinalPassword = isFinal
+		}
+		for _, field := range unset {
+			if field == "setupTokenHash" {
+				restaurant.SetupTokenHash = ""
+			}
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+func (r *RestaurantRepositoryMock) IncrementRate(id primitive.ObjectID, stars int, votes int) error {
+	for i := range r.Restaurants {
+		if r.Restaurants[i].Id == id {
+			r.Restaurants[i].Rate.Stars = uint(int(r.Restaurants[i].Rate.Stars) + stars)
+			r.Restaurants[i].Rate.Votes = uint(int(r.Restaurants[i].Rate.Votes) + votes)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (r *RestaurantRepositoryMock) Delete(id primitive.ObjectID) error {
+	r.DeleteCalls = append(r.DeleteCalls, id)
+	return nil
+}
+
+func (r *RestaurantRepositoryMock) PushMenuItem(id primitive.ObjectID, path models.MenuPath, item interface{}) error {
+	r.MenuCalls = append(r.MenuCalls, ExpectMenuUpdate{"PushMenuItem", id, path, item})
+	return nil
+}
+
+func (r *RestaurantRepositoryMock) UpdateMenuItem(id primitive.ObjectID, path models.MenuPath, updates map[string]interface{}) error {
+	r.MenuCalls = append(r.MenuCalls, ExpectMenuUpdate{"UpdateMenuItem", id, path, updates})
+	return nil
+}
+
+func (r *RestaurantRepositoryMock) PullMenuItem(id primitive.ObjectID, path models.MenuPath) error {
+	r.MenuCalls = append(r.MenuCalls, ExpectMenuUpdate{"PullMenuItem", id, path, nil})
+	return nil
+}
+
+func (r *RestaurantRepositoryMock) SetMenuItems(id primitive.ObjectID, path models.MenuPath, items interface{}) error {
+	r.MenuCalls = append(r.MenuCalls, ExpectMenuUpdate{"SetMenuItems", id, path, items})
 	return nil
 }
@@ -1,6 +1,8 @@
 package mocks
 
 import (
+	"time"
+
 	"errors"
 
 	"github.com/nicodeheza/peersEat/models"
@@ -23,17 +25,29 @@ func (r *RestaurantServiceMock) CompleteRestaurantInitialData(newRestaurant *mod
 	} else {
 		newRestaurant.Coord = models.GeoCoords{Long: 1, Lat: 1}
 	}
-	newRestaurant.Password = "testHash"
 	newRestaurant.UserName = "testUsername"
 	newRestaurant.IsFinalPassword = false
-	return "testPassword", nil
+	newRestaurant.SetupTokenHash = "testHash"
+	newRestaurant.Status = models.RESTAURANT_DRAFT
+	return "testToken", nil
 }
 
 func (r *RestaurantServiceMock) AddNewRestaurant(newRestaurant models.Restaurant) (primitive.ObjectID, error) {
 	r.Calls["AddNewRestaurant"] = append(r.Calls["AddNewRestaurant"], []interface{}{newRestaurant})
 	return primitive.ObjectID{}, nil
 }
+func (r *RestaurantServiceMock) NewSetupToken(id primitive.ObjectID) (string, error) {
+	r.Calls["NewSetupToken"] = append(r.Calls["NewSetupToken"], []interface{}{id})
+	return "testToken", nil
+}
+
+func (r *RestaurantServiceMock) SetupAccount(data types.RestaurantSetupData) error {
+	r.Calls["SetupAccount"] = append(r.Calls["SetupAccount"], []interface{}{data})
+	return nil
+}
+
 func (r *RestaurantServiceMock) UpdateRestaurantUsernameAndPassword(id primitive.ObjectID, newPassword string, newUserNames string) error {
+	r.Calls["UpdateRestaurantUsernameAndPassword"] = append(r.Calls["UpdateRestaurantUsernameAndPassword"], []interface{}{id, newPassword, newUserNames})
 	if newPassword == "error" {
 		return errors.New("test error")
 	}
@@ -44,6 +58,51 @@ func (r *RestaurantServiceMock) Authenticate(password, userName string) (bool, s
 	return true, "testId", nil
 }
 
-func (r *RestaurantServiceMock) UpdateData(data types.RestaurantData) (bool, models.GeoCoords, float64, error) {
+func (r *RestaurantServiceMock) UpdateData(id primitive.ObjectID, data types.RestaurantData) (bool, models.GeoCoords, float64, error) {
+	r.Calls["UpdateData"] = append(r.Calls["UpdateData"], []interface{}{id, data})
 	return false, models.GeoCoords{}, 0, nil
 }
+
+func (r *RestaurantServiceMock) GetById(id primitive.ObjectID) (models.Restaurant, error) {
+	return models.Restaurant{Id: id}, nil
+}
+
+func (r *RestaurantServiceMock) UpdateAddress(id primitive.ObjectID, data types.RestaurantAddressData, selfPeer models.Peer) (models.Restaurant, error) {
+	r.Calls["UpdateAddress"] = append(r.Calls["UpdateAddress"], []interface{}{id, data})
+	return models.Restaurant{Id: id, Address: data.Address, City: data.City, Country: data.Country}, nil
+}
+
+func (r *RestaurantServiceMock) OverrideCoord(id primitive.ObjectID, coord models.GeoCoords, selfPeer models.Peer) (models.Restaurant, error) {
+	r.Calls["OverrideCoord"] = append(r.Calls["OverrideCoord"], []interface{}{id, coord})
+	return models.Restaurant{Id: id, Coord: coord, IsCoordOverridden: true}, nil
+}
+
+func (r *RestaurantServiceMock) ClearCoordOverride(id primitive.ObjectID, selfPeer models.Peer) (models.Restaurant, error) {
+	r.Calls["ClearCoordOverride"] = append(r.Calls["ClearCoordOverride"], []interface{}{id})
+	return models.Restaurant{Id: id, Coord: models.GeoCoords{Long: 1, Lat: 1}}, nil
+}
+
+func (r *RestaurantServiceMock) UpdateOpeningHours(id primitive.ObjectID, data types.OpeningHoursData) (models.OpeningHours, error) {
+	r.Calls["UpdateOpeningHours"] = append(r.Calls["UpdateOpeningHours"], []interface{}{id, data})
+	return models.OpeningHours{Timezone: data.Timezone}, nil
+}
+
+func (r *RestaurantServiceMock) IsOpenAt(id primitive.ObjectID, t time.Time) (bool, error) {
+	r.Calls["IsOpenAt"] = append(r.Calls["IsOpenAt"], []interface{}{id, t})
+	return true, nil
+}
+
+func (r *RestaurantServiceMock) NextOpening(id primitive.ObjectID, t time.Time) (time.Time, bool, error) {
+	r.Calls["NextOpening"] = append(r.Calls["NextOpening"], []interface{}{id, t})
+	return t.Add(time.Hour), true, nil
+}
+
+func (r *RestaurantServiceMock) List(query types.RestaurantListQuery, at time.Time) ([]types.PublicRestaurant, error) {
+	r.Calls["List"] = append(r.Calls["List"], []interface{}{query, at})
+	return []types.PublicRestaurant{}, nil
+}
+
+func (r *RestaurantServiceMock) ChangeStatus(id primitive.ObjectID, status string, reason string, by string) (models.Restaurant, error) {
+	r.Calls["ChangeStatus"] = append(r.Calls["ChangeStatus"], []interface{}{id, status, reason, by})
+	return models.Restaurant{Id: id, Status: status, StatusHistory: []models.StatusChange{{To: status, Reason: reason, By: by}}}, nil
+}
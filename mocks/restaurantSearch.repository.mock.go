@@ -0,0 +1,64 @@
+package mocks
+
+import (
+	"strings"
+
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/repositories"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type RestaurantSearchRepositoryMock struct {
+	Documents map[primitive.ObjectID]models.RestaurantSearch
+}
+
+func NewRestaurantSearchRepositoryMock() *RestaurantSearchRepositoryMock {
+	return &RestaurantSearchRepositoryMock{map[primitive.ObjectID]models.RestaurantSearch{}}
+}
+
+func (r *RestaurantSearchRepositoryMock) Upsert(search models.RestaurantSearch) error {
+	r.Documents[search.RestaurantId] = search
+	return nil
+}
+
+func (r *RestaurantSearchRepositoryMock) Delete(restaurantId primitive.ObjectID) error {
+	delete(r.Documents, restaurantId)
+	return nil
+}
+
+func (r *RestaurantSearchRepositoryMock) Match(city string, terms []repositories.SearchTerm) ([]primitive.ObjectID, error) {
+	ids := []primitive.ObjectID{}
+	for id, search := range r.Documents {
+		if city != "" && !strings.EqualFold(search.City, city) {
+			continue
+		}
+		matched := true
+		for _, term := range terms {
+			matched = matched && (hasAny(search.Keys, term.Keys) || term.Prefix && hasPrefix(search.Words, term.Word))
+		}
+		if matched {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func hasAny(values []string, wanted []string) bool {
+	for _, value := range values {
+		for _, w := range wanted {
+			if value == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasPrefix(words []string, prefix string) bool {
+	for _, word := range words {
+		if strings.HasPrefix(word, prefix) {
+			return true
+		}
+	}
+	return false
+}
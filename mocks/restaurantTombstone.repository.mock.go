@@ -0,0 +1,49 @@
+package mocks
+
+import (
+	"github.com/nicodeheza/peersEat/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type RestaurantTombstoneRepositoryMock struct {
+	Tombstones []models.RestaurantTombstone
+}
+
+func NewRestaurantTombstoneRepositoryMock() *RestaurantTombstoneRepositoryMock {
+	return &RestaurantTombstoneRepositoryMock{}
+}
+
+func (r *RestaurantTombstoneRepositoryMock) Upsert(tombstone models.RestaurantTombstone) error {
+	r.Tombstones = append(r.Tombstones, tombstone)
+	return nil
+}
+
+func (r *RestaurantTombstoneRepositoryMock) FindOne(query map[string]interface{}) (models.RestaurantTombstone, error) {
+	for _, tombstone := range r.Tombstones {
+		if tombstone.RestaurantId == query["restaurantId"] || tombstone.UserName != "" && tombstone.UserName == query["userName"] {
+			return tombstone, nil
+		}
+		for _, userName := range tombstone.StaffUserNames {
+			if userName == query["staffUserNames"] {
+				return tombstone, nil
+			}
+		}
+	}
+	return models.RestaurantTombstone{}, mongo.ErrNoDocuments
+}
+
+func (r *RestaurantTombstoneRepositoryMock) DeleteByRestaurantIds(ids []primitive.ObjectID) error {
+	remaining := []models.RestaurantTombstone{}
+	for _, tombstone := range r.Tombstones {
+		var deleted bool
+		for _, id := range ids {
+			deleted = deleted || tombstone.RestaurantId == id
+		}
+		if !deleted {
+			remaining = append(remaining, tombstone)
+		}
+	}
+	r.Tombstones = remaining
+	return nil
+}
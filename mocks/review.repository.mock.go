@@ -0,0 +1,115 @@
+package mocks
+
+import (
+	"sort"
+
+	"github.com/nicodeheza/peersEat/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type ReviewRepositoryMock struct {
+	Reviews []models.Review
+}
+
+func NewReviewRepositoryMock() *ReviewRepositoryMock {
+	return &ReviewRepositoryMock{}
+}
+
+func (r *ReviewRepositoryMock) Insert(review models.Review) (primitive.ObjectID, error) {
+	review.Id = primitive.NewObjectID()
+	r.Reviews = append(r.Reviews, review)
+	return review.Id, nil
+}
+
+func (r *ReviewRepositoryMock) FindOne(query map[string]interface{}) (models.Review, error) {
+	for _, review := range r.Reviews {
+		if id, ok := query["_id"]; ok && review.Id != id {
+			continue
+		}
+		if restaurantId, ok := query["restaurantId"]; ok && review.RestaurantId != restaurantId {
+			continue
+		}
+		if customerId, ok := query["customerId"]; ok && review.CustomerId != customerId {
+			continue
+		}
+		if orderId, ok := query["orderId"]; ok && review.OrderId != orderId {
+			continue
+		}
+		return review, nil
+	}
+	return models.Review{}, mongo.ErrNoDocuments
+}
+
+func (r *ReviewRepositoryMock) FindPage(restaurantId primitive.ObjectID, order string, skip int64, limit int64) ([]models.Review, error) {
+	reviews := []models.Review{}
+	for _, review := range r.Reviews {
+		if review.RestaurantId == restaurantId {
+			reviews = append(reviews, review)
+		}
+	}
+	sort.SliceStable(reviews, func(i, j int) bool {
+		if order == models.REVIEW_SORT_HELPFUL && reviews[i].Helpful != reviews[j].Helpful {
+			return reviews[i].Helpful > reviews[j].Helpful
+		}
+		return reviews[i].CreatedAt.After(reviews[j].CreatedAt)
+	})
+
+	if skip >= int64(len(reviews)) {
+		return []models.Review{}, nil
+	}
+	end := skip + limit
+	if end > int64(len(reviews)) {
+		end = int64(len(reviews))
+	}
+	return reviews[skip:end], nil
+}
+
+func (r *ReviewRepositoryMock) Count(restaurantId primitive.ObjectID) (int64, error) {
+	var count int64
+	for _, review := range r.Reviews {
+		if review.RestaurantId == restaurantId {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *ReviewRepositoryMock) Update(id primitive.ObjectID, updates map[string]interface{}) error {
+	for i := range r.Reviews {
+		if r.Reviews[i].Id != id {
+			continue
+		}
+		for key, value := range updates {
+			switch key {
+			case "stars":
+				r.Reviews[i].Stars = value.(uint)
+			case "text":
+				r.Reviews[i].Text = value.(string)
+			case "dishes":
+				r.Reviews[i].Dishes = value.([]models.DishRating)
+			case "reply":
+				r.Reviews[i].Reply = value.(*models.ReviewReply)
+			}
+		}
+		return nil
+	}
+	return mongo.ErrNoDocuments
+}
+
+func (r *ReviewRepositoryMock) MarkHelpful(id primitive.ObjectID, orderId string) (bool, error) {
+	for i := range r.Reviews {
+		if r.Reviews[i].Id != id {
+			continue
+		}
+		for _, marked := range r.Reviews[i].HelpfulBy {
+			if marked == orderId {
+				return false, nil
+			}
+		}
+		r.Reviews[i].HelpfulBy = append(r.Reviews[i].HelpfulBy, orderId)
+		r.Reviews[i].Helpful++
+		return true, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,40 @@
+package mocks
+
+import (
+	"github.com/nicodeheza/peersEat/types"
+)
+
+type SearchServiceMock struct {
+	Calls map[string][][]interface{}
+	Err   error
+}
+
+func NewSearchServiceMock() *SearchServiceMock {
+	calls := make(map[string][][]interface{})
+	return &SearchServiceMock{Calls: calls}
+}
+
+func (s *SearchServiceMock) Search(query types.SearchQuery) (types.SearchResult, error) {
+	s.Calls["Search"] = append(s.Calls["Search"], []interface{}{query})
+	return types.SearchResult{Restaurants: []types.SearchHit{}, FailedPeers: []string{}}, s.Err
+}
+
+func (s *SearchServiceMock) LocalSearch(query types.SearchQuery) ([]types.SearchHit, error) {
+	s.Calls["LocalSearch"] = append(s.Calls["LocalSearch"], []interface{}{query})
+	return []types.SearchHit{}, s.Err
+}
+
+type TextSearchServiceMock struct {
+	Calls map[string][][]interface{}
+	Err   error
+}
+
+func NewTextSearchServiceMock() *TextSearchServiceMock {
+	calls := make(map[string][][]interface{})
+	return &TextSearchServiceMock{Calls: calls}
+}
+
+func (s *TextSearchServiceMock) Search(query types.TextSearchQuery) (types.TextSearchResult, error) {
+	s.Calls["Search"] = append(s.Calls["Search"], []interface{}{query})
+	return types.TextSearchResult{Results: []types.TextSearchHit{}, Page: query.Page, PageSize: query.PageSize}, s.Err
+}
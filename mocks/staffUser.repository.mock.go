@@ -0,0 +1,115 @@
+package mocks
+
+import (
+	"github.com/nicodeheza/peersEat/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type StaffUserRepositoryMock struct {
+	Users []models.StaffUser
+}
+
+func NewStaffUserRepositoryMock() *StaffUserRepositoryMock {
+	return &StaffUserRepositoryMock{}
+}
+
+func (r *StaffUserRepositoryMock) Insert(user models.StaffUser) (primitive.ObjectID, error) {
+	user.Id = primitive.NewObjectID()
+	r.Users = append(r.Users, user)
+	return user.Id, nil
+}
+
+func (r *StaffUserRepositoryMock) FindOne(query map[string]interface{}) (models.StaffUser, error) {
+	for _, user := range r.Users {
+		if id, ok := query["_id"]; ok && user.Id != id {
+			continue
+		}
+		if restaurantId, ok := query["restaurantId"]; ok && user.RestaurantId != restaurantId {
+			continue
+		}
+		if userName, ok := query["userName"]; ok && user.UserName != userName {
+			continue
+		}
+		if tokenHash, ok := query["inviteTokenHash"]; ok && user.InviteTokenHash != tokenHash {
+			continue
+		}
+		return user, nil
+	}
+	return models.StaffUser{}, mongo.ErrNoDocuments
+}
+
+func (r *StaffUserRepositoryMock) FindMany(restaurantId primitive.ObjectID) ([]models.StaffUser, error) {
+	result := []models.StaffUser{}
+	for _, user := range r.Users {
+		if user.RestaurantId == restaurantId {
+			result = append(result, user)
+		}
+	}
+	return result, nil
+}
+
+func (r *StaffUserRepositoryMock) Update(id primitive.ObjectID, set map[string]interface{}, unset []string) error {
+	for i, user := range r.Users {
+		if user.Id != id {
+			continue
+		}
+		for key, value := range set {
+			switch key {
+			case "name":
+				user.Name = value.(string)
+			case "role":
+				user.Role = value.(string)
+			case "userName":
+				user.UserName = value.(string)
+			case "password":
+				user.Password = value.(string)
+			case "isPending":
+				user.IsPending = value.(bool)
+			}
+		}
+		for _, key := range unset {
+			switch key {
+			case "inviteTokenHash":
+				user.InviteTokenHash = ""
+			case "inviteExpiresAt":
+				user.InviteExpiresAt = nil
+			}
+		}
+		r.Users[i] = user
+		return nil
+	}
+	return mongo.ErrNoDocuments
+}
+
+func (r *StaffUserRepositoryMock) Delete(id primitive.ObjectID) error {
+	for i, user := range r.Users {
+		if user.Id == id {
+			r.Users = append(r.Users[:i], r.Users[i+1:]...)
+			return nil
+		}
+	}
+	return mongo.ErrNoDocuments
+}
+
+func (r *StaffUserRepositoryMock) Upsert(user models.StaffUser) error {
+	for i := range r.Users {
+		if r.Users[i].Id == user.Id {
+			r.Users[i] = user
+			return nil
+		}
+	}
+	r.Users = append(r.Users, user)
+	return nil
+}
+
+func (r *StaffUserRepositoryMock) DeleteByRestaurant(restaurantId primitive.ObjectID) error {
+	remaining := []models.StaffUser{}
+	for _, user := range r.Users {
+		if user.RestaurantId != restaurantId {
+			remaining = append(remaining, user)
+		}
+	}
+	r.Users = remaining
+	return nil
+}
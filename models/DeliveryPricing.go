@@ -0,0 +1,130 @@
+package models
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/nicodeheza/peersEat/config"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DistanceTier charges PerKm for every km up to UpToKm, distances beyond the
+// last tier are charged at its rate
+type DistanceTier struct {
+	UpToKm float64 `bson:"upToKm" json:"upToKm"`
+	PerKm  Money   `bson:"perKm" json:"perKm"`
+}
+
+// PeakWindow multiplies the fee while it's active, in the restaurant timezone
+type PeakWindow struct {
+	AvailabilityWindow `bson:",inline"`
+	Multiplier         float64 `bson:"multiplier" json:"multiplier"`
+}
+
+// zero MinOrder or FreeAbove means there is none
+type DeliveryPricing struct {
+	BaseFee   Money          `bson:"baseFee" json:"baseFee"`
+	Tiers     []DistanceTier `bson:"tiers,omitempty" json:"tiers,omitempty"`
+	MinOrder  Money          `bson:"minOrder,omitempty" json:"minOrder,omitempty"`
+	FreeAbove Money          `bson:"freeAbove,omitempty" json:"freeAbove,omitempty"`
+	Peaks     []PeakWindow   `bson:"peaks,omitempty" json:"peaks,omitempty"`
+}
+
+// DistanceFee is rounded once to the minor unit after adding up the tiers
+func (p DeliveryPricing) DistanceFee(km float64, currency string) Money {
+	fee, from := 0.0, 0.0
+	for i, tier := range p.Tiers {
+		to := tier.UpToKm
+		if i == len(p.Tiers)-1 || km < to {
+			to = km
+		}
+		if to > from {
+			fee += (to - from) * float64(tier.PerKm.Amount)
+		}
+		if to >= km {
+			break
+		}
+		from = to
+	}
+	return Money{int64(math.Round(fee)), currency}
+}
+
+// InCurrency gives a currency to the amounts stored before currencies existed,
+// it tells if any amount changed
+func (p *DeliveryPricing) InCurrency(currency string) bool {
+	changed := false
+	for _, money := range p.Amounts() {
+		changed = setCurrency(money, currency) || changed
+	}
+	return changed
+}
+
+// Amounts points to every amount of the pricing
+func (p *DeliveryPricing) Amounts() []*Money {
+	amounts := []*Money{&p.BaseFee, &p.MinOrder, &p.FreeAbove}
+	for i := range p.Tiers {
+		amounts = append(amounts, &p.Tiers[i].PerKm)
+	}
+	return amounts
+}
+
+// Multiplier is the highest one of the peaks active at t, 1 when there is none
+func (p DeliveryPricing) Multiplier(t time.Time) float64 {
+	multiplier := 1.0
+	for _, peak := range p.Peaks {
+		if peak.Contains(t) && peak.Multiplier > multiplier {
+			multiplier = peak.Multiplier
+		}
+	}
+	return multiplier
+}
+
+// Pricing returns the delivery pricing, or the one built from DeliveryCost which
+// is a fixed fee or a price per km, in the restaurant currency
+func (r Restaurant) Pricing() DeliveryPricing {
+	var pricing DeliveryPricing
+	switch {
+	case r.DeliveryPricing != nil:
+		pricing = *r.DeliveryPricing
+		pricing.Tiers = append([]DistanceTier{}, pricing.Tiers...)
+	case r.IsDeliveryFixCost:
+		pricing = DeliveryPricing{BaseFee: r.DeliveryCost}
+	default:
+		pricing = DeliveryPricing{Tiers: []DistanceTier{{UpToKm: r.DeliveryRadius, PerKm: r.DeliveryCost}}}
+	}
+	pricing.InCurrency(r.CurrencyOrDefault())
+	return pricing
+}
+
+// DeliveryQuote is the fee offered to a customer, it's never modified so an order
+// placed with it keeps the fee the customer saw
+type DeliveryQuote struct {
+	Id           primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	RestaurantId primitive.ObjectID `bson:"restaurantId" json:"restaurantId"`
+	Coord        GeoCoords          `bson:"coord" json:"coord"`
+	Subtotal     Money              `bson:"subtotal" json:"subtotal"`
+	DistanceKm   float64            `bson:"distanceKm" json:"distanceKm"`
+	BaseFee      Money              `bson:"baseFee" json:"baseFee"`
+	DistanceFee  Money              `bson:"distanceFee" json:"distanceFee"`
+	Multiplier   float64            `bson:"multiplier" json:"multiplier"`
+	FreeDelivery bool               `bson:"freeDelivery" json:"freeDelivery"`
+	Fee          Money              `bson:"fee" json:"fee"`
+	DeliverAt    time.Time          `bson:"deliverAt" json:"deliverAt"`
+	CreatedAt    time.Time          `bson:"createdAt" json:"createdAt"`
+	ExpiresAt    time.Time          `bson:"expiresAt" json:"expiresAt"`
+}
+
+func GetDeliveryQuoteColl(databaseName string) *mongo.Collection {
+	return config.GetDatabase(databaseName).Collection("deliveryQuotes")
+}
+
+func InitDeliveryQuoteModel(databaseName string) {
+	GetDeliveryQuoteColl(databaseName).Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+}
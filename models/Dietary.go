@@ -0,0 +1,158 @@
+package models
+
+// the 14 allergens EU regulation 1169/2011 requires to declare
+const ALLERGEN_GLUTEN = "gluten"
+const ALLERGEN_CRUSTACEANS = "crustaceans"
+const ALLERGEN_EGGS = "eggs"
+const ALLERGEN_FISH = "fish"
+const ALLERGEN_PEANUTS = "peanuts"
+const ALLERGEN_SOYBEANS = "soybeans"
+const ALLERGEN_MILK = "milk"
+const ALLERGEN_NUTS = "nuts"
+const ALLERGEN_CELERY = "celery"
+const ALLERGEN_MUSTARD = "mustard"
+const ALLERGEN_SESAME = "sesame"
+const ALLERGEN_SULPHITES = "sulphites"
+const ALLERGEN_LUPIN = "lupin"
+const ALLERGEN_MOLLUSCS = "molluscs"
+
+var ALLERGENS = []string{
+	ALLERGEN_GLUTEN, ALLERGEN_CRUSTACEANS, ALLERGEN_EGGS, ALLERGEN_FISH, ALLERGEN_PEANUTS,
+	ALLERGEN_SOYBEANS, ALLERGEN_MILK, ALLERGEN_NUTS, ALLERGEN_CELERY, ALLERGEN_MUSTARD,
+	ALLERGEN_SESAME, ALLERGEN_SULPHITES, ALLERGEN_LUPIN, ALLERGEN_MOLLUSCS,
+}
+
+const DIET_VEGAN = "vegan"
+const DIET_VEGETARIAN = "vegetarian"
+const DIET_HALAL = "halal"
+const DIET_GLUTEN_FREE = "glutenFree"
+
+var DIETARY_LABELS = []string{DIET_VEGAN, DIET_VEGETARIAN, DIET_HALAL, DIET_GLUTEN_FREE}
+
+// allergens a dish with the label can't contain
+var LABEL_EXCLUDED_ALLERGENS = map[string][]string{
+	DIET_VEGAN:       {ALLERGEN_EGGS, ALLERGEN_MILK, ALLERGEN_FISH, ALLERGEN_CRUSTACEANS, ALLERGEN_MOLLUSCS},
+	DIET_VEGETARIAN:  {ALLERGEN_FISH, ALLERGEN_CRUSTACEANS, ALLERGEN_MOLLUSCS},
+	DIET_GLUTEN_FREE: {ALLERGEN_GLUTEN},
+}
+
+func IsAllergen(value string) bool {
+	return contains(ALLERGENS, value)
+}
+
+func IsDietaryLabel(value string) bool {
+	return contains(DIETARY_LABELS, value)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Dietary is what a dish or option declares, MayContain are the allergens that
+// can be present as traces
+type Dietary struct {
+	Allergens     []string `bson:"allergens,omitempty" json:"allergens,omitempty"`
+	MayContain    []string `bson:"mayContain,omitempty" json:"mayContain,omitempty"`
+	DietaryLabels []string `bson:"dietaryLabels,omitempty" json:"dietaryLabels,omitempty"`
+}
+
+// DietaryFilter keeps the dishes with every Include label and none of the Exclude
+// allergens, ExcludeTraces also leaves out the ones that may contain them
+type DietaryFilter struct {
+	Include       []string
+	Exclude       []string
+	ExcludeTraces bool
+}
+
+func (f DietaryFilter) IsEmpty() bool {
+	return len(f.Include) == 0 && len(f.Exclude) == 0
+}
+
+func (f DietaryFilter) Allows(dietary Dietary) bool {
+	for _, label := range f.Include {
+		if !contains(dietary.DietaryLabels, label) {
+			return false
+		}
+	}
+	return f.avoids(dietary)
+}
+
+func (f DietaryFilter) avoids(dietary Dietary) bool {
+	for _, allergen := range f.Exclude {
+		if contains(dietary.Allergens, allergen) || (f.ExcludeTraces && contains(dietary.MayContain, allergen)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter keeps the dishes the filter allows without the options containing the
+// excluded allergens, labels only apply to dishes. Dishes left without enough
+// options for a group are dropped and so are empty sections
+func (m Menu) Filter(f DietaryFilter) Menu {
+	if f.IsEmpty() {
+		return m
+	}
+
+	filtered := Menu{}
+	for _, section := range m.Sections {
+		dishes := []Dish{}
+		for _, dish := range section.Dishes {
+			if !f.Allows(dish.Dietary) {
+				continue
+			}
+			groups, ok := f.filterGroups(dish.OptionGroups)
+			if !ok {
+				continue
+			}
+			dish.OptionGroups = groups
+
+			var options []DishOptions
+			for _, option := range dish.Options {
+				if f.avoids(option.Dietary) {
+					options = append(options, option)
+				}
+			}
+			dish.Options = options
+			dishes = append(dishes, dish)
+		}
+		if len(dishes) > 0 {
+			section.Dishes = dishes
+			filtered.Sections = append(filtered.Sections, section)
+		}
+	}
+	return filtered
+}
+
+func (f DietaryFilter) filterGroups(groups []OptionGroup) ([]OptionGroup, bool) {
+	if len(groups) == 0 {
+		return groups, true
+	}
+
+	filtered := []OptionGroup{}
+	for _, group := range groups {
+		options := []GroupOption{}
+		for _, option := range group.Options {
+			if !f.avoids(option.Dietary) {
+				continue
+			}
+			nested, ok := f.filterGroups(option.Groups)
+			if !ok {
+				continue
+			}
+			option.Groups = nested
+			options = append(options, option)
+		}
+		if uint(len(options)) < group.MinSelections() {
+			return nil, false
+		}
+		group.Options = options
+		filtered = append(filtered, group)
+	}
+	return filtered, true
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+const POLYGON = "Polygon"
+const MULTI_POLYGON = "MultiPolygon"
+
+// GeoJSON position, [long, lat]
+type GeoPosition []float64
+
+// GeoJSON linear ring, the first and last positions are the same
+type GeoRing []GeoPosition
+
+// GeoJSON polygon, the first ring is the exterior and the rest are holes
+type GeoPolygon []GeoRing
+
+// GeoZone is a GeoJSON Polygon or MultiPolygon. Polygons are stored as a
+// MultiPolygon of one element so they can be handled the same way.
+type GeoZone struct {
+	Type        string       `bson:"type" json:"type"`
+	Coordinates []GeoPolygon `bson:"coordinates" json:"coordinates"`
+}
+
+func NewGeoZone(polygons ...GeoPolygon) *GeoZone {
+	return &GeoZone{Type: MULTI_POLYGON, Coordinates: polygons}
+}
+
+func (z *GeoZone) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Type        string
+		Coordinates json.RawMessage
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	switch raw.Type {
+	case POLYGON:
+		var polygon GeoPolygon
+		if err := json.Unmarshal(raw.Coordinates, &polygon); err != nil {
+			return err
+		}
+		z.Coordinates = []GeoPolygon{polygon}
+	case MULTI_POLYGON:
+		var polygons []GeoPolygon
+		if err := json.Unmarshal(raw.Coordinates, &polygons); err != nil {
+			return err
+		}
+		z.Coordinates = polygons
+	default:
+		return errors.New("zone type must be Polygon or MultiPolygon")
+	}
+
+	z.Type = MULTI_POLYGON
+	return nil
+}
+
+func (p GeoPosition) ToCoords() GeoCoords {
+	return GeoCoords{Long: p[0], Lat: p[1]}
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/nicodeheza/peersEat/config"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const DEFAULT_GEOCODE_CACHE_TTL = 30 * 24 * time.Hour
+
+type GeocodeCache struct {
+	Id          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Query       string             `bson:"query" json:"query"`
+	Coord       GeoCoords          `bson:"coord" json:"coord"`
+	Provider    string             `bson:"provider" json:"provider"`
+	Confidence  float64            `bson:"confidence" json:"confidence"`
+	BoundingBox []float64          `bson:"boundingBox,omitempty" json:"boundingBox,omitempty"`
+	DisplayName string             `bson:"displayName,omitempty" json:"displayName,omitempty"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+func GetGeocodeCacheColl(databaseName string) *mongo.Collection {
+	return config.GetDatabase(databaseName).Collection("geocodeCache")
+}
+
+func GetGeocodeCacheTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("GEOCODE_CACHE_TTL"))
+	if err != nil || ttl <= 0 {
+		return DEFAULT_GEOCODE_CACHE_TTL
+	}
+	return ttl
+}
+
+func InitGeocodeCacheModel(databaseName string) {
+	// entries used to be unique by query only, each provider keeps its own now
+	GetGeocodeCacheColl(databaseName).Indexes().DropOne(context.Background(), "query_1")
+	GetGeocodeCacheColl(databaseName).Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "provider", Value: 1}, {Key: "query", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "createdAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(GetGeocodeCacheTTL().Seconds())),
+		},
+	})
+}
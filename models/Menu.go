@@ -1,24 +1,242 @@
 package models
 
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SoldOut and AvailableAt are filled on reads from the restaurant availability, they are never stored
 type DishOptions struct {
+	Id          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
 	Name        string
 	Description string
-	Price       float32
+	Price       Money
+	Dietary     `bson:",inline"`
+	SoldOut     bool       `bson:"-" json:"soldOut,omitempty"`
+	AvailableAt *time.Time `bson:"-" json:"availableAt,omitempty"`
 }
 
-type Dish struct {
+// GroupOption is a choice inside an OptionGroup, its own groups can only
+// be one level deep
+type GroupOption struct {
+	Id          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
 	Name        string
 	Description string
-	Price       float32
-	ImageUrl    string
-	Options     []DishOptions `bson:"options,omitempty" json:"options,omitempty"`
+	PriceDelta  Money
+	IsDefault   bool
+	Dietary     `bson:",inline"`
+	Groups      []OptionGroup `bson:"groups,omitempty" json:"groups,omitempty"`
+	SoldOut     bool          `bson:"-" json:"soldOut,omitempty"`
+	AvailableAt *time.Time    `bson:"-" json:"availableAt,omitempty"`
+}
+
+// OptionGroup limits how many of its options can be chosen, Max 0 means no limit
+type OptionGroup struct {
+	Id       primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Name     string
+	Min      uint
+	Max      uint
+	Required bool
+	Options  []GroupOption
+}
+
+// at least one option must be chosen in required groups
+func (g OptionGroup) MinSelections() uint {
+	if g.Required && g.Min == 0 {
+		return 1
+	}
+	return g.Min
+}
+
+type Dish struct {
+	Id           primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Name         string
+	Description  string
+	Price        Money
+	ImageUrl     string
+	Options      []DishOptions `bson:"options,omitempty" json:"options,omitempty"`
+	OptionGroups []OptionGroup `bson:"optionGroups,omitempty" json:"optionGroups,omitempty"`
+	Tags         []string      `bson:"tags,omitempty" json:"tags,omitempty"`
+	Dietary      `bson:",inline"`
+	SoldOut      bool       `bson:"-" json:"soldOut,omitempty"`
+	AvailableAt  *time.Time `bson:"-" json:"availableAt,omitempty"`
 }
 
 type MenuSection struct {
+	Id     primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
 	Name   string
-	Dishes []Dish
+	Dishes []Dish `bson:"dishes,omitempty"`
 }
 
 type Menu struct {
-	Sections []MenuSection
+	Sections []MenuSection `bson:"sections,omitempty"`
+}
+
+// MenuPath points to a menu entity, the ids are filled from the section down
+// and the deepest non zero id is the target
+type MenuPath struct {
+	SectionId primitive.ObjectID
+	DishId    primitive.ObjectID
+	OptionId  primitive.ObjectID
+}
+
+func (p MenuPath) Ids() []primitive.ObjectID {
+	ids := []primitive.ObjectID{}
+	for _, id := range []primitive.ObjectID{p.SectionId, p.DishId, p.OptionId} {
+		if id.IsZero() {
+			break
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// sets the missing ids, menus stored before the ids were added have none
+func (m *Menu) AssignIds() bool {
+	changed := false
+	for i := range m.Sections {
+		section := &m.Sections[i]
+		if section.Id.IsZero() {
+			section.Id = primitive.NewObjectID()
+			changed = true
+		}
+		for j := range section.Dishes {
+			dish := &section.Dishes[j]
+			if dish.Id.IsZero() {
+				dish.Id = primitive.NewObjectID()
+				changed = true
+			}
+			for k := range dish.Options {
+				if dish.Options[k].Id.IsZero() {
+					dish.Options[k].Id = primitive.NewObjectID()
+					changed = true
+				}
+			}
+			changed = assignGroupIds(dish.OptionGroups) || changed
+		}
+	}
+	return changed
+}
+
+func assignGroupIds(groups []OptionGroup) bool {
+	changed := false
+	for i := range groups {
+		group := &groups[i]
+		if group.Id.IsZero() {
+			group.Id = primitive.NewObjectID()
+			changed = true
+		}
+		for j := range group.Options {
+			if group.Options[j].Id.IsZero() {
+				group.Options[j].Id = primitive.NewObjectID()
+				changed = true
+			}
+			changed = assignGroupIds(group.Options[j].Groups) || changed
+		}
+	}
+	return changed
+}
+
+// InCurrency gives a currency to the prices stored before currencies existed,
+// it tells if any price changed
+func (m *Menu) InCurrency(currency string) bool {
+	changed := false
+	for i := range m.Sections {
+		for j := range m.Sections[i].Dishes {
+			dish := &m.Sections[i].Dishes[j]
+			changed = setCurrency(&dish.Price, currency) || changed
+			for k := range dish.Options {
+				changed = setCurrency(&dish.Options[k].Price, currency) || changed
+			}
+			changed = groupsInCurrency(dish.OptionGroups, currency) || changed
+		}
+	}
+	return changed
+}
+
+func groupsInCurrency(groups []OptionGroup, currency string) bool {
+	changed := false
+	for i := range groups {
+		for j := range groups[i].Options {
+			option := &groups[i].Options[j]
+			changed = setCurrency(&option.PriceDelta, currency) || changed
+			changed = groupsInCurrency(option.Groups, currency) || changed
+		}
+	}
+	return changed
+}
+
+// UnavailableItem marks a dish or option as sold out. It lasts until Until when
+// set, forever when Indefinite and otherwise until the next opening after MarkedAt
+type UnavailableItem struct {
+	ItemId     primitive.ObjectID `bson:"itemId" json:"itemId"`
+	Until      *time.Time         `bson:"until,omitempty" json:"until,omitempty"`
+	Indefinite bool               `bson:"indefinite,omitempty" json:"indefinite,omitempty"`
+	MarkedAt   time.Time          `bson:"markedAt" json:"markedAt"`
+}
+
+// MarkSoldOut flags the menu items found in unavailable with the time they are available again, nil if unknown
+func (m *Menu) MarkSoldOut(unavailable map[primitive.ObjectID]*time.Time) {
+	for i := range m.Sections {
+		for j := range m.Sections[i].Dishes {
+			dish := &m.Sections[i].Dishes[j]
+			dish.AvailableAt, dish.SoldOut = unavailable[dish.Id]
+			for k := range dish.Options {
+				option := &dish.Options[k]
+				option.AvailableAt, option.SoldOut = unavailable[option.Id]
+			}
+			markGroupsSoldOut(dish.OptionGroups, unavailable)
+		}
+	}
+}
+
+func markGroupsSoldOut(groups []OptionGroup, unavailable map[primitive.ObjectID]*time.Time) {
+	for i := range groups {
+		for j := range groups[i].Options {
+			option := &groups[i].Options[j]
+			option.AvailableAt, option.SoldOut = unavailable[option.Id]
+			markGroupsSoldOut(option.Groups, unavailable)
+		}
+	}
+}
+
+// HasDish reports if id is a dish of the menu, options don't count
+func (m Menu) HasDish(id primitive.ObjectID) bool {
+	for _, section := range m.Sections {
+		for _, dish := range section.Dishes {
+			if dish.Id == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasItem reports if id is a dish or an option of the menu
+func (m Menu) HasItem(id primitive.ObjectID) bool {
+	for _, section := range m.Sections {
+		for _, dish := range section.Dishes {
+			if dish.Id == id || groupsHaveItem(dish.OptionGroups, id) {
+				return true
+			}
+			for _, option := range dish.Options {
+				if option.Id == id {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func groupsHaveItem(groups []OptionGroup, id primitive.ObjectID) bool {
+	for _, group := range groups {
+		for _, option := range group.Options {
+			if option.Id == id || groupsHaveItem(option.Groups, id) {
+				return true
+			}
+		}
+	}
+	return false
 }
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nicodeheza/peersEat/config"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AvailabilityWindow is a daily time range in "15:04" format, End before Start
+// crosses midnight and an empty Days means every day (0 is Sunday)
+type AvailabilityWindow struct {
+	Days  []time.Weekday `bson:"days,omitempty" json:"days,omitempty"`
+	Start string         `bson:"start" json:"start"`
+	End   string         `bson:"end" json:"end"`
+}
+
+// MenuVersion is a published copy of a restaurant menu, it is never modified
+// so orders can point to the exact dishes and prices they were placed against
+type MenuVersion struct {
+	Id           primitive.ObjectID   `bson:"_id,omitempty" json:"id,omitempty"`
+	RestaurantId primitive.ObjectID   `bson:"restaurantId" json:"restaurantId"`
+	Name         string               `bson:"name" json:"name"`
+	Version      int                  `bson:"version" json:"version"`
+	Menu         Menu                 `bson:"menu" json:"menu"`
+	Windows      []AvailabilityWindow `bson:"windows,omitempty" json:"windows,omitempty"`
+	PublishedAt  time.Time            `bson:"publishedAt" json:"publishedAt"`
+	Archived     bool                 `bson:"archived,omitempty" json:"archived,omitempty"`
+}
+
+func minutesOfDay(clock string) (int, error) {
+	var hour, minute int
+	if _, err := fmt.Sscanf(clock, "%d:%d", &hour, &minute); err != nil {
+		return 0, err
+	}
+	return hour*60 + minute, nil
+}
+
+func (w AvailabilityWindow) hasDay(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// uses the location of t, a window with the same start and end lasts the whole day
+func (w AvailabilityWindow) Contains(t time.Time) bool {
+	start, err := minutesOfDay(w.Start)
+	if err != nil {
+		return false
+	}
+	end, err := minutesOfDay(w.End)
+	if err != nil {
+		return false
+	}
+	now := t.Hour()*60 + t.Minute()
+	yesterday := (t.Weekday() + 6) % 7
+
+	switch {
+	case start == end:
+		return w.hasDay(t.Weekday())
+	case start < end:
+		return w.hasDay(t.Weekday()) && now >= start && now < end
+	default:
+		return w.hasDay(t.Weekday()) && now >= start || w.hasDay(yesterday) && now < end
+	}
+}
+
+// a version without windows is available at any time
+func (v MenuVersion) IsAvailableAt(t time.Time) bool {
+	if len(v.Windows) == 0 {
+		return true
+	}
+	for _, window := range v.Windows {
+		if window.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func GetMenuVersionColl(databaseName string) *mongo.Collection {
+	return config.GetDatabase(databaseName).Collection("menuVersions")
+}
+
+func InitMenuVersionModel(databaseName string) {
+	GetMenuVersionColl(databaseName).Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "restaurantId", Value: 1}, {Key: "name", Value: 1}, {Key: "version", Value: -1}},
+		Options: options.Index().SetUnique(true),
+	})
+}
@@ -0,0 +1,185 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+const DEFAULT_CURRENCY = "USD"
+
+var ErrCurrencyMismatch = errors.New("currency mismatch")
+
+// currencies without 2 decimals, ISO 4217 minor units
+var currencyExponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"CLF": 4, "UYW": 4,
+}
+
+// DefaultCurrency is the currency of the restaurants of this peer, the CURRENCY
+// env variable sets it
+func DefaultCurrency() string {
+	currency := strings.ToUpper(os.Getenv("CURRENCY"))
+	if len(currency) != 3 {
+		return DEFAULT_CURRENCY
+	}
+	return currency
+}
+
+func CurrencyExponent(currency string) int {
+	if exponent, ok := currencyExponents[currency]; ok {
+		return exponent
+	}
+	return 2
+}
+
+// Money is an exact amount in the minor units of an ISO 4217 currency, cents
+// for USD. Amounts without a currency come from the float prices stored before
+// and are in hundredths, In gives them their currency
+type Money struct {
+	Amount   int64  `bson:"amount" json:"amount"`
+	Currency string `bson:"currency" json:"currency"`
+}
+
+func NewMoney(amount int64, currency string) Money {
+	return Money{amount, currency}
+}
+
+// MoneyFromMajor converts an amount in major units, like 12.5 dollars
+func MoneyFromMajor(amount float64, currency string) Money {
+	return Money{int64(math.Round(amount * math.Pow10(CurrencyExponent(currency)))), currency}
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// In sets the currency of the amounts that have none, others are left as they are
+func (m Money) In(currency string) Money {
+	if m.Currency != "" {
+		return m
+	}
+	exponent := CurrencyExponent(currency)
+	if exponent == 2 {
+		return Money{m.Amount, currency}
+	}
+	return Money{int64(math.Round(float64(m.Amount) * math.Pow10(exponent-2))), currency}
+}
+
+// As checks m is in the currency, amounts without one are converted to it
+func (m Money) As(currency string) (Money, error) {
+	m = m.In(currency)
+	if m.Currency != currency {
+		return Money{}, fmt.Errorf("%w: expected %v, got %v", ErrCurrencyMismatch, currency, m.Currency)
+	}
+	return m, nil
+}
+
+func setCurrency(money *Money, currency string) bool {
+	if money.Currency != "" {
+		return false
+	}
+	*money = money.In(currency)
+	return true
+}
+
+// zero amounts without a currency add to any currency
+func (m Money) currencyWith(other Money) (string, error) {
+	if m.Currency == other.Currency || (other.Amount == 0 && other.Currency == "") {
+		return m.Currency, nil
+	}
+	if m.Amount == 0 && m.Currency == "" {
+		return other.Currency, nil
+	}
+	return "", fmt.Errorf("%w: %v and %v", ErrCurrencyMismatch, m.Currency, other.Currency)
+}
+
+func (m Money) Add(other Money) (Money, error) {
+	currency, err := m.currencyWith(other)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{m.Amount + other.Amount, currency}, nil
+}
+
+func (m Money) Sub(other Money) (Money, error) {
+	currency, err := m.currencyWith(other)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{m.Amount - other.Amount, currency}, nil
+}
+
+// Cmp returns -1, 0 or 1 when m is less, equal or greater than other
+func (m Money) Cmp(other Money) (int, error) {
+	if _, err := m.currencyWith(other); err != nil {
+		return 0, err
+	}
+	if m.Amount < other.Amount {
+		return -1, nil
+	}
+	if m.Amount > other.Amount {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (m Money) Mul(quantity int64) Money {
+	return Money{m.Amount * quantity, m.Currency}
+}
+
+// MulFloat rounds half away from zero to the minor unit
+func (m Money) MulFloat(factor float64) Money {
+	return Money{int64(math.Round(float64(m.Amount) * factor)), m.Currency}
+}
+
+func (m Money) String() string {
+	exponent := CurrencyExponent(m.Currency)
+	return fmt.Sprintf("%.*f %v", exponent, float64(m.Amount)/math.Pow10(exponent), m.Currency)
+}
+
+type moneyDocument Money
+
+// numbers are the major units sent before Money existed
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var amount float64
+	if err := json.Unmarshal(data, &amount); err == nil {
+		*m = Money{Amount: int64(math.Round(amount * 100))}
+		return nil
+	}
+	return json.Unmarshal(data, (*moneyDocument)(m))
+}
+
+func (m Money) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	return bson.MarshalValue(moneyDocument(m))
+}
+
+// prices stored before Money existed are numbers in major units
+func (m *Money) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	raw := bson.RawValue{Type: t, Value: data}
+	switch t {
+	case bsontype.Double:
+		*m = Money{Amount: int64(math.Round(raw.Double() * 100))}
+	case bsontype.Int32:
+		*m = Money{Amount: int64(raw.Int32()) * 100}
+	case bsontype.Int64:
+		*m = Money{Amount: raw.Int64() * 100}
+	case bsontype.Null, bsontype.Undefined:
+		*m = Money{}
+	default:
+		var document moneyDocument
+		if err := raw.Unmarshal(&document); err != nil {
+			return err
+		}
+		*m = Money(document)
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package models
+
+import (
+	"os"
+	"sort"
+	"time"
+)
+
+const CLOCK_LAYOUT = "15:04"
+const DATE_LAYOUT = "2006-01-02"
+
+// the legacy OpenTime and CloseTime format
+const LEGACY_CLOCK_LAYOUT = "3:04PM"
+
+// days searched for the next opening, exceptions can close a restaurant for long periods
+const NEXT_OPENING_SEARCH_DAYS = 366
+
+// OpeningInterval is a range in "15:04" format, a Close before or equal to Open
+// ends the next day
+type OpeningInterval struct {
+	Open  string `bson:"open" json:"open"`
+	Close string `bson:"close" json:"close"`
+}
+
+type DaySchedule struct {
+	Day       time.Weekday      `bson:"day" json:"day"`
+	Intervals []OpeningInterval `bson:"intervals" json:"intervals"`
+}
+
+// ScheduleException replaces the weekly schedule of a date, with no intervals the restaurant is closed
+type ScheduleException struct {
+	Date      string            `bson:"date" json:"date"`
+	Intervals []OpeningInterval `bson:"intervals,omitempty" json:"intervals,omitempty"`
+	Note      string            `bson:"note,omitempty" json:"note,omitempty"`
+}
+
+// OpeningHours are evaluated in Timezone, or in the peer timezone when empty
+type OpeningHours struct {
+	Timezone   string              `bson:"timezone,omitempty" json:"timezone,omitempty"`
+	Weekly     []DaySchedule       `bson:"weekly" json:"weekly"`
+	Exceptions []ScheduleException `bson:"exceptions,omitempty" json:"exceptions,omitempty"`
+}
+
+// the TIMEZONE env, the server timezone when not set or invalid
+func PeerLocation() *time.Location {
+	location, err := time.LoadLocation(os.Getenv("TIMEZONE"))
+	if err != nil || os.Getenv("TIMEZONE") == "" {
+		return time.Local
+	}
+	return location
+}
+
+func (h OpeningHours) Location() *time.Location {
+	if h.Timezone == "" {
+		return PeerLocation()
+	}
+	location, err := time.LoadLocation(h.Timezone)
+	if err != nil {
+		return PeerLocation()
+	}
+	return location
+}
+
+// intervals of a local date, an exception replaces the weekly schedule
+func (h OpeningHours) intervalsOn(date time.Time) []OpeningInterval {
+	day := date.Format(DATE_LAYOUT)
+	for _, exception := range h.Exceptions {
+		if exception.Date == day {
+			return exception.Intervals
+		}
+	}
+
+	intervals := []OpeningInterval{}
+	for _, schedule := range h.Weekly {
+		if schedule.Day == date.Weekday() {
+			intervals = append(intervals, schedule.Intervals...)
+		}
+	}
+	return intervals
+}
+
+// returns the start and end of an interval of the given local date
+func (i OpeningInterval) bounds(date time.Time) (time.Time, time.Time, bool) {
+	open, err := time.Parse(CLOCK_LAYOUT, i.Open)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	closing, err := time.Parse(CLOCK_LAYOUT, i.Close)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+
+	start := time.Date(date.Year(), date.Month(), date.Day(), open.Hour(), open.Minute(), 0, 0, date.Location())
+	end := time.Date(date.Year(), date.Month(), date.Day(), closing.Hour(), closing.Minute(), 0, 0, date.Location())
+	if !end.After(start) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return start, end, true
+}
+
+func (h OpeningHours) IsOpenAt(t time.Time) bool {
+	local := t.In(h.Location())
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, local.Location())
+
+	// intervals from the day before can end after midnight
+	for _, date := range []time.Time{today.AddDate(0, 0, -1), today} {
+		for _, interval := range h.intervalsOn(date) {
+			start, end, ok := interval.bounds(date)
+			if ok && !local.Before(start) && local.Before(end) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// NextOpening returns the first interval start after t
+func (h OpeningHours) NextOpening(t time.Time) (time.Time, bool) {
+	local := t.In(h.Location())
+	date := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, local.Location())
+
+	for i := 0; i < NEXT_OPENING_SEARCH_DAYS; i++ {
+		starts := []time.Time{}
+		for _, interval := range h.intervalsOn(date) {
+			if start, _, ok := interval.bounds(date); ok && start.After(t) {
+				starts = append(starts, start)
+			}
+		}
+		if len(starts) > 0 {
+			sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+			return starts[0], true
+		}
+		date = date.AddDate(0, 0, 1)
+	}
+	return time.Time{}, false
+}
+
+// the same interval every day, converted from the legacy OpenTime and CloseTime
+func legacyOpeningHours(openTime string, closeTime string) OpeningHours {
+	hours := OpeningHours{Weekly: []DaySchedule{}}
+
+	open, err := parseClock(openTime)
+	if err != nil {
+		return hours
+	}
+	closing, err := parseClock(closeTime)
+	if err != nil {
+		// without a close time the restaurant is considered open the whole day
+		closing = open
+	}
+
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		hours.Weekly = append(hours.Weekly, DaySchedule{Day: day, Intervals: []OpeningInterval{{
+			Open:  open.Format(CLOCK_LAYOUT),
+			Close: closing.Format(CLOCK_LAYOUT),
+		}}})
+	}
+	return hours
+}
+
+func parseClock(clock string) (time.Time, error) {
+	parsed, err := time.Parse(LEGACY_CLOCK_LAYOUT, clock)
+	if err != nil {
+		return time.Parse(CLOCK_LAYOUT, clock)
+	}
+	return parsed, nil
+}
@@ -19,9 +19,14 @@ type Peer struct {
 	Id                  primitive.ObjectID   `bson:"_id,omitempty" json:"id,omitempty"`
 	Url                 string               `bson:"url" json:"url" validate:"required,url"`
 	Center              GeoCoords            `validate:"dive"`
+	Geohash             string               `bson:"geohash,omitempty" json:"geohash,omitempty"`
 	City                string               `bson:"city,omitempty" json:"city,omitempty" validate:"required"`
 	Country             string               `bson:"country,omitempty" json:"country,omitempty" validate:"required"`
 	DeliveryRadius      float64              `bson:"delivery_radius,omitempty" json:"delivery_radius,omitempty"`
+	InfluenceRadius     float64              `bson:"influence_radius,omitempty" json:"influence_radius,omitempty" validate:"gte=0"`
+	Currency            string               `bson:"currency,omitempty" json:"currency,omitempty" validate:"omitempty,iso4217"`
+	DeliveryZone        *GeoZone             `bson:"delivery_zone,omitempty" json:"delivery_zone,omitempty"`
+	InfluenceZone       *GeoZone             `bson:"influence_zone,omitempty" json:"influence_zone,omitempty"`
 	InAreaPeers         []primitive.ObjectID `bson:"in_area_peers,omitempty" json:"in_area_peers,omitempty"`
 	InDeliveryAreaPeers []primitive.ObjectID `bson:"in_area_delivery_peers,omitempty" json:"in_area_delivery_peers,omitempty"`
 }
@@ -36,4 +41,7 @@ func InitPeerModel(databaseName string) {
 		Keys:    bson.D{{Key: "url", Value: 1}},
 		Options: options.Index().SetUnique(true),
 	})
+	GetPeerColl(databaseName).Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{{Key: "geohash", Value: 1}},
+	})
 }
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/nicodeheza/peersEat/config"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const PROMOTION_PERCENT = "percent"
+const PROMOTION_FIXED = "fixed"
+const PROMOTION_FREE_DELIVERY = "freeDelivery"
+const PROMOTION_BUY_X_GET_Y = "buyXGetY"
+
+// Promotion is a discount of a restaurant, or of every restaurant of the peer when
+// RestaurantId is nil. Promotions with a Code are coupons the customer has to enter,
+// the others apply by themselves.
+//
+// Percent is taken off the dishes, up to MaxDiscount when set. Fixed takes Amount
+// off the dishes. BuyXGetY gives the cheapest GetQuantity dishes of every
+// BuyQuantity + GetQuantity. DishIds limits the dishes discounted, and counted for
+// MinSubtotal, to the listed ones. Zero limits mean there is no limit and a
+// promotion that isn't Stackable is never combined with another one
+type Promotion struct {
+	Id                 primitive.ObjectID   `bson:"_id,omitempty" json:"id,omitempty"`
+	RestaurantId       *primitive.ObjectID  `bson:"restaurantId,omitempty" json:"restaurantId,omitempty"`
+	Name               string               `bson:"name" json:"name"`
+	Code               string               `bson:"code" json:"code,omitempty"`
+	Type               string               `bson:"type" json:"type"`
+	Percent            float64              `bson:"percent" json:"percent,omitempty"`
+	Amount             Money                `bson:"amount" json:"amount"`
+	MaxDiscount        Money                `bson:"maxDiscount" json:"maxDiscount"`
+	BuyQuantity        uint                 `bson:"buyQuantity" json:"buyQuantity,omitempty"`
+	GetQuantity        uint                 `bson:"getQuantity" json:"getQuantity,omitempty"`
+	DishIds            []primitive.ObjectID `bson:"dishIds" json:"dishIds,omitempty"`
+	MinSubtotal        Money                `bson:"minSubtotal" json:"minSubtotal"`
+	NewCustomersOnly   bool                 `bson:"newCustomersOnly" json:"newCustomersOnly"`
+	StartsAt           *time.Time           `bson:"startsAt" json:"startsAt,omitempty"`
+	EndsAt             *time.Time           `bson:"endsAt" json:"endsAt,omitempty"`
+	Windows            []AvailabilityWindow `bson:"windows" json:"windows,omitempty"`
+	MaxUses            uint                 `bson:"maxUses" json:"maxUses"`
+	MaxUsesPerCustomer uint                 `bson:"maxUsesPerCustomer" json:"maxUsesPerCustomer"`
+	Uses               uint                 `bson:"uses" json:"uses"`
+	Stackable          bool                 `bson:"stackable" json:"stackable"`
+	CreatedAt          time.Time            `bson:"createdAt" json:"createdAt"`
+}
+
+// applies to the dish, every dish when DishIds is empty
+func (p Promotion) Covers(dishId primitive.ObjectID) bool {
+	if len(p.DishIds) == 0 {
+		return true
+	}
+	for _, id := range p.DishIds {
+		if id == dishId {
+			return true
+		}
+	}
+	return false
+}
+
+// Redemption is a cart checked out by a customer with the promotions applied to
+// it, carts without promotions are stored too so new customers can be told apart
+type Redemption struct {
+	Id           primitive.ObjectID   `bson:"_id,omitempty" json:"id,omitempty"`
+	RestaurantId primitive.ObjectID   `bson:"restaurantId" json:"restaurantId"`
+	CustomerId   string               `bson:"customerId" json:"-"`
+	PromotionIds []primitive.ObjectID `bson:"promotionIds" json:"promotionIds"`
+	// a copy of the quote, so the fee of the order can't change once the quote expires
+	DeliveryQuote *DeliveryQuote `bson:"deliveryQuote,omitempty" json:"deliveryQuote,omitempty"`
+	Discount      Money          `bson:"discount" json:"discount"`
+	Total         Money          `bson:"total" json:"total"`
+	// the customer gets the token with the redemption, reviewing the order requires it
+	ReviewTokenHash string    `bson:"reviewTokenHash,omitempty" json:"-"`
+	CreatedAt       time.Time `bson:"createdAt" json:"createdAt"`
+}
+
+func GetPromotionColl(databaseName string) *mongo.Collection {
+	return config.GetDatabase(databaseName).Collection("promotions")
+}
+
+func GetRedemptionColl(databaseName string) *mongo.Collection {
+	return config.GetDatabase(databaseName).Collection("redemptions")
+}
+
+func InitPromotionModel(databaseName string) {
+	GetPromotionColl(databaseName).Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{{Key: "restaurantId", Value: 1}, {Key: "code", Value: 1}},
+	})
+	GetRedemptionColl(databaseName).Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "customerId", Value: 1}, {Key: "restaurantId", Value: 1}}},
+		{Keys: bson.D{{Key: "customerId", Value: 1}, {Key: "promotionIds", Value: 1}}},
+		{Keys: bson.D{{Key: "reviewTokenHash", Value: 1}}},
+	})
+}
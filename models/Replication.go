@@ -0,0 +1,56 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/nicodeheza/peersEat/config"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// peers replicating their restaurants into this one
+const REPLICATION_ORIGIN = "origin"
+
+// peers this one replicates its restaurants to
+const REPLICATION_BUDDY = "buddy"
+
+type ReplicationLink struct {
+	Id       primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Url      string             `bson:"url" json:"url"`
+	Role     string             `bson:"role" json:"role"`
+	Secret   string             `bson:"secret" json:"-"`
+	Seq      int64              `bson:"seq" json:"seq"`
+	Synced   bool               `bson:"synced" json:"synced"`
+	LastSeen time.Time          `bson:"lastSeen" json:"lastSeen"`
+}
+
+// copy of a restaurant owned by another peer, the id is the restaurant id
+type RestaurantReplica struct {
+	Id           primitive.ObjectID `bson:"_id" json:"id"`
+	Origin       string             `bson:"origin" json:"origin"`
+	Restaurant   Restaurant         `bson:"restaurant" json:"restaurant"`
+	MenuVersions []MenuVersion      `bson:"menuVersions,omitempty" json:"menuVersions,omitempty"`
+	StaffUsers   []StaffUser        `bson:"staffUsers,omitempty" json:"-"`
+	UpdatedAt    time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+func GetReplicationLinkColl(databaseName string) *mongo.Collection {
+	return config.GetDatabase(databaseName).Collection("replicationLinks")
+}
+
+func GetRestaurantReplicaColl(databaseName string) *mongo.Collection {
+	return config.GetDatabase(databaseName).Collection("restaurantReplicas")
+}
+
+func InitReplicationModel(databaseName string) {
+	GetReplicationLinkColl(databaseName).Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "url", Value: 1}, {Key: "role", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	GetRestaurantReplicaColl(databaseName).Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{{Key: "origin", Value: 1}},
+	})
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"context"
+	"time"
 
 	"github.com/nicodeheza/peersEat/config"
 	"go.mongodb.org/mongo-driver/bson"
@@ -10,11 +11,29 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// Stars is the sum of the stars of every review
 type Rate struct {
 	Stars uint
 	Votes uint
 }
 
+// prior used to smooth the score of restaurants with few votes
+const RATE_PRIOR_MEAN = 3.5
+const RATE_PRIOR_VOTES = 5
+
+func (r Rate) Average() float64 {
+	if r.Votes == 0 {
+		return 0
+	}
+	return float64(r.Stars) / float64(r.Votes)
+}
+
+// Score is the bayesian average, it starts at RATE_PRIOR_MEAN and moves to the
+// real average as votes come in
+func (r Rate) Score() float64 {
+	return (RATE_PRIOR_MEAN*RATE_PRIOR_VOTES + float64(r.Stars)) / float64(RATE_PRIOR_VOTES+r.Votes)
+}
+
 type Restaurant struct {
 	Id                primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
 	Name              string             `validate:"required"`
@@ -22,21 +41,75 @@ type Restaurant struct {
 	City              string             `validate:"required"`
 	Country           string             `validate:"required"`
 	Coord             GeoCoords          `validate:"-"`
+	IsCoordOverridden bool               `bson:"isCoordOverridden,omitempty" json:"isCoordOverridden,omitempty"`
 	IsConnected       bool               `bson:"isConnected,omitempty" json:"isConnected,omitempty"`
+	IsPaused          bool               `bson:"isPaused,omitempty" json:"isPaused,omitempty"`
+	LastSeen          *time.Time         `bson:"lastSeen,omitempty" json:"lastSeen,omitempty"`
 	ImageUrl          string             `bson:"imageUrl,omitempty" json:"imageUrl,omitempty"`
 	Menu              Menu               `bson:"menu,omitempty" json:"menu,omitempty"`
 	OpenTime          string             `bson:"openTime,omitempty" json:"openTime,omitempty"`
 	CloseTime         string             `bson:"closeTime,omitempty" json:"closeTime,omitempty"`
 	Rate              Rate               `bson:"rate,omitempty" json:"rate,omitempty"`
 	Phone             string             `bson:"Phone,omitempty" json:"phone,omitempty"`
-	DeliveryCost      float32            `bson:"deliveryCost,omitempty" json:"deliveryCost,omitempty"`
+	Currency          string             `bson:"currency,omitempty" json:"currency,omitempty" validate:"omitempty,iso4217"`
+	DeliveryCost      Money              `bson:"deliveryCost,omitempty" json:"deliveryCost,omitempty"`
 	IsDeliveryFixCost bool               `bson:"isDeliveryFixCost,omitempty" json:"isDeliveryFixCost,omitempty"`
 	MinDeliveryTime   uint               `bson:"minDeliveryTime,omitempty" json:"minDeliveryTime,omitempty"`
 	MaxDeliveryTime   uint               `bson:"maxDeliveryTime,omitempty" json:"maxDeliveryTime,omitempty"`
 	DeliveryRadius    float64            `bson:"deliveryRadius,omitempty" json:"deliveryRadius,omitempty"`
+	DeliveryZone      *GeoZone           `bson:"deliveryZone,omitempty" json:"deliveryZone,omitempty"`
 	UserName          string             `bson:"userName,omitempty" json:"userName,omitempty"`
 	Password          string             `bson:"password,omitempty" json:"password,omitempty"`
 	IsFinalPassword   bool               `bson:"isFinalPassword,omitempty" json:"isFinalPassword,omitempty"`
+	// only the hash of the one time token the owner sets the credentials with
+	SetupTokenHash  string            `bson:"setupTokenHash,omitempty" json:"-"`
+	SetupExpiresAt  *time.Time        `bson:"setupExpiresAt,omitempty" json:"-"`
+	Unavailable     []UnavailableItem `bson:"unavailable,omitempty" json:"unavailable,omitempty"`
+	OpeningHours    *OpeningHours     `bson:"openingHours,omitempty" json:"openingHours,omitempty"`
+	Tags            []string          `bson:"tags,omitempty" json:"tags,omitempty"`
+	DeliveryPricing *DeliveryPricing  `bson:"deliveryPricing,omitempty" json:"deliveryPricing,omitempty"`
+	Status          string            `bson:"status,omitempty" json:"status,omitempty"`
+	StatusHistory   []StatusChange    `bson:"statusHistory,omitempty" json:"statusHistory,omitempty"`
+}
+
+// restaurants created before currencies existed use the one of the peer
+func (r Restaurant) CurrencyOrDefault() string {
+	if r.Currency == "" {
+		return DefaultCurrency()
+	}
+	return r.Currency
+}
+
+// Schedule returns the opening hours, or the ones built from OpenTime and CloseTime
+func (r Restaurant) Schedule() OpeningHours {
+	if r.OpeningHours != nil {
+		return *r.OpeningHours
+	}
+	return legacyOpeningHours(r.OpenTime, r.CloseTime)
+}
+
+// ActiveUnavailable returns the items still sold out at the given time with the
+// time they are available again, nil when unknown
+func (r Restaurant) ActiveUnavailable(at time.Time) map[primitive.ObjectID]*time.Time {
+	active := map[primitive.ObjectID]*time.Time{}
+	for _, item := range r.Unavailable {
+		switch {
+		case item.Until != nil:
+			if at.Before(*item.Until) {
+				active[item.ItemId] = item.Until
+			}
+		case item.Indefinite:
+			active[item.ItemId] = nil
+		default:
+			next, ok := r.Schedule().NextOpening(item.MarkedAt)
+			if !ok {
+				active[item.ItemId] = nil
+			} else if at.Before(next) {
+				active[item.ItemId] = &next
+			}
+		}
+	}
+	return active
 }
 
 func GetRestaurantColl(databaseName string) *mongo.Collection {
@@ -44,8 +117,14 @@ func GetRestaurantColl(databaseName string) *mongo.Collection {
 }
 
 func InitRestaurantModel(databaseName string) {
-	GetRestaurantColl(databaseName).Indexes().CreateOne(context.Background(), mongo.IndexModel{
-		Keys:    bson.D{{Key: "userName", Value: 1}},
-		Options: options.Index().SetUnique(true),
+	GetRestaurantColl(databaseName).Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "userName", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "setupTokenHash", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		},
 	})
 }
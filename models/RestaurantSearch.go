@@ -0,0 +1,32 @@
+package models
+
+import (
+	"context"
+
+	"github.com/nicodeheza/peersEat/config"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// RestaurantSearch is the text search document of a restaurant, rebuilt on every write
+// to the restaurant or its menu versions. Words are the ones of its name, tags and the
+// dishes of every named menu and Keys the same words with the letters a typo may change
+// deleted, so a search only loads the restaurants that can match
+type RestaurantSearch struct {
+	RestaurantId primitive.ObjectID `bson:"_id" json:"restaurantId"`
+	City         string             `bson:"city" json:"city"`
+	Words        []string           `bson:"words" json:"words"`
+	Keys         []string           `bson:"keys" json:"keys"`
+}
+
+func GetRestaurantSearchColl(databaseName string) *mongo.Collection {
+	return config.GetDatabase(databaseName).Collection("restaurantSearch")
+}
+
+func InitRestaurantSearchModel(databaseName string) {
+	GetRestaurantSearchColl(databaseName).Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "keys", Value: 1}}},
+		{Keys: bson.D{{Key: "words", Value: 1}}},
+	})
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/nicodeheza/peersEat/config"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const RESTAURANT_DRAFT = "draft"
+const RESTAURANT_PENDING = "pending"
+const RESTAURANT_ACTIVE = "active"
+const RESTAURANT_SUSPENDED = "suspended"
+const RESTAURANT_CLOSED = "closed"
+
+// who changed the status
+const STATUS_BY_RESTAURANT = "restaurant"
+const STATUS_BY_PEER_OWNER = "peerOwner"
+
+// statuses each status can move to, closed is final
+var statusChanges = map[string][]string{
+	RESTAURANT_DRAFT:     {RESTAURANT_PENDING, RESTAURANT_CLOSED},
+	RESTAURANT_PENDING:   {RESTAURANT_ACTIVE, RESTAURANT_DRAFT, RESTAURANT_CLOSED},
+	RESTAURANT_ACTIVE:    {RESTAURANT_SUSPENDED, RESTAURANT_CLOSED},
+	RESTAURANT_SUSPENDED: {RESTAURANT_ACTIVE, RESTAURANT_CLOSED},
+}
+
+func CanChangeStatus(from string, to string) bool {
+	for _, status := range statusChanges[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+type StatusChange struct {
+	From   string    `bson:"from,omitempty" json:"from,omitempty"`
+	To     string    `bson:"to" json:"to"`
+	Reason string    `bson:"reason,omitempty" json:"reason,omitempty"`
+	By     string    `bson:"by" json:"by"`
+	At     time.Time `bson:"at" json:"at"`
+}
+
+// restaurants created before statuses existed have none and are active
+func (r Restaurant) CurrentStatus() string {
+	if r.Status == "" {
+		return RESTAURANT_ACTIVE
+	}
+	return r.Status
+}
+
+func (r Restaurant) IsActive() bool {
+	return r.CurrentStatus() == RESTAURANT_ACTIVE
+}
+
+// RemoteRestaurantStatus is the last status an in area peer announced for one
+// of its restaurants
+type RemoteRestaurantStatus struct {
+	RestaurantId primitive.ObjectID `bson:"_id" json:"restaurantId"`
+	PeerUrl      string             `bson:"peerUrl" json:"peerUrl" validate:"required,url"`
+	Status       string             `bson:"status" json:"status" validate:"required,oneof=draft pending active suspended closed"`
+	UpdatedAt    time.Time          `bson:"updatedAt" json:"updatedAt" validate:"required"`
+}
+
+func GetRemoteRestaurantStatusColl(databaseName string) *mongo.Collection {
+	return config.GetDatabase(databaseName).Collection("remoteRestaurantStatuses")
+}
+
+func InitRemoteRestaurantStatusModel(databaseName string) {
+	GetRemoteRestaurantStatusColl(databaseName).Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{{Key: "peerUrl", Value: 1}},
+	})
+}
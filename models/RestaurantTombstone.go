@@ -0,0 +1,58 @@
+package models
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/nicodeheza/peersEat/config"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const DEFAULT_HANDOFF_GRACE_PERIOD = 7 * 24 * time.Hour
+
+// left behind when a restaurant is handed off to another peer, requests for
+// it are redirected to the new peer until it expires
+type RestaurantTombstone struct {
+	Id           primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	RestaurantId primitive.ObjectID `bson:"restaurantId" json:"restaurantId"`
+	UserName     string             `bson:"userName,omitempty" json:"userName,omitempty"`
+	// the staff users of the restaurant log in at the new peer too
+	StaffUserNames []string  `bson:"staffUserNames,omitempty" json:"staffUserNames,omitempty"`
+	MovedTo        string    `bson:"movedTo" json:"movedTo"`
+	ExpiresAt      time.Time `bson:"expiresAt" json:"expiresAt"`
+}
+
+func GetRestaurantTombstoneColl(databaseName string) *mongo.Collection {
+	return config.GetDatabase(databaseName).Collection("restaurantTombstones")
+}
+
+func GetHandoffGracePeriod() time.Duration {
+	gracePeriod, err := time.ParseDuration(os.Getenv("HANDOFF_GRACE_PERIOD"))
+	if err != nil || gracePeriod <= 0 {
+		return DEFAULT_HANDOFF_GRACE_PERIOD
+	}
+	return gracePeriod
+}
+
+func InitRestaurantTombstoneModel(databaseName string) {
+	GetRestaurantTombstoneColl(databaseName).Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "restaurantId", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "userName", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "staffUserNames", Value: 1}},
+		},
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+}
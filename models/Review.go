@@ -0,0 +1,60 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/nicodeheza/peersEat/config"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const REVIEW_SORT_RECENT = "recent"
+const REVIEW_SORT_HELPFUL = "helpful"
+
+type DishRating struct {
+	DishId primitive.ObjectID `bson:"dishId" json:"dishId"`
+	Stars  uint               `bson:"stars" json:"stars"`
+}
+
+type ReviewReply struct {
+	Text      string    `bson:"text" json:"text"`
+	RepliedAt time.Time `bson:"repliedAt" json:"repliedAt"`
+}
+
+// Review is the opinion of a customer about an order of a restaurant, an order has
+// one review and sending a new one replaces it
+type Review struct {
+	Id           primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	RestaurantId primitive.ObjectID `bson:"restaurantId" json:"restaurantId"`
+	CustomerId   string             `bson:"customerId" json:"-"`
+	CustomerName string             `bson:"customerName,omitempty" json:"customerName,omitempty"`
+	OrderId      string             `bson:"orderId,omitempty" json:"orderId,omitempty"`
+	Stars        uint               `bson:"stars" json:"stars"`
+	Text         string             `bson:"text,omitempty" json:"text,omitempty"`
+	Dishes       []DishRating       `bson:"dishes,omitempty" json:"dishes,omitempty"`
+	Reply        *ReviewReply       `bson:"reply,omitempty" json:"reply,omitempty"`
+	Helpful      uint               `bson:"helpful" json:"helpful"`
+	HelpfulBy    []string           `bson:"helpfulBy,omitempty" json:"-"`
+	CreatedAt    time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt    time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+func GetReviewColl(databaseName string) *mongo.Collection {
+	return config.GetDatabase(databaseName).Collection("reviews")
+}
+
+func InitReviewModel(databaseName string) {
+	// reviews were unique per customer before they were bound to orders
+	GetReviewColl(databaseName).Indexes().DropOne(context.Background(), "restaurantId_1_customerId_1")
+	GetReviewColl(databaseName).Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "restaurantId", Value: 1}, {Key: "orderId", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.D{{Key: "orderId", Value: bson.D{{Key: "$type", Value: "string"}}}}),
+		},
+		{Keys: bson.D{{Key: "restaurantId", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "restaurantId", Value: 1}, {Key: "helpful", Value: -1}, {Key: "createdAt", Value: -1}}},
+	})
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/nicodeheza/peersEat/config"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const STAFF_OWNER = "owner"
+const STAFF_MANAGER = "manager"
+const STAFF_KITCHEN = "kitchen"
+const STAFF_READ_ONLY = "readOnly"
+
+// what a staff user can do in its restaurant
+const PERM_VIEW = "view"
+const PERM_OPERATE = "operate"
+const PERM_EDIT_MENU = "editMenu"
+const PERM_EDIT_RESTAURANT = "editRestaurant"
+const PERM_EDIT_PRICING = "editPricing"
+const PERM_REPLY_REVIEWS = "replyReviews"
+const PERM_MANAGE_STAFF = "manageStaff"
+const PERM_MANAGE_ACCOUNT = "manageAccount"
+
+var rolePermissions = map[string][]string{
+	STAFF_OWNER:     {PERM_VIEW, PERM_OPERATE, PERM_EDIT_MENU, PERM_EDIT_RESTAURANT, PERM_EDIT_PRICING, PERM_REPLY_REVIEWS, PERM_MANAGE_STAFF, PERM_MANAGE_ACCOUNT},
+	STAFF_MANAGER:   {PERM_VIEW, PERM_OPERATE, PERM_EDIT_MENU, PERM_EDIT_RESTAURANT, PERM_EDIT_PRICING, PERM_REPLY_REVIEWS, PERM_MANAGE_STAFF},
+	STAFF_KITCHEN:   {PERM_VIEW, PERM_OPERATE},
+	STAFF_READ_ONLY: {PERM_VIEW},
+}
+
+// roles each role can invite and manage, managers can't touch owners or other managers
+var assignableRoles = map[string][]string{
+	STAFF_OWNER:   {STAFF_OWNER, STAFF_MANAGER, STAFF_KITCHEN, STAFF_READ_ONLY},
+	STAFF_MANAGER: {STAFF_KITCHEN, STAFF_READ_ONLY},
+}
+
+func RoleCan(role string, permission string) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func CanAssignRole(by string, role string) bool {
+	for _, r := range assignableRoles[by] {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// StaffUser is a person working for a restaurant with its own credentials, it
+// starts as an invitation and gets them when the invitation is accepted. The
+// credentials embedded in the restaurant keep working as its owner account
+type StaffUser struct {
+	Id              primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	RestaurantId    primitive.ObjectID `bson:"restaurantId" json:"restaurantId"`
+	Name            string             `bson:"name" json:"name"`
+	Role            string             `bson:"role" json:"role"`
+	UserName        string             `bson:"userName,omitempty" json:"userName,omitempty"`
+	Password        string             `bson:"password,omitempty" json:"-"`
+	InviteTokenHash string             `bson:"inviteTokenHash,omitempty" json:"-"`
+	InviteExpiresAt *time.Time         `bson:"inviteExpiresAt,omitempty" json:"inviteExpiresAt,omitempty"`
+	InvitedBy       primitive.ObjectID `bson:"invitedBy,omitempty" json:"invitedBy,omitempty"`
+	IsPending       bool               `bson:"isPending" json:"isPending"`
+	CreatedAt       time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt       time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// StaffActor is who is making a request, the zero id is the restaurant account
+type StaffActor struct {
+	Id   primitive.ObjectID
+	Role string
+}
+
+func GetStaffUserColl(databaseName string) *mongo.Collection {
+	return config.GetDatabase(databaseName).Collection("staffUsers")
+}
+
+func InitStaffUserModel(databaseName string) {
+	GetStaffUserColl(databaseName).Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "userName", Value: 1}},
+			Options: options.Index().SetUnique(true).SetSparse(true),
+		},
+		{
+			Keys:    bson.D{{Key: "inviteTokenHash", Value: 1}},
+			Options: options.Index().SetUnique(true).SetSparse(true),
+		},
+		{Keys: bson.D{{Key: "restaurantId", Value: 1}}},
+	})
+}
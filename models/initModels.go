@@ -3,4 +3,14 @@ package models
 func InitModels(databaseName string) {
 	InitPeerModel(databaseName)
 	InitRestaurantModel(databaseName)
+	InitGeocodeCacheModel(databaseName)
+	InitRestaurantTombstoneModel(databaseName)
+	InitReplicationModel(databaseName)
+	InitMenuVersionModel(databaseName)
+	InitReviewModel(databaseName)
+	InitDeliveryQuoteModel(databaseName)
+	InitRemoteRestaurantStatusModel(databaseName)
+	InitStaffUserModel(databaseName)
+	InitPromotionModel(databaseName)
+	InitRestaurantSearchModel(databaseName)
 }
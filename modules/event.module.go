@@ -4,6 +4,7 @@ import (
 	"github.com/nicodeheza/peersEat/events"
 	"github.com/nicodeheza/peersEat/repositories"
 	"github.com/nicodeheza/peersEat/services/geo"
+	"github.com/nicodeheza/peersEat/services/overlay"
 	"github.com/nicodeheza/peersEat/services/validations"
 )
 
@@ -16,8 +17,10 @@ func InitEventModules(
 	peerRepo repositories.PeerRepositoryI,
 	validation validations.ValidateI,
 	geo geo.GeoServiceI,
+	overlay overlay.OverlayServiceI,
+	remoteStatusesRepo repositories.RemoteRestaurantStatusRepositoryI,
 ) *EventModule {
-	handlers := events.NewEventHandlers(peerRepo, validation, geo)
+	handlers := events.NewEventHandlers(peerRepo, validation, geo, overlay, remoteStatusesRepo)
 	loop := events.InitEventLoop(handlers)
 	return &EventModule{
 		loop, handlers,
@@ -1,6 +1,8 @@
 package modules
 
 import (
+	"log"
+
 	"github.com/go-playground/validator/v10"
 	"github.com/nicodeheza/peersEat/controllers"
 	"github.com/nicodeheza/peersEat/events"
@@ -9,6 +11,9 @@ import (
 	"github.com/nicodeheza/peersEat/repositories"
 	"github.com/nicodeheza/peersEat/services"
 	"github.com/nicodeheza/peersEat/services/geo"
+	"github.com/nicodeheza/peersEat/services/geocoder"
+	"github.com/nicodeheza/peersEat/services/images"
+	"github.com/nicodeheza/peersEat/services/overlay"
 	"github.com/nicodeheza/peersEat/services/validations"
 	"github.com/nicodeheza/peersEat/utils"
 )
@@ -20,37 +25,106 @@ func initRepositories() *Repositories {
 	peerCollection := models.GetPeerColl("peersEatDB")
 	peerRepository := repositories.NewPeerRepository(peerCollection)
 
-	return &Repositories{Peer: peerRepository, Restaurant: restaurantRepository}
+	geocodeCacheCollection := models.GetGeocodeCacheColl("peersEatDB")
+	geocodeCacheRepository := repositories.NewGeocodeCacheRepository(geocodeCacheCollection, models.GetGeocodeCacheTTL())
+
+	tombstoneCollection := models.GetRestaurantTombstoneColl("peersEatDB")
+	tombstoneRepository := repositories.NewRestaurantTombstoneRepository(tombstoneCollection)
+
+	replicationLinkCollection := models.GetReplicationLinkColl("peersEatDB")
+	replicaCollection := models.GetRestaurantReplicaColl("peersEatDB")
+	replicationRepository := repositories.NewReplicationRepository(replicationLinkCollection, replicaCollection)
+
+	menuVersionCollection := models.GetMenuVersionColl("peersEatDB")
+	menuVersionRepository := repositories.NewMenuVersionRepository(menuVersionCollection)
+
+	reviewCollection := models.GetReviewColl("peersEatDB")
+	reviewRepository := repositories.NewReviewRepository(reviewCollection)
+	deliveryQuoteCollection := models.GetDeliveryQuoteColl("peersEatDB")
+	deliveryQuoteRepository := repositories.NewDeliveryQuoteRepository(deliveryQuoteCollection)
+	remoteStatusCollection := models.GetRemoteRestaurantStatusColl("peersEatDB")
+	remoteStatusRepository := repositories.NewRemoteRestaurantStatusRepository(remoteStatusCollection)
+	staffUserCollection := models.GetStaffUserColl("peersEatDB")
+	staffUserRepository := repositories.NewStaffUserRepository(staffUserCollection)
+	promotionCollection := models.GetPromotionColl("peersEatDB")
+	promotionRepository := repositories.NewPromotionRepository(promotionCollection)
+	redemptionCollection := models.GetRedemptionColl("peersEatDB")
+	redemptionRepository := repositories.NewRedemptionRepository(redemptionCollection)
+	restaurantSearchCollection := models.GetRestaurantSearchColl("peersEatDB")
+	restaurantSearchRepository := repositories.NewRestaurantSearchRepository(restaurantSearchCollection)
+
+	return &Repositories{Peer: peerRepository, Restaurant: restaurantRepository, GeocodeCache: geocodeCacheRepository, Tombstone: tombstoneRepository, Replication: replicationRepository, MenuVersion: menuVersionRepository, Review: reviewRepository, DeliveryQuote: deliveryQuoteRepository, RemoteStatus: remoteStatusRepository, StaffUser: staffUserRepository, Promotion: promotionRepository, Redemption: redemptionRepository, RestaurantSearch: restaurantSearchRepository}
 }
 
-func initServices(repos *Repositories, authHelpers *utils.AuthHelpers, eventLoop *events.EventLoop, geo *geo.GeoService) *Services {
-	restaurant := services.NewRestaurantService(repos.Restaurant, authHelpers, geo)
-	peer := services.NewPeerService(repos.Peer, geo, repos.Restaurant, eventLoop)
+func initServices(repos *Repositories, authHelpers *utils.AuthHelpers, eventLoop *events.EventLoop, geo *geo.GeoService, geocoder geocoder.GeocoderI, overlay *overlay.OverlayService, replication *services.ReplicationService, imageStore images.ImageStoreI) *Services {
+	staff := services.NewStaffService(repos.StaffUser, repos.Restaurant, authHelpers)
+	restaurant := services.NewRestaurantService(repos.Restaurant, authHelpers, geocoder, geo, staff)
+	peer := services.NewPeerService(repos.Peer, geo, repos.Restaurant, eventLoop, overlay)
+	handoff := services.NewHandoffService(repos.Peer, repos.Restaurant, repos.Tombstone, repos.MenuVersion, repos.StaffUser, geo, peer, models.GetHandoffGracePeriod())
+
+	menu := services.NewMenuService(repos.Restaurant)
+	menuVersions := services.NewMenuVersionService(repos.MenuVersion, repos.Restaurant, menu)
+	search := services.NewSearchService(restaurant, peer, geo, repos.RemoteStatus, menuVersions)
+	textSearch := services.NewTextSearchService(repos.Restaurant, repos.RestaurantSearch, menuVersions, geo)
+	review := services.NewReviewService(repos.Review, repos.Restaurant, repos.Redemption, menuVersions)
+	image := services.NewImageService(imageStore, repos.Restaurant, menu)
+	delivery := services.NewDeliveryService(repos.DeliveryQuote, repos.Restaurant, geo)
+	presence := services.NewPresenceService(repos.Restaurant)
+	migration := services.NewMigrationService(repos.Restaurant, repos.MenuVersion)
+	promotion := services.NewPromotionService(repos.Promotion, repos.Redemption, repos.Restaurant, menuVersions, delivery)
 
-	return &Services{peer, restaurant}
+	return &Services{peer, restaurant, handoff, replication, menu, menuVersions, search, textSearch, review, image, delivery, presence, staff, migration, promotion}
 }
 
 func initControllers(services *Services, validate *validations.Validate, geo *geo.GeoService) *Controllers {
-	restaurant := controllers.NewRestaurantController(services.restaurant, services.peer, validate)
-	peer := controllers.NewPeerController(services.peer, validate, services.restaurant, geo)
-	return &Controllers{peer, restaurant}
+	restaurant := controllers.NewRestaurantController(services.restaurant, services.peer, validate, services.menu, services.menuVersions)
+	peer := controllers.NewPeerController(services.peer, validate, services.restaurant, geo, services.handoff, services.replication)
+	search := controllers.NewSearchController(services.search, services.textSearch, validate)
+	review := controllers.NewReviewController(services.review, validate)
+	image := controllers.NewImageController(services.image)
+	delivery := controllers.NewDeliveryController(services.delivery, validate)
+	presence := controllers.NewPresenceController(services.presence)
+	staff := controllers.NewStaffController(services.staff, validate)
+	promotion := controllers.NewPromotionController(services.promotion, validate)
+	return &Controllers{peer, restaurant, search, review, image, delivery, presence, staff, promotion}
 }
 
 func InitApp() *Application {
 
 	geo := geo.NewGeo()
+	providerGeocoder, err := geocoder.NewGeocoderFromEnv(geo)
+	if err != nil {
+		log.Fatal(err)
+	}
+	imageStore, err := images.NewImageStoreFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
 	validate := validations.NewValidator(validator.New())
 	authHelpers := utils.NewAuthHelper()
 
 	repos := initRepositories()
-	eventHandlers := events.NewEventHandlers(repos.Peer, validate, geo)
+	// every write is indexed, including the ones of the replication service
+	searchIndex := services.NewSearchIndexService(repos.Restaurant, repos.MenuVersion, repos.RestaurantSearch)
+	repos.Restaurant = repositories.NewReplicatedRestaurantRepository(repos.Restaurant, searchIndex)
+	repos.MenuVersion = repositories.NewReplicatedMenuVersionRepository(repos.MenuVersion, searchIndex)
+	// the replication service reads and writes through the search index wrapper only,
+	// every other service writes through both so buddies get the changes too
+	replication := services.NewReplicationService(repos.Peer, repos.Restaurant, repos.Replication, repos.MenuVersion, repos.StaffUser, geo)
+	repos.Restaurant = repositories.NewReplicatedRestaurantRepository(repos.Restaurant, replication)
+	repos.MenuVersion = repositories.NewReplicatedMenuVersionRepository(repos.MenuVersion, replication)
+	repos.StaffUser = repositories.NewReplicatedStaffUserRepository(repos.StaffUser, replication)
+	geocoder := geocoder.NewCachedGeocoder(providerGeocoder, repos.GeocodeCache)
+	overlay := overlay.NewOverlayService(repos.Peer, geo)
+	eventHandlers := events.NewEventHandlers(repos.Peer, validate, geo, overlay, repos.RemoteStatus)
 	eventLoop := events.InitEventLoop(eventHandlers)
-	services := initServices(repos, authHelpers, eventLoop, geo)
+	services := initServices(repos, authHelpers, eventLoop, geo, geocoder, overlay, replication, imageStore)
 	controllers := initControllers(services, validate, geo)
 
 	restaurantModule := &RestaurantModule{repos.Restaurant, services.restaurant, controllers.restaurant}
 	peerModule := &PeerModule{repos.Peer, services.peer, controllers.peer}
-	authMiddleware := middleware.InitAuthMiddleware(restaurantModule.Service)
+	authMiddleware := middleware.InitAuthMiddleware(restaurantModule.Service, services.staff)
+	handoffMiddleware := middleware.InitHandoffMiddleware(services.handoff)
 
-	return &Application{peerModule, restaurantModule, authMiddleware}
+	return &Application{peerModule, restaurantModule, authMiddleware, handoffMiddleware, services.replication, controllers.search, controllers.review, controllers.image, controllers.delivery, services.presence, controllers.presence, controllers.staff, services.migration, controllers.promotion, searchIndex}
 }
@@ -8,24 +8,67 @@ import (
 )
 
 type Application struct {
-	Peer           *PeerModule
-	Restaurant     *RestaurantModule
-	AuthMiddleware *middleware.AuthMiddleware
+	Peer              *PeerModule
+	Restaurant        *RestaurantModule
+	AuthMiddleware    *middleware.AuthMiddleware
+	HandoffMiddleware *middleware.HandoffMiddleware
+	Replication       services.ReplicationServiceI
+	Search            controllers.SearchControllerI
+	Review            controllers.ReviewControllerI
+	Image             controllers.ImageControllerI
+	Delivery          controllers.DeliveryControllerI
+	PresenceService   services.PresenceServiceI
+	Presence          controllers.PresenceControllerI
+	Staff             controllers.StaffControllerI
+	Migration         services.MigrationServiceI
+	Promotion         controllers.PromotionControllerI
+	SearchIndex       services.SearchIndexServiceI
 }
 
 type Repositories struct {
-	Peer       repositories.PeerRepositoryI
-	Restaurant repositories.RestaurantRepositoryI
+	Peer             repositories.PeerRepositoryI
+	Restaurant       repositories.RestaurantRepositoryI
+	GeocodeCache     repositories.GeocodeCacheRepositoryI
+	Tombstone        repositories.RestaurantTombstoneRepositoryI
+	Replication      repositories.ReplicationRepositoryI
+	MenuVersion      repositories.MenuVersionRepositoryI
+	Review           repositories.ReviewRepositoryI
+	DeliveryQuote    repositories.DeliveryQuoteRepositoryI
+	RemoteStatus     repositories.RemoteRestaurantStatusRepositoryI
+	StaffUser        repositories.StaffUserRepositoryI
+	Promotion        repositories.PromotionRepositoryI
+	Redemption       repositories.RedemptionRepositoryI
+	RestaurantSearch repositories.RestaurantSearchRepositoryI
 }
 
 type Services struct {
-	peer       services.PeerServiceI
-	restaurant services.RestaurantServiceI
+	peer         services.PeerServiceI
+	restaurant   services.RestaurantServiceI
+	handoff      services.HandoffServiceI
+	replication  services.ReplicationServiceI
+	menu         services.MenuServiceI
+	menuVersions services.MenuVersionServiceI
+	search       services.SearchServiceI
+	textSearch   services.TextSearchServiceI
+	review       services.ReviewServiceI
+	image        services.ImageServiceI
+	delivery     services.DeliveryServiceI
+	presence     services.PresenceServiceI
+	staff        services.StaffServiceI
+	migration    services.MigrationServiceI
+	promotion    services.PromotionServiceI
 }
 
 type Controllers struct {
 	peer       controllers.PeerControllerI
 	restaurant controllers.RestaurantControllerI
+	search     controllers.SearchControllerI
+	review     controllers.ReviewControllerI
+	image      controllers.ImageControllerI
+	delivery   controllers.DeliveryControllerI
+	presence   controllers.PresenceControllerI
+	staff      controllers.StaffControllerI
+	promotion  controllers.PromotionControllerI
 }
 
 type RestaurantModule struct {
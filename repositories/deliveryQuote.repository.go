@@ -0,0 +1,43 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/nicodeheza/peersEat/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type DeliveryQuoteRepositoryI interface {
+	Insert(quote models.DeliveryQuote) (primitive.ObjectID, error)
+	FindOne(query map[string]interface{}) (models.DeliveryQuote, error)
+}
+
+type DeliveryQuoteRepository struct {
+	coll *mongo.Collection
+}
+
+func NewDeliveryQuoteRepository(collection *mongo.Collection) *DeliveryQuoteRepository {
+	return &DeliveryQuoteRepository{collection}
+}
+
+func (r *DeliveryQuoteRepository) Insert(quote models.DeliveryQuote) (primitive.ObjectID, error) {
+	result, err := r.coll.InsertOne(context.Background(), quote)
+	if err != nil {
+		return primitive.ObjectID{}, err
+	}
+	return result.InsertedID.(primitive.ObjectID), nil
+}
+
+func (r *DeliveryQuoteRepository) FindOne(query map[string]interface{}) (models.DeliveryQuote, error) {
+	filter := bson.D{}
+	for k, v := range query {
+		filter = append(filter, bson.E{Key: k, Value: v})
+	}
+
+	var result models.DeliveryQuote
+	err := r.coll.FindOne(context.Background(), filter).Decode(&result)
+
+	return result, err
+}
@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/nicodeheza/peersEat/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type GeocodeCacheRepositoryI interface {
+	FindByQuery(provider, query string) (models.GeocodeCache, error)
+	Upsert(entry models.GeocodeCache) error
+}
+
+type GeocodeCacheRepository struct {
+	coll *mongo.Collection
+	ttl  time.Duration
+}
+
+func NewGeocodeCacheRepository(collection *mongo.Collection, ttl time.Duration) *GeocodeCacheRepository {
+	return &GeocodeCacheRepository{collection, ttl}
+}
+
+// mongo removes expired documents once a minute, so expired entries are filtered here too
+func (g *GeocodeCacheRepository) FindByQuery(provider, query string) (models.GeocodeCache, error) {
+	filter := bson.D{
+		{Key: "provider", Value: provider},
+		{Key: "query", Value: query},
+		{Key: "createdAt", Value: bson.M{"$gt": time.Now().Add(-g.ttl)}},
+	}
+
+	var result models.GeocodeCache
+	err := g.coll.FindOne(context.Background(), filter).Decode(&result)
+
+	return result, err
+}
+
+func (g *GeocodeCacheRepository) Upsert(entry models.GeocodeCache) error {
+	filter := bson.D{{Key: "provider", Value: entry.Provider}, {Key: "query", Value: entry.Query}}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "coord", Value: entry.Coord},
+		{Key: "confidence", Value: entry.Confidence},
+		{Key: "boundingBox", Value: entry.BoundingBox},
+		{Key: "displayName", Value: entry.DisplayName},
+		{Key: "createdAt", Value: entry.CreatedAt},
+	}}}
+
+	_, err := g.coll.UpdateOne(context.Background(), filter, update, options.Update().SetUpsert(true))
+	return err
+}
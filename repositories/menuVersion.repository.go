@@ -0,0 +1,120 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/nicodeheza/peersEat/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// published versions are only inserted, archiving hides a whole named menu and
+// MigrateMenuMoney only rewrites the versions stored before prices had a currency
+type MenuVersionRepositoryI interface {
+	Insert(version models.MenuVersion) (primitive.ObjectID, error)
+	FindOne(query map[string]interface{}) (models.MenuVersion, error)
+	FindMany(query map[string]interface{}) ([]models.MenuVersion, error)
+	LastVersion(restaurantId primitive.ObjectID, name string) (int, error)
+	Archive(restaurantId primitive.ObjectID, name string) (int64, error)
+	MigrateMenuMoney(id primitive.ObjectID, menu models.Menu) (bool, error)
+}
+
+type MenuVersionRepository struct {
+	coll *mongo.Collection
+}
+
+func NewMenuVersionRepository(collection *mongo.Collection) *MenuVersionRepository {
+	return &MenuVersionRepository{collection}
+}
+
+func (r *MenuVersionRepository) Insert(version models.MenuVersion) (primitive.ObjectID, error) {
+	result, err := r.coll.InsertOne(context.Background(), version)
+	if err != nil {
+		return primitive.ObjectID{}, err
+	}
+	return result.InsertedID.(primitive.ObjectID), nil
+}
+
+func (r *MenuVersionRepository) FindOne(query map[string]interface{}) (models.MenuVersion, error) {
+	filter := bson.D{}
+	for k, v := range query {
+		filter = append(filter, bson.E{Key: k, Value: v})
+	}
+
+	var result models.MenuVersion
+	err := r.coll.FindOne(context.Background(), filter).Decode(&result)
+
+	return result, err
+}
+
+// newest versions first
+func (r *MenuVersionRepository) FindMany(query map[string]interface{}) ([]models.MenuVersion, error) {
+	filter := bson.D{}
+	for k, v := range query {
+		filter = append(filter, bson.E{Key: k, Value: v})
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}, {Key: "version", Value: -1}})
+	cursor, err := r.coll.Find(context.Background(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []models.MenuVersion{}
+	if err = cursor.All(context.Background(), &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r *MenuVersionRepository) LastVersion(restaurantId primitive.ObjectID, name string) (int, error) {
+	filter := bson.D{{Key: "restaurantId", Value: restaurantId}, {Key: "name", Value: name}}
+	opts := options.FindOne().SetSort(bson.D{{Key: "version", Value: -1}})
+
+	var result models.MenuVersion
+	err := r.coll.FindOne(context.Background(), filter, opts).Decode(&result)
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	return result.Version, err
+}
+
+func (r *MenuVersionRepository) Archive(restaurantId primitive.ObjectID, name string) (int64, error) {
+	filter := bson.D{{Key: "restaurantId", Value: restaurantId}, {Key: "name", Value: name}}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "archived", Value: true}}}}
+
+	result, err := r.coll.UpdateMany(context.Background(), filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return result.MatchedCount, nil
+}
+
+// the prices of the menu stored in the version, options of group options are one level deep
+var menuPricePaths = []string{
+	"menu.sections.dishes.price",
+	"menu.sections.dishes.options.price",
+	"menu.sections.dishes.optionGroups.options.pricedelta",
+	"menu.sections.dishes.optionGroups.options.groups.options.pricedelta",
+}
+
+// the version is only matched while some price is still a legacy float or has no
+// currency, so a published menu that was already migrated is never overwritten
+func (r *MenuVersionRepository) MigrateMenuMoney(id primitive.ObjectID, menu models.Menu) (bool, error) {
+	legacy := bson.A{}
+	for _, path := range menuPricePaths {
+		legacy = append(legacy,
+			bson.D{{Key: path, Value: bson.D{{Key: "$type", Value: "number"}}}},
+			bson.D{{Key: path + ".currency", Value: ""}})
+	}
+	filter := bson.D{{Key: "_id", Value: id}, {Key: "$or", Value: legacy}}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "menu", Value: menu}}}}
+
+	result, err := r.coll.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount == 1, nil
+}
@@ -6,9 +6,11 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"regexp"
 	"strings"
 
 	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/utils"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -27,6 +29,9 @@ type PeerRepositoryI interface {
 	GetManyByIds(ids []primitive.ObjectID) ([]models.Peer, error)
 	FindMany(query map[string]interface{}) ([]models.Peer, error)
 	FindByUrlAndUpdate(url string, updates map[string]interface{}) (models.Peer, error)
+	FindByGeohashPrefix(prefix string, excludePrefix string, limit int64) ([]models.Peer, error)
+	Count() (int64, error)
+	SetMissingGeohashes() error
 }
 
 type PeerRepository struct {
@@ -38,6 +43,7 @@ func NewPeerRepository(collection *mongo.Collection) *PeerRepository {
 }
 
 func (p *PeerRepository) Insert(peer models.Peer) (id primitive.ObjectID, err error) {
+	peer.Geohash = utils.Geohash(peer.Center, utils.GEOHASH_PRECISION)
 	result, err := p.coll.InsertOne(context.Background(), peer)
 	if err != nil {
 		return primitive.NewObjectID(), err
@@ -49,6 +55,7 @@ func (p *PeerRepository) InsertMany(peers []models.Peer) (ids []primitive.Object
 	peersToInsert := make([]interface{}, len(peers))
 
 	for i, peer := range peers {
+		peer.Geohash = utils.Geohash(peer.Center, utils.GEOHASH_PRECISION)
 		peersToInsert[i] = peer
 	}
 
@@ -125,6 +132,9 @@ func (p *PeerRepository) Update(peer models.Peer, fields []string) error {
 			return errors.New(message)
 		}
 		updateData = append(updateData, bson.E{Key: strings.ToLower(field), Value: f})
+		if field == "Center" {
+			updateData = append(updateData, bson.E{Key: "geohash", Value: utils.Geohash(peer.Center, utils.GEOHASH_PRECISION)})
+		}
 	}
 
 	update := bson.D{{Key: "$set", Value: updateData}}
@@ -243,3 +253,55 @@ func (p *PeerRepository) FindByUrlAndUpdate(url string, updates map[string]inter
 
 	return *result, nil
 }
+
+// finds the peers whose geohash starts with prefix but not with excludePrefix, a limit of 0 returns all of them
+func (p *PeerRepository) FindByGeohashPrefix(prefix string, excludePrefix string, limit int64) ([]models.Peer, error) {
+	conditions := bson.A{bson.M{"geohash": bson.M{"$regex": "^" + regexp.QuoteMeta(prefix)}}}
+	if excludePrefix != "" {
+		conditions = append(conditions, bson.M{"geohash": bson.M{"$not": primitive.Regex{Pattern: "^" + regexp.QuoteMeta(excludePrefix)}}})
+	}
+	filter := bson.M{"$and": conditions}
+
+	opts := options.Find().SetSort(bson.D{{Key: "geohash", Value: 1}})
+	if limit > 0 {
+		opts.SetLimit(limit)
+	}
+
+	cursor, err := p.coll.Find(context.Background(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []models.Peer
+	if err = cursor.All(context.Background(), &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (p *PeerRepository) Count() (int64, error) {
+	return p.coll.CountDocuments(context.Background(), bson.D{})
+}
+
+// peers stored before the overlay existed have no geohash
+func (p *PeerRepository) SetMissingGeohashes() error {
+	filter := bson.M{"geohash": bson.M{"$exists": false}}
+
+	cursor, err := p.coll.Find(context.Background(), filter)
+	if err != nil {
+		return err
+	}
+
+	var peers []models.Peer
+	if err = cursor.All(context.Background(), &peers); err != nil {
+		return err
+	}
+
+	for _, peer := range peers {
+		update := bson.M{"$set": bson.M{"geohash": utils.Geohash(peer.Center, utils.GEOHASH_PRECISION)}}
+		if _, err := p.coll.UpdateByID(context.Background(), peer.Id, update); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/joho/godotenv"
 	"github.com/nicodeheza/peersEat/config"
 	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/utils"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
@@ -58,6 +59,7 @@ func TestInsertAndFindById(t *testing.T) {
 	}
 
 	newPeer.Id = result.Id
+	newPeer.Geohash = utils.Geohash(newPeer.Center, utils.GEOHASH_PRECISION)
 	if !reflect.DeepEqual(newPeer, result) {
 		t.Errorf("elements are not equal:\n %v\n %v", newPeer, result)
 	}
@@ -122,6 +124,7 @@ func TestInsertManyAndGetAll(t *testing.T) {
 
 	for i, peer := range res {
 		newPeers[i].Id = ids[i]
+		newPeers[i].Geohash = utils.Geohash(newPeers[i].Center, utils.GEOHASH_PRECISION)
 		if !reflect.DeepEqual(peer, newPeers[i]) {
 			t.Errorf("elements are not equal:\n %v\n %v", peer, newPeers[i])
 		}
@@ -158,6 +161,7 @@ func TestGetSelf(t *testing.T) {
 	}
 
 	selfPeer.Id = id
+	selfPeer.Geohash = utils.Geohash(selfPeer.Center, utils.GEOHASH_PRECISION)
 
 	result, err := peerRepository.GetSelf()
 	if err != nil {
@@ -208,6 +212,7 @@ func TestUpdate(t *testing.T) {
 		t.Errorf("get by id failed with error:\n%v", err)
 	}
 
+	peer.Geohash = utils.Geohash(peer.Center, utils.GEOHASH_PRECISION)
 	if !reflect.DeepEqual(result, peer) {
 		t.Errorf("expect peer and receive peer are not equal.\n receive: %v\n expect: %v\n", result, peer)
 	}
@@ -405,3 +410,89 @@ func TestUpdateByUrl(t *testing.T) {
 	}
 
 }
+
+func TestUpdateDeliveryAreaFields(t *testing.T) {
+	coll, server := initPeerDb()
+	defer server.Stop(context.Background())
+
+	peerRepository := PeerRepository{coll}
+
+	peer := models.Peer{
+		Url:            "http://tests.com",
+		Center:         models.GeoCoords{Long: 99.0, Lat: 99.0},
+		City:           "test city",
+		Country:        "test country",
+		DeliveryRadius: 2,
+	}
+
+	id, err := peerRepository.Insert(peer)
+	if err != nil {
+		t.Errorf("fail to insert peer with err:\n%v", err)
+	}
+	peer.Id = id
+
+	// fields are resolved by json tag, struct field names are rejected
+	expectedError := peerRepository.Update(peer, []string{"deliveryRadius"})
+	if expectedError == nil {
+		t.Error("Update should throw and error")
+	}
+
+	inAreaPeers := []primitive.ObjectID{primitive.NewObjectID(), primitive.NewObjectID()}
+	_, err = peerRepository.FindByUrlAndUpdate(peer.Url, map[string]interface{}{
+		"delivery_radius":        5.0,
+		"in_area_delivery_peers": inAreaPeers,
+	})
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	result, err := peerRepository.GetById(peer.Id)
+	if err != nil {
+		t.Errorf("get by id failed with error:\n%v", err)
+	}
+	if result.DeliveryRadius != 5 {
+		t.Errorf("expected delivery radius: 5\n got: %f", result.DeliveryRadius)
+	}
+	if !reflect.DeepEqual(result.InDeliveryAreaPeers, inAreaPeers) {
+		t.Errorf("expected in area delivery peers: %v\n got: %v", inAreaPeers, result.InDeliveryAreaPeers)
+	}
+}
+
+func TestFindByGeohashPrefix(t *testing.T) {
+	coll, server := initPeerDb()
+	defer server.Stop(context.Background())
+
+	peerRepository := PeerRepository{coll}
+
+	peers := []models.Peer{
+		{Url: "http://tests1.com", Center: models.GeoCoords{Long: -58.3826948, Lat: -34.5992499}, City: "c", Country: "c"},
+		{Url: "http://tests2.com", Center: models.GeoCoords{Long: -58.3827, Lat: -34.6001}, City: "c", Country: "c"},
+		{Url: "http://tests3.com", Center: models.GeoCoords{Long: 10.40744, Lat: 57.64911}, City: "c", Country: "c"},
+	}
+
+	_, err := peerRepository.InsertMany(peers)
+	if err != nil {
+		t.Errorf("document InsertMany failed with err: %v", err)
+	}
+
+	result, err := peerRepository.FindByGeohashPrefix("69y", "", 0)
+	if err != nil {
+		t.Errorf("find by geohash prefix failed with err: %v", err)
+	}
+	if len(result) != 2 {
+		t.Errorf("expect 2 peers but gets %d", len(result))
+	}
+
+	result, err = peerRepository.FindByGeohashPrefix("", "69y", 0)
+	if err != nil {
+		t.Errorf("find by geohash prefix failed with err: %v", err)
+	}
+	if len(result) != 1 || result[0].Url != "http://tests3.com" {
+		t.Errorf("expect only http://tests3.com but gets %v", result)
+	}
+
+	count, err := peerRepository.Count()
+	if err != nil || count != 3 {
+		t.Errorf("expect 3 peers but gets %d, err: %v", count, err)
+	}
+}
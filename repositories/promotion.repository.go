@@ -0,0 +1,137 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/nicodeheza/peersEat/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// a nil restaurant id is the scope of the promotions of the whole peer
+type PromotionRepositoryI interface {
+	Insert(promotion models.Promotion) (primitive.ObjectID, error)
+	FindOne(query map[string]interface{}) (models.Promotion, error)
+	FindByScope(restaurantId *primitive.ObjectID) ([]models.Promotion, error)
+	FindApplicable(restaurantId primitive.ObjectID) ([]models.Promotion, error)
+	Update(promotion models.Promotion) error
+	Delete(id primitive.ObjectID) error
+	Use(id primitive.ObjectID) (bool, error)
+	Release(id primitive.ObjectID) error
+}
+
+type PromotionRepository struct {
+	coll *mongo.Collection
+}
+
+func NewPromotionRepository(collection *mongo.Collection) *PromotionRepository {
+	return &PromotionRepository{collection}
+}
+
+func (r *PromotionRepository) Insert(promotion models.Promotion) (primitive.ObjectID, error) {
+	result, err := r.coll.InsertOne(context.Background(), promotion)
+	if err != nil {
+		return primitive.ObjectID{}, err
+	}
+	return result.InsertedID.(primitive.ObjectID), nil
+}
+
+func (r *PromotionRepository) FindOne(query map[string]interface{}) (models.Promotion, error) {
+	filter := bson.D{}
+	for k, v := range query {
+		filter = append(filter, bson.E{Key: k, Value: v})
+	}
+
+	var result models.Promotion
+	err := r.coll.FindOne(context.Background(), filter).Decode(&result)
+
+	return result, err
+}
+
+func (r *PromotionRepository) find(filter bson.D) ([]models.Promotion, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	cursor, err := r.coll.Find(context.Background(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []models.Promotion{}
+	if err = cursor.All(context.Background(), &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// newest first, null also matches the promotions without restaurant id
+func (r *PromotionRepository) FindByScope(restaurantId *primitive.ObjectID) ([]models.Promotion, error) {
+	return r.find(bson.D{{Key: "restaurantId", Value: restaurantId}})
+}
+
+// the promotions of the restaurant and the ones of the whole peer
+func (r *PromotionRepository) FindApplicable(restaurantId primitive.ObjectID) ([]models.Promotion, error) {
+	scopes := bson.A{restaurantId, nil}
+	return r.find(bson.D{{Key: "restaurantId", Value: bson.D{{Key: "$in", Value: scopes}}}})
+}
+
+// replaces every field but the scope, the uses and the creation time
+func (r *PromotionRepository) Update(promotion models.Promotion) error {
+	raw, err := bson.Marshal(promotion)
+	if err != nil {
+		return err
+	}
+	fields := bson.M{}
+	if err = bson.Unmarshal(raw, &fields); err != nil {
+		return err
+	}
+	for _, key := range []string{"_id", "restaurantId", "uses", "createdAt"} {
+		delete(fields, key)
+	}
+
+	filter := bson.D{{Key: "_id", Value: promotion.Id}}
+	result, err := r.coll.UpdateOne(context.Background(), filter, bson.D{{Key: "$set", Value: fields}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (r *PromotionRepository) Delete(id primitive.ObjectID) error {
+	result, err := r.coll.DeleteOne(context.Background(), bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// counts a use, returns false when the promotion reached its max uses
+func (r *PromotionRepository) Use(id primitive.ObjectID) (bool, error) {
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "maxUses", Value: 0}},
+			bson.D{{Key: "$expr", Value: bson.D{{Key: "$lt", Value: bson.A{"$uses", "$maxUses"}}}}},
+		}},
+	}
+	update := bson.D{{Key: "$inc", Value: bson.D{{Key: "uses", Value: 1}}}}
+	result, err := r.coll.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// gives back a use counted for a cart that couldn't be redeemed
+func (r *PromotionRepository) Release(id primitive.ObjectID) error {
+	filter := bson.D{{Key: "_id", Value: id}, {Key: "uses", Value: bson.D{{Key: "$gt", Value: 0}}}}
+	update := bson.D{{Key: "$inc", Value: bson.D{{Key: "uses", Value: -1}}}}
+	_, err := r.coll.UpdateOne(context.Background(), filter, update)
+	return err
+}
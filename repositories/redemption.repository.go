@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/nicodeheza/peersEat/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type RedemptionRepositoryI interface {
+	Insert(redemption models.Redemption) (primitive.ObjectID, error)
+	FindOne(query map[string]interface{}) (models.Redemption, error)
+	Count(query map[string]interface{}) (int64, error)
+}
+
+type RedemptionRepository struct {
+	coll *mongo.Collection
+}
+
+func NewRedemptionRepository(collection *mongo.Collection) *RedemptionRepository {
+	return &RedemptionRepository{collection}
+}
+
+func (r *RedemptionRepository) Insert(redemption models.Redemption) (primitive.ObjectID, error) {
+	result, err := r.coll.InsertOne(context.Background(), redemption)
+	if err != nil {
+		return primitive.ObjectID{}, err
+	}
+	return result.InsertedID.(primitive.ObjectID), nil
+}
+
+func (r *RedemptionRepository) FindOne(query map[string]interface{}) (models.Redemption, error) {
+	filter := bson.D{}
+	for k, v := range query {
+		filter = append(filter, bson.E{Key: k, Value: v})
+	}
+
+	var result models.Redemption
+	err := r.coll.FindOne(context.Background(), filter).Decode(&result)
+
+	return result, err
+}
+
+// a promotion id in the query matches the redemptions it was applied to
+func (r *RedemptionRepository) Count(query map[string]interface{}) (int64, error) {
+	filter := bson.D{}
+	for k, v := range query {
+		filter = append(filter, bson.E{Key: k, Value: v})
+	}
+	return r.coll.CountDocuments(context.Background(), filter)
+}
@@ -0,0 +1,48 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/nicodeheza/peersEat/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type RemoteRestaurantStatusRepositoryI interface {
+	Upsert(status models.RemoteRestaurantStatus) error
+	FindOne(id primitive.ObjectID) (models.RemoteRestaurantStatus, error)
+	FindMany(ids []primitive.ObjectID) ([]models.RemoteRestaurantStatus, error)
+}
+
+type RemoteRestaurantStatusRepository struct {
+	coll *mongo.Collection
+}
+
+func NewRemoteRestaurantStatusRepository(collection *mongo.Collection) *RemoteRestaurantStatusRepository {
+	return &RemoteRestaurantStatusRepository{collection}
+}
+
+func (r *RemoteRestaurantStatusRepository) Upsert(status models.RemoteRestaurantStatus) error {
+	_, err := r.coll.ReplaceOne(context.Background(), bson.D{{Key: "_id", Value: status.RestaurantId}}, status, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (r *RemoteRestaurantStatusRepository) FindOne(id primitive.ObjectID) (models.RemoteRestaurantStatus, error) {
+	var result models.RemoteRestaurantStatus
+	err := r.coll.FindOne(context.Background(), bson.D{{Key: "_id", Value: id}}).Decode(&result)
+	return result, err
+}
+
+func (r *RemoteRestaurantStatusRepository) FindMany(ids []primitive.ObjectID) ([]models.RemoteRestaurantStatus, error) {
+	filter := bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}}
+	cursor, err := r.coll.Find(context.Background(), filter)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []models.RemoteRestaurantStatus{}
+	err = cursor.All(context.Background(), &results)
+	return results, err
+}
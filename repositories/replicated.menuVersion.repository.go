@@ -0,0 +1,44 @@
+package repositories
+
+import (
+	"github.com/nicodeheza/peersEat/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// published versions travel with their restaurant, so every write marks it changed
+type ReplicatedMenuVersionRepository struct {
+	MenuVersionRepositoryI
+	listener RestaurantChangeListenerI
+}
+
+func NewReplicatedMenuVersionRepository(inner MenuVersionRepositoryI, listener RestaurantChangeListenerI) *ReplicatedMenuVersionRepository {
+	return &ReplicatedMenuVersionRepository{inner, listener}
+}
+
+func (r *ReplicatedMenuVersionRepository) Insert(version models.MenuVersion) (primitive.ObjectID, error) {
+	id, err := r.MenuVersionRepositoryI.Insert(version)
+	if err == nil {
+		r.listener.RestaurantChanged(version.RestaurantId)
+	}
+	return id, err
+}
+
+func (r *ReplicatedMenuVersionRepository) Archive(restaurantId primitive.ObjectID, name string) (int64, error) {
+	archived, err := r.MenuVersionRepositoryI.Archive(restaurantId, name)
+	if err == nil && archived > 0 {
+		r.listener.RestaurantChanged(restaurantId)
+	}
+	return archived, err
+}
+
+func (r *ReplicatedMenuVersionRepository) MigrateMenuMoney(id primitive.ObjectID, menu models.Menu) (bool, error) {
+	migrated, err := r.MenuVersionRepositoryI.MigrateMenuMoney(id, menu)
+	if err != nil || !migrated {
+		return migrated, err
+	}
+	version, err := r.MenuVersionRepositoryI.FindOne(map[string]interface{}{"_id": id})
+	if err == nil {
+		r.listener.RestaurantChanged(version.RestaurantId)
+	}
+	return true, nil
+}
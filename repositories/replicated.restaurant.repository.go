@@ -0,0 +1,92 @@
+package repositories
+
+import (
+	"github.com/nicodeheza/peersEat/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type RestaurantChangeListenerI interface {
+	RestaurantChanged(id primitive.ObjectID)
+}
+
+// notifies every restaurant write so it can be replicated to the buddy peers
+type ReplicatedRestaurantRepository struct {
+	RestaurantRepositoryI
+	listener RestaurantChangeListenerI
+}
+
+func NewReplicatedRestaurantRepository(inner RestaurantRepositoryI, listener RestaurantChangeListenerI) *ReplicatedRestaurantRepository {
+	return &ReplicatedRestaurantRepository{inner, listener}
+}
+
+func (r *ReplicatedRestaurantRepository) Insert(restaurant models.Restaurant) (primitive.ObjectID, error) {
+	id, err := r.RestaurantRepositoryI.Insert(restaurant)
+	if err == nil {
+		r.listener.RestaurantChanged(id)
+	}
+	return id, err
+}
+
+func (r *ReplicatedRestaurantRepository) Update(id primitive.ObjectID, updates map[string]interface{}) error {
+	err := r.RestaurantRepositoryI.Update(id, updates)
+	if err == nil {
+		r.listener.RestaurantChanged(id)
+	}
+	return err
+}
+
+func (r *ReplicatedRestaurantRepository) UpdateWhere(id primitive.ObjectID, query map[string]interface{}, set map[string]interface{}, unset []string) (bool, error) {
+	updated, err := r.RestaurantRepositoryI.UpdateWhere(id, query, set, unset)
+	if err == nil && updated {
+		r.listener.RestaurantChanged(id)
+	}
+	return updated, err
+}
+
+func (r *ReplicatedRestaurantRepository) IncrementRate(id primitive.ObjectID, stars int, votes int) error {
+	err := r.RestaurantRepositoryI.IncrementRate(id, stars, votes)
+	if err == nil {
+		r.listener.RestaurantChanged(id)
+	}
+	return err
+}
+
+func (r *ReplicatedRestaurantRepository) Delete(id primitive.ObjectID) error {
+	err := r.RestaurantRepositoryI.Delete(id)
+	if err == nil {
+		r.listener.RestaurantChanged(id)
+	}
+	return err
+}
+
+func (r *ReplicatedRestaurantRepository) PushMenuItem(id primitive.ObjectID, path models.MenuPath, item interface{}) error {
+	err := r.RestaurantRepositoryI.PushMenuItem(id, path, item)
+	if err == nil {
+		r.listener.RestaurantChanged(id)
+	}
+	return err
+}
+
+func (r *ReplicatedRestaurantRepository) UpdateMenuItem(id primitive.ObjectID, path models.MenuPath, updates map[string]interface{}) error {
+	err := r.RestaurantRepositoryI.UpdateMenuItem(id, path, updates)
+	if err == nil {
+		r.listener.RestaurantChanged(id)
+	}
+	return err
+}
+
+func (r *ReplicatedRestaurantRepository) PullMenuItem(id primitive.ObjectID, path models.MenuPath) error {
+	err := r.RestaurantRepositoryI.PullMenuItem(id, path)
+	if err == nil {
+		r.listener.RestaurantChanged(id)
+	}
+	return err
+}
+
+func (r *ReplicatedRestaurantRepository) SetMenuItems(id primitive.ObjectID, path models.MenuPath, items interface{}) error {
+	err := r.RestaurantRepositoryI.SetMenuItems(id, path, items)
+	if err == nil {
+		r.listener.RestaurantChanged(id)
+	}
+	return err
+}
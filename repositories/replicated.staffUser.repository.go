@@ -0,0 +1,65 @@
+package repositories
+
+import (
+	"github.com/nicodeheza/peersEat/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// staff users travel with their restaurant, so every write marks it changed
+type ReplicatedStaffUserRepository struct {
+	StaffUserRepositoryI
+	listener RestaurantChangeListenerI
+}
+
+func NewReplicatedStaffUserRepository(inner StaffUserRepositoryI, listener RestaurantChangeListenerI) *ReplicatedStaffUserRepository {
+	return &ReplicatedStaffUserRepository{inner, listener}
+}
+
+func (r *ReplicatedStaffUserRepository) Insert(user models.StaffUser) (primitive.ObjectID, error) {
+	id, err := r.StaffUserRepositoryI.Insert(user)
+	if err == nil {
+		r.listener.RestaurantChanged(user.RestaurantId)
+	}
+	return id, err
+}
+
+func (r *ReplicatedStaffUserRepository) Update(id primitive.ObjectID, set map[string]interface{}, unset []string) error {
+	err := r.StaffUserRepositoryI.Update(id, set, unset)
+	if err == nil {
+		r.changed(id)
+	}
+	return err
+}
+
+// the restaurant is read before the user is gone
+func (r *ReplicatedStaffUserRepository) Delete(id primitive.ObjectID) error {
+	user, findErr := r.StaffUserRepositoryI.FindOne(map[string]interface{}{"_id": id})
+	err := r.StaffUserRepositoryI.Delete(id)
+	if err == nil && findErr == nil {
+		r.listener.RestaurantChanged(user.RestaurantId)
+	}
+	return err
+}
+
+func (r *ReplicatedStaffUserRepository) Upsert(user models.StaffUser) error {
+	err := r.StaffUserRepositoryI.Upsert(user)
+	if err == nil {
+		r.listener.RestaurantChanged(user.RestaurantId)
+	}
+	return err
+}
+
+func (r *ReplicatedStaffUserRepository) DeleteByRestaurant(restaurantId primitive.ObjectID) error {
+	err := r.StaffUserRepositoryI.DeleteByRestaurant(restaurantId)
+	if err == nil {
+		r.listener.RestaurantChanged(restaurantId)
+	}
+	return err
+}
+
+func (r *ReplicatedStaffUserRepository) changed(id primitive.ObjectID) {
+	user, err := r.StaffUserRepositoryI.FindOne(map[string]interface{}{"_id": id})
+	if err == nil {
+		r.listener.RestaurantChanged(user.RestaurantId)
+	}
+}
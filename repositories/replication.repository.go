@@ -0,0 +1,135 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"github.com/nicodeheza/peersEat/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrReplicaOtherOrigin = errors.New("replica belongs to another origin")
+
+type ReplicationRepositoryI interface {
+	FindLink(url string, role string) (models.ReplicationLink, error)
+	FindLinks(role string) ([]models.ReplicationLink, error)
+	UpsertLink(link models.ReplicationLink) error
+	DeleteLink(url string, role string) error
+	UpsertReplica(replica models.RestaurantReplica) error
+	DeleteReplica(id primitive.ObjectID, origin string) error
+	DeleteReplicasNotIn(origin string, ids []primitive.ObjectID) error
+	FindReplica(id primitive.ObjectID) (models.RestaurantReplica, error)
+	FindReplicasByOrigin(origin string) ([]models.RestaurantReplica, error)
+}
+
+type ReplicationRepository struct {
+	links    *mongo.Collection
+	replicas *mongo.Collection
+}
+
+func NewReplicationRepository(links *mongo.Collection, replicas *mongo.Collection) *ReplicationRepository {
+	return &ReplicationRepository{links, replicas}
+}
+
+func (r *ReplicationRepository) FindLink(url string, role string) (models.ReplicationLink, error) {
+	filter := bson.D{{Key: "url", Value: url}, {Key: "role", Value: role}}
+
+	var result models.ReplicationLink
+	err := r.links.FindOne(context.Background(), filter).Decode(&result)
+
+	return result, err
+}
+
+func (r *ReplicationRepository) FindLinks(role string) ([]models.ReplicationLink, error) {
+	filter := bson.D{{Key: "role", Value: role}}
+
+	cursor, err := r.links.Find(context.Background(), filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []models.ReplicationLink
+	if err = cursor.All(context.Background(), &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r *ReplicationRepository) UpsertLink(link models.ReplicationLink) error {
+	filter := bson.D{{Key: "url", Value: link.Url}, {Key: "role", Value: link.Role}}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "secret", Value: link.Secret},
+		{Key: "seq", Value: link.Seq},
+		{Key: "synced", Value: link.Synced},
+		{Key: "lastSeen", Value: link.LastSeen},
+	}}}
+
+	_, err := r.links.UpdateOne(context.Background(), filter, update, options.Update().SetUpsert(true))
+	return err
+}
+
+func (r *ReplicationRepository) DeleteLink(url string, role string) error {
+	filter := bson.D{{Key: "url", Value: url}, {Key: "role", Value: role}}
+	_, err := r.links.DeleteOne(context.Background(), filter)
+	return err
+}
+
+// an origin can only write its own replicas, when the id is replicated from
+// another origin the upsert collides with it
+func (r *ReplicationRepository) UpsertReplica(replica models.RestaurantReplica) error {
+	filter := bson.D{{Key: "_id", Value: replica.Id}, {Key: "origin", Value: replica.Origin}}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "restaurant", Value: replica.Restaurant},
+		{Key: "menuVersions", Value: replica.MenuVersions},
+		{Key: "updatedAt", Value: replica.UpdatedAt},
+	}}}
+
+	_, err := r.replicas.UpdateOne(context.Background(), filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrReplicaOtherOrigin
+	}
+	return err
+}
+
+func (r *ReplicationRepository) DeleteReplica(id primitive.ObjectID, origin string) error {
+	filter := bson.D{{Key: "_id", Value: id}, {Key: "origin", Value: origin}}
+	_, err := r.replicas.DeleteOne(context.Background(), filter)
+	return err
+}
+
+// removes the replicas of origin missing from a full snapshot
+func (r *ReplicationRepository) DeleteReplicasNotIn(origin string, ids []primitive.ObjectID) error {
+	if ids == nil {
+		ids = []primitive.ObjectID{}
+	}
+	filter := bson.D{{Key: "origin", Value: origin}, {Key: "_id", Value: bson.M{"$nin": ids}}}
+	_, err := r.replicas.DeleteMany(context.Background(), filter)
+	return err
+}
+
+func (r *ReplicationRepository) FindReplica(id primitive.ObjectID) (models.RestaurantReplica, error) {
+	filter := bson.D{{Key: "_id", Value: id}}
+
+	var result models.RestaurantReplica
+	err := r.replicas.FindOne(context.Background(), filter).Decode(&result)
+
+	return result, err
+}
+
+func (r *ReplicationRepository) FindReplicasByOrigin(origin string) ([]models.RestaurantReplica, error) {
+	filter := bson.D{{Key: "origin", Value: origin}}
+
+	cursor, err := r.replicas.Find(context.Background(), filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []models.RestaurantReplica
+	if err = cursor.All(context.Background(), &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -2,17 +2,28 @@ package repositories
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
 	"github.com/nicodeheza/peersEat/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type RestaurantRepositoryI interface {
 	Insert(restaurant models.Restaurant) (id primitive.ObjectID, err error)
 	FindOne(query map[string]interface{}) (models.Restaurant, error)
+	FindMany(query map[string]interface{}) ([]models.Restaurant, error)
 	Update(id primitive.ObjectID, updates map[string]interface{}) error
+	UpdateWhere(id primitive.ObjectID, query map[string]interface{}, set map[string]interface{}, unset []string) (bool, error)
+	Delete(id primitive.ObjectID) error
+	PushMenuItem(id primitive.ObjectID, path models.MenuPath, item interface{}) error
+	UpdateMenuItem(id primitive.ObjectID, path models.MenuPath, updates map[string]interface{}) error
+	PullMenuItem(id primitive.ObjectID, path models.MenuPath) error
+	SetMenuItems(id primitive.ObjectID, path models.MenuPath, items interface{}) error
+	IncrementRate(id primitive.ObjectID, stars int, votes int) error
 }
 
 type RestaurantRepository struct {
@@ -43,9 +54,177 @@ func (r *RestaurantRepository) FindOne(query map[string]interface{}) (models.Res
 	return result, err
 }
 
+func (r *RestaurantRepository) FindMany(query map[string]interface{}) ([]models.Restaurant, error) {
+	filter := bson.D{}
+	for k, v := range query {
+		filter = append(filter, bson.E{Key: k, Value: v})
+	}
+	cursor, err := r.coll.Find(context.Background(), filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []models.Restaurant
+	for cursor.Next(context.Background()) {
+		var restaurant models.Restaurant
+		if err := cursor.Decode(&restaurant); err != nil {
+			return result, err
+		}
+		result = append(result, restaurant)
+	}
+	return result, nil
+}
+
 func (r *RestaurantRepository) Update(id primitive.ObjectID, updates map[string]interface{}) error {
 	filter := bson.D{{Key: "_id", Value: id}}
 	update := bson.D{{Key: "$set", Value: updates}}
 	_, err := r.coll.UpdateOne(context.Background(), filter, update)
 	return err
 }
+
+// updates the restaurant only while it still matches query, false when it doesn't so
+// only one of concurrent requests can change it
+func (r *RestaurantRepository) UpdateWhere(id primitive.ObjectID, query map[string]interface{}, set map[string]interface{}, unset []string) (bool, error) {
+	filter := bson.D{{Key: "_id", Value: id}}
+	for k, v := range query {
+		filter = append(filter, bson.E{Key: k, Value: v})
+	}
+	update := bson.D{{Key: "$set", Value: set}}
+	if len(unset) > 0 {
+		fields := bson.D{}
+		for _, field := range unset {
+			fields = append(fields, bson.E{Key: field, Value: ""})
+		}
+		update = append(update, bson.E{Key: "$unset", Value: fields})
+	}
+
+	result, err := r.coll.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount == 1, nil
+}
+
+// stars and votes can be negative when a review is changed
+func (r *RestaurantRepository) IncrementRate(id primitive.ObjectID, stars int, votes int) error {
+	filter := bson.D{{Key: "_id", Value: id}}
+	update := bson.D{{Key: "$inc", Value: bson.D{{Key: "rate.stars", Value: stars}, {Key: "rate.votes", Value: votes}}}}
+	_, err := r.coll.UpdateOne(context.Background(), filter, update)
+	return err
+}
+
+func (r *RestaurantRepository) Delete(id primitive.ObjectID) error {
+	filter := bson.D{{Key: "_id", Value: id}}
+	_, err := r.coll.DeleteOne(context.Background(), filter)
+	return err
+}
+
+var menuLevels = []string{"sections", "dishes", "options"}
+var menuFilterNames = []string{"section", "dish", "option"}
+
+// returns the field of the entity at ids, its array filters and a query
+// matching only restaurants where the whole path exists
+func menuField(ids []primitive.ObjectID) (string, []interface{}, bson.E) {
+	field := "menu"
+	filters := []interface{}{}
+	for i, id := range ids {
+		field += fmt.Sprintf(".%s.$[%s]", menuLevels[i], menuFilterNames[i])
+		filters = append(filters, bson.M{menuFilterNames[i] + "._id": id})
+	}
+
+	if len(ids) == 0 {
+		return field, filters, bson.E{}
+	}
+	match := bson.M{"_id": ids[len(ids)-1]}
+	for i := len(ids) - 2; i >= 0; i-- {
+		match = bson.M{"_id": ids[i], menuLevels[i+1]: bson.M{"$elemMatch": match}}
+	}
+	return field, filters, bson.E{Key: "menu.sections", Value: bson.M{"$elemMatch": match}}
+}
+
+func (r *RestaurantRepository) updateMenu(id primitive.ObjectID, ids []primitive.ObjectID, update bson.D, filters []interface{}) error {
+	filter := bson.D{{Key: "_id", Value: id}}
+	_, _, match := menuField(ids)
+	if match.Key != "" {
+		filter = append(filter, match)
+	}
+
+	opts := options.Update()
+	if len(filters) > 0 {
+		opts.SetArrayFilters(options.ArrayFilters{Filters: filters})
+	}
+	result, err := r.coll.UpdateOne(context.Background(), filter, update, opts)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// adds item at the end of the children of path
+func (r *RestaurantRepository) PushMenuItem(id primitive.ObjectID, path models.MenuPath, item interface{}) error {
+	ids := path.Ids()
+	if len(ids) == len(menuLevels) {
+		return errors.New("options have no children")
+	}
+	field, filters, _ := menuField(ids)
+	children := field + "." + menuLevels[len(ids)]
+
+	// older documents stored empty children as null, which can not be pushed to
+	filter := bson.D{{Key: "_id", Value: id}}
+	nullFilters := append([]interface{}{}, filters...)
+	if len(ids) == 0 {
+		filter = append(filter, bson.E{Key: children, Value: nil})
+	} else {
+		last := len(ids) - 1
+		nullFilters[last] = bson.M{menuFilterNames[last] + "._id": ids[last], menuFilterNames[last] + "." + menuLevels[len(ids)]: nil}
+	}
+	opts := options.Update()
+	if len(nullFilters) > 0 {
+		opts.SetArrayFilters(options.ArrayFilters{Filters: nullFilters})
+	}
+	_, err := r.coll.UpdateOne(context.Background(), filter, bson.D{{Key: "$set", Value: bson.D{{Key: children, Value: bson.A{}}}}}, opts)
+	if err != nil {
+		return err
+	}
+
+	update := bson.D{{Key: "$push", Value: bson.D{{Key: children, Value: item}}}}
+	return r.updateMenu(id, ids, update, filters)
+}
+
+func (r *RestaurantRepository) UpdateMenuItem(id primitive.ObjectID, path models.MenuPath, updates map[string]interface{}) error {
+	ids := path.Ids()
+	if len(ids) == 0 || len(updates) == 0 {
+		return errors.New("nothing to update")
+	}
+	field, filters, _ := menuField(ids)
+	set := bson.D{}
+	for k, v := range updates {
+		set = append(set, bson.E{Key: field + "." + k, Value: v})
+	}
+	return r.updateMenu(id, ids, bson.D{{Key: "$set", Value: set}}, filters)
+}
+
+func (r *RestaurantRepository) PullMenuItem(id primitive.ObjectID, path models.MenuPath) error {
+	ids := path.Ids()
+	if len(ids) == 0 {
+		return errors.New("nothing to delete")
+	}
+	parent := ids[:len(ids)-1]
+	field, filters, _ := menuField(parent)
+	update := bson.D{{Key: "$pull", Value: bson.D{{Key: field + "." + menuLevels[len(parent)], Value: bson.M{"_id": ids[len(ids)-1]}}}}}
+	return r.updateMenu(id, ids, update, filters)
+}
+
+// replaces the children of path, used to reorder them
+func (r *RestaurantRepository) SetMenuItems(id primitive.ObjectID, path models.MenuPath, items interface{}) error {
+	ids := path.Ids()
+	if len(ids) == len(menuLevels) {
+		return errors.New("options have no children")
+	}
+	field, filters, _ := menuField(ids)
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: field + "." + menuLevels[len(ids)], Value: items}}}}
+	return r.updateMenu(id, ids, update, filters)
+}
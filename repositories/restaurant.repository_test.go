@@ -11,6 +11,8 @@ import (
 	"github.com/joho/godotenv"
 	"github.com/nicodeheza/peersEat/config"
 	"github.com/nicodeheza/peersEat/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
@@ -86,6 +88,37 @@ func TestInsertAndFindOne(t *testing.T) {
 	}
 }
 
+func TestFindManyRestaurants(t *testing.T) {
+	coll, server := initRestaurantDb()
+	defer server.Stop(context.Background())
+
+	rr := RestaurantRepository{coll}
+
+	newRestaurants := []models.Restaurant{
+		{Name: "test1", Address: "testAddress1", City: "testCity", Country: "testCountry", UserName: "test1"},
+		{Name: "test2", Address: "testAddress2", City: "testCity", Country: "testCountry", UserName: "test2"},
+		{Name: "test3", Address: "testAddress3", City: "otherCity", Country: "testCountry", UserName: "test3"},
+	}
+	for _, restaurant := range newRestaurants {
+		if _, err := rr.Insert(restaurant); err != nil {
+			t.Errorf("fail to insert restaurant with error: %v", err)
+		}
+	}
+
+	result, err := rr.FindMany(map[string]interface{}{"city": "testCity"})
+	if err != nil {
+		t.Errorf("fail to find restaurants with error: %v", err)
+	}
+	if len(result) != 2 {
+		t.Errorf("expecting 2 restaurants but got %d", len(result))
+	}
+	for _, restaurant := range result {
+		if restaurant.City != "testCity" {
+			t.Errorf("incorrect restaurant city.\n expected: testCity\n got: %v\n", restaurant.City)
+		}
+	}
+}
+
 func TestUpdateRestaurant(t *testing.T) {
 	coll, server := initRestaurantDb()
 	defer server.Stop(context.Background())
@@ -142,3 +175,48 @@ func TestUpdateRestaurant(t *testing.T) {
 		t.Errorf("incorrect restaurant isFinalPassword.\n expected: %v\n got: %v\n", updates["isFinalPassword"], res.IsFinalPassword)
 	}
 }
+
+func TestPushMenuItemOnNullChildren(t *testing.T) {
+	coll, server := initRestaurantDb()
+	defer server.Stop(context.Background())
+
+	rr := RestaurantRepository{coll}
+	id, err := rr.Insert(models.Restaurant{Name: "test", UserName: "testUsername"})
+	if err != nil {
+		t.Errorf("fail to insert restaurant with error: %v", err)
+	}
+
+	// documents stored before the menu had omitempty tags
+	_, err = coll.UpdateOne(context.Background(), bson.M{"_id": id}, bson.M{"$set": bson.M{"menu.sections": nil}})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	section := models.MenuSection{Id: primitive.NewObjectID(), Name: "pizzas"}
+	err = rr.PushMenuItem(id, models.MenuPath{}, section)
+	if err != nil {
+		t.Fatalf("fail to push to null sections with error: %v", err)
+	}
+
+	_, err = coll.UpdateOne(context.Background(), bson.M{"_id": id}, bson.M{"$set": bson.M{"menu.sections.0.dishes": nil}})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	dish := models.Dish{Id: primitive.NewObjectID(), Name: "muzzarella"}
+	err = rr.PushMenuItem(id, models.MenuPath{SectionId: section.Id}, dish)
+	if err != nil {
+		t.Fatalf("fail to push to null dishes with error: %v", err)
+	}
+
+	res, err := rr.FindOne(map[string]interface{}{"_id": id})
+	if err != nil {
+		t.Errorf("fail to find restaurant with error: %v", err)
+	}
+	if len(res.Menu.Sections) != 1 || res.Menu.Sections[0].Id != section.Id {
+		t.Fatalf("expecting the pushed section but got %v", res.Menu.Sections)
+	}
+	if len(res.Menu.Sections[0].Dishes) != 1 || res.Menu.Sections[0].Dishes[0].Id != dish.Id {
+		t.Errorf("expecting the pushed dish but got %v", res.Menu.Sections[0].Dishes)
+	}
+}
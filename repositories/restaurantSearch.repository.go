@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/nicodeheza/peersEat/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SearchTerm matches the documents sharing one of its keys, a prefix term also
+// matches the ones with a word starting with it
+type SearchTerm struct {
+	Word   string
+	Keys   []string
+	Prefix bool
+}
+
+type RestaurantSearchRepositoryI interface {
+	Upsert(search models.RestaurantSearch) error
+	Delete(restaurantId primitive.ObjectID) error
+	Match(city string, terms []SearchTerm) ([]primitive.ObjectID, error)
+}
+
+type RestaurantSearchRepository struct {
+	coll *mongo.Collection
+}
+
+func NewRestaurantSearchRepository(collection *mongo.Collection) *RestaurantSearchRepository {
+	return &RestaurantSearchRepository{collection}
+}
+
+func (r *RestaurantSearchRepository) Upsert(search models.RestaurantSearch) error {
+	filter := bson.D{{Key: "_id", Value: search.RestaurantId}}
+	_, err := r.coll.ReplaceOne(context.Background(), filter, search, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (r *RestaurantSearchRepository) Delete(restaurantId primitive.ObjectID) error {
+	_, err := r.coll.DeleteOne(context.Background(), bson.D{{Key: "_id", Value: restaurantId}})
+	return err
+}
+
+// every term has to match, the city match is case insensitive
+func (r *RestaurantSearchRepository) Match(city string, terms []SearchTerm) ([]primitive.ObjectID, error) {
+	filter := bson.D{}
+	if city != "" {
+		filter = append(filter, bson.E{Key: "city", Value: primitive.Regex{Pattern: "^" + regexp.QuoteMeta(city) + "$", Options: "i"}})
+	}
+	matches := bson.A{}
+	for _, term := range terms {
+		or := bson.A{bson.D{{Key: "keys", Value: bson.D{{Key: "$in", Value: term.Keys}}}}}
+		if term.Prefix {
+			or = append(or, bson.D{{Key: "words", Value: primitive.Regex{Pattern: "^" + regexp.QuoteMeta(term.Word)}}})
+		}
+		matches = append(matches, bson.D{{Key: "$or", Value: or}})
+	}
+	if len(matches) > 0 {
+		filter = append(filter, bson.E{Key: "$and", Value: matches})
+	}
+
+	opts := options.Find().SetProjection(bson.D{{Key: "_id", Value: 1}})
+	cursor, err := r.coll.Find(context.Background(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []models.RestaurantSearch
+	if err = cursor.All(context.Background(), &result); err != nil {
+		return nil, err
+	}
+	ids := []primitive.ObjectID{}
+	for _, search := range result {
+		ids = append(ids, search.RestaurantId)
+	}
+	return ids, nil
+}
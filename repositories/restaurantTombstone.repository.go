@@ -0,0 +1,58 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/nicodeheza/peersEat/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type RestaurantTombstoneRepositoryI interface {
+	Upsert(tombstone models.RestaurantTombstone) error
+	FindOne(query map[string]interface{}) (models.RestaurantTombstone, error)
+	DeleteByRestaurantIds(ids []primitive.ObjectID) error
+}
+
+type RestaurantTombstoneRepository struct {
+	coll *mongo.Collection
+}
+
+func NewRestaurantTombstoneRepository(collection *mongo.Collection) *RestaurantTombstoneRepository {
+	return &RestaurantTombstoneRepository{collection}
+}
+
+func (r *RestaurantTombstoneRepository) Upsert(tombstone models.RestaurantTombstone) error {
+	filter := bson.D{{Key: "restaurantId", Value: tombstone.RestaurantId}}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "userName", Value: tombstone.UserName},
+		{Key: "staffUserNames", Value: tombstone.StaffUserNames},
+		{Key: "movedTo", Value: tombstone.MovedTo},
+		{Key: "expiresAt", Value: tombstone.ExpiresAt},
+	}}}
+
+	_, err := r.coll.UpdateOne(context.Background(), filter, update, options.Update().SetUpsert(true))
+	return err
+}
+
+// mongo removes expired documents once a minute, so expired tombstones are filtered here too
+func (r *RestaurantTombstoneRepository) FindOne(query map[string]interface{}) (models.RestaurantTombstone, error) {
+	filter := bson.D{{Key: "expiresAt", Value: bson.M{"$gt": time.Now()}}}
+	for k, v := range query {
+		filter = append(filter, bson.E{Key: k, Value: v})
+	}
+
+	var result models.RestaurantTombstone
+	err := r.coll.FindOne(context.Background(), filter).Decode(&result)
+
+	return result, err
+}
+
+func (r *RestaurantTombstoneRepository) DeleteByRestaurantIds(ids []primitive.ObjectID) error {
+	filter := bson.D{{Key: "restaurantId", Value: bson.M{"$in": ids}}}
+	_, err := r.coll.DeleteMany(context.Background(), filter)
+	return err
+}
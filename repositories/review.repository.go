@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/nicodeheza/peersEat/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ReviewRepositoryI interface {
+	Insert(review models.Review) (primitive.ObjectID, error)
+	FindOne(query map[string]interface{}) (models.Review, error)
+	FindPage(restaurantId primitive.ObjectID, sort string, skip int64, limit int64) ([]models.Review, error)
+	Count(restaurantId primitive.ObjectID) (int64, error)
+	Update(id primitive.ObjectID, updates map[string]interface{}) error
+	MarkHelpful(id primitive.ObjectID, orderId string) (bool, error)
+}
+
+type ReviewRepository struct {
+	coll *mongo.Collection
+}
+
+func NewReviewRepository(collection *mongo.Collection) *ReviewRepository {
+	return &ReviewRepository{collection}
+}
+
+func (r *ReviewRepository) Insert(review models.Review) (primitive.ObjectID, error) {
+	result, err := r.coll.InsertOne(context.Background(), review)
+	if err != nil {
+		return primitive.ObjectID{}, err
+	}
+	return result.InsertedID.(primitive.ObjectID), nil
+}
+
+func (r *ReviewRepository) FindOne(query map[string]interface{}) (models.Review, error) {
+	filter := bson.D{}
+	for k, v := range query {
+		filter = append(filter, bson.E{Key: k, Value: v})
+	}
+
+	var result models.Review
+	err := r.coll.FindOne(context.Background(), filter).Decode(&result)
+
+	return result, err
+}
+
+// newest first, or most helpful first and then newest
+func (r *ReviewRepository) FindPage(restaurantId primitive.ObjectID, sort string, skip int64, limit int64) ([]models.Review, error) {
+	filter := bson.D{{Key: "restaurantId", Value: restaurantId}}
+
+	order := bson.D{{Key: "createdAt", Value: -1}}
+	if sort == models.REVIEW_SORT_HELPFUL {
+		order = bson.D{{Key: "helpful", Value: -1}, {Key: "createdAt", Value: -1}}
+	}
+	opts := options.Find().SetSort(order).SetSkip(skip).SetLimit(limit)
+
+	cursor, err := r.coll.Find(context.Background(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []models.Review{}
+	if err = cursor.All(context.Background(), &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r *ReviewRepository) Count(restaurantId primitive.ObjectID) (int64, error) {
+	return r.coll.CountDocuments(context.Background(), bson.D{{Key: "restaurantId", Value: restaurantId}})
+}
+
+func (r *ReviewRepository) Update(id primitive.ObjectID, updates map[string]interface{}) error {
+	filter := bson.D{{Key: "_id", Value: id}}
+	update := bson.D{{Key: "$set", Value: updates}}
+	result, err := r.coll.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// counts an order once, returns false when it already marked the review
+func (r *ReviewRepository) MarkHelpful(id primitive.ObjectID, orderId string) (bool, error) {
+	filter := bson.D{{Key: "_id", Value: id}, {Key: "helpfulBy", Value: bson.D{{Key: "$ne", Value: orderId}}}}
+	update := bson.D{
+		{Key: "$push", Value: bson.D{{Key: "helpfulBy", Value: orderId}}},
+		{Key: "$inc", Value: bson.D{{Key: "helpful", Value: 1}}},
+	}
+	result, err := r.coll.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
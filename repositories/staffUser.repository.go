@@ -0,0 +1,110 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/nicodeheza/peersEat/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type StaffUserRepositoryI interface {
+	Insert(user models.StaffUser) (primitive.ObjectID, error)
+	FindOne(query map[string]interface{}) (models.StaffUser, error)
+	FindMany(restaurantId primitive.ObjectID) ([]models.StaffUser, error)
+	Update(id primitive.ObjectID, set map[string]interface{}, unset []string) error
+	Delete(id primitive.ObjectID) error
+	Upsert(user models.StaffUser) error
+	DeleteByRestaurant(restaurantId primitive.ObjectID) error
+}
+
+type StaffUserRepository struct {
+	coll *mongo.Collection
+}
+
+func NewStaffUserRepository(collection *mongo.Collection) *StaffUserRepository {
+	return &StaffUserRepository{collection}
+}
+
+func (r *StaffUserRepository) Insert(user models.StaffUser) (primitive.ObjectID, error) {
+	result, err := r.coll.InsertOne(context.Background(), user)
+	if err != nil {
+		return primitive.ObjectID{}, err
+	}
+	return result.InsertedID.(primitive.ObjectID), nil
+}
+
+func (r *StaffUserRepository) FindOne(query map[string]interface{}) (models.StaffUser, error) {
+	filter := bson.D{}
+	for k, v := range query {
+		filter = append(filter, bson.E{Key: k, Value: v})
+	}
+
+	var result models.StaffUser
+	err := r.coll.FindOne(context.Background(), filter).Decode(&result)
+
+	return result, err
+}
+
+func (r *StaffUserRepository) FindMany(restaurantId primitive.ObjectID) ([]models.StaffUser, error) {
+	filter := bson.D{{Key: "restaurantId", Value: restaurantId}}
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}})
+
+	cursor, err := r.coll.Find(context.Background(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []models.StaffUser{}
+	if err = cursor.All(context.Background(), &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// the unset fields are removed so the sparse indexes skip them
+func (r *StaffUserRepository) Update(id primitive.ObjectID, set map[string]interface{}, unset []string) error {
+	filter := bson.D{{Key: "_id", Value: id}}
+	update := bson.D{{Key: "$set", Value: set}}
+	if len(unset) > 0 {
+		fields := bson.D{}
+		for _, field := range unset {
+			fields = append(fields, bson.E{Key: field, Value: ""})
+		}
+		update = append(update, bson.E{Key: "$unset", Value: fields})
+	}
+
+	result, err := r.coll.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (r *StaffUserRepository) Delete(id primitive.ObjectID) error {
+	result, err := r.coll.DeleteOne(context.Background(), bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// staff users received with their restaurant keep their ids, receiving them again is a no op
+func (r *StaffUserRepository) Upsert(user models.StaffUser) error {
+	filter := bson.D{{Key: "_id", Value: user.Id}}
+	_, err := r.coll.ReplaceOne(context.Background(), filter, user, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (r *StaffUserRepository) DeleteByRestaurant(restaurantId primitive.ObjectID) error {
+	_, err := r.coll.DeleteMany(context.Background(), bson.D{{Key: "restaurantId", Value: restaurantId}})
+	return err
+}
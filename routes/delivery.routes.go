@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/nicodeheza/peersEat/controllers"
+	"github.com/nicodeheza/peersEat/middleware"
+	"github.com/nicodeheza/peersEat/models"
+)
+
+func deliveryRoutes(app *fiber.App, controllers controllers.DeliveryControllerI, authMiddleware middleware.AuthMiddlewareI, handoffMiddleware middleware.HandoffMiddlewareI) {
+	app.Put("/restaurant/delivery-pricing", authMiddleware.Protect, handoffMiddleware.RedirectMoved, authMiddleware.Require(models.PERM_EDIT_PRICING), controllers.UpdatePricing)
+	app.Post("/restaurant/:id/delivery-quotes", handoffMiddleware.RedirectMoved, controllers.Quote)
+	app.Get("/restaurant/:id/delivery-quotes/:quoteId", handoffMiddleware.RedirectMoved, controllers.GetQuote)
+}
@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nicodeheza/peersEat/controllers"
+	"github.com/nicodeheza/peersEat/middleware"
+	"github.com/nicodeheza/peersEat/models"
+)
+
+func imageRoutes(app *fiber.App, controllers controllers.ImageControllerI, authMiddleware middleware.AuthMiddlewareI, handoffMiddleware middleware.HandoffMiddlewareI) {
+	app.Put("/restaurant/image", authMiddleware.Protect, handoffMiddleware.RedirectMoved, authMiddleware.Require(models.PERM_EDIT_RESTAURANT), controllers.UploadRestaurantImage)
+	// protected by the /restaurant/menu group
+	app.Put("/restaurant/menu/sections/:sectionId/dishes/:dishId/image", authMiddleware.Require(models.PERM_EDIT_MENU), controllers.UploadDishImage)
+	app.Get("/images/:hash/:file", controllers.ServeImage)
+}
+
+// the uploads are the only routes taking bodies over fiber's default limit
+func isImageUpload(c *fiber.Ctx) bool {
+	return c.Method() == fiber.MethodPut && strings.HasSuffix(c.Path(), "/image")
+}
@@ -2,11 +2,22 @@ package routes
 
 import (
 	"github.com/gofiber/fiber/v2"
+	"github.com/nicodeheza/peersEat/middleware"
 	"github.com/nicodeheza/peersEat/modules"
 )
 
 func Register(app *fiber.App, appModule *modules.Application) {
+	app.Use(middleware.BodyLimit(fiber.DefaultBodyLimit, isImageUpload))
 
-	peerRoutes(app, appModule.Peer.Controllers, appModule.AuthMiddleware)
-	RestaurantRoutes(app, appModule.Restaurant.Controller, appModule.AuthMiddleware)
+	peerRoutes(app, appModule.Peer.Controllers, appModule.AuthMiddleware, appModule.HandoffMiddleware)
+	// before the restaurant routes so /restaurant/staff and /restaurant/promotions
+	// aren't taken as restaurant ids
+	staffRoutes(app, appModule.Staff, appModule.AuthMiddleware, appModule.HandoffMiddleware)
+	promotionRoutes(app, appModule.Promotion, appModule.AuthMiddleware, appModule.HandoffMiddleware)
+	RestaurantRoutes(app, appModule.Restaurant.Controller, appModule.AuthMiddleware, appModule.HandoffMiddleware)
+	searchRoutes(app, appModule.Search)
+	reviewRoutes(app, appModule.Review, appModule.AuthMiddleware, appModule.HandoffMiddleware)
+	imageRoutes(app, appModule.Image, appModule.AuthMiddleware, appModule.HandoffMiddleware)
+	deliveryRoutes(app, appModule.Delivery, appModule.AuthMiddleware, appModule.HandoffMiddleware)
+	presenceRoutes(app, appModule.Presence, appModule.AuthMiddleware, appModule.HandoffMiddleware)
 }
@@ -6,11 +6,32 @@ import (
 	"github.com/nicodeheza/peersEat/middleware"
 )
 
-func peerRoutes(app *fiber.App, controllers controllers.PeerControllerI, authMiddleware middleware.AuthMiddlewareI) {
+func peerRoutes(app *fiber.App, controllers controllers.PeerControllerI, authMiddleware middleware.AuthMiddlewareI, handoffMiddleware middleware.HandoffMiddlewareI) {
 	peerGroup := app.Group("/peer")
 
 	peerGroup.Get("/all", controllers.SendAllPeers)
+	peerGroup.Get("/serving", controllers.PeersServing)
+	peerGroup.Get("/overlay/closest", controllers.ClosestPeers)
 	peerGroup.Get("/restaurant/have", controllers.HaveRestaurant)
 	peerGroup.Post("/restaurant", authMiddleware.OnlyPeerOwner, controllers.AddNewRestaurant)
+	peerGroup.Post("/restaurant/:id/setup-token", authMiddleware.OnlyPeerOwner, handoffMiddleware.RedirectMoved, controllers.NewRestaurantSetupToken)
+	peerGroup.Put("/restaurant/:id/coord", authMiddleware.OnlyPeerOwner, handoffMiddleware.RedirectMoved, controllers.OverrideRestaurantCoord)
+	peerGroup.Delete("/restaurant/:id/coord", authMiddleware.OnlyPeerOwner, handoffMiddleware.RedirectMoved, controllers.ClearRestaurantCoordOverride)
+	peerGroup.Get("/restaurant/:id/status", authMiddleware.OnlyPeerOwner, handoffMiddleware.RedirectMoved, controllers.GetRestaurantStatus)
+	peerGroup.Post("/restaurant/:id/approve", authMiddleware.OnlyPeerOwner, handoffMiddleware.RedirectMoved, controllers.ApproveRestaurant)
+	peerGroup.Post("/restaurant/:id/reject", authMiddleware.OnlyPeerOwner, handoffMiddleware.RedirectMoved, controllers.RejectRestaurant)
+	peerGroup.Post("/restaurant/:id/suspend", authMiddleware.OnlyPeerOwner, handoffMiddleware.RedirectMoved, controllers.SuspendRestaurant)
+	peerGroup.Post("/restaurant/:id/close", authMiddleware.OnlyPeerOwner, handoffMiddleware.RedirectMoved, controllers.CloseRestaurant)
 	peerGroup.Post("/event", controllers.EventReceiver)
+	peerGroup.Patch("/influence-radius", authMiddleware.OnlyPeerOwner, controllers.UpdateInfluenceRadius)
+	peerGroup.Put("/influence-zone", authMiddleware.OnlyPeerOwner, controllers.UpdateInfluenceZone)
+	peerGroup.Post("/handoff", authMiddleware.OnlyPeerOwner, controllers.Handoff)
+	peerGroup.Post("/handoff/offer", controllers.HandoffOffer)
+	peerGroup.Get("/handoff/:id", controllers.HandoffRestaurants)
+	peerGroup.Post("/replication/subscribe", controllers.ReplicationSubscribe)
+	peerGroup.Get("/replication/handshake/:id", controllers.ReplicationHandshake)
+	peerGroup.Post("/replication/stream", controllers.ReplicationStream)
+	peerGroup.Post("/replication/promote", authMiddleware.OnlyPeerOwner, controllers.PromoteReplicas)
+	peerGroup.Get("/replicas", controllers.GetReplicas)
+	peerGroup.Get("/replicas/:id", controllers.GetReplica)
 }
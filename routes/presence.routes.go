@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/nicodeheza/peersEat/controllers"
+	"github.com/nicodeheza/peersEat/middleware"
+	"github.com/nicodeheza/peersEat/models"
+)
+
+func presenceRoutes(app *fiber.App, controllers controllers.PresenceControllerI, authMiddleware middleware.AuthMiddlewareI, handoffMiddleware middleware.HandoffMiddlewareI) {
+	operate := authMiddleware.Require(models.PERM_OPERATE)
+	app.Get("/restaurant/presence/stream", authMiddleware.Protect, handoffMiddleware.RedirectMoved, operate, controllers.Stream)
+	app.Post("/restaurant/presence/heartbeat", authMiddleware.Protect, handoffMiddleware.RedirectMoved, operate, controllers.Heartbeat)
+}
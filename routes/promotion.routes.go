@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/nicodeheza/peersEat/controllers"
+	"github.com/nicodeheza/peersEat/middleware"
+	"github.com/nicodeheza/peersEat/models"
+)
+
+func promotionRoutes(app *fiber.App, controllers controllers.PromotionControllerI, authMiddleware middleware.AuthMiddlewareI, handoffMiddleware middleware.HandoffMiddlewareI) {
+	app.Get("/restaurant/promotions", authMiddleware.Protect, handoffMiddleware.RedirectMoved, authMiddleware.Require(models.PERM_EDIT_PRICING), controllers.ListRestaurantPromotions)
+	app.Post("/restaurant/promotions", authMiddleware.Protect, handoffMiddleware.RedirectMoved, authMiddleware.Require(models.PERM_EDIT_PRICING), controllers.CreateRestaurantPromotion)
+	app.Put("/restaurant/promotions/:promotionId", authMiddleware.Protect, handoffMiddleware.RedirectMoved, authMiddleware.Require(models.PERM_EDIT_PRICING), controllers.UpdateRestaurantPromotion)
+	app.Delete("/restaurant/promotions/:promotionId", authMiddleware.Protect, handoffMiddleware.RedirectMoved, authMiddleware.Require(models.PERM_EDIT_PRICING), controllers.DeleteRestaurantPromotion)
+
+	app.Get("/peer/promotions", authMiddleware.OnlyPeerOwner, controllers.ListPeerPromotions)
+	app.Post("/peer/promotions", authMiddleware.OnlyPeerOwner, controllers.CreatePeerPromotion)
+	app.Put("/peer/promotions/:promotionId", authMiddleware.OnlyPeerOwner, controllers.UpdatePeerPromotion)
+	app.Delete("/peer/promotions/:promotionId", authMiddleware.OnlyPeerOwner, controllers.DeletePeerPromotion)
+	app.Post("/peer/customers/token", authMiddleware.OnlyPeerOwner, controllers.IssueCustomerToken)
+
+	app.Post("/restaurant/:id/cart/evaluate", handoffMiddleware.RedirectMoved, controllers.EvaluateCart)
+	app.Post("/restaurant/:id/cart/redeem", handoffMiddleware.RedirectMoved, controllers.RedeemCart)
+}
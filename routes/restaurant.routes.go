@@ -4,12 +4,53 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/nicodeheza/peersEat/controllers"
 	"github.com/nicodeheza/peersEat/middleware"
+	"github.com/nicodeheza/peersEat/models"
 )
 
-func RestaurantRoutes(app *fiber.App, controllers controllers.RestaurantControllerI, authMiddleware middleware.AuthMiddlewareI) {
+func RestaurantRoutes(app *fiber.App, controllers controllers.RestaurantControllerI, authMiddleware middleware.AuthMiddlewareI, handoffMiddleware middleware.HandoffMiddlewareI) {
+	view := authMiddleware.Require(models.PERM_VIEW)
+	operate := authMiddleware.Require(models.PERM_OPERATE)
+	editMenu := authMiddleware.Require(models.PERM_EDIT_MENU)
+	editRestaurant := authMiddleware.Require(models.PERM_EDIT_RESTAURANT)
+
 	restaurantGroup := app.Group("/restaurant")
-	restaurantGroup.Patch("/password", authMiddleware.Protect, controllers.UpdatePassword)
-	restaurantGroup.Post("/login", authMiddleware.Authenticate, controllers.RetuneOk)
+	restaurantGroup.Patch("/password", authMiddleware.Protect, handoffMiddleware.RedirectMoved, authMiddleware.Require(models.PERM_MANAGE_ACCOUNT), controllers.UpdatePassword)
+	restaurantGroup.Post("/setup", controllers.SetupAccount)
+	restaurantGroup.Post("/login", handoffMiddleware.RedirectMovedLogin, authMiddleware.Authenticate, controllers.RetuneOk)
 	restaurantGroup.Delete("/logout", authMiddleware.Logout, controllers.RetuneOk)
-	restaurantGroup.Put("/data", authMiddleware.Protect, controllers.UpdateRestaurantData)
+	restaurantGroup.Put("/data", authMiddleware.Protect, handoffMiddleware.RedirectMoved, editRestaurant, controllers.UpdateRestaurantData)
+	restaurantGroup.Put("/address", authMiddleware.Protect, handoffMiddleware.RedirectMoved, editRestaurant, controllers.UpdateAddress)
+	restaurantGroup.Put("/coord", authMiddleware.Protect, handoffMiddleware.RedirectMoved, editRestaurant, controllers.OverrideCoord)
+	restaurantGroup.Delete("/coord", authMiddleware.Protect, handoffMiddleware.RedirectMoved, editRestaurant, controllers.ClearCoordOverride)
+	restaurantGroup.Put("/opening-hours", authMiddleware.Protect, handoffMiddleware.RedirectMoved, editRestaurant, controllers.UpdateOpeningHours)
+	restaurantGroup.Post("/submit", authMiddleware.Protect, handoffMiddleware.RedirectMoved, editRestaurant, controllers.SubmitForReview)
+
+	menuGroup := restaurantGroup.Group("/menu", authMiddleware.Protect, handoffMiddleware.RedirectMoved)
+	menuGroup.Get("/", view, controllers.GetMenu)
+	menuGroup.Post("/sections", editMenu, controllers.AddMenuSection)
+	menuGroup.Put("/sections/order", editMenu, controllers.ReorderMenu)
+	menuGroup.Patch("/sections/:sectionId", editMenu, controllers.UpdateMenuSection)
+	menuGroup.Delete("/sections/:sectionId", editMenu, controllers.DeleteMenuItem)
+	menuGroup.Post("/sections/:sectionId/dishes", editMenu, controllers.AddDish)
+	menuGroup.Put("/sections/:sectionId/dishes/order", editMenu, controllers.ReorderMenu)
+	menuGroup.Patch("/sections/:sectionId/dishes/:dishId", editMenu, controllers.UpdateDish)
+	menuGroup.Delete("/sections/:sectionId/dishes/:dishId", editMenu, controllers.DeleteMenuItem)
+	menuGroup.Post("/sections/:sectionId/dishes/:dishId/options", editMenu, controllers.AddDishOption)
+	menuGroup.Put("/sections/:sectionId/dishes/:dishId/options/order", editMenu, controllers.ReorderMenu)
+	menuGroup.Patch("/sections/:sectionId/dishes/:dishId/options/:optionId", editMenu, controllers.UpdateDishOption)
+	menuGroup.Delete("/sections/:sectionId/dishes/:dishId/options/:optionId", editMenu, controllers.DeleteMenuItem)
+	menuGroup.Put("/sections/:sectionId/dishes/:dishId/option-groups", editMenu, controllers.SetDishOptionGroups)
+	menuGroup.Put("/items/:itemId/availability", operate, controllers.SetItemAvailability)
+	menuGroup.Post("/versions", editMenu, controllers.PublishMenu)
+	menuGroup.Get("/versions", view, controllers.GetMenuVersions)
+	menuGroup.Post("/versions/:versionId/checkout", editMenu, controllers.CheckoutMenuVersion)
+	menuGroup.Delete("/names/:name", editMenu, controllers.ArchiveMenu)
+
+	restaurantGroup.Get("/", controllers.ListRestaurants)
+	restaurantGroup.Get("/:id", handoffMiddleware.RedirectMoved, controllers.GetRestaurant)
+	restaurantGroup.Get("/:id/opening-hours", handoffMiddleware.RedirectMoved, controllers.GetOpeningStatus)
+	restaurantGroup.Post("/:id/menu/price", handoffMiddleware.RedirectMoved, controllers.PriceDishSelection)
+	restaurantGroup.Get("/:id/menu/current", handoffMiddleware.RedirectMoved, controllers.GetCurrentMenu)
+	restaurantGroup.Get("/:id/menu/versions/:versionId", handoffMiddleware.RedirectMoved, controllers.GetMenuVersion)
+	restaurantGroup.Post("/:id/menu/versions/:versionId/price", handoffMiddleware.RedirectMoved, controllers.PriceMenuVersionSelection)
 }
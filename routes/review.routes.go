@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/nicodeheza/peersEat/controllers"
+	"github.com/nicodeheza/peersEat/middleware"
+	"github.com/nicodeheza/peersEat/models"
+)
+
+func reviewRoutes(app *fiber.App, controllers controllers.ReviewControllerI, authMiddleware middleware.AuthMiddlewareI, handoffMiddleware middleware.HandoffMiddlewareI) {
+	app.Put("/restaurant/reviews/:reviewId/reply", authMiddleware.Protect, handoffMiddleware.RedirectMoved, authMiddleware.Require(models.PERM_REPLY_REVIEWS), controllers.ReplyReview)
+	app.Get("/restaurant/:id/reviews", handoffMiddleware.RedirectMoved, controllers.ListReviews)
+	app.Post("/restaurant/:id/reviews", handoffMiddleware.RedirectMoved, controllers.SubmitReview)
+	app.Post("/restaurant/:id/reviews/:reviewId/helpful", handoffMiddleware.RedirectMoved, controllers.MarkReviewHelpful)
+}
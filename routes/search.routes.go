@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/nicodeheza/peersEat/controllers"
+)
+
+func searchRoutes(app *fiber.App, controllers controllers.SearchControllerI) {
+	app.Get("/search/restaurants", controllers.Search)
+	app.Get("/search/text", controllers.TextSearch)
+	app.Get("/peer/search/restaurants", controllers.PeerSearch)
+}
@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/nicodeheza/peersEat/controllers"
+	"github.com/nicodeheza/peersEat/middleware"
+	"github.com/nicodeheza/peersEat/models"
+)
+
+func staffRoutes(app *fiber.App, controllers controllers.StaffControllerI, authMiddleware middleware.AuthMiddlewareI, handoffMiddleware middleware.HandoffMiddlewareI) {
+	app.Post("/restaurant/staff/invitations/accept", controllers.AcceptInvitation)
+	app.Patch("/restaurant/staff/password", authMiddleware.Protect, authMiddleware.Require(models.PERM_VIEW), controllers.UpdateStaffPassword)
+
+	manageStaff := authMiddleware.Require(models.PERM_MANAGE_STAFF)
+	app.Get("/restaurant/staff", authMiddleware.Protect, handoffMiddleware.RedirectMoved, manageStaff, controllers.ListStaff)
+	app.Post("/restaurant/staff/invitations", authMiddleware.Protect, handoffMiddleware.RedirectMoved, manageStaff, controllers.InviteStaff)
+	app.Patch("/restaurant/staff/:staffId/role", authMiddleware.Protect, handoffMiddleware.RedirectMoved, manageStaff, controllers.ChangeStaffRole)
+	app.Delete("/restaurant/staff/:staffId", authMiddleware.Protect, handoffMiddleware.RedirectMoved, manageStaff, controllers.RemoveStaff)
+}
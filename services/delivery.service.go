@@ -0,0 +1,165 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/nicodeheza/peersEat/constants"
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/repositories"
+	"github.com/nicodeheza/peersEat/services/geo"
+	"github.com/nicodeheza/peersEat/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrOutOfDeliveryArea = errors.New("the address is out of the restaurant delivery area")
+var ErrInvalidDeliveryPricing = errors.New("invalid delivery pricing")
+var ErrQuoteNotFound = errors.New("delivery quote not found")
+var ErrQuoteExpired = errors.New("delivery quote expired")
+var ErrQuoteMismatch = errors.New("delivery quote was made for another subtotal")
+
+// MinimumOrderError is returned when the subtotal doesn't reach the restaurant minimum
+type MinimumOrderError struct {
+	MinOrder models.Money
+}
+
+func (e *MinimumOrderError) Error() string {
+	return fmt.Sprintf("the minimum order is %v", e.MinOrder)
+}
+
+type DeliveryServiceI interface {
+	UpdatePricing(id primitive.ObjectID, data types.DeliveryPricingData) (models.DeliveryPricing, error)
+	Quote(restaurantId primitive.ObjectID, data types.DeliveryQuoteRequest) (models.DeliveryQuote, error)
+	GetQuote(restaurantId primitive.ObjectID, quoteId primitive.ObjectID) (models.DeliveryQuote, error)
+}
+
+type DeliveryService struct {
+	quoteRepo      repositories.DeliveryQuoteRepositoryI
+	restaurantRepo repositories.RestaurantRepositoryI
+	geo            geo.GeoServiceI
+}
+
+func NewDeliveryService(quoteRepo repositories.DeliveryQuoteRepositoryI, restaurantRepo repositories.RestaurantRepositoryI, geo geo.GeoServiceI) *DeliveryService {
+	return &DeliveryService{quoteRepo, restaurantRepo, geo}
+}
+
+func (s *DeliveryService) UpdatePricing(id primitive.ObjectID, data types.DeliveryPricingData) (models.DeliveryPricing, error) {
+	restaurant, err := s.restaurantRepo.FindOne(map[string]interface{}{"_id": id})
+	if err != nil {
+		return models.DeliveryPricing{}, err
+	}
+
+	pricing := models.DeliveryPricing{BaseFee: data.BaseFee, MinOrder: data.MinOrder, FreeAbove: data.FreeAbove}
+
+	tiers := append([]types.DistanceTierData{}, data.Tiers...)
+	sort.SliceStable(tiers, func(i, j int) bool { return tiers[i].UpToKm < tiers[j].UpToKm })
+	for i, tier := range tiers {
+		if i > 0 && tier.UpToKm == tiers[i-1].UpToKm {
+			return models.DeliveryPricing{}, fmt.Errorf("%w: repeated tier up to %v km", ErrInvalidDeliveryPricing, tier.UpToKm)
+		}
+		pricing.Tiers = append(pricing.Tiers, models.DistanceTier{UpToKm: tier.UpToKm, PerKm: tier.PerKm})
+	}
+
+	err = inCurrency(restaurant.CurrencyOrDefault(), pricing.Amounts()...)
+	if err != nil {
+		return models.DeliveryPricing{}, err
+	}
+	if !pricing.FreeAbove.IsZero() && pricing.FreeAbove.Amount < pricing.MinOrder.Amount {
+		return models.DeliveryPricing{}, fmt.Errorf("%w: free delivery below the minimum order", ErrInvalidDeliveryPricing)
+	}
+
+	for _, peak := range data.Peaks {
+		window := models.AvailabilityWindow{Days: peak.Days, Start: peak.Start, End: peak.End}
+		pricing.Peaks = append(pricing.Peaks, models.PeakWindow{AvailabilityWindow: window, Multiplier: peak.Multiplier})
+	}
+
+	err = s.restaurantRepo.Update(id, map[string]interface{}{"deliveryPricing": pricing})
+	if err != nil {
+		return models.DeliveryPricing{}, err
+	}
+	return pricing, nil
+}
+
+// the quote is stored so the order can be placed with the same fee until it expires
+func (s *DeliveryService) Quote(restaurantId primitive.ObjectID, data types.DeliveryQuoteRequest) (models.DeliveryQuote, error) {
+	restaurant, err := s.restaurantRepo.FindOne(map[string]interface{}{"_id": restaurantId})
+	if err != nil {
+		return models.DeliveryQuote{}, err
+	}
+	if !restaurant.IsActive() {
+		return models.DeliveryQuote{}, ErrRestaurantNotActive
+	}
+	if restaurant.IsPaused {
+		return models.DeliveryQuote{}, ErrRestaurantPaused
+	}
+
+	coord := models.GeoCoords{Lat: *data.Lat, Long: *data.Long}
+	if !s.geo.IsInRestaurantDeliveryArea(restaurant, coord) {
+		return models.DeliveryQuote{}, ErrOutOfDeliveryArea
+	}
+
+	currency := restaurant.CurrencyOrDefault()
+	subtotal, err := data.Subtotal.As(currency)
+	if err != nil {
+		return models.DeliveryQuote{}, err
+	}
+	pricing := restaurant.Pricing()
+	if !pricing.MinOrder.IsZero() && subtotal.Amount < pricing.MinOrder.Amount {
+		return models.DeliveryQuote{}, &MinimumOrderError{pricing.MinOrder}
+	}
+
+	now := time.Now()
+	deliverAt := now
+	if data.At != "" {
+		deliverAt, _ = time.Parse(time.RFC3339, data.At)
+	}
+
+	distance := s.geo.GetCoordDistance(restaurant.Coord, coord)
+	quote := models.DeliveryQuote{
+		RestaurantId: restaurantId,
+		Coord:        coord,
+		Subtotal:     subtotal,
+		DistanceKm:   distance,
+		BaseFee:      pricing.BaseFee,
+		DistanceFee:  pricing.DistanceFee(distance, currency),
+		Multiplier:   pricing.Multiplier(deliverAt.In(restaurant.Schedule().Location())),
+		DeliverAt:    deliverAt,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(constants.DELIVERY_QUOTE_TTL),
+	}
+
+	if !pricing.FreeAbove.IsZero() && subtotal.Amount >= pricing.FreeAbove.Amount {
+		quote.FreeDelivery = true
+		quote.Fee = models.NewMoney(0, currency)
+	} else {
+		fee, err := quote.BaseFee.Add(quote.DistanceFee)
+		if err != nil {
+			return models.DeliveryQuote{}, err
+		}
+		quote.Fee = fee.MulFloat(quote.Multiplier)
+	}
+
+	quote.Id, err = s.quoteRepo.Insert(quote)
+	if err != nil {
+		return models.DeliveryQuote{}, err
+	}
+	return quote, nil
+}
+
+// expired quotes are removed by a ttl index, which may run a minute late
+func (s *DeliveryService) GetQuote(restaurantId primitive.ObjectID, quoteId primitive.ObjectID) (models.DeliveryQuote, error) {
+	quote, err := s.quoteRepo.FindOne(map[string]interface{}{"_id": quoteId, "restaurantId": restaurantId})
+	if err == mongo.ErrNoDocuments {
+		return models.DeliveryQuote{}, ErrQuoteNotFound
+	}
+	if err != nil {
+		return models.DeliveryQuote{}, err
+	}
+	if !time.Now().Before(quote.ExpiresAt) {
+		return models.DeliveryQuote{}, ErrQuoteExpired
+	}
+	return quote, nil
+}
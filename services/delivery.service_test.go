@@ -0,0 +1,139 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nicodeheza/peersEat/mocks"
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func initDeliveryTest() (*DeliveryService, *mocks.DeliveryQuoteRepositoryMock, *mocks.RestaurantRepositoryMock, models.Restaurant) {
+	quoteRepo := mocks.NewDeliveryQuoteRepositoryMock()
+	restaurantRepo := mocks.NewRestaurantRepositoryMock()
+	// the geo mock measures distances as the restaurant longitude
+	restaurant := models.Restaurant{
+		Id:             primitive.NewObjectID(),
+		Currency:       "USD",
+		Coord:          models.GeoCoords{Lat: 0, Long: 6},
+		DeliveryRadius: 1,
+		DeliveryPricing: &models.DeliveryPricing{
+			BaseFee:   usd(200),
+			Tiers:     []models.DistanceTier{{UpToKm: 3, PerKm: usd(100)}, {UpToKm: 5, PerKm: usd(50)}},
+			MinOrder:  usd(1000),
+			FreeAbove: usd(3000),
+			Peaks: []models.PeakWindow{
+				{AvailabilityWindow: models.AvailabilityWindow{Start: "18:00", End: "21:00"}, Multiplier: 1.5},
+			},
+		},
+	}
+	restaurantRepo.Restaurants = []models.Restaurant{restaurant}
+	return NewDeliveryService(quoteRepo, restaurantRepo, mocks.NewGeo()), quoteRepo, restaurantRepo, restaurant
+}
+
+func TestDeliveryPricing(t *testing.T) {
+	pricing := models.DeliveryPricing{Tiers: []models.DistanceTier{{UpToKm: 3, PerKm: usd(100)}, {UpToKm: 5, PerKm: usd(50)}}}
+	fees := map[float64]int64{0: 0, 2: 200, 3: 300, 4: 350, 6: 450, 3.333: 317}
+	for km, expected := range fees {
+		if fee := pricing.DistanceFee(km, "USD"); fee != usd(expected) {
+			t.Errorf("expecting %v for %v km but got %v", expected, km, fee)
+		}
+	}
+
+	// legacy costs are in the restaurant currency
+	fixed := models.Restaurant{Currency: "USD", DeliveryCost: models.Money{Amount: 300}, IsDeliveryFixCost: true}.Pricing()
+	if fixed.BaseFee != usd(300) || fixed.DistanceFee(10, "USD") != usd(0) {
+		t.Errorf("unexpected fixed cost pricing %v", fixed)
+	}
+	perKm := models.Restaurant{Currency: "JPY", DeliveryCost: models.Money{Amount: 5000}, DeliveryRadius: 5}.Pricing()
+	if !perKm.BaseFee.IsZero() || perKm.DistanceFee(4, "JPY") != models.NewMoney(200, "JPY") {
+		t.Errorf("unexpected per km pricing %v", perKm)
+	}
+}
+
+func TestDeliveryQuote(t *testing.T) {
+	service, quoteRepo, restaurantRepo, restaurant := initDeliveryTest()
+	lat, long := 1.0, 1.0
+
+	quote, err := service.Quote(restaurant.Id, types.DeliveryQuoteRequest{Lat: &lat, Long: &long, Subtotal: usd(1500), At: "2024-05-06T12:00:00Z"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if quote.DistanceFee != usd(450) || quote.Multiplier != 1 || quote.Fee != usd(650) || quote.FreeDelivery {
+		t.Errorf("unexpected quote %v", quote)
+	}
+	if len(quoteRepo.Quotes) != 1 || quoteRepo.Quotes[0].Id != quote.Id {
+		t.Errorf("expecting the quote to be stored")
+	}
+
+	peak, _ := service.Quote(restaurant.Id, types.DeliveryQuoteRequest{Lat: &lat, Long: &long, Subtotal: usd(1500), At: "2024-05-06T19:00:00Z"})
+	if peak.Multiplier != 1.5 || peak.Fee != usd(975) {
+		t.Errorf("expecting the peak multiplier but got %v", peak)
+	}
+
+	free, _ := service.Quote(restaurant.Id, types.DeliveryQuoteRequest{Lat: &lat, Long: &long, Subtotal: usd(3000), At: "2024-05-06T19:00:00Z"})
+	if !free.FreeDelivery || free.Fee != usd(0) {
+		t.Errorf("expecting free delivery but got %v", free)
+	}
+
+	_, err = service.Quote(restaurant.Id, types.DeliveryQuoteRequest{Lat: &lat, Long: &long, Subtotal: models.Money{Amount: 500}})
+	var minimumError *MinimumOrderError
+	if !errors.As(err, &minimumError) || minimumError.MinOrder != usd(1000) {
+		t.Errorf("expecting minimum order error but got %v", err)
+	}
+	_, err = service.Quote(restaurant.Id, types.DeliveryQuoteRequest{Lat: &lat, Long: &long, Subtotal: models.NewMoney(1500, "EUR")})
+	if !errors.Is(err, models.ErrCurrencyMismatch) {
+		t.Errorf("expecting currency mismatch error but got %v", err)
+	}
+
+	restaurantRepo.Restaurants[0].DeliveryRadius = 2
+	_, err = service.Quote(restaurant.Id, types.DeliveryQuoteRequest{Lat: &lat, Long: &long, Subtotal: usd(1500)})
+	if err != ErrOutOfDeliveryArea {
+		t.Errorf("expecting out of delivery area error but got %v", err)
+	}
+
+	stored, err := service.GetQuote(restaurant.Id, quote.Id)
+	if err != nil || stored.Fee != quote.Fee {
+		t.Errorf("expecting the stored quote but got %v %v", stored, err)
+	}
+	_, err = service.GetQuote(primitive.NewObjectID(), quote.Id)
+	if err != ErrQuoteNotFound {
+		t.Errorf("expecting quote not found error but got %v", err)
+	}
+	quoteRepo.Quotes[0].ExpiresAt = time.Now().Add(-time.Minute)
+	_, err = service.GetQuote(restaurant.Id, quote.Id)
+	if err != ErrQuoteExpired {
+		t.Errorf("expecting quote expired error but got %v", err)
+	}
+}
+
+func TestUpdateDeliveryPricing(t *testing.T) {
+	service, _, restaurantRepo, restaurant := initDeliveryTest()
+
+	_, err := service.UpdatePricing(restaurant.Id, types.DeliveryPricingData{Tiers: []types.DistanceTierData{{UpToKm: 2, PerKm: usd(100)}, {UpToKm: 2, PerKm: usd(200)}}})
+	if !errors.Is(err, ErrInvalidDeliveryPricing) {
+		t.Errorf("expecting invalid pricing error but got %v", err)
+	}
+	_, err = service.UpdatePricing(restaurant.Id, types.DeliveryPricingData{MinOrder: usd(2000), FreeAbove: usd(1000)})
+	if !errors.Is(err, ErrInvalidDeliveryPricing) {
+		t.Errorf("expecting invalid pricing error but got %v", err)
+	}
+
+	pricing, err := service.UpdatePricing(restaurant.Id, types.DeliveryPricingData{
+		BaseFee: models.Money{Amount: 100},
+		Tiers:   []types.DistanceTierData{{UpToKm: 5, PerKm: usd(50)}, {UpToKm: 2, PerKm: usd(100)}},
+		Peaks:   []types.PeakWindowData{{AvailabilityWindowData: types.AvailabilityWindowData{Start: "12:00", End: "14:00"}, Multiplier: 1.2}},
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if pricing.Tiers[0].UpToKm != 2 || pricing.Tiers[1].UpToKm != 5 || pricing.Peaks[0].Start != "12:00" || pricing.BaseFee != usd(100) {
+		t.Errorf("unexpected pricing %v", pricing)
+	}
+	if len(restaurantRepo.UpdateCalls) != 1 || restaurantRepo.UpdateCalls[0].Updates["deliveryPricing"] == nil {
+		t.Errorf("expecting the pricing to be stored but got %v", restaurantRepo.UpdateCalls)
+	}
+}
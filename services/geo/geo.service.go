@@ -1,26 +1,26 @@
 package geo
 
 import (
-	"encoding/json"
-	"fmt"
 	"math"
-	"net/http"
-	"net/url"
-	"strconv"
 
 	"github.com/nicodeheza/peersEat/constants"
 	"github.com/nicodeheza/peersEat/models"
-	"github.com/nicodeheza/peersEat/types"
 )
 
 type GeoService struct{}
 type GeoServiceI interface {
 	GetCoordDistance(coord1 models.GeoCoords, coord2 models.GeoCoords) float64
 	IsSameCoord(coord1 models.GeoCoords, coord2 models.GeoCoords) bool
-	IsInInfluenceArea(peerCenter, geoPoint models.GeoCoords) bool
+	GetInfluenceRadius(peer models.Peer) float64
+	IsInInfluenceArea(peer models.Peer, geoPoint models.GeoCoords) bool
 	AreInfluenceAreasOverlaying(selfPeer models.Peer, peer models.Peer) bool
 	IsInDeliveryArea(selfPeer models.Peer, peer models.Peer) bool
-	GetAddressCoords(address, city, country string) (models.GeoCoords, error)
+	IsInRestaurantDeliveryArea(restaurant models.Restaurant, geoPoint models.GeoCoords) bool
+	IsInPeerDeliveryArea(peer models.Peer, geoPoint models.GeoCoords) bool
+	IsPointInZone(zone models.GeoZone, geoPoint models.GeoCoords) bool
+	AreZonesIntersecting(zone1 models.GeoZone, zone2 models.GeoZone) bool
+	IsZoneIntersectingCircle(zone models.GeoZone, center models.GeoCoords, radius float64) bool
+	CircleToZone(center models.GeoCoords, radius float64) models.GeoZone
 }
 
 func NewGeo() *GeoService {
@@ -57,15 +57,27 @@ func (g *GeoService) IsSameCoord(coord1 models.GeoCoords, coord2 models.GeoCoord
 	return false
 }
 
-func (g *GeoService) IsInInfluenceArea(peerCenter, geoPoint models.GeoCoords) bool {
-	if g.IsSameCoord(peerCenter, geoPoint) {
+// peers created before the influence radius was configurable don't have one stored
+func (g *GeoService) GetInfluenceRadius(peer models.Peer) float64 {
+	if peer.InfluenceRadius <= 0 {
+		return constants.INFLUENCE_RADIUS
+	}
+	return peer.InfluenceRadius
+}
+
+func (g *GeoService) IsInInfluenceArea(peer models.Peer, geoPoint models.GeoCoords) bool {
+	if peer.InfluenceZone != nil {
+		return g.IsPointInZone(*peer.InfluenceZone, geoPoint)
+	}
+
+	if g.IsSameCoord(peer.Center, geoPoint) {
 		return true
 	}
 
-	peerDis := g.GetCoordDistance(peerCenter, geoPoint)
+	peerDis := g.GetCoordDistance(peer.Center, geoPoint)
 	peerDis = math.Abs(peerDis)
 
-	if peerDis <= constants.INFLUENCE_RADIUS {
+	if peerDis <= g.GetInfluenceRadius(peer) {
 		return true
 	}
 	return false
@@ -76,67 +88,207 @@ func (g *GeoService) AreInfluenceAreasOverlaying(selfPeer models.Peer, peer mode
 		return true
 	}
 
-	peerDis := g.GetCoordDistance(peer.Center, selfPeer.Center)
-	peerDis = math.Abs(peerDis)
+	return g.areAreasOverlaying(
+		selfPeer.InfluenceZone, selfPeer.Center, g.GetInfluenceRadius(selfPeer),
+		peer.InfluenceZone, peer.Center, g.GetInfluenceRadius(peer),
+	)
+}
 
-	if peerDis <= constants.INFLUENCE_RADIUS*2 {
+func (g *GeoService) IsInDeliveryArea(selfPeer models.Peer, peer models.Peer) bool {
+	if g.IsSameCoord(selfPeer.Center, peer.Center) {
 		return true
 	}
+
+	if selfPeer.DeliveryRadius == 0 && selfPeer.DeliveryZone == nil {
+		return false
+	}
+
+	return g.areAreasOverlaying(
+		selfPeer.DeliveryZone, selfPeer.Center, selfPeer.DeliveryRadius,
+		peer.DeliveryZone, peer.Center, peer.DeliveryRadius,
+	)
+}
+
+func (g *GeoService) IsInRestaurantDeliveryArea(restaurant models.Restaurant, geoPoint models.GeoCoords) bool {
+	if restaurant.DeliveryZone != nil {
+		return g.IsPointInZone(*restaurant.DeliveryZone, geoPoint)
+	}
+
+	dist := math.Abs(g.GetCoordDistance(restaurant.Coord, geoPoint))
+	return dist <= restaurant.DeliveryRadius
+}
+
+func (g *GeoService) IsInPeerDeliveryArea(peer models.Peer, geoPoint models.GeoCoords) bool {
+	if peer.DeliveryZone != nil {
+		return g.IsPointInZone(*peer.DeliveryZone, geoPoint)
+	}
+
+	dist := math.Abs(g.GetCoordDistance(peer.Center, geoPoint))
+	return dist <= peer.DeliveryRadius
+}
+
+// zones are preferred over circles, when only one area has a zone it's compared against the other circle
+func (g *GeoService) areAreasOverlaying(
+	zone1 *models.GeoZone, center1 models.GeoCoords, radius1 float64,
+	zone2 *models.GeoZone, center2 models.GeoCoords, radius2 float64,
+) bool {
+	switch {
+	case zone1 != nil && zone2 != nil:
+		return g.AreZonesIntersecting(*zone1, *zone2)
+	case zone1 != nil:
+		return g.IsZoneIntersectingCircle(*zone1, center2, radius2)
+	case zone2 != nil:
+		return g.IsZoneIntersectingCircle(*zone2, center1, radius1)
+	}
+
+	dist := math.Abs(g.GetCoordDistance(center1, center2))
+	return dist <= radius1+radius2
+}
+
+// ray casting, positions are treated as planar which is fine at city scale
+func (g *GeoService) isPointInRing(ring models.GeoRing, geoPoint models.GeoCoords) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+
+		if (yi > geoPoint.Lat) != (yj > geoPoint.Lat) &&
+			geoPoint.Long < (xj-xi)*(geoPoint.Lat-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+func (g *GeoService) isPointInPolygon(polygon models.GeoPolygon, geoPoint models.GeoCoords) bool {
+	if len(polygon) == 0 || !g.isPointInRing(polygon[0], geoPoint) {
+		return false
+	}
+	for _, hole := range polygon[1:] {
+		if g.isPointInRing(hole, geoPoint) {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *GeoService) IsPointInZone(zone models.GeoZone, geoPoint models.GeoCoords) bool {
+	for _, polygon := range zone.Coordinates {
+		if g.isPointInPolygon(polygon, geoPoint) {
+			return true
+		}
+	}
 	return false
 }
 
-func (g *GeoService) IsInDeliveryArea(selfPeer models.Peer, peer models.Peer) bool {
-	if g.IsSameCoord(selfPeer.Center, peer.Center) {
+func (g *GeoService) orientation(a, b, c models.GeoPosition) float64 {
+	return (b[0]-a[0])*(c[1]-a[1]) - (b[1]-a[1])*(c[0]-a[0])
+}
+
+func (g *GeoService) areSegmentsIntersecting(a1, a2, b1, b2 models.GeoPosition) bool {
+	d1 := g.orientation(b1, b2, a1)
+	d2 := g.orientation(b1, b2, a2)
+	d3 := g.orientation(a1, a2, b1)
+	d4 := g.orientation(a1, a2, b2)
+
+	if ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) &&
+		((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0)) {
 		return true
 	}
 
-	if selfPeer.DeliveryRadius == 0 {
+	onSegment := func(p, q, r models.GeoPosition) bool {
+		return math.Min(p[0], q[0]) <= r[0] && r[0] <= math.Max(p[0], q[0]) &&
+			math.Min(p[1], q[1]) <= r[1] && r[1] <= math.Max(p[1], q[1])
+	}
+
+	return (d1 == 0 && onSegment(b1, b2, a1)) ||
+		(d2 == 0 && onSegment(b1, b2, a2)) ||
+		(d3 == 0 && onSegment(a1, a2, b1)) ||
+		(d4 == 0 && onSegment(a1, a2, b2))
+}
+
+func (g *GeoService) arePolygonsIntersecting(polygon1, polygon2 models.GeoPolygon) bool {
+	if len(polygon1) == 0 || len(polygon2) == 0 {
 		return false
 	}
 
-	peerDis := g.GetCoordDistance(peer.Center, selfPeer.Center)
-	peerDis = math.Abs(peerDis)
+	for _, ring1 := range polygon1 {
+		for i := 1; i < len(ring1); i++ {
+			for _, ring2 := range polygon2 {
+				for j := 1; j < len(ring2); j++ {
+					if g.areSegmentsIntersecting(ring1[i-1], ring1[i], ring2[j-1], ring2[j]) {
+						return true
+					}
+				}
+			}
+		}
+	}
 
-	deliverySum := selfPeer.DeliveryRadius + peer.DeliveryRadius
+	// no edges cross, so one polygon can only be fully inside the other
+	return g.isPointInPolygon(polygon2, polygon1[0][0].ToCoords()) ||
+		g.isPointInPolygon(polygon1, polygon2[0][0].ToCoords())
+}
 
-	if peerDis <= deliverySum {
-		return true
+func (g *GeoService) AreZonesIntersecting(zone1 models.GeoZone, zone2 models.GeoZone) bool {
+	for _, polygon1 := range zone1.Coordinates {
+		for _, polygon2 := range zone2.Coordinates {
+			if g.arePolygonsIntersecting(polygon1, polygon2) {
+				return true
+			}
+		}
 	}
 	return false
 }
 
-func (g *GeoService) GetAddressCoords(address, city, country string) (models.GeoCoords, error) {
-	url, err := url.Parse("https://nominatim.openstreetmap.org/search")
-	if err != nil {
-		return models.GeoCoords{}, err
-	}
+// equirectangular projection around origin, the result is in km
+func (g *GeoService) project(origin models.GeoCoords, position models.GeoPosition) (float64, float64) {
+	x := (position[0] - origin.Long) * 111.320 * math.Cos(origin.Lat*math.Pi/180)
+	y := (position[1] - origin.Lat) * 110.574
+	return x, y
+}
 
-	query := url.Query()
-	query.Add("q", fmt.Sprintf("%s,%s,%s", address, city, country))
-	query.Add("format", "json")
-	query.Add("limit", "1")
-	url.RawQuery = query.Encode()
+func (g *GeoService) distanceToSegment(geoPoint models.GeoCoords, a, b models.GeoPosition) float64 {
+	ax, ay := g.project(geoPoint, a)
+	bx, by := g.project(geoPoint, b)
 
-	resp, err := http.Get(url.String())
-	if err != nil {
-		return models.GeoCoords{}, err
+	dx, dy := bx-ax, by-ay
+	lengthSq := dx*dx + dy*dy
+	t := 0.0
+	if lengthSq > 0 {
+		t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/lengthSq))
 	}
 
-	decoder := json.NewDecoder(resp.Body)
-	var getCordsResponse []types.GetCordsResponse
-	err = decoder.Decode(&getCordsResponse)
-	if err != nil {
-		return models.GeoCoords{}, err
+	return math.Hypot(ax+t*dx, ay+t*dy)
+}
+
+func (g *GeoService) IsZoneIntersectingCircle(zone models.GeoZone, center models.GeoCoords, radius float64) bool {
+	if g.IsPointInZone(zone, center) {
+		return true
 	}
 
-	long, err := strconv.ParseFloat(getCordsResponse[0].Lon, 64)
-	if err != nil {
-		return models.GeoCoords{}, err
+	for _, polygon := range zone.Coordinates {
+		for _, ring := range polygon {
+			for i := 1; i < len(ring); i++ {
+				if g.distanceToSegment(center, ring[i-1], ring[i]) <= radius {
+					return true
+				}
+			}
+		}
 	}
-	lat, err := strconv.ParseFloat(getCordsResponse[0].Lat, 64)
-	if err != nil {
-		return models.GeoCoords{}, err
+	return false
+}
+
+func (g *GeoService) CircleToZone(center models.GeoCoords, radius float64) models.GeoZone {
+	const sides = 32
+	ring := make(models.GeoRing, sides+1)
+
+	for i := 0; i < sides; i++ {
+		angle := 2 * math.Pi * float64(i) / sides
+		long := center.Long + radius*math.Cos(angle)/(111.320*math.Cos(center.Lat*math.Pi/180))
+		lat := center.Lat + radius*math.Sin(angle)/110.574
+		ring[i] = models.GeoPosition{long, lat}
 	}
+	ring[sides] = ring[0]
 
-	return models.GeoCoords{Long: long, Lat: lat}, nil
+	return *models.NewGeoZone(models.GeoPolygon{ring})
 }
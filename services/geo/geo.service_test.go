@@ -2,7 +2,6 @@ package geo
 
 import (
 	"math"
-	"reflect"
 	"testing"
 
 	"github.com/nicodeheza/peersEat/models"
@@ -136,6 +135,38 @@ func TestIsInInfluenceArea(t *testing.T) {
 	}
 }
 
+func TestInfluenceRadius(t *testing.T) {
+	g := NewGeo()
+
+	peer1 := models.Peer{
+		Url:     "http://test.com",
+		Center:  models.GeoCoords{Long: -34.605447, Lat: -58.383594},
+		City:    "test city",
+		Country: "test country",
+	}
+	peer2 := peer1
+	peer2.Center = models.GeoCoords{Long: -34.606716, Lat: -58.470303}
+
+	if g.GetInfluenceRadius(peer1) != 2 {
+		t.Errorf("expect default influence radius 2 but gets %f", g.GetInfluenceRadius(peer1))
+	}
+
+	point := models.GeoCoords{Long: -34.605447, Lat: -58.406094}
+	if g.IsInInfluenceArea(peer1, point) {
+		t.Errorf("expect %v to be out of the default influence area", point)
+	}
+
+	peer1.InfluenceRadius = 3
+	if !g.IsInInfluenceArea(peer1, point) {
+		t.Errorf("expect %v to be in a 3km influence area", point)
+	}
+
+	peer2.InfluenceRadius = 7
+	if !g.AreInfluenceAreasOverlaying(peer1, peer2) {
+		t.Errorf("expect influence areas of 3km and 7km to overlay for (%v, %v)", peer1, peer2)
+	}
+}
+
 func TestIsInDeliveryArea(t *testing.T) {
 	g := NewGeo()
 
@@ -189,17 +220,128 @@ func TestIsInDeliveryArea(t *testing.T) {
 	}
 }
 
-func TestGetAddressCords(t *testing.T) {
+func squareRing(long, lat, size float64) models.GeoRing {
+	return models.GeoRing{
+		{long, lat},
+		{long + size, lat},
+		{long + size, lat + size},
+		{long, lat + size},
+		{long, lat},
+	}
+}
+
+func TestIsPointInZone(t *testing.T) {
+	g := NewGeo()
+
+	zone := *models.NewGeoZone(
+		models.GeoPolygon{squareRing(0, 0, 10), squareRing(4, 4, 2)},
+		models.GeoPolygon{squareRing(20, 20, 1)},
+	)
+
+	type Test struct {
+		Point  models.GeoCoords
+		result bool
+	}
+	tests := []Test{
+		{models.GeoCoords{Long: 1, Lat: 1}, true},
+		{models.GeoCoords{Long: 5, Lat: 5}, false},
+		{models.GeoCoords{Long: 20.5, Lat: 20.5}, true},
+		{models.GeoCoords{Long: 15, Lat: 15}, false},
+	}
+
+	for _, test := range tests {
+		result := g.IsPointInZone(zone, test.Point)
+		if result != test.result {
+			t.Errorf("expect %t but gets %t for %v", test.result, result, test.Point)
+		}
+	}
+}
+
+func TestAreZonesIntersecting(t *testing.T) {
+	g := NewGeo()
+
+	base := *models.NewGeoZone(models.GeoPolygon{squareRing(0, 0, 10)})
+
+	type Test struct {
+		Zone   models.GeoZone
+		result bool
+	}
+	tests := []Test{
+		{*models.NewGeoZone(models.GeoPolygon{squareRing(5, 5, 10)}), true},
+		{*models.NewGeoZone(models.GeoPolygon{squareRing(2, 2, 1)}), true},
+		{*models.NewGeoZone(models.GeoPolygon{squareRing(-5, -5, 30)}), true},
+		{*models.NewGeoZone(models.GeoPolygon{squareRing(11, 11, 1)}), false},
+	}
+
+	for _, test := range tests {
+		result := g.AreZonesIntersecting(base, test.Zone)
+		if result != test.result {
+			t.Errorf("expect %t but gets %t for %v", test.result, result, test.Zone)
+		}
+	}
+}
+
+func TestZonesAndCircles(t *testing.T) {
+	g := NewGeo()
+
+	center := models.GeoCoords{Long: -58.383594, Lat: -34.605447}
+	circle := g.CircleToZone(center, 2)
+
+	if !g.IsPointInZone(circle, center) {
+		t.Errorf("expect circle zone to contain its center %v", center)
+	}
+
+	inside := models.GeoCoords{Long: -58.383594, Lat: -34.595447}
+	outside := models.GeoCoords{Long: -58.383594, Lat: -34.575447}
+	if !g.IsPointInZone(circle, inside) {
+		t.Errorf("expect circle zone to contain %v", inside)
+	}
+	if g.IsPointInZone(circle, outside) {
+		t.Errorf("expect circle zone to not contain %v", outside)
+	}
+
+	if !g.IsZoneIntersectingCircle(circle, outside, 2) {
+		t.Errorf("expect circle zone to intersect a 2km circle at %v", outside)
+	}
+	if g.IsZoneIntersectingCircle(circle, outside, 0.5) {
+		t.Errorf("expect circle zone to not intersect a 0.5km circle at %v", outside)
+	}
+}
+
+func TestZonesArePreferred(t *testing.T) {
 	g := NewGeo()
 
-	res, err := g.GetAddressCoords("cerrito 800", "Buenos Aires", "Argentina")
-	if err != nil {
-		t.Error(err.Error())
+	peer1 := models.Peer{
+		Url:            "http://test.com",
+		Center:         models.GeoCoords{Long: 0, Lat: 0},
+		City:           "test city",
+		Country:        "test country",
+		DeliveryRadius: 1,
+		DeliveryZone:   models.NewGeoZone(models.GeoPolygon{squareRing(0, 0, 1)}),
 	}
+	peer2 := peer1
+	peer2.Center = models.GeoCoords{Long: 0.5, Lat: 0.5}
+	peer2.DeliveryZone = models.NewGeoZone(models.GeoPolygon{squareRing(5, 5, 1)})
 
-	expected := models.GeoCoords{Long: -58.3826948, Lat: -34.5992499}
+	if g.IsInDeliveryArea(peer1, peer2) {
+		t.Error("expect delivery zones to be used instead of the delivery radius")
+	}
 
-	if !reflect.DeepEqual(expected, res) {
-		t.Errorf("Expected: %v but go: %v", expected, res)
+	peer2.DeliveryZone = nil
+	if !g.IsInDeliveryArea(peer1, peer2) {
+		t.Error("expect peer delivery radius to intersect the other peer zone")
+	}
+
+	restaurant := models.Restaurant{
+		Coord:          models.GeoCoords{Long: 0, Lat: 0},
+		DeliveryRadius: 1,
+	}
+	point := models.GeoCoords{Long: 0.5, Lat: 0.5}
+	if g.IsInRestaurantDeliveryArea(restaurant, point) {
+		t.Errorf("expect %v to be out of restaurant delivery radius", point)
+	}
+	restaurant.DeliveryZone = models.NewGeoZone(models.GeoPolygon{squareRing(0, 0, 1)})
+	if !g.IsInRestaurantDeliveryArea(restaurant, point) {
+		t.Errorf("expect %v to be in restaurant delivery zone", point)
 	}
 }
@@ -0,0 +1,64 @@
+package geocoder
+
+import (
+	"log"
+	"time"
+
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/repositories"
+	"github.com/nicodeheza/peersEat/types"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// CachedGeocoder stores successful geocoding results keyed by the provider and the
+// normalized address, failures are never cached so they are retried next time
+type CachedGeocoder struct {
+	geocoder GeocoderI
+	repo     repositories.GeocodeCacheRepositoryI
+}
+
+func NewCachedGeocoder(geocoder GeocoderI, repo repositories.GeocodeCacheRepositoryI) *CachedGeocoder {
+	return &CachedGeocoder{geocoder, repo}
+}
+
+func (c *CachedGeocoder) Provider() string {
+	return c.geocoder.Provider()
+}
+
+func (c *CachedGeocoder) Geocode(address, city, country string) (types.GeocodeResult, error) {
+	query := NormalizeQuery(address, city, country)
+
+	cached, err := c.repo.FindByQuery(c.Provider(), query)
+	if err == nil {
+		return types.GeocodeResult{
+			Coord:       cached.Coord,
+			Provider:    cached.Provider,
+			Confidence:  cached.Confidence,
+			BoundingBox: cached.BoundingBox,
+			DisplayName: cached.DisplayName,
+		}, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		log.Println(err.Error())
+	}
+
+	result, err := c.geocoder.Geocode(address, city, country)
+	if err != nil {
+		return result, err
+	}
+
+	err = c.repo.Upsert(models.GeocodeCache{
+		Query:       query,
+		Coord:       result.Coord,
+		Provider:    c.Provider(),
+		Confidence:  result.Confidence,
+		BoundingBox: result.BoundingBox,
+		DisplayName: result.DisplayName,
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		log.Println(err.Error())
+	}
+
+	return result, nil
+}
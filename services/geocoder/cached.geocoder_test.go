@@ -0,0 +1,70 @@
+package geocoder
+
+import (
+	"testing"
+
+	"github.com/nicodeheza/peersEat/mocks"
+)
+
+func TestCachedGeocoder(t *testing.T) {
+	inner := mocks.NewGeocoderMock()
+	repo := mocks.NewGeocodeCacheRepositoryMock()
+	g := NewCachedGeocoder(inner, repo)
+
+	first, err := g.Geocode("Cerrito 800", "Buenos Aires", "Argentina")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	second, err := g.Geocode(" cerrito  800", "BUENOS AIRES", "argentina")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(inner.Calls) != 1 {
+		t.Errorf("expecting the provider to be called once but was called %d times", len(inner.Calls))
+	}
+	if first.Coord != second.Coord || second.Provider != "mock" || second.Confidence != 0.9 {
+		t.Errorf("expecting cached result %v but got %v", first, second)
+	}
+
+	entry, ok := repo.Entries[mocks.GeocodeCacheKey("mock", NormalizeQuery("Cerrito 800", "Buenos Aires", "Argentina"))]
+	if !ok {
+		t.Fatal("expecting result to be cached")
+	}
+	if entry.CreatedAt.IsZero() {
+		t.Error("expecting cache entry to have a creation time")
+	}
+
+	if _, err := g.Geocode("error", "Buenos Aires", "Argentina"); err == nil {
+		t.Error("expecting provider error")
+	}
+	if _, ok := repo.Entries[mocks.GeocodeCacheKey("mock", NormalizeQuery("error", "Buenos Aires", "Argentina"))]; ok {
+		t.Error("expecting failed results to not be cached")
+	}
+}
+
+func TestCachedGeocoderPerProvider(t *testing.T) {
+	repo := mocks.NewGeocodeCacheRepositoryMock()
+
+	first := mocks.NewGeocoderMock()
+	if _, err := NewCachedGeocoder(first, repo).Geocode("Cerrito 800", "Buenos Aires", "Argentina"); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	second := mocks.NewGeocoderMock()
+	second.Name = "other"
+	result, err := NewCachedGeocoder(second, repo).Geocode("Cerrito 800", "Buenos Aires", "Argentina")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(second.Calls) != 1 {
+		t.Errorf("expecting the new provider to be called but was called %d times", len(second.Calls))
+	}
+	if result.Provider != "other" {
+		t.Errorf("expecting the result of the new provider but got %s", result.Provider)
+	}
+	if len(repo.Entries) != 2 {
+		t.Errorf("expecting an entry per provider but got %d", len(repo.Entries))
+	}
+}
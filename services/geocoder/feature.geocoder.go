@@ -0,0 +1,101 @@
+package geocoder
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/services/geo"
+	"github.com/nicodeheza/peersEat/types"
+)
+
+// FeatureGeocoder talks to servers answering with a GeoJSON FeatureCollection,
+// like Photon and Pelias
+type FeatureGeocoder struct {
+	provider   string
+	searchUrl  string
+	textParam  string
+	limitParam string
+	userAgent  string
+	client     *http.Client
+	geo        geo.GeoServiceI
+}
+
+func NewPhotonGeocoder(baseUrl, userAgent string, client *http.Client, geo geo.GeoServiceI) *FeatureGeocoder {
+	return &FeatureGeocoder{PHOTON, baseUrl + "/api", "q", "limit", userAgent, client, geo}
+}
+
+func NewPeliasGeocoder(baseUrl, userAgent string, client *http.Client, geo geo.GeoServiceI) *FeatureGeocoder {
+	return &FeatureGeocoder{PELIAS, baseUrl + "/v1/search", "text", "size", userAgent, client, geo}
+}
+
+func (f *FeatureGeocoder) Provider() string {
+	return f.provider
+}
+
+func (f *FeatureGeocoder) Geocode(address, city, country string) (types.GeocodeResult, error) {
+	url, err := url.Parse(f.searchUrl)
+	if err != nil {
+		return types.GeocodeResult{}, err
+	}
+
+	q := fmt.Sprintf("%s,%s,%s", address, city, country)
+	query := url.Query()
+	query.Add(f.textParam, q)
+	query.Add(f.limitParam, "5")
+	url.RawQuery = query.Encode()
+
+	req, err := http.NewRequest("GET", url.String(), nil)
+	if err != nil {
+		return types.GeocodeResult{}, err
+	}
+	req.Header.Set("User-Agent", f.userAgent)
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return types.GeocodeResult{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return types.GeocodeResult{}, &ProviderError{Provider: f.provider, StatusCode: resp.StatusCode}
+	}
+
+	var collection types.GeoJsonFeatureCollection
+	err = json.NewDecoder(resp.Body).Decode(&collection)
+	if err != nil {
+		return types.GeocodeResult{}, err
+	}
+
+	candidates := make([]types.GeocodeResult, 0, len(collection.Features))
+	for _, feature := range collection.Features {
+		if len(feature.Geometry.Coordinates) < 2 {
+			continue
+		}
+
+		// pelias scores its results, photon doesn't so its candidates have no confidence
+		// and any of them far from the first one makes the address ambiguous
+		candidate := types.GeocodeResult{
+			Coord:       models.GeoCoords{Long: feature.Geometry.Coordinates[0], Lat: feature.Geometry.Coordinates[1]},
+			Provider:    f.provider,
+			Confidence:  feature.Properties.Confidence,
+			BoundingBox: feature.Bbox,
+			DisplayName: feature.Properties.Label,
+		}
+
+		// photon extent is [minLong, maxLat, maxLong, minLat]
+		if candidate.BoundingBox == nil && len(feature.Properties.Extent) == 4 {
+			extent := feature.Properties.Extent
+			candidate.BoundingBox = []float64{extent[0], extent[3], extent[2], extent[1]}
+		}
+		if candidate.DisplayName == "" {
+			candidate.DisplayName = feature.Properties.Name
+		}
+
+		candidates = append(candidates, candidate)
+	}
+
+	return resolveCandidates(q, candidates, f.geo)
+}
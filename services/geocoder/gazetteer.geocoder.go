@@ -0,0 +1,80 @@
+package geocoder
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/services/geo"
+	"github.com/nicodeheza/peersEat/types"
+)
+
+// GazetteerGeocoder resolves addresses from a local csv file with the columns
+// address,city,country,long,lat
+type GazetteerGeocoder struct {
+	entries map[string][]types.GeocodeResult
+	geo     geo.GeoServiceI
+}
+
+func NewGazetteerGeocoderFromFile(path string, geo geo.GeoServiceI) (*GazetteerGeocoder, error) {
+	if path == "" {
+		return nil, errors.New("GAZETTEER_FILE is required for gazetteer")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return NewGazetteerGeocoder(file, geo)
+}
+
+func NewGazetteerGeocoder(reader io.Reader, geo geo.GeoServiceI) (*GazetteerGeocoder, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = 5
+	csvReader.TrimLeadingSpace = true
+
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string][]types.GeocodeResult)
+	for i, record := range records {
+		if i == 0 && record[0] == "address" {
+			continue
+		}
+
+		long, err := strconv.ParseFloat(record[3], 64)
+		if err != nil {
+			return nil, fmt.Errorf("gazetteer line %d: %v", i+1, err)
+		}
+		lat, err := strconv.ParseFloat(record[4], 64)
+		if err != nil {
+			return nil, fmt.Errorf("gazetteer line %d: %v", i+1, err)
+		}
+
+		key := NormalizeQuery(record[0], record[1], record[2])
+		entries[key] = append(entries[key], types.GeocodeResult{
+			Coord:       models.GeoCoords{Long: long, Lat: lat},
+			Provider:    GAZETTEER,
+			Confidence:  1,
+			DisplayName: fmt.Sprintf("%s, %s, %s", record[0], record[1], record[2]),
+		})
+	}
+
+	return &GazetteerGeocoder{entries, geo}, nil
+}
+
+func (g *GazetteerGeocoder) Provider() string {
+	return GAZETTEER
+}
+
+func (g *GazetteerGeocoder) Geocode(address, city, country string) (types.GeocodeResult, error) {
+	key := NormalizeQuery(address, city, country)
+	return resolveCandidates(key, g.entries[key], g.geo)
+}
@@ -0,0 +1,134 @@
+package geocoder
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nicodeheza/peersEat/services/geo"
+	"github.com/nicodeheza/peersEat/types"
+)
+
+const NOMINATIM = "nominatim"
+const PHOTON = "photon"
+const PELIAS = "pelias"
+const GAZETTEER = "gazetteer"
+
+const DEFAULT_NOMINATIM_URL = "https://nominatim.openstreetmap.org"
+const DEFAULT_USER_AGENT = "peersEat"
+
+// two candidates are considered the same place when they are closer than this (km)
+const AMBIGUITY_DISTANCE = 1.0
+
+// a candidate is a clear winner when its confidence beats the next one by this margin
+const AMBIGUITY_MARGIN = 0.1
+
+type GeocoderI interface {
+	Geocode(address, city, country string) (types.GeocodeResult, error)
+	Provider() string
+}
+
+type NoMatchError struct {
+	Query string
+}
+
+func (e *NoMatchError) Error() string {
+	return fmt.Sprintf("no match found for %q", e.Query)
+}
+
+type AmbiguousError struct {
+	Query      string
+	Candidates []types.GeocodeResult
+}
+
+func (e *AmbiguousError) Error() string {
+	return fmt.Sprintf("%d different places match %q", len(e.Candidates), e.Query)
+}
+
+type ProviderError struct {
+	Provider   string
+	StatusCode int
+}
+
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("%s responded with status %d", e.Provider, e.StatusCode)
+}
+
+func IsNoMatch(err error) bool {
+	var noMatch *NoMatchError
+	return errors.As(err, &noMatch)
+}
+
+func IsAmbiguous(err error) bool {
+	var ambiguous *AmbiguousError
+	return errors.As(err, &ambiguous)
+}
+
+var spacesRegex = regexp.MustCompile(`\s+`)
+
+func normalize(s string) string {
+	return spacesRegex.ReplaceAllString(strings.ToLower(strings.TrimSpace(s)), " ")
+}
+
+func NormalizeQuery(address, city, country string) string {
+	return fmt.Sprintf("%s,%s,%s", normalize(address), normalize(city), normalize(country))
+}
+
+func NewGeocoderFromEnv(geo geo.GeoServiceI) (GeocoderI, error) {
+	baseUrl := os.Getenv("GEOCODER_URL")
+	userAgent := os.Getenv("GEOCODER_USER_AGENT")
+	if userAgent == "" {
+		userAgent = DEFAULT_USER_AGENT
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	switch os.Getenv("GEOCODER") {
+	case "", NOMINATIM:
+		if baseUrl == "" {
+			baseUrl = DEFAULT_NOMINATIM_URL
+		}
+		return NewNominatimGeocoder(baseUrl, userAgent, client, geo), nil
+	case PHOTON:
+		if baseUrl == "" {
+			return nil, errors.New("GEOCODER_URL is required for photon")
+		}
+		return NewPhotonGeocoder(baseUrl, userAgent, client, geo), nil
+	case PELIAS:
+		if baseUrl == "" {
+			return nil, errors.New("GEOCODER_URL is required for pelias")
+		}
+		return NewPeliasGeocoder(baseUrl, userAgent, client, geo), nil
+	case GAZETTEER:
+		return NewGazetteerGeocoderFromFile(os.Getenv("GAZETTEER_FILE"), geo)
+	}
+
+	return nil, fmt.Errorf("unknown geocoder %q", os.Getenv("GEOCODER"))
+}
+
+// candidates must be sorted by relevance
+func resolveCandidates(query string, candidates []types.GeocodeResult, geo geo.GeoServiceI) (types.GeocodeResult, error) {
+	if len(candidates) == 0 {
+		return types.GeocodeResult{}, &NoMatchError{Query: query}
+	}
+
+	best := candidates[0]
+	rivals := []types.GeocodeResult{best}
+	for _, candidate := range candidates[1:] {
+		if best.Confidence-candidate.Confidence >= AMBIGUITY_MARGIN {
+			break
+		}
+		if geo.GetCoordDistance(best.Coord, candidate.Coord) > AMBIGUITY_DISTANCE {
+			rivals = append(rivals, candidate)
+		}
+	}
+
+	if len(rivals) > 1 {
+		return types.GeocodeResult{}, &AmbiguousError{Query: query, Candidates: rivals}
+	}
+
+	return best, nil
+}
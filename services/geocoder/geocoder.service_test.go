@@ -0,0 +1,175 @@
+package geocoder
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/services/geo"
+)
+
+func TestNominatimGeocode(t *testing.T) {
+	client := &http.Client{}
+	httpmock.ActivateNonDefault(client)
+	defer httpmock.DeactivateAndReset()
+
+	g := NewNominatimGeocoder("http://nominatim.test", "peersEatTest", client, geo.NewGeo())
+
+	httpmock.RegisterResponder("GET", "http://nominatim.test/search",
+		func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get("User-Agent") != "peersEatTest" {
+				return httpmock.NewStringResponse(403, ``), nil
+			}
+			switch req.URL.Query().Get("q") {
+			case "cerrito 800,Buenos Aires,Argentina":
+				return httpmock.NewStringResponse(200, `[{"lat":"-34.5992499","lon":"-58.3826948","importance":0.6,
+					"boundingbox":["-34.6","-34.59","-58.39","-58.38"],"display_name":"Cerrito 800"},
+					{"lat":"-34.5993","lon":"-58.3827","importance":0.55,"display_name":"Cerrito 800 B"}]`), nil
+			case "San Martin 100,Test,Argentina":
+				return httpmock.NewStringResponse(200, `[{"lat":"-34.6","lon":"-58.4","importance":0.5},
+					{"lat":"-31.4","lon":"-64.1","importance":0.48}]`), nil
+			case "down,Test,Argentina":
+				return httpmock.NewStringResponse(503, ``), nil
+			}
+			return httpmock.NewStringResponse(200, `[]`), nil
+		})
+
+	res, err := g.Geocode("cerrito 800", "Buenos Aires", "Argentina")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expected := models.GeoCoords{Long: -58.3826948, Lat: -34.5992499}
+	if !reflect.DeepEqual(expected, res.Coord) {
+		t.Errorf("Expected: %v but go: %v", expected, res.Coord)
+	}
+	expectedBox := []float64{-58.39, -34.6, -58.38, -34.59}
+	if !reflect.DeepEqual(expectedBox, res.BoundingBox) {
+		t.Errorf("Expected bounding box: %v but go: %v", expectedBox, res.BoundingBox)
+	}
+	if res.Provider != NOMINATIM || res.Confidence != 0.6 {
+		t.Errorf("incorrect provider or confidence: %v", res)
+	}
+
+	_, err = g.Geocode("San Martin 100", "Test", "Argentina")
+	ambiguous, ok := err.(*AmbiguousError)
+	if !ok {
+		t.Fatalf("expecting ambiguous error but got: %v", err)
+	}
+	if len(ambiguous.Candidates) != 2 {
+		t.Errorf("expecting 2 candidates but got %d", len(ambiguous.Candidates))
+	}
+
+	_, err = g.Geocode("nowhere", "Test", "Argentina")
+	if !IsNoMatch(err) {
+		t.Errorf("expecting no match error but got: %v", err)
+	}
+
+	_, err = g.Geocode("down", "Test", "Argentina")
+	if _, ok := err.(*ProviderError); !ok {
+		t.Errorf("expecting provider error but got: %v", err)
+	}
+}
+
+func TestPhotonGeocode(t *testing.T) {
+	client := &http.Client{}
+	httpmock.ActivateNonDefault(client)
+	defer httpmock.DeactivateAndReset()
+
+	g := NewPhotonGeocoder("http://photon.test", "peersEatTest", client, geo.NewGeo())
+
+	httpmock.RegisterResponder("GET", "http://photon.test/api",
+		func(req *http.Request) (*http.Response, error) {
+			if req.URL.Query().Get("q") == "San Martin 100,Test,Argentina" {
+				return httpmock.NewStringResponse(200, `{"features":[
+					{"geometry":{"coordinates":[-58.4,-34.6]},"properties":{"name":"San Martin"}},
+					{"geometry":{"coordinates":[-64.1,-31.4]},"properties":{"name":"San Martin"}}]}`), nil
+			}
+			return httpmock.NewStringResponse(200, `{"features":[
+				{"geometry":{"coordinates":[-58.38,-34.59]},"properties":{"name":"Cerrito","extent":[-58.39,-34.58,-58.37,-34.6]}},
+				{"geometry":{"coordinates":[-58.381,-34.591]},"properties":{"name":"Cerrito"}}]}`), nil
+		})
+
+	res, err := g.Geocode("cerrito 800", "Buenos Aires", "Argentina")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expected := models.GeoCoords{Long: -58.38, Lat: -34.59}
+	if !reflect.DeepEqual(expected, res.Coord) {
+		t.Errorf("Expected: %v but go: %v", expected, res.Coord)
+	}
+	expectedBox := []float64{-58.39, -34.6, -58.37, -34.58}
+	if !reflect.DeepEqual(expectedBox, res.BoundingBox) {
+		t.Errorf("Expected bounding box: %v but go: %v", expectedBox, res.BoundingBox)
+	}
+	if res.Confidence != 0 {
+		t.Errorf("expecting photon results to have no confidence but got %v", res.Confidence)
+	}
+
+	// without scores a far candidate can't be ruled out
+	if _, err := g.Geocode("San Martin 100", "Test", "Argentina"); !IsAmbiguous(err) {
+		t.Errorf("expecting ambiguous error but got: %v", err)
+	}
+}
+
+func TestPeliasGeocode(t *testing.T) {
+	client := &http.Client{}
+	httpmock.ActivateNonDefault(client)
+	defer httpmock.DeactivateAndReset()
+
+	g := NewPeliasGeocoder("http://pelias.test", "peersEatTest", client, geo.NewGeo())
+
+	httpmock.RegisterResponder("GET", "http://pelias.test/v1/search",
+		func(req *http.Request) (*http.Response, error) {
+			if req.URL.Query().Get("text") == "San Martin 100,Test,Argentina" {
+				return httpmock.NewStringResponse(200, `{"features":[
+					{"geometry":{"coordinates":[-58.4,-34.6]},"properties":{"label":"San Martin 100","confidence":0.8}},
+					{"geometry":{"coordinates":[-64.1,-31.4]},"properties":{"label":"San Martin 100","confidence":0.75}}]}`), nil
+			}
+			return httpmock.NewStringResponse(200, `{"features":[
+				{"geometry":{"coordinates":[-58.38,-34.59]},"properties":{"label":"Cerrito 800","confidence":0.9}},
+				{"geometry":{"coordinates":[-64.1,-31.4]},"properties":{"label":"Cerrito","confidence":0.4}}]}`), nil
+		})
+
+	res, err := g.Geocode("cerrito 800", "Buenos Aires", "Argentina")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if res.Provider != PELIAS || res.Confidence != 0.9 || res.DisplayName != "Cerrito 800" {
+		t.Errorf("expecting the pelias confidence but got: %v", res)
+	}
+
+	if _, err := g.Geocode("San Martin 100", "Test", "Argentina"); !IsAmbiguous(err) {
+		t.Errorf("expecting ambiguous error but got: %v", err)
+	}
+}
+
+func TestGazetteerGeocode(t *testing.T) {
+	file := strings.NewReader(`address,city,country,long,lat
+Cerrito 800,Buenos Aires,Argentina,-58.3826948,-34.5992499
+San Martin 100,Test,Argentina,-58.4,-34.6
+San Martin 100,Test,Argentina,-64.1,-31.4
+`)
+	g, err := NewGazetteerGeocoder(file, geo.NewGeo())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	res, err := g.Geocode("  cerrito   800", "buenos aires", "ARGENTINA")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expected := models.GeoCoords{Long: -58.3826948, Lat: -34.5992499}
+	if !reflect.DeepEqual(expected, res.Coord) {
+		t.Errorf("Expected: %v but go: %v", expected, res.Coord)
+	}
+
+	if _, err := g.Geocode("San Martin 100", "Test", "Argentina"); !IsAmbiguous(err) {
+		t.Errorf("expecting ambiguous error but got: %v", err)
+	}
+	if _, err := g.Geocode("Cerrito 900", "Buenos Aires", "Argentina"); !IsNoMatch(err) {
+		t.Errorf("expecting no match error but got: %v", err)
+	}
+}
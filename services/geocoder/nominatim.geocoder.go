@@ -0,0 +1,102 @@
+package geocoder
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/services/geo"
+	"github.com/nicodeheza/peersEat/types"
+)
+
+type NominatimGeocoder struct {
+	baseUrl   string
+	userAgent string
+	client    *http.Client
+	geo       geo.GeoServiceI
+}
+
+func NewNominatimGeocoder(baseUrl, userAgent string, client *http.Client, geo geo.GeoServiceI) *NominatimGeocoder {
+	return &NominatimGeocoder{baseUrl, userAgent, client, geo}
+}
+
+func (n *NominatimGeocoder) Provider() string {
+	return NOMINATIM
+}
+
+func (n *NominatimGeocoder) Geocode(address, city, country string) (types.GeocodeResult, error) {
+	url, err := url.Parse(n.baseUrl + "/search")
+	if err != nil {
+		return types.GeocodeResult{}, err
+	}
+
+	q := fmt.Sprintf("%s,%s,%s", address, city, country)
+	query := url.Query()
+	query.Add("q", q)
+	query.Add("format", "json")
+	query.Add("limit", "5")
+	url.RawQuery = query.Encode()
+
+	req, err := http.NewRequest("GET", url.String(), nil)
+	if err != nil {
+		return types.GeocodeResult{}, err
+	}
+	req.Header.Set("User-Agent", n.userAgent)
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return types.GeocodeResult{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return types.GeocodeResult{}, &ProviderError{Provider: NOMINATIM, StatusCode: resp.StatusCode}
+	}
+
+	var getCordsResponse []types.GetCordsResponse
+	err = json.NewDecoder(resp.Body).Decode(&getCordsResponse)
+	if err != nil {
+		return types.GeocodeResult{}, err
+	}
+
+	candidates := make([]types.GeocodeResult, 0, len(getCordsResponse))
+	for _, place := range getCordsResponse {
+		long, err := strconv.ParseFloat(place.Lon, 64)
+		if err != nil {
+			return types.GeocodeResult{}, err
+		}
+		lat, err := strconv.ParseFloat(place.Lat, 64)
+		if err != nil {
+			return types.GeocodeResult{}, err
+		}
+
+		candidates = append(candidates, types.GeocodeResult{
+			Coord:       models.GeoCoords{Long: long, Lat: lat},
+			Provider:    NOMINATIM,
+			Confidence:  place.Importance,
+			BoundingBox: n.parseBoundingBox(place.Boundingbox),
+			DisplayName: place.Display_name,
+		})
+	}
+
+	return resolveCandidates(q, candidates, n.geo)
+}
+
+// nominatim sends [minLat, maxLat, minLong, maxLong] as strings
+func (n *NominatimGeocoder) parseBoundingBox(box []string) []float64 {
+	if len(box) != 4 {
+		return nil
+	}
+	values := make([]float64, 4)
+	for i, value := range box {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil
+		}
+		values[i] = parsed
+	}
+	return []float64{values[2], values[0], values[3], values[1]}
+}
@@ -0,0 +1,444 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/repositories"
+	"github.com/nicodeheza/peersEat/services/geo"
+	"github.com/nicodeheza/peersEat/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// split only hands restaurants to neighbors closer to them, merge hands every
+// restaurant a neighbor can take
+const HANDOFF_SPLIT = "split"
+const HANDOFF_MERGE = "merge"
+
+var ErrUnknownPeer = errors.New("unknown peer")
+var ErrHandoffNotFound = errors.New("handoff not found")
+
+type HandoffServiceI interface {
+	Plan(request types.HandoffRequest) ([]types.HandoffPlanItem, error)
+	Execute(request types.HandoffRequest) (types.HandoffResult, error)
+	GetOffered(id string, token string) ([]types.TransferredRestaurant, error)
+	ReceiveOffer(offer types.HandoffOffer) ([]string, error)
+	FindTombstone(query map[string]interface{}) (models.RestaurantTombstone, error)
+}
+
+type pendingHandoff struct {
+	token       string
+	restaurants []types.TransferredRestaurant
+}
+
+type HandoffService struct {
+	peerRepo        repositories.PeerRepositoryI
+	restaurantRepo  repositories.RestaurantRepositoryI
+	tombstoneRepo   repositories.RestaurantTombstoneRepositoryI
+	menuVersionRepo repositories.MenuVersionRepositoryI
+	staffRepo       repositories.StaffUserRepositoryI
+	geo             geo.GeoServiceI
+	peers           PeerServiceI
+	gracePeriod     time.Duration
+	mutex           sync.Mutex
+	pending         map[string]pendingHandoff
+}
+
+func NewHandoffService(
+	peerRepo repositories.PeerRepositoryI,
+	restaurantRepo repositories.RestaurantRepositoryI,
+	tombstoneRepo repositories.RestaurantTombstoneRepositoryI,
+	menuVersionRepo repositories.MenuVersionRepositoryI,
+	staffRepo repositories.StaffUserRepositoryI,
+	geo geo.GeoServiceI,
+	peers PeerServiceI,
+	gracePeriod time.Duration,
+) *HandoffService {
+	return &HandoffService{
+		peerRepo:        peerRepo,
+		restaurantRepo:  restaurantRepo,
+		tombstoneRepo:   tombstoneRepo,
+		menuVersionRepo: menuVersionRepo,
+		staffRepo:       staffRepo,
+		geo:             geo,
+		peers:           peers,
+		gracePeriod:     gracePeriod,
+		pending:         map[string]pendingHandoff{},
+	}
+}
+
+// every restaurant goes to the closest neighbor whose influence area contains it
+func (h *HandoffService) Plan(request types.HandoffRequest) ([]types.HandoffPlanItem, error) {
+	self, err := h.peerRepo.GetSelf()
+	if err != nil {
+		return nil, err
+	}
+
+	neighbors, err := h.peerRepo.GetManyByIds(self.InAreaPeers)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.Target != "" {
+		targets := []models.Peer{}
+		for _, neighbor := range neighbors {
+			if neighbor.Url == request.Target {
+				targets = append(targets, neighbor)
+			}
+		}
+		if len(targets) == 0 {
+			return nil, ErrUnknownPeer
+		}
+		neighbors = targets
+	}
+
+	restaurants, err := h.restaurantRepo.FindMany(map[string]interface{}{})
+	if err != nil {
+		return nil, err
+	}
+
+	selected := map[string]bool{}
+	for _, id := range request.RestaurantIds {
+		selected[id] = true
+	}
+
+	plan := []types.HandoffPlanItem{}
+	for _, restaurant := range restaurants {
+		if len(selected) > 0 && !selected[restaurant.Id.Hex()] {
+			continue
+		}
+
+		selfDistance := math.Abs(h.geo.GetCoordDistance(self.Center, restaurant.Coord))
+
+		var closest *models.Peer
+		var closestDistance float64
+		for i, neighbor := range neighbors {
+			if !h.geo.IsInInfluenceArea(neighbor, restaurant.Coord) {
+				continue
+			}
+			distance := math.Abs(h.geo.GetCoordDistance(neighbor.Center, restaurant.Coord))
+			if request.Mode == HANDOFF_SPLIT && distance >= selfDistance {
+				continue
+			}
+			if closest == nil || distance < closestDistance {
+				closest = &neighbors[i]
+				closestDistance = distance
+			}
+		}
+
+		if closest != nil {
+			plan = append(plan, types.HandoffPlanItem{
+				RestaurantId: restaurant.Id.Hex(),
+				Name:         restaurant.Name,
+				To:           closest.Url,
+			})
+		}
+	}
+
+	return plan, nil
+}
+
+func (h *HandoffService) Execute(request types.HandoffRequest) (types.HandoffResult, error) {
+	plan, err := h.Plan(request)
+	if err != nil {
+		return types.HandoffResult{}, err
+	}
+
+	result := types.HandoffResult{Planned: plan, Moved: []types.HandoffPlanItem{}, Rejected: []types.HandoffPlanItem{}}
+	if request.DryRun {
+		return result, nil
+	}
+
+	self, err := h.peerRepo.GetSelf()
+	if err != nil {
+		return types.HandoffResult{}, err
+	}
+
+	itemsByPeer := map[string][]types.HandoffPlanItem{}
+	for _, item := range plan {
+		itemsByPeer[item.To] = append(itemsByPeer[item.To], item)
+	}
+	peerUrls := []string{}
+	for peerUrl := range itemsByPeer {
+		peerUrls = append(peerUrls, peerUrl)
+	}
+	sort.Strings(peerUrls)
+
+	for _, peerUrl := range peerUrls {
+		items := itemsByPeer[peerUrl]
+
+		offered := map[string]types.TransferredRestaurant{}
+		transferred := []types.TransferredRestaurant{}
+		for _, item := range items {
+			id, _ := primitive.ObjectIDFromHex(item.RestaurantId)
+			restaurant, err := h.restaurantRepo.FindOne(map[string]interface{}{"_id": id})
+			if err != nil {
+				return result, err
+			}
+			versions, err := h.menuVersionRepo.FindMany(map[string]interface{}{"restaurantId": id})
+			if err != nil {
+				return result, err
+			}
+			staff, err := h.staffRepo.FindMany(id)
+			if err != nil {
+				return result, err
+			}
+			offered[item.RestaurantId] = types.TransferredRestaurant{
+				Restaurant:   restaurant,
+				UserName:     restaurant.UserName,
+				Password:     restaurant.Password,
+				MenuVersions: versions,
+				StaffUsers:   transferStaffUsers(staff),
+			}
+			transferred = append(transferred, offered[item.RestaurantId])
+		}
+
+		accepted, err := h.offer(self, peerUrl, request.Mode, transferred)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %s", peerUrl, err.Error()))
+			result.Rejected = append(result.Rejected, items...)
+			continue
+		}
+
+		for _, item := range items {
+			if !accepted[item.RestaurantId] {
+				result.Rejected = append(result.Rejected, item)
+				continue
+			}
+			err := h.retire(offered[item.RestaurantId], peerUrl)
+			if err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: %s", item.RestaurantId, err.Error()))
+				continue
+			}
+			result.Moved = append(result.Moved, item)
+		}
+	}
+
+	// recomputed even when nothing moved, running the handoff again repairs
+	// a recompute that failed after the restaurants were retired
+	err = h.peers.RecomputeDeliveryArea()
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+	}
+
+	return result, nil
+}
+
+// the restaurants are only served while the offer request is in flight
+func (h *HandoffService) offer(self models.Peer, peerUrl string, mode string, restaurants []types.TransferredRestaurant) (map[string]bool, error) {
+	token, err := newHandoffToken()
+	if err != nil {
+		return nil, err
+	}
+	id := primitive.NewObjectID().Hex()
+
+	h.mutex.Lock()
+	h.pending[id] = pendingHandoff{token, restaurants}
+	h.mutex.Unlock()
+
+	defer func() {
+		h.mutex.Lock()
+		delete(h.pending, id)
+		h.mutex.Unlock()
+	}()
+
+	body, err := json.Marshal(types.HandoffOffer{Id: id, Token: token, From: self.Url, Mode: mode})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.Post(peerUrl+"/peer/handoff/offer", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("peer responded with status %d", resp.StatusCode)
+	}
+
+	response := types.HandoffAccept{}
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		return nil, err
+	}
+
+	accepted := map[string]bool{}
+	for _, restaurantId := range response.Accepted {
+		accepted[restaurantId] = true
+	}
+
+	return accepted, nil
+}
+
+// the staff users leave with the restaurant, their logins are redirected too
+func (h *HandoffService) retire(transferred types.TransferredRestaurant, movedTo string) error {
+	restaurant := transferred.Restaurant
+	staffUserNames := []string{}
+	for _, staff := range transferred.StaffUsers {
+		if staff.StaffUser.UserName != "" {
+			staffUserNames = append(staffUserNames, staff.StaffUser.UserName)
+		}
+	}
+	err := h.tombstoneRepo.Upsert(models.RestaurantTombstone{
+		RestaurantId:   restaurant.Id,
+		UserName:       restaurant.UserName,
+		StaffUserNames: staffUserNames,
+		MovedTo:        movedTo,
+		ExpiresAt:      time.Now().Add(h.gracePeriod),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := h.restaurantRepo.Delete(restaurant.Id); err != nil {
+		return err
+	}
+	return h.staffRepo.DeleteByRestaurant(restaurant.Id)
+}
+
+func (h *HandoffService) GetOffered(id string, token string) ([]types.TransferredRestaurant, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	pending, ok := h.pending[id]
+	if !ok || subtle.ConstantTimeCompare([]byte(pending.token), []byte(token)) != 1 {
+		return nil, ErrHandoffNotFound
+	}
+
+	return pending.restaurants, nil
+}
+
+// restaurants are pulled from the sending peer, so only known peers can hand
+// restaurants off
+func (h *HandoffService) ReceiveOffer(offer types.HandoffOffer) ([]string, error) {
+	self, err := h.peerRepo.GetSelf()
+	if err != nil {
+		return nil, err
+	}
+
+	fromPeers, err := h.peerRepo.FindMany(map[string]interface{}{"url": offer.From})
+	if err != nil {
+		return nil, err
+	}
+	if len(fromPeers) == 0 || offer.From == self.Url {
+		return nil, ErrUnknownPeer
+	}
+	from := fromPeers[0]
+
+	restaurants, err := h.getOfferedRestaurants(offer)
+	if err != nil {
+		return nil, err
+	}
+
+	accepted := []string{}
+	acceptedIds := []primitive.ObjectID{}
+	for _, transferred := range restaurants {
+		restaurant := transferred.Restaurant
+		restaurant.UserName = transferred.UserName
+		restaurant.Password = transferred.Password
+
+		if restaurant.Id.IsZero() || !h.geo.IsInInfluenceArea(self, restaurant.Coord) {
+			continue
+		}
+		if offer.Mode == HANDOFF_SPLIT &&
+			math.Abs(h.geo.GetCoordDistance(self.Center, restaurant.Coord)) >= math.Abs(h.geo.GetCoordDistance(from.Center, restaurant.Coord)) {
+			continue
+		}
+
+		// versions and staff go first so the restaurant is never served without them
+		if err := insertMenuVersions(h.menuVersionRepo, transferred.MenuVersions); err != nil {
+			log.Printf("failed to receive restaurant %v: %v\n", restaurant.Id.Hex(), err.Error())
+			continue
+		}
+		if err := upsertStaffUsers(h.staffRepo, receiveStaffUsers(restaurant.Id, transferred.StaffUsers)); err != nil {
+			log.Printf("failed to receive restaurant %v: %v\n", restaurant.Id.Hex(), err.Error())
+			continue
+		}
+
+		_, err := h.restaurantRepo.Insert(restaurant)
+		if err != nil {
+			// already received by a previous attempt of the same handoff
+			_, findErr := h.restaurantRepo.FindOne(map[string]interface{}{"_id": restaurant.Id})
+			if findErr != nil {
+				log.Printf("failed to receive restaurant %v: %v\n", restaurant.Id.Hex(), err.Error())
+				continue
+			}
+		}
+
+		accepted = append(accepted, restaurant.Id.Hex())
+		acceptedIds = append(acceptedIds, restaurant.Id)
+	}
+
+	if len(acceptedIds) == 0 {
+		return accepted, nil
+	}
+
+	// restaurants coming back should not redirect anymore
+	err = h.tombstoneRepo.DeleteByRestaurantIds(acceptedIds)
+	if err != nil {
+		log.Println(err.Error())
+	}
+
+	// the offer fails so the sender keeps the restaurants and retries, receiving
+	// them again is a no op
+	err = h.peers.RecomputeDeliveryArea()
+	if err != nil {
+		return nil, err
+	}
+
+	return accepted, nil
+}
+
+func (h *HandoffService) getOfferedRestaurants(offer types.HandoffOffer) ([]types.TransferredRestaurant, error) {
+	url, err := url.Parse(fmt.Sprintf("%s/peer/handoff/%s", offer.From, url.PathEscape(offer.Id)))
+	if err != nil {
+		return nil, err
+	}
+	query := url.Query()
+	query.Add("token", offer.Token)
+	url.RawQuery = query.Encode()
+
+	resp, err := http.Get(url.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("peer responded with status %d", resp.StatusCode)
+	}
+
+	restaurants := []types.TransferredRestaurant{}
+	err = json.NewDecoder(resp.Body).Decode(&restaurants)
+	if err != nil {
+		return nil, err
+	}
+
+	return restaurants, nil
+}
+
+func (h *HandoffService) FindTombstone(query map[string]interface{}) (models.RestaurantTombstone, error) {
+	return h.tombstoneRepo.FindOne(query)
+}
+
+func newHandoffToken() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/joho/godotenv"
+	"github.com/nicodeheza/peersEat/mocks"
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/services/geo"
+	"github.com/nicodeheza/peersEat/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type handoffTest struct {
+	service         *HandoffService
+	peerRepo        *mocks.PeerRepositoryMock
+	restaurantRepo  *mocks.RestaurantRepositoryMock
+	tombstoneRepo   *mocks.RestaurantTombstoneRepositoryMock
+	menuVersionRepo *mocks.MenuVersionRepositoryMock
+	staffRepo       *mocks.StaffUserRepositoryMock
+	peers           *mocks.PeerServiceMock
+}
+
+// returns a coord east of the peer center by the given degrees of longitude
+func eastOfCenter(degrees float64) models.GeoCoords {
+	center := strings.Split(os.Getenv("CENTER"), ",")
+	long, _ := strconv.ParseFloat(center[0], 64)
+	lat, _ := strconv.ParseFloat(center[1], 64)
+	return models.GeoCoords{Long: long + degrees, Lat: lat}
+}
+
+func initHandoffTest() handoffTest {
+	err := godotenv.Load("../.env")
+	if err != nil {
+		log.Fatal("Error loading .env file")
+	}
+
+	peerRepo := mocks.NewPeerRepository()
+	peerRepo.Peers = []models.Peer{{Id: primitive.NewObjectID(), Url: "http://neighbor.com", Center: eastOfCenter(0.02)}}
+
+	restaurantRepo := mocks.NewRestaurantRepositoryMock()
+	restaurantRepo.Restaurants = []models.Restaurant{
+		{Id: primitive.NewObjectID(), Name: "near neighbor", Coord: eastOfCenter(0.025), UserName: "user1", Password: "hash1"},
+		{Id: primitive.NewObjectID(), Name: "near self", Coord: eastOfCenter(0.005), UserName: "user2", Password: "hash2"},
+		{Id: primitive.NewObjectID(), Name: "far", Coord: eastOfCenter(-0.5), UserName: "user3", Password: "hash3"},
+	}
+
+	tombstoneRepo := mocks.NewRestaurantTombstoneRepositoryMock()
+	menuVersionRepo := mocks.NewMenuVersionRepositoryMock()
+	staffRepo := mocks.NewStaffUserRepositoryMock()
+	peers := mocks.NewPeerServiceMock()
+	service := NewHandoffService(peerRepo, restaurantRepo, tombstoneRepo, menuVersionRepo, staffRepo, geo.NewGeo(), peers, time.Hour)
+
+	return handoffTest{service, peerRepo, restaurantRepo, tombstoneRepo, menuVersionRepo, staffRepo, peers}
+}
+
+func TestHandoffPlan(t *testing.T) {
+	test := initHandoffTest()
+
+	plan, err := test.service.Plan(types.HandoffRequest{Mode: HANDOFF_SPLIT})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(plan) != 1 || plan[0].Name != "near neighbor" || plan[0].To != "http://neighbor.com" {
+		t.Errorf("expecting only the restaurant closer to the neighbor but got %v", plan)
+	}
+
+	plan, err = test.service.Plan(types.HandoffRequest{Mode: HANDOFF_MERGE})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(plan) != 2 {
+		t.Errorf("expecting every restaurant in the neighbor area but got %v", plan)
+	}
+
+	_, err = test.service.Plan(types.HandoffRequest{Mode: HANDOFF_SPLIT, Target: "http://unknown.com"})
+	if err != ErrUnknownPeer {
+		t.Errorf("expecting unknown peer error but got %v", err)
+	}
+}
+
+func TestHandoffExecute(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	test := initHandoffTest()
+	moved := test.restaurantRepo.Restaurants[0]
+	test.menuVersionRepo.Versions = []models.MenuVersion{{Id: primitive.NewObjectID(), RestaurantId: moved.Id, Name: "main", Version: 1}}
+	test.staffRepo.Users = []models.StaffUser{{Id: primitive.NewObjectID(), RestaurantId: moved.Id, UserName: "waiter", Password: "staffHash", Role: models.STAFF_KITCHEN}}
+
+	var offered []types.TransferredRestaurant
+	httpmock.RegisterResponder("POST", "http://neighbor.com/peer/handoff/offer",
+		func(req *http.Request) (*http.Response, error) {
+			offer := types.HandoffOffer{}
+			json.NewDecoder(req.Body).Decode(&offer)
+			offered, _ = test.service.GetOffered(offer.Id, offer.Token)
+			return httpmock.NewJsonResponse(200, types.HandoffAccept{Accepted: []string{moved.Id.Hex()}})
+		})
+
+	result, err := test.service.Execute(types.HandoffRequest{Mode: HANDOFF_SPLIT})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(offered) != 1 || offered[0].UserName != moved.UserName || offered[0].Password != moved.Password {
+		t.Fatalf("expecting the restaurant and its credentials to be offered but got %v", offered)
+	}
+	if len(offered[0].MenuVersions) != 1 || offered[0].MenuVersions[0].Id != test.menuVersionRepo.Versions[0].Id {
+		t.Errorf("expecting the published menus to be offered but got %v", offered[0].MenuVersions)
+	}
+	if staff := offered[0].StaffUsers; len(staff) != 1 || staff[0].StaffUser.UserName != "waiter" || staff[0].Password != "staffHash" {
+		t.Errorf("expecting the staff users and their credentials to be offered but got %v", staff)
+	}
+	if _, err := test.service.GetOffered("any", "token"); err != ErrHandoffNotFound {
+		t.Errorf("expecting offered restaurants to not be served after the handoff")
+	}
+
+	if len(result.Moved) != 1 || len(test.restaurantRepo.DeleteCalls) != 1 || test.restaurantRepo.DeleteCalls[0] != moved.Id {
+		t.Errorf("expecting the moved restaurant to be deleted, result: %v", result)
+	}
+	if len(test.tombstoneRepo.Tombstones) != 1 {
+		t.Fatalf("expecting a tombstone but got %v", test.tombstoneRepo.Tombstones)
+	}
+	tombstone := test.tombstoneRepo.Tombstones[0]
+	if tombstone.MovedTo != "http://neighbor.com" || tombstone.UserName != moved.UserName || tombstone.ExpiresAt.Before(time.Now()) {
+		t.Errorf("unexpected tombstone %v", tombstone)
+	}
+	if len(tombstone.StaffUserNames) != 1 || tombstone.StaffUserNames[0] != "waiter" || len(test.staffRepo.Users) != 0 {
+		t.Errorf("expecting the staff users to be tombstoned and deleted but got %v %v", tombstone.StaffUserNames, test.staffRepo.Users)
+	}
+	if len(test.peers.Calls["RecomputeDeliveryArea"]) != 1 {
+		t.Error("expecting the delivery area to be recomputed")
+	}
+}
+
+func TestReceiveOffer(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	test := initHandoffTest()
+	test.restaurantRepo.Restaurants = nil
+
+	// the neighbor hands restaurants to this peer
+	staff := types.TransferredStaffUser{StaffUser: models.StaffUser{Id: primitive.NewObjectID(), RestaurantId: primitive.NewObjectID(), UserName: "waiter"}, Password: "staffHash"}
+	offered := []types.TransferredRestaurant{
+		{Restaurant: models.Restaurant{Id: primitive.NewObjectID(), Coord: eastOfCenter(0.005)}, UserName: "user", Password: "hash", MenuVersions: []models.MenuVersion{{Id: primitive.NewObjectID(), Name: "main", Version: 1}}, StaffUsers: []types.TransferredStaffUser{staff}},
+		{Restaurant: models.Restaurant{Id: primitive.NewObjectID(), Coord: eastOfCenter(0.025)}, UserName: "user2", Password: "hash2"},
+	}
+	test.tombstoneRepo.Tombstones = []models.RestaurantTombstone{{RestaurantId: offered[0].Restaurant.Id, MovedTo: "http://neighbor.com"}}
+	httpmock.RegisterResponder("GET", "http://neighbor.com/peer/handoff/1",
+		httpmock.NewJsonResponderOrPanic(200, offered))
+
+	accepted, err := test.service.ReceiveOffer(types.HandoffOffer{Id: "1", Token: "token", From: "http://neighbor.com", Mode: HANDOFF_SPLIT})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(accepted) != 1 || accepted[0] != offered[0].Restaurant.Id.Hex() {
+		t.Errorf("expecting only the restaurant closer to this peer to be accepted but got %v", accepted)
+	}
+	inserted := test.restaurantRepo.InsertCalls[0]
+	if inserted.UserName != "user" || inserted.Password != "hash" {
+		t.Errorf("expecting credentials to be kept but got %v", inserted)
+	}
+	if len(test.tombstoneRepo.Tombstones) != 0 {
+		t.Error("expecting the tombstone of a returning restaurant to be removed")
+	}
+	if len(test.menuVersionRepo.Versions) != 1 || test.menuVersionRepo.Versions[0].Id != offered[0].MenuVersions[0].Id {
+		t.Errorf("expecting the published menus to be received with their ids but got %v", test.menuVersionRepo.Versions)
+	}
+	if users := test.staffRepo.Users; len(users) != 1 || users[0].Id != staff.StaffUser.Id || users[0].Password != "staffHash" || users[0].RestaurantId != offered[0].Restaurant.Id {
+		t.Errorf("expecting the staff users to be received for the restaurant but got %v", users)
+	}
+
+	_, err = test.service.ReceiveOffer(types.HandoffOffer{Id: "1", Token: "token", From: "http://unknown.com", Mode: HANDOFF_SPLIT})
+	if err != ErrUnknownPeer {
+		t.Errorf("expecting unknown peer error but got %v", err)
+	}
+
+	// a failed recompute fails the offer and the sender retries it
+	test.peers.RecomputeErr = errors.New("test error")
+	if _, err = test.service.ReceiveOffer(types.HandoffOffer{Id: "1", Token: "token", From: "http://neighbor.com", Mode: HANDOFF_SPLIT}); err == nil {
+		t.Error("expecting the offer to fail when the delivery area is not recomputed")
+	}
+	test.peers.RecomputeErr = nil
+	accepted, err = test.service.ReceiveOffer(types.HandoffOffer{Id: "1", Token: "token", From: "http://neighbor.com", Mode: HANDOFF_SPLIT})
+	if err != nil || len(accepted) != 1 {
+		t.Errorf("expecting the retried offer to be accepted but got %v %v", accepted, err)
+	}
+	if len(test.menuVersionRepo.Versions) != 1 {
+		t.Errorf("expecting the menus of a retried offer to not be duplicated but got %v", test.menuVersionRepo.Versions)
+	}
+	if len(test.staffRepo.Users) != 1 {
+		t.Errorf("expecting the staff users of a retried offer to not be duplicated but got %v", test.staffRepo.Users)
+	}
+}
+
+func TestHandoffExecuteRecomputesWithoutPlan(t *testing.T) {
+	test := initHandoffTest()
+	test.restaurantRepo.Restaurants = nil
+
+	result, err := test.service.Execute(types.HandoffRequest{Mode: HANDOFF_SPLIT})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(result.Planned) != 0 || len(test.peers.Calls["RecomputeDeliveryArea"]) != 1 {
+		t.Errorf("expecting the delivery area to be recomputed when nothing moves, result: %v", result)
+	}
+
+	_, err = test.service.Execute(types.HandoffRequest{Mode: HANDOFF_SPLIT, DryRun: true})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(test.peers.Calls["RecomputeDeliveryArea"]) != 1 {
+		t.Error("expecting a dry run to not recompute the delivery area")
+	}
+}
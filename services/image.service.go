@@ -0,0 +1,97 @@
+package services
+
+import (
+	"os"
+	"time"
+
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/repositories"
+	"github.com/nicodeheza/peersEat/services/images"
+	"github.com/nicodeheza/peersEat/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const DEFAULT_IMAGE_SIZE = "medium"
+
+type ImageServiceI interface {
+	SetRestaurantImage(id primitive.ObjectID, data []byte) (types.ImageUpload, error)
+	SetDishImage(id primitive.ObjectID, path models.MenuPath, data []byte) (types.ImageUpload, error)
+	Get(key string) ([]byte, string, time.Time, error)
+}
+
+type ImageService struct {
+	store          images.ImageStoreI
+	restaurantRepo repositories.RestaurantRepositoryI
+	menu           MenuServiceI
+	baseUrl        string
+}
+
+// images are served by this peer, so their urls start with HOST
+func NewImageService(store images.ImageStoreI, restaurantRepo repositories.RestaurantRepositoryI, menu MenuServiceI) *ImageService {
+	return &ImageService{store, restaurantRepo, menu, os.Getenv("HOST")}
+}
+
+func (s *ImageService) upload(data []byte) (types.ImageUpload, error) {
+	variants, err := images.Process(data)
+	if err != nil {
+		return types.ImageUpload{}, err
+	}
+
+	upload := types.ImageUpload{Sizes: map[string]string{}}
+	for _, variant := range variants {
+		if err = s.store.Save(variant.Key, variant.Data); err != nil {
+			return types.ImageUpload{}, err
+		}
+		upload.Sizes[variant.Size] = s.baseUrl + "/images/" + variant.Key
+	}
+	upload.ImageUrl = upload.Sizes[DEFAULT_IMAGE_SIZE]
+	return upload, nil
+}
+
+func (s *ImageService) SetRestaurantImage(id primitive.ObjectID, data []byte) (types.ImageUpload, error) {
+	upload, err := s.upload(data)
+	if err != nil {
+		return types.ImageUpload{}, err
+	}
+
+	err = s.restaurantRepo.Update(id, map[string]interface{}{"imageUrl": upload.ImageUrl})
+	if err != nil {
+		return types.ImageUpload{}, err
+	}
+	return upload, nil
+}
+
+// the dish is checked before storing so missing dishes don't leave images behind
+func (s *ImageService) SetDishImage(id primitive.ObjectID, path models.MenuPath, data []byte) (types.ImageUpload, error) {
+	restaurant, err := s.restaurantRepo.FindOne(map[string]interface{}{"_id": id})
+	if err != nil {
+		return types.ImageUpload{}, err
+	}
+	if !restaurant.Menu.HasItem(path.DishId) {
+		return types.ImageUpload{}, ErrMenuItemNotFound
+	}
+
+	upload, err := s.upload(data)
+	if err != nil {
+		return types.ImageUpload{}, err
+	}
+
+	err = s.menu.UpdateDish(id, path, types.DishUpdate{ImageUrl: &upload.ImageUrl})
+	if err != nil {
+		return types.ImageUpload{}, err
+	}
+	return upload, nil
+}
+
+// returns the image with its content type and when it was stored
+func (s *ImageService) Get(key string) ([]byte, string, time.Time, error) {
+	if !images.IsValidKey(key) {
+		return nil, "", time.Time{}, images.ErrImageNotFound
+	}
+
+	data, modTime, err := s.store.Open(key)
+	if err != nil {
+		return nil, "", time.Time{}, err
+	}
+	return data, images.ContentType(key), modTime, nil
+}
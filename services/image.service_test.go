@@ -0,0 +1,72 @@
+package services
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/nicodeheza/peersEat/mocks"
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/services/images"
+	"github.com/nicodeheza/peersEat/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestImageService(t *testing.T) {
+	t.Setenv("HOST", "http://peer.test")
+	store := images.NewLocalImageStore(t.TempDir())
+	restaurantRepo := mocks.NewRestaurantRepositoryMock()
+	menu := mocks.NewMenuServiceMock()
+	dishId := primitive.NewObjectID()
+	restaurant := models.Restaurant{
+		Id:   primitive.NewObjectID(),
+		Menu: models.Menu{Sections: []models.MenuSection{{Id: primitive.NewObjectID(), Dishes: []models.Dish{{Id: dishId}}}}},
+	}
+	restaurantRepo.Restaurants = []models.Restaurant{restaurant}
+	service := NewImageService(store, restaurantRepo, menu)
+
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 700, 700)))
+
+	upload, err := service.SetRestaurantImage(restaurant.Id, buf.Bytes())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.HasPrefix(upload.ImageUrl, "http://peer.test/images/") || upload.ImageUrl != upload.Sizes["medium"] || len(upload.Sizes) != 3 {
+		t.Errorf("unexpected upload %v", upload)
+	}
+	if len(restaurantRepo.UpdateCalls) != 1 || restaurantRepo.UpdateCalls[0].Updates["imageUrl"] != upload.ImageUrl {
+		t.Errorf("expecting the restaurant image url to be updated but got %v", restaurantRepo.UpdateCalls)
+	}
+
+	key := strings.TrimPrefix(upload.Sizes["thumb"], "http://peer.test/images/")
+	data, contentType, _, err := service.Get(key)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if contentType != "image/png" || len(data) == 0 {
+		t.Errorf("unexpected stored image %v %v", contentType, len(data))
+	}
+	_, _, _, err = service.Get("../" + key)
+	if err != images.ErrImageNotFound {
+		t.Errorf("expecting not found error but got %v", err)
+	}
+
+	path := models.MenuPath{SectionId: restaurant.Menu.Sections[0].Id, DishId: primitive.NewObjectID()}
+	_, err = service.SetDishImage(restaurant.Id, path, buf.Bytes())
+	if err != ErrMenuItemNotFound {
+		t.Errorf("expecting menu item not found error but got %v", err)
+	}
+
+	path.DishId = dishId
+	upload, err = service.SetDishImage(restaurant.Id, path, buf.Bytes())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	calls := menu.Calls["UpdateDish"]
+	if len(calls) != 1 || *calls[0][2].(types.DishUpdate).ImageUrl != upload.ImageUrl {
+		t.Errorf("expecting the dish image url to be updated but got %v", calls)
+	}
+}
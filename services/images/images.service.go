@@ -0,0 +1,41 @@
+package images
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+)
+
+const LOCAL = "local"
+
+const DEFAULT_IMAGE_DIR = "./images"
+
+var ErrImageNotFound = errors.New("image not found")
+
+// ImageStoreI keeps the processed images, keys look like "<sha256>/<size>.<ext>"
+type ImageStoreI interface {
+	Save(key string, data []byte) error
+	Open(key string) ([]byte, time.Time, error)
+}
+
+var keyRegex = regexp.MustCompile(`^[a-f0-9]{64}/(thumb|medium|large)\.(jpg|png)$`)
+
+// keys come from urls, anything else could escape the store
+func IsValidKey(key string) bool {
+	return keyRegex.MatchString(key)
+}
+
+func NewImageStoreFromEnv() (ImageStoreI, error) {
+	switch os.Getenv("IMAGE_STORE") {
+	case "", LOCAL:
+		dir := os.Getenv("IMAGE_DIR")
+		if dir == "" {
+			dir = DEFAULT_IMAGE_DIR
+		}
+		return NewLocalImageStore(dir), nil
+	}
+
+	return nil, fmt.Errorf("unknown image store %q", os.Getenv("IMAGE_STORE"))
+}
@@ -0,0 +1,52 @@
+package images
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type LocalImageStore struct {
+	dir string
+}
+
+func NewLocalImageStore(dir string) *LocalImageStore {
+	return &LocalImageStore{dir}
+}
+
+// writes to a temporary file first so a half written image is never served
+func (s *LocalImageStore) Save(key string, data []byte) error {
+	path := filepath.Join(s.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *LocalImageStore) Open(key string) ([]byte, time.Time, error) {
+	path := filepath.Join(s.dir, filepath.FromSlash(key))
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, time.Time{}, ErrImageNotFound
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	data, err := os.ReadFile(path)
+	return data, info.ModTime(), err
+}
@@ -0,0 +1,135 @@
+package images
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/nicodeheza/peersEat/constants"
+)
+
+var ErrImageTooLarge = errors.New("image too large")
+var ErrUnsupportedImage = errors.New("only jpeg, png and gif images are supported")
+
+type Size struct {
+	Name    string
+	MaxSide int
+}
+
+// every upload is stored in these sizes, smaller images are not upscaled
+var SIZES = []Size{{"thumb", 160}, {"medium", 640}, {"large", 1280}}
+
+type Variant struct {
+	Key  string
+	Size string
+	Data []byte
+}
+
+// ContentType of a stored key
+func ContentType(key string) string {
+	if strings.HasSuffix(key, ".png") {
+		return "image/png"
+	}
+	return "image/jpeg"
+}
+
+// Process sniffs the uploaded bytes, the declared content type is ignored, and
+// returns every size keyed by the hash of the upload. png keeps its transparency,
+// jpeg and gif, only the first frame, are stored as jpeg
+func Process(data []byte) ([]Variant, error) {
+	if len(data) > constants.IMAGE_MAX_BYTES {
+		return nil, ErrImageTooLarge
+	}
+
+	var ext string
+	switch http.DetectContentType(data) {
+	case "image/jpeg", "image/gif":
+		ext = "jpg"
+	case "image/png":
+		ext = "png"
+	default:
+		return nil, ErrUnsupportedImage
+	}
+
+	// the header is enough to reject decompression bombs before decoding
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	if config.Width*config.Height > constants.IMAGE_MAX_PIXELS {
+		return nil, ErrImageTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	variants := []Variant{}
+	for _, size := range SIZES {
+		resized := Resize(src, size.MaxSide)
+
+		var out bytes.Buffer
+		if ext == "png" {
+			err = png.Encode(&out, resized)
+		} else {
+			err = jpeg.Encode(&out, resized, &jpeg.Options{Quality: constants.IMAGE_JPEG_QUALITY})
+		}
+		if err != nil {
+			return nil, err
+		}
+		variants = append(variants, Variant{Key: hash + "/" + size.Name + "." + ext, Size: size.Name, Data: out.Bytes()})
+	}
+	return variants, nil
+}
+
+// Resize scales src down to fit maxSide averaging the source pixels under every
+// destination pixel
+func Resize(src image.Image, maxSide int) image.Image {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxSide && height <= maxSide {
+		return src
+	}
+
+	scale := float64(maxSide) / math.Max(float64(width), float64(height))
+	dstWidth := int(math.Max(1, math.Round(float64(width)*scale)))
+	dstHeight := int(math.Max(1, math.Round(float64(height)*scale)))
+	dst := image.NewRGBA64(image.Rect(0, 0, dstWidth, dstHeight))
+
+	for y := 0; y < dstHeight; y++ {
+		top, bottom := y*height/dstHeight, (y+1)*height/dstHeight
+		if bottom <= top {
+			bottom = top + 1
+		}
+		for x := 0; x < dstWidth; x++ {
+			left, right := x*width/dstWidth, (x+1)*width/dstWidth
+			if right <= left {
+				right = left + 1
+			}
+
+			var r, g, b, a, count uint64
+			for sy := top; sy < bottom; sy++ {
+				for sx := left; sx < right; sx++ {
+					pr, pg, pb, pa := src.At(bounds.Min.X+sx, bounds.Min.Y+sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					count++
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{uint16(r / count), uint16(g / count), uint16(b / count), uint16(a / count)})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,129 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func testPng(t *testing.T, width int, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 100, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err.Error())
+	}
+	return buf.Bytes()
+}
+
+func TestResize(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 2000, 1000))
+
+	cases := []struct {
+		maxSide int
+		width   int
+		height  int
+	}{{160, 160, 80}, {640, 640, 320}, {4000, 2000, 1000}}
+
+	for _, c := range cases {
+		bounds := Resize(src, c.maxSide).Bounds()
+		if bounds.Dx() != c.width || bounds.Dy() != c.height {
+			t.Errorf("expecting %vx%v for %v but got %vx%v", c.width, c.height, c.maxSide, bounds.Dx(), bounds.Dy())
+		}
+	}
+}
+
+func TestProcess(t *testing.T) {
+	data := testPng(t, 800, 400)
+
+	variants, err := Process(data)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(variants) != len(SIZES) {
+		t.Fatalf("expecting %v variants but got %v", len(SIZES), len(variants))
+	}
+
+	expectedWidths := map[string]int{"thumb": 160, "medium": 640, "large": 800}
+	for _, variant := range variants {
+		if !IsValidKey(variant.Key) || !strings.HasSuffix(variant.Key, "/"+variant.Size+".png") {
+			t.Errorf("unexpected key %v", variant.Key)
+		}
+		img, format, err := image.Decode(bytes.NewReader(variant.Data))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if format != "png" {
+			t.Errorf("expecting png but got %v", format)
+		}
+		if img.Bounds().Dx() != expectedWidths[variant.Size] {
+			t.Errorf("expecting %v width %v but got %v", variant.Size, expectedWidths[variant.Size], img.Bounds().Dx())
+		}
+	}
+
+	again, _ := Process(data)
+	if again[0].Key != variants[0].Key {
+		t.Errorf("expecting the same key for the same image")
+	}
+
+	_, err = Process([]byte("<html><body>not an image</body></html>"))
+	if err != ErrUnsupportedImage {
+		t.Errorf("expecting unsupported image error but got %v", err)
+	}
+
+	// a png header declaring a huge image must be rejected before decoding it
+	huge := testPng(t, 1, 1)
+	copy(huge[16:24], []byte{0, 0, 0x4e, 0x20, 0, 0, 0x4e, 0x20})
+	binary.BigEndian.PutUint32(huge[29:33], crc32.ChecksumIEEE(huge[12:29]))
+	_, err = Process(huge)
+	if err != ErrImageTooLarge {
+		t.Errorf("expecting image too large error but got %v", err)
+	}
+}
+
+func TestLocalImageStore(t *testing.T) {
+	store := NewLocalImageStore(t.TempDir())
+	key := strings.Repeat("a", 64) + "/thumb.jpg"
+
+	_, _, err := store.Open(key)
+	if err != ErrImageNotFound {
+		t.Errorf("expecting not found error but got %v", err)
+	}
+
+	if err = store.Save(key, []byte("image")); err != nil {
+		t.Fatal(err.Error())
+	}
+	data, modTime, err := store.Open(key)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if string(data) != "image" || modTime.IsZero() {
+		t.Errorf("unexpected stored image %v %v", string(data), modTime)
+	}
+}
+
+func TestIsValidKey(t *testing.T) {
+	hash := strings.Repeat("0f", 32)
+	valid := []string{hash + "/thumb.jpg", hash + "/large.png"}
+	invalid := []string{"../" + hash + "/thumb.jpg", hash + "/huge.jpg", hash + "/thumb.gif", "abc/thumb.jpg"}
+
+	for _, key := range valid {
+		if !IsValidKey(key) {
+			t.Errorf("expecting %v to be valid", key)
+		}
+	}
+	for _, key := range invalid {
+		if IsValidKey(key) {
+			t.Errorf("expecting %v to be invalid", key)
+		}
+	}
+}
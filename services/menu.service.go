@@ -0,0 +1,620 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/repositories"
+	"github.com/nicodeheza/peersEat/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrMenuItemNotFound = errors.New("menu item not found")
+var ErrInvalidMenuOrder = errors.New("the order must contain every id exactly once")
+
+// OptionRulesError lists why option groups or a selection of them break the rules
+type OptionRulesError struct {
+	Problems []string
+}
+
+func (e *OptionRulesError) Error() string {
+	return "invalid options: " + strings.Join(e.Problems, ", ")
+}
+
+type MenuServiceI interface {
+	GetMenu(restaurantId primitive.ObjectID) (models.Menu, error)
+	AddSection(restaurantId primitive.ObjectID, data types.MenuSectionData) (models.MenuSection, error)
+	UpdateSection(restaurantId primitive.ObjectID, path models.MenuPath, data types.MenuSectionUpdate) error
+	AddDish(restaurantId primitive.ObjectID, path models.MenuPath, data types.DishData) (models.Dish, error)
+	UpdateDish(restaurantId primitive.ObjectID, path models.MenuPath, data types.DishUpdate) error
+	AddOption(restaurantId primitive.ObjectID, path models.MenuPath, data types.DishOptionData) (models.DishOptions, error)
+	UpdateOption(restaurantId primitive.ObjectID, path models.MenuPath, data types.DishOptionUpdate) error
+	DeleteItem(restaurantId primitive.ObjectID, path models.MenuPath) error
+	Reorder(restaurantId primitive.ObjectID, path models.MenuPath, ids []string) error
+	SetOptionGroups(restaurantId primitive.ObjectID, path models.MenuPath, data types.DishOptionGroups) ([]models.OptionGroup, error)
+	PriceSelection(restaurantId primitive.ObjectID, selection types.DishSelection) (types.PricedSelection, error)
+	SetAvailability(restaurantId primitive.ObjectID, itemId primitive.ObjectID, data types.ItemAvailability) ([]models.UnavailableItem, error)
+}
+
+type MenuService struct {
+	repo repositories.RestaurantRepositoryI
+}
+
+func NewMenuService(repository repositories.RestaurantRepositoryI) *MenuService {
+	return &MenuService{repository}
+}
+
+func menuError(err error) error {
+	if err == mongo.ErrNoDocuments {
+		return ErrMenuItemNotFound
+	}
+	return err
+}
+
+func (m *MenuService) GetMenu(restaurantId primitive.ObjectID) (models.Menu, error) {
+	menu, _, err := m.menuAndCurrency(restaurantId)
+	return menu, err
+}
+
+// menus stored before the entities had ids or the prices had currencies get
+// them on the first access
+func (m *MenuService) menuAndCurrency(restaurantId primitive.ObjectID) (models.Menu, string, error) {
+	restaurant, err := m.repo.FindOne(map[string]interface{}{"_id": restaurantId})
+	if err != nil {
+		return models.Menu{}, "", menuError(err)
+	}
+
+	menu := restaurant.Menu
+	currency := restaurant.CurrencyOrDefault()
+	changed := menu.AssignIds()
+	changed = menu.InCurrency(currency) || changed
+	if changed {
+		err = m.repo.Update(restaurantId, map[string]interface{}{"menu": menu})
+		if err != nil {
+			return models.Menu{}, "", err
+		}
+	}
+	menu.MarkSoldOut(restaurant.ActiveUnavailable(time.Now()))
+	return menu, currency, nil
+}
+
+// marks a dish or option as sold out or available again, expired marks are dropped
+func (m *MenuService) SetAvailability(restaurantId primitive.ObjectID, itemId primitive.ObjectID, data types.ItemAvailability) ([]models.UnavailableItem, error) {
+	restaurant, err := m.repo.FindOne(map[string]interface{}{"_id": restaurantId})
+	if err != nil {
+		return nil, menuError(err)
+	}
+	if !restaurant.Menu.HasItem(itemId) {
+		return nil, ErrMenuItemNotFound
+	}
+
+	now := time.Now()
+	active := restaurant.ActiveUnavailable(now)
+	unavailable := []models.UnavailableItem{}
+	for _, item := range restaurant.Unavailable {
+		if _, ok := active[item.ItemId]; ok && item.ItemId != itemId {
+			unavailable = append(unavailable, item)
+		}
+	}
+
+	if !data.Available {
+		item := models.UnavailableItem{ItemId: itemId, Indefinite: data.Indefinite, MarkedAt: now}
+		if data.Until != "" {
+			until, err := time.Parse(time.RFC3339, data.Until)
+			if err != nil {
+				return nil, err
+			}
+			item.Until = &until
+		}
+		unavailable = append(unavailable, item)
+	}
+
+	err = m.repo.Update(restaurantId, map[string]interface{}{"unavailable": unavailable})
+	if err != nil {
+		return nil, err
+	}
+	return unavailable, nil
+}
+
+func newDishOption(data types.DishOptionData, currency string) (models.DishOptions, error) {
+	price, err := data.Price.As(currency)
+	if err != nil {
+		return models.DishOptions{}, err
+	}
+	return models.DishOptions{
+		Id:          primitive.NewObjectID(),
+		Name:        data.Name,
+		Description: data.Description,
+		Price:       price,
+		Dietary:     data.Dietary(),
+	}, nil
+}
+
+func (m *MenuService) AddSection(restaurantId primitive.ObjectID, data types.MenuSectionData) (models.MenuSection, error) {
+	if _, err := m.GetMenu(restaurantId); err != nil {
+		return models.MenuSection{}, err
+	}
+
+	section := models.MenuSection{Id: primitive.NewObjectID(), Name: data.Name}
+	err := m.repo.PushMenuItem(restaurantId, models.MenuPath{}, section)
+	return section, menuError(err)
+}
+
+func (m *MenuService) UpdateSection(restaurantId primitive.ObjectID, path models.MenuPath, data types.MenuSectionUpdate) error {
+	if _, err := m.GetMenu(restaurantId); err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{}
+	if data.Name != nil {
+		updates["name"] = *data.Name
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	return menuError(m.repo.UpdateMenuItem(restaurantId, models.MenuPath{SectionId: path.SectionId}, updates))
+}
+
+func (m *MenuService) AddDish(restaurantId primitive.ObjectID, path models.MenuPath, data types.DishData) (models.Dish, error) {
+	_, currency, err := m.menuAndCurrency(restaurantId)
+	if err != nil {
+		return models.Dish{}, err
+	}
+	price, err := data.Price.As(currency)
+	if err != nil {
+		return models.Dish{}, err
+	}
+
+	dish := models.Dish{
+		Id:          primitive.NewObjectID(),
+		Name:        data.Name,
+		Description: data.Description,
+		Price:       price,
+		ImageUrl:    data.ImageUrl,
+		Tags:        data.Tags,
+		Dietary:     data.Dietary(),
+	}
+	for _, optionData := range data.Options {
+		option, err := newDishOption(optionData, currency)
+		if err != nil {
+			return models.Dish{}, err
+		}
+		dish.Options = append(dish.Options, option)
+	}
+	if len(data.OptionGroups) > 0 {
+		groups, err := buildOptionGroups(data.OptionGroups, currency)
+		if err != nil {
+			return models.Dish{}, err
+		}
+		dish.OptionGroups = groups
+	}
+
+	err = m.repo.PushMenuItem(restaurantId, models.MenuPath{SectionId: path.SectionId}, dish)
+	return dish, menuError(err)
+}
+
+func (m *MenuService) UpdateDish(restaurantId primitive.ObjectID, path models.MenuPath, data types.DishUpdate) error {
+	_, currency, err := m.menuAndCurrency(restaurantId)
+	if err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{}
+	if data.Name != nil {
+		updates["name"] = *data.Name
+	}
+	if data.Description != nil {
+		updates["description"] = *data.Description
+	}
+	if data.Price != nil {
+		price, err := data.Price.As(currency)
+		if err != nil {
+			return err
+		}
+		updates["price"] = price
+	}
+	if data.ImageUrl != nil {
+		updates["imageurl"] = *data.ImageUrl
+	}
+	if data.Tags != nil {
+		updates["tags"] = *data.Tags
+	}
+	if data.DietaryData != nil {
+		setDietary(updates, *data.DietaryData)
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	return menuError(m.repo.UpdateMenuItem(restaurantId, models.MenuPath{SectionId: path.SectionId, DishId: path.DishId}, updates))
+}
+
+func (m *MenuService) AddOption(restaurantId primitive.ObjectID, path models.MenuPath, data types.DishOptionData) (models.DishOptions, error) {
+	_, currency, err := m.menuAndCurrency(restaurantId)
+	if err != nil {
+		return models.DishOptions{}, err
+	}
+
+	option, err := newDishOption(data, currency)
+	if err != nil {
+		return models.DishOptions{}, err
+	}
+	err = m.repo.PushMenuItem(restaurantId, models.MenuPath{SectionId: path.SectionId, DishId: path.DishId}, option)
+	return option, menuError(err)
+}
+
+func (m *MenuService) UpdateOption(restaurantId primitive.ObjectID, path models.MenuPath, data types.DishOptionUpdate) error {
+	_, currency, err := m.menuAndCurrency(restaurantId)
+	if err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{}
+	if data.Name != nil {
+		updates["name"] = *data.Name
+	}
+	if data.Description != nil {
+		updates["description"] = *data.Description
+	}
+	if data.Price != nil {
+		price, err := data.Price.As(currency)
+		if err != nil {
+			return err
+		}
+		updates["price"] = price
+	}
+	if data.DietaryData != nil {
+		setDietary(updates, *data.DietaryData)
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	return menuError(m.repo.UpdateMenuItem(restaurantId, path, updates))
+}
+
+// empty lists are stored so the previous declaration is cleared
+func setDietary(updates map[string]interface{}, data types.DietaryData) {
+	dietary := data.Dietary()
+	for key, values := range map[string][]string{"allergens": dietary.Allergens, "mayContain": dietary.MayContain, "dietaryLabels": dietary.DietaryLabels} {
+		if values == nil {
+			values = []string{}
+		}
+		updates[key] = values
+	}
+}
+
+func (m *MenuService) DeleteItem(restaurantId primitive.ObjectID, path models.MenuPath) error {
+	if _, err := m.GetMenu(restaurantId); err != nil {
+		return err
+	}
+	return menuError(m.repo.PullMenuItem(restaurantId, path))
+}
+
+// reorders the children of path, ids must be a permutation of the current ones
+func (m *MenuService) Reorder(restaurantId primitive.ObjectID, path models.MenuPath, ids []string) error {
+	menu, err := m.GetMenu(restaurantId)
+	if err != nil {
+		return err
+	}
+
+	order := map[primitive.ObjectID]int{}
+	for i, hex := range ids {
+		id, err := primitive.ObjectIDFromHex(hex)
+		if err != nil {
+			return ErrInvalidMenuOrder
+		}
+		if _, repeated := order[id]; repeated {
+			return ErrInvalidMenuOrder
+		}
+		order[id] = i
+	}
+
+	switch len(path.Ids()) {
+	case 0:
+		sections := make([]models.MenuSection, len(ids))
+		if !placeInOrder(order, len(menu.Sections), func(i int) primitive.ObjectID { return menu.Sections[i].Id }, func(i, to int) { sections[to] = menu.Sections[i] }) {
+			return ErrInvalidMenuOrder
+		}
+		return menuError(m.repo.SetMenuItems(restaurantId, path, sections))
+	case 1:
+		section := findSection(menu, path.SectionId)
+		if section == nil {
+			return ErrMenuItemNotFound
+		}
+		dishes := make([]models.Dish, len(ids))
+		if !placeInOrder(order, len(section.Dishes), func(i int) primitive.ObjectID { return section.Dishes[i].Id }, func(i, to int) { dishes[to] = section.Dishes[i] }) {
+			return ErrInvalidMenuOrder
+		}
+		return menuError(m.repo.SetMenuItems(restaurantId, path, dishes))
+	case 2:
+		dish := findDish(menu, path.SectionId, path.DishId)
+		if dish == nil {
+			return ErrMenuItemNotFound
+		}
+		options := make([]models.DishOptions, len(ids))
+		if !placeInOrder(order, len(dish.Options), func(i int) primitive.ObjectID { return dish.Options[i].Id }, func(i, to int) { options[to] = dish.Options[i] }) {
+			return ErrInvalidMenuOrder
+		}
+		return menuError(m.repo.SetMenuItems(restaurantId, path, options))
+	}
+	return ErrInvalidMenuOrder
+}
+
+// moves every current item to its position in order, false when the ids do not match
+func placeInOrder(order map[primitive.ObjectID]int, count int, idAt func(int) primitive.ObjectID, place func(int, int)) bool {
+	if count != len(order) {
+		return false
+	}
+	for i := 0; i < count; i++ {
+		to, ok := order[idAt(i)]
+		if !ok {
+			return false
+		}
+		place(i, to)
+	}
+	return true
+}
+
+func findSection(menu models.Menu, sectionId primitive.ObjectID) *models.MenuSection {
+	for i := range menu.Sections {
+		if menu.Sections[i].Id == sectionId {
+			return &menu.Sections[i]
+		}
+	}
+	return nil
+}
+
+func findDish(menu models.Menu, sectionId primitive.ObjectID, dishId primitive.ObjectID) *models.Dish {
+	section := findSection(menu, sectionId)
+	if section == nil {
+		return nil
+	}
+	for i := range section.Dishes {
+		if section.Dishes[i].Id == dishId {
+			return &section.Dishes[i]
+		}
+	}
+	return nil
+}
+
+// replaces the option groups of a dish, ids sent back are kept
+func (m *MenuService) SetOptionGroups(restaurantId primitive.ObjectID, path models.MenuPath, data types.DishOptionGroups) ([]models.OptionGroup, error) {
+	menu, currency, err := m.menuAndCurrency(restaurantId)
+	if err != nil {
+		return nil, err
+	}
+	if findDish(menu, path.SectionId, path.DishId) == nil {
+		return nil, ErrMenuItemNotFound
+	}
+
+	groups, err := buildOptionGroups(data.Groups, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	dishPath := models.MenuPath{SectionId: path.SectionId, DishId: path.DishId}
+	err = m.repo.UpdateMenuItem(restaurantId, dishPath, map[string]interface{}{"optionGroups": groups})
+	return groups, menuError(err)
+}
+
+func buildOptionGroups(data []types.OptionGroupData, currency string) ([]models.OptionGroup, error) {
+	problems := []string{}
+	groups := buildGroups(data, 0, currency, map[primitive.ObjectID]bool{}, &problems)
+	if len(problems) > 0 {
+		return nil, &OptionRulesError{problems}
+	}
+	return groups, nil
+}
+
+func entityId(hex string, seen map[primitive.ObjectID]bool, problems *[]string) primitive.ObjectID {
+	id := primitive.NewObjectID()
+	if hex != "" {
+		parsed, err := primitive.ObjectIDFromHex(hex)
+		if err != nil {
+			*problems = append(*problems, fmt.Sprintf("invalid id %s", hex))
+			return id
+		}
+		id = parsed
+	}
+	if seen[id] {
+		*problems = append(*problems, fmt.Sprintf("repeated id %s", hex))
+	}
+	seen[id] = true
+	return id
+}
+
+func buildGroups(data []types.OptionGroupData, depth int, currency string, seen map[primitive.ObjectID]bool, problems *[]string) []models.OptionGroup {
+	groups := []models.OptionGroup{}
+	for _, groupData := range data {
+		group := models.OptionGroup{
+			Id:       entityId(groupData.Id, seen, problems),
+			Name:     groupData.Name,
+			Min:      groupData.Min,
+			Max:      groupData.Max,
+			Required: groupData.Required,
+		}
+
+		defaults := uint(0)
+		for _, optionData := range groupData.Options {
+			priceDelta, err := optionData.PriceDelta.As(currency)
+			if err != nil {
+				*problems = append(*problems, fmt.Sprintf("%s: %v", optionData.Name, err))
+			}
+			option := models.GroupOption{
+				Id:          entityId(optionData.Id, seen, problems),
+				Name:        optionData.Name,
+				Description: optionData.Description,
+				PriceDelta:  priceDelta,
+				IsDefault:   optionData.IsDefault,
+				Dietary:     optionData.Dietary(),
+			}
+			if optionData.IsDefault {
+				defaults++
+			}
+			if len(optionData.Groups) > 0 {
+				if depth > 0 {
+					*problems = append(*problems, fmt.Sprintf("%s: groups can only be nested one level deep", optionData.Name))
+				} else {
+					option.Groups = buildGroups(optionData.Groups, depth+1, currency, seen, problems)
+				}
+			}
+			group.Options = append(group.Options, option)
+		}
+
+		if group.Max > 0 && group.MinSelections() > group.Max {
+			*problems = append(*problems, fmt.Sprintf("%s: min is greater than max", group.Name))
+		}
+		if group.MinSelections() > uint(len(group.Options)) {
+			*problems = append(*problems, fmt.Sprintf("%s: min is greater than the number of options", group.Name))
+		}
+		if group.Max > 0 && defaults > group.Max {
+			*problems = append(*problems, fmt.Sprintf("%s: more default options than max", group.Name))
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+func (m *MenuService) PriceSelection(restaurantId primitive.ObjectID, selection types.DishSelection) (types.PricedSelection, error) {
+	menu, err := m.GetMenu(restaurantId)
+	if err != nil {
+		return types.PricedSelection{}, err
+	}
+
+	return priceMenuSelection(menu, selection)
+}
+
+func priceMenuSelection(menu models.Menu, selection types.DishSelection) (types.PricedSelection, error) {
+	dishId, err := primitive.ObjectIDFromHex(selection.DishId)
+	if err != nil {
+		return types.PricedSelection{}, ErrMenuItemNotFound
+	}
+	for _, section := range menu.Sections {
+		for _, dish := range section.Dishes {
+			if dish.Id == dishId {
+				return PriceDish(dish, selection)
+			}
+		}
+	}
+	return types.PricedSelection{}, ErrMenuItemNotFound
+}
+
+// checks the selection against the dish option rules and computes its final price
+func PriceDish(dish models.Dish, selection types.DishSelection) (types.PricedSelection, error) {
+	priced := types.PricedSelection{DishId: dish.Id.Hex(), BasePrice: dish.Price, Options: []types.PricedOption{}}
+	problems := []string{}
+	if dish.SoldOut {
+		problems = append(problems, fmt.Sprintf("%s is not available", dish.Name))
+	}
+
+	seen := map[string]bool{}
+	for _, optionId := range selection.Options {
+		if seen[optionId] {
+			problems = append(problems, fmt.Sprintf("option %s selected more than once", optionId))
+			continue
+		}
+		seen[optionId] = true
+
+		found := false
+		for _, option := range dish.Options {
+			if option.Id.Hex() == optionId {
+				if option.SoldOut {
+					problems = append(problems, fmt.Sprintf("%s is not available", option.Name))
+				}
+				priced.Options = append(priced.Options, types.PricedOption{Id: optionId, Name: option.Name, PriceDelta: option.Price})
+				found = true
+			}
+		}
+		if !found {
+			problems = append(problems, fmt.Sprintf("unknown option %s", optionId))
+		}
+	}
+
+	priced.Options = priceGroups(dish.OptionGroups, selection.Groups, priced.Options, &problems)
+
+	priced.Total = priced.BasePrice
+	for _, option := range priced.Options {
+		total, err := priced.Total.Add(option.PriceDelta)
+		if err != nil {
+			return types.PricedSelection{}, err
+		}
+		priced.Total = total
+	}
+	if priced.Total.Amount < 0 {
+		problems = append(problems, "the final price can not be negative")
+	}
+
+	if len(problems) > 0 {
+		return types.PricedSelection{}, &OptionRulesError{problems}
+	}
+	return priced, nil
+}
+
+func priceGroups(groups []models.OptionGroup, selections []types.GroupSelection, priced []types.PricedOption, problems *[]string) []types.PricedOption {
+	byGroup := map[string]types.GroupSelection{}
+	for _, selection := range selections {
+		if _, repeated := byGroup[selection.GroupId]; repeated {
+			*problems = append(*problems, fmt.Sprintf("group %s selected more than once", selection.GroupId))
+		}
+		byGroup[selection.GroupId] = selection
+	}
+
+	known := map[string]bool{}
+	for _, group := range groups {
+		known[group.Id.Hex()] = true
+
+		selection, ok := byGroup[group.Id.Hex()]
+		chosen := selection.Options
+		if !ok {
+			chosen = []types.OptionSelection{}
+			for _, option := range group.Options {
+				if option.IsDefault {
+					chosen = append(chosen, types.OptionSelection{OptionId: option.Id.Hex()})
+				}
+			}
+		}
+
+		if uint(len(chosen)) < group.MinSelections() {
+			*problems = append(*problems, fmt.Sprintf("%s requires at least %d options", group.Name, group.MinSelections()))
+		}
+		if group.Max > 0 && uint(len(chosen)) > group.Max {
+			*problems = append(*problems, fmt.Sprintf("%s allows at most %d options", group.Name, group.Max))
+		}
+
+		seen := map[string]bool{}
+		for _, choice := range chosen {
+			if seen[choice.OptionId] {
+				*problems = append(*problems, fmt.Sprintf("option %s selected more than once", choice.OptionId))
+				continue
+			}
+			seen[choice.OptionId] = true
+
+			var option *models.GroupOption
+			for i := range group.Options {
+				if group.Options[i].Id.Hex() == choice.OptionId {
+					option = &group.Options[i]
+				}
+			}
+			if option == nil {
+				*problems = append(*problems, fmt.Sprintf("unknown option %s in %s", choice.OptionId, group.Name))
+				continue
+			}
+			if option.SoldOut {
+				*problems = append(*problems, fmt.Sprintf("%s is not available", option.Name))
+			}
+
+			priced = append(priced, types.PricedOption{Id: choice.OptionId, Name: option.Name, PriceDelta: option.PriceDelta})
+			priced = priceGroups(option.Groups, choice.Groups, priced, problems)
+		}
+	}
+
+	for _, selection := range selections {
+		if !known[selection.GroupId] {
+			*problems = append(*problems, fmt.Sprintf("unknown group %s", selection.GroupId))
+		}
+	}
+	return priced
+}
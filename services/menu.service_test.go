@@ -0,0 +1,381 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nicodeheza/peersEat/mocks"
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func initMenuTest() (*MenuService, *mocks.RestaurantRepositoryMock, primitive.ObjectID, models.Menu) {
+	repo := mocks.NewRestaurantRepositoryMock()
+	menu := models.Menu{Sections: []models.MenuSection{
+		{Id: primitive.NewObjectID(), Name: "starters", Dishes: []models.Dish{
+			{Id: primitive.NewObjectID(), Name: "soup", Price: usd(500)},
+			{Id: primitive.NewObjectID(), Name: "salad", Price: usd(600)},
+		}},
+		{Id: primitive.NewObjectID(), Name: "mains"},
+	}}
+	id := primitive.NewObjectID()
+	repo.Restaurants = []models.Restaurant{{Id: id, Currency: "USD", Menu: menu}}
+
+	return NewMenuService(repo), repo, id, menu
+}
+
+func TestGetMenuAssignsIds(t *testing.T) {
+	service, repo, _, _ := initMenuTest()
+	legacyId := primitive.NewObjectID()
+	repo.Restaurants = append(repo.Restaurants, models.Restaurant{Id: legacyId, Currency: "JPY", Menu: models.Menu{Sections: []models.MenuSection{
+		{Name: "legacy", Dishes: []models.Dish{{Name: "dish", Price: models.Money{Amount: 50000}, Options: []models.DishOptions{{Name: "option"}}}}},
+	}}})
+
+	menu, err := service.GetMenu(legacyId)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if menu.Sections[0].Id.IsZero() || menu.Sections[0].Dishes[0].Id.IsZero() || menu.Sections[0].Dishes[0].Options[0].Id.IsZero() {
+		t.Errorf("expecting every entity to get an id but got %v", menu)
+	}
+	if menu.Sections[0].Dishes[0].Price != models.NewMoney(500, "JPY") || menu.Sections[0].Dishes[0].Options[0].Price.Currency != "JPY" {
+		t.Errorf("expecting the prices to get the restaurant currency but got %v", menu)
+	}
+	if len(repo.UpdateCalls) != 1 || repo.UpdateCalls[0].Id != legacyId {
+		t.Errorf("expecting the ids to be saved once but got %v", repo.UpdateCalls)
+	}
+
+	if _, err := service.GetMenu(primitive.NewObjectID()); err != ErrMenuItemNotFound {
+		t.Errorf("expecting ErrMenuItemNotFound but got %v", err)
+	}
+}
+
+func TestMenuPartialUpdates(t *testing.T) {
+	service, repo, id, menu := initMenuTest()
+	section := menu.Sections[0]
+
+	dish, err := service.AddDish(id, models.MenuPath{SectionId: section.Id}, types.DishData{Name: "pie", Price: models.Money{Amount: 300}, Options: []types.DishOptionData{{Name: "cream"}}})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if dish.Id.IsZero() || dish.Options[0].Id.IsZero() {
+		t.Errorf("expecting the new dish and its options to have ids but got %v", dish)
+	}
+	if dish.Price != usd(300) || dish.Options[0].Price != usd(0) {
+		t.Errorf("expecting the prices in the restaurant currency but got %v", dish)
+	}
+	push := repo.MenuCalls[0]
+	if push.Method != "PushMenuItem" || push.Path != (models.MenuPath{SectionId: section.Id}) {
+		t.Errorf("expecting the dish to be pushed to the section but got %v", push)
+	}
+
+	price := usd(0)
+	path := models.MenuPath{SectionId: section.Id, DishId: section.Dishes[0].Id}
+	err = service.UpdateDish(id, path, types.DishUpdate{Price: &price})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	update := repo.MenuCalls[1]
+	if update.Method != "UpdateMenuItem" || update.Path != path {
+		t.Errorf("expecting the dish to be updated but got %v", update)
+	}
+	if updates := update.Value.(map[string]interface{}); len(updates) != 1 || updates["price"] != price {
+		t.Errorf("expecting only the price to be updated but got %v", updates)
+	}
+
+	err = service.UpdateDish(id, path, types.DishUpdate{})
+	if err != nil || len(repo.MenuCalls) != 2 {
+		t.Errorf("expecting empty updates to be skipped")
+	}
+}
+
+func TestMenuReorder(t *testing.T) {
+	service, repo, id, menu := initMenuTest()
+	section := menu.Sections[0]
+	path := models.MenuPath{SectionId: section.Id}
+
+	err := service.Reorder(id, path, []string{section.Dishes[1].Id.Hex(), section.Dishes[0].Id.Hex()})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	dishes := repo.MenuCalls[0].Value.([]models.Dish)
+	if dishes[0].Name != "salad" || dishes[1].Name != "soup" {
+		t.Errorf("expecting the dishes to be reordered but got %v", dishes)
+	}
+
+	invalid := [][]string{
+		{section.Dishes[0].Id.Hex()},
+		{section.Dishes[0].Id.Hex(), section.Dishes[0].Id.Hex()},
+		{section.Dishes[0].Id.Hex(), primitive.NewObjectID().Hex()},
+	}
+	for _, ids := range invalid {
+		if err := service.Reorder(id, path, ids); err != ErrInvalidMenuOrder {
+			t.Errorf("expecting ErrInvalidMenuOrder for %v but got %v", ids, err)
+		}
+	}
+
+	err = service.Reorder(id, models.MenuPath{SectionId: primitive.NewObjectID()}, []string{})
+	if err != ErrMenuItemNotFound {
+		t.Errorf("expecting ErrMenuItemNotFound but got %v", err)
+	}
+}
+
+func pizza() models.Dish {
+	groups, err := buildOptionGroups([]types.OptionGroupData{
+		{Name: "size", Required: true, Max: 1, Options: []types.GroupOptionData{
+			{Name: "small", PriceDelta: usd(-100), IsDefault: true},
+			{Name: "large", PriceDelta: usd(200)},
+		}},
+		{Name: "toppings", Max: 2, Options: []types.GroupOptionData{
+			{Name: "cheese", PriceDelta: usd(100), Groups: []types.OptionGroupData{
+				{Name: "cheese type", Required: true, Max: 1, Options: []types.GroupOptionData{
+					{Name: "cheddar"},
+					{Name: "blue", PriceDelta: usd(50)},
+				}},
+			}},
+			{Name: "ham", PriceDelta: usd(150)},
+			{Name: "olives", PriceDelta: usd(50)},
+		}},
+	}, "USD")
+	if err != nil {
+		panic(err)
+	}
+	return models.Dish{Id: primitive.NewObjectID(), Name: "pizza", Price: usd(1000), OptionGroups: groups}
+}
+
+func TestPriceDish(t *testing.T) {
+	dish := pizza()
+	size := dish.OptionGroups[0]
+	toppings := dish.OptionGroups[1]
+	cheese := toppings.Options[0]
+
+	priced, err := PriceDish(dish, types.DishSelection{DishId: dish.Id.Hex()})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if priced.Total != usd(900) || len(priced.Options) != 1 || priced.Options[0].Name != "small" {
+		t.Errorf("expecting the default size to be applied but got %v", priced)
+	}
+
+	selection := types.DishSelection{DishId: dish.Id.Hex(), Groups: []types.GroupSelection{
+		{GroupId: size.Id.Hex(), Options: []types.OptionSelection{{OptionId: size.Options[1].Id.Hex()}}},
+		{GroupId: toppings.Id.Hex(), Options: []types.OptionSelection{
+			{OptionId: cheese.Id.Hex(), Groups: []types.GroupSelection{
+				{GroupId: cheese.Groups[0].Id.Hex(), Options: []types.OptionSelection{{OptionId: cheese.Groups[0].Options[1].Id.Hex()}}},
+			}},
+			{OptionId: toppings.Options[1].Id.Hex()},
+		}},
+	}}
+	priced, err = PriceDish(dish, selection)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if priced.Total != usd(1500) || len(priced.Options) != 4 {
+		t.Errorf("expecting 10 + 2 + 1 + 0.5 + 1.5 but got %v", priced)
+	}
+
+	invalid := map[string]types.DishSelection{
+		"no size": {Groups: []types.GroupSelection{{GroupId: size.Id.Hex()}}},
+		"two sizes": {Groups: []types.GroupSelection{{GroupId: size.Id.Hex(), Options: []types.OptionSelection{
+			{OptionId: size.Options[0].Id.Hex()}, {OptionId: size.Options[1].Id.Hex()},
+		}}}},
+		"too many toppings": {Groups: []types.GroupSelection{{GroupId: toppings.Id.Hex(), Options: []types.OptionSelection{
+			{OptionId: toppings.Options[1].Id.Hex()}, {OptionId: toppings.Options[2].Id.Hex()}, {OptionId: cheese.Id.Hex()},
+		}}}},
+		"cheese without type": {Groups: []types.GroupSelection{{GroupId: toppings.Id.Hex(), Options: []types.OptionSelection{
+			{OptionId: cheese.Id.Hex()},
+		}}}},
+		"unknown option": {Groups: []types.GroupSelection{{GroupId: size.Id.Hex(), Options: []types.OptionSelection{
+			{OptionId: primitive.NewObjectID().Hex()},
+		}}}},
+		"unknown group": {Groups: []types.GroupSelection{{GroupId: primitive.NewObjectID().Hex()}}},
+	}
+	for title, selection := range invalid {
+		_, err := PriceDish(dish, selection)
+		if _, ok := err.(*OptionRulesError); !ok {
+			t.Errorf("%s: expecting an OptionRulesError but got %v", title, err)
+		}
+	}
+}
+
+func TestOptionGroupRules(t *testing.T) {
+	option := types.GroupOptionData{Name: "option", IsDefault: true}
+	nested := types.GroupOptionData{Name: "nested", Groups: []types.OptionGroupData{{Name: "inner", Options: []types.GroupOptionData{option}}}}
+
+	invalid := map[string][]types.OptionGroupData{
+		"min over max":      {{Name: "group", Min: 2, Max: 1, Options: []types.GroupOptionData{option, option}}},
+		"min over options":  {{Name: "group", Min: 2, Options: []types.GroupOptionData{option}}},
+		"too many defaults": {{Name: "group", Max: 1, Options: []types.GroupOptionData{option, option}}},
+		"nested too deep": {{Name: "group", Options: []types.GroupOptionData{
+			{Name: "outer", Groups: []types.OptionGroupData{{Name: "middle", Options: []types.GroupOptionData{nested}}}},
+		}}},
+	}
+	for title, groups := range invalid {
+		if _, err := buildOptionGroups(groups, "USD"); err == nil {
+			t.Errorf("%s: expecting the groups to be rejected", title)
+		}
+	}
+
+	service, repo, id, menu := initMenuTest()
+	path := models.MenuPath{SectionId: menu.Sections[0].Id, DishId: menu.Sections[0].Dishes[0].Id}
+	keptId := primitive.NewObjectID().Hex()
+	groups, err := service.SetOptionGroups(id, path, types.DishOptionGroups{Groups: []types.OptionGroupData{
+		{Id: keptId, Name: "size", Required: true, Options: []types.GroupOptionData{option}},
+	}})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if groups[0].Id.Hex() != keptId || groups[0].Options[0].Id.IsZero() {
+		t.Errorf("expecting the sent id to be kept and the new ones assigned but got %v", groups)
+	}
+	if update := repo.MenuCalls[0]; update.Path != path || update.Value.(map[string]interface{})["optionGroups"] == nil {
+		t.Errorf("expecting only the dish option groups to be updated but got %v", update)
+	}
+}
+
+func TestSetAvailability(t *testing.T) {
+	service, repo, id, menu := initMenuTest()
+	repo.Restaurants[0].OpenTime = "9:00AM"
+	dish := menu.Sections[0].Dishes[0]
+
+	unavailable, err := service.SetAvailability(id, dish.Id, types.ItemAvailability{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(unavailable) != 1 || unavailable[0].ItemId != dish.Id || repo.UpdateCalls[0].Updates["unavailable"] == nil {
+		t.Fatalf("expecting the dish to be saved as unavailable but got %v", unavailable)
+	}
+	repo.Restaurants[0].Unavailable = unavailable
+
+	current, err := service.GetMenu(id)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	soldOut := current.Sections[0].Dishes[0]
+	if !soldOut.SoldOut || soldOut.AvailableAt == nil || soldOut.AvailableAt.Hour() != 9 || current.Sections[0].Dishes[1].SoldOut {
+		t.Errorf("expecting only the dish to be sold out until the next opening but got %v", current.Sections[0].Dishes)
+	}
+
+	_, err = service.PriceSelection(id, types.DishSelection{DishId: dish.Id.Hex()})
+	if _, ok := err.(*OptionRulesError); !ok {
+		t.Errorf("expecting sold out dishes to be rejected but got %v", err)
+	}
+
+	unavailable, err = service.SetAvailability(id, dish.Id, types.ItemAvailability{Available: true})
+	if err != nil || len(unavailable) != 0 {
+		t.Errorf("expecting the dish to be available again but got %v, %v", unavailable, err)
+	}
+
+	if _, err := service.SetAvailability(id, primitive.NewObjectID(), types.ItemAvailability{}); err != ErrMenuItemNotFound {
+		t.Errorf("expecting ErrMenuItemNotFound but got %v", err)
+	}
+}
+
+func TestActiveUnavailable(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Hour)
+	ids := []primitive.ObjectID{primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()}
+
+	restaurant := models.Restaurant{OpenTime: now.Add(-time.Hour).Local().Format("3:04PM"), Unavailable: []models.UnavailableItem{
+		{ItemId: ids[0], Until: &past, MarkedAt: now.Add(-time.Hour)},
+		{ItemId: ids[1], Until: &future, MarkedAt: now},
+		{ItemId: ids[2], Indefinite: true, MarkedAt: now.Add(-48 * time.Hour)},
+		{ItemId: ids[3], MarkedAt: now.Add(-2 * time.Hour)},
+	}}
+
+	active := restaurant.ActiveUnavailable(now)
+	if _, ok := active[ids[0]]; ok {
+		t.Errorf("expecting items past their until time to be available")
+	}
+	if until, ok := active[ids[1]]; !ok || !until.Equal(future) {
+		t.Errorf("expecting items to be unavailable until the given time")
+	}
+	if until, ok := active[ids[2]]; !ok || until != nil {
+		t.Errorf("expecting indefinite items to stay unavailable")
+	}
+	if _, ok := active[ids[3]]; ok {
+		t.Errorf("expecting items to be reset at the opening after they were marked")
+	}
+}
+
+func TestNextOpening(t *testing.T) {
+	restaurant := models.Restaurant{OpenTime: "9:00AM"}
+	morning := time.Date(2024, 5, 6, 8, 0, 0, 0, time.Local)
+
+	next, ok := restaurant.Schedule().NextOpening(morning)
+	if !ok || !next.Equal(time.Date(2024, 5, 6, 9, 0, 0, 0, time.Local)) {
+		t.Errorf("expecting to open at 9:00AM the same day but got %v", next)
+	}
+	next, _ = restaurant.Schedule().NextOpening(morning.Add(2 * time.Hour))
+	if !next.Equal(time.Date(2024, 5, 7, 9, 0, 0, 0, time.Local)) {
+		t.Errorf("expecting to open at 9:00AM the next day but got %v", next)
+	}
+	if _, ok := (models.Restaurant{}).Schedule().NextOpening(morning); ok {
+		t.Errorf("expecting no opening without an open time")
+	}
+}
+
+func TestDietaryFilter(t *testing.T) {
+	milk := models.Dietary{Allergens: []string{models.ALLERGEN_MILK}}
+	vegan := models.Dietary{DietaryLabels: []string{models.DIET_VEGAN, models.DIET_VEGETARIAN}}
+	veganTraces := models.Dietary{DietaryLabels: []string{models.DIET_VEGAN}, MayContain: []string{models.ALLERGEN_MILK}}
+	menu := models.Menu{Sections: []models.MenuSection{
+		{Name: "mains", Dishes: []models.Dish{
+			{Name: "pizza", Dietary: milk},
+			{Name: "salad", Dietary: vegan, Options: []models.DishOptions{{Name: "cheese", Dietary: milk}, {Name: "seeds"}}},
+			{Name: "curry", Dietary: veganTraces},
+			{Name: "bowl", Dietary: vegan, OptionGroups: []models.OptionGroup{
+				{Name: "topping", Required: true, Options: []models.GroupOption{{Name: "feta", Dietary: milk}}},
+			}},
+		}},
+		{Name: "desserts", Dishes: []models.Dish{{Name: "ice cream", Dietary: milk}}},
+	}}
+
+	if filtered := menu.Filter(models.DietaryFilter{}); len(filtered.Sections) != 2 || len(filtered.Sections[0].Dishes) != 4 {
+		t.Errorf("expecting an empty filter to keep the menu but got %v", filtered)
+	}
+
+	filtered := menu.Filter(models.DietaryFilter{Exclude: []string{models.ALLERGEN_MILK}})
+	names := []string{}
+	for _, dish := range filtered.Sections[0].Dishes {
+		names = append(names, dish.Name)
+	}
+	if len(filtered.Sections) != 1 || len(names) != 2 || names[0] != "salad" || names[1] != "curry" {
+		t.Fatalf("expecting salad and curry but got %v", names)
+	}
+	if options := filtered.Sections[0].Dishes[0].Options; len(options) != 1 || options[0].Name != "seeds" {
+		t.Errorf("expecting the cheese option to be left out but got %v", options)
+	}
+	if len(menu.Sections[0].Dishes[1].Options) != 2 {
+		t.Errorf("expecting the original menu to be unchanged")
+	}
+
+	filtered = menu.Filter(models.DietaryFilter{Include: []string{models.DIET_VEGAN}, Exclude: []string{models.ALLERGEN_MILK}, ExcludeTraces: true})
+	if len(filtered.Sections) != 1 || len(filtered.Sections[0].Dishes) != 1 || filtered.Sections[0].Dishes[0].Name != "salad" {
+		t.Errorf("expecting only the salad but got %v", filtered)
+	}
+
+	filtered = menu.Filter(models.DietaryFilter{Include: []string{models.DIET_VEGETARIAN}})
+	if len(filtered.Sections) != 1 || len(filtered.Sections[0].Dishes) != 2 {
+		t.Errorf("expecting the vegetarian salad and bowl but got %v", filtered)
+	}
+}
+
+func TestUpdateDishDietary(t *testing.T) {
+	service, repo, id, menu := initMenuTest()
+	path := models.MenuPath{SectionId: menu.Sections[0].Id, DishId: menu.Sections[0].Dishes[0].Id}
+
+	dietary := types.DietaryData{Allergens: []string{models.ALLERGEN_CELERY}}
+	if err := service.UpdateDish(id, path, types.DishUpdate{DietaryData: &dietary}); err != nil {
+		t.Fatal(err.Error())
+	}
+	updates := repo.MenuCalls[0].Value.(map[string]interface{})
+	if allergens := updates["allergens"].([]string); len(allergens) != 1 || allergens[0] != models.ALLERGEN_CELERY {
+		t.Errorf("expecting the allergens to be set but got %v", updates)
+	}
+	if mayContain, ok := updates["mayContain"].([]string); !ok || mayContain == nil || len(mayContain) != 0 {
+		t.Errorf("expecting the traces to be cleared but got %v", updates)
+	}
+}
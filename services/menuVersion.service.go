@@ -0,0 +1,197 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/repositories"
+	"github.com/nicodeheza/peersEat/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrMenuVersionNotFound = errors.New("menu version not found")
+var ErrNoMenuAvailable = errors.New("no menu available at that time")
+var ErrEmptyMenu = errors.New("can not publish an empty menu")
+
+// the restaurant menu works as a draft, publishing it creates a new immutable
+// version of a named menu
+type MenuVersionServiceI interface {
+	Publish(restaurantId primitive.ObjectID, data types.PublishMenu) (models.MenuVersion, error)
+	GetVersions(restaurantId primitive.ObjectID, name string) ([]models.MenuVersion, error)
+	GetVersion(restaurantId primitive.ObjectID, versionId primitive.ObjectID) (models.MenuVersion, error)
+	Checkout(restaurantId primitive.ObjectID, versionId primitive.ObjectID) (models.Menu, error)
+	Archive(restaurantId primitive.ObjectID, name string) error
+	CurrentMenu(restaurantId primitive.ObjectID, at time.Time) (models.MenuVersion, error)
+	PriceSelection(restaurantId primitive.ObjectID, versionId primitive.ObjectID, selection types.DishSelection) (types.PricedSelection, error)
+}
+
+type MenuVersionService struct {
+	repo           repositories.MenuVersionRepositoryI
+	restaurantRepo repositories.RestaurantRepositoryI
+	menu           MenuServiceI
+}
+
+func NewMenuVersionService(repo repositories.MenuVersionRepositoryI, restaurantRepo repositories.RestaurantRepositoryI, menu MenuServiceI) *MenuVersionService {
+	return &MenuVersionService{repo, restaurantRepo, menu}
+}
+
+func (m *MenuVersionService) Publish(restaurantId primitive.ObjectID, data types.PublishMenu) (models.MenuVersion, error) {
+	menu, err := m.menu.GetMenu(restaurantId)
+	if err != nil {
+		return models.MenuVersion{}, err
+	}
+	if len(menu.Sections) == 0 {
+		return models.MenuVersion{}, ErrEmptyMenu
+	}
+
+	last, err := m.repo.LastVersion(restaurantId, data.Name)
+	if err != nil {
+		return models.MenuVersion{}, err
+	}
+
+	version := models.MenuVersion{
+		RestaurantId: restaurantId,
+		Name:         data.Name,
+		Version:      last + 1,
+		Menu:         menu,
+		PublishedAt:  time.Now(),
+	}
+	for _, window := range data.Windows {
+		version.Windows = append(version.Windows, models.AvailabilityWindow{Days: window.Days, Start: window.Start, End: window.End})
+	}
+
+	version.Id, err = m.repo.Insert(version)
+	if err != nil {
+		return models.MenuVersion{}, err
+	}
+	return version, nil
+}
+
+// an empty name returns the history of every named menu
+func (m *MenuVersionService) GetVersions(restaurantId primitive.ObjectID, name string) ([]models.MenuVersion, error) {
+	query := map[string]interface{}{"restaurantId": restaurantId}
+	if name != "" {
+		query["name"] = name
+	}
+	return m.repo.FindMany(query)
+}
+
+func (m *MenuVersionService) GetVersion(restaurantId primitive.ObjectID, versionId primitive.ObjectID) (models.MenuVersion, error) {
+	version, err := m.repo.FindOne(map[string]interface{}{"_id": versionId, "restaurantId": restaurantId})
+	if err == mongo.ErrNoDocuments {
+		return models.MenuVersion{}, ErrMenuVersionNotFound
+	}
+	if err != nil {
+		return models.MenuVersion{}, err
+	}
+	return version, m.markSoldOut(&version, time.Now())
+}
+
+// published versions are immutable, the current availability is only added to the copy read
+func (m *MenuVersionService) markSoldOut(version *models.MenuVersion, at time.Time) error {
+	restaurant, err := m.restaurantRepo.FindOne(map[string]interface{}{"_id": version.RestaurantId})
+	if err != nil {
+		return err
+	}
+	version.Menu.MarkSoldOut(restaurant.ActiveUnavailable(at))
+	return nil
+}
+
+// copies a published version back to the draft so it can be edited
+func (m *MenuVersionService) Checkout(restaurantId primitive.ObjectID, versionId primitive.ObjectID) (models.Menu, error) {
+	version, err := m.GetVersion(restaurantId, versionId)
+	if err != nil {
+		return models.Menu{}, err
+	}
+
+	err = m.restaurantRepo.Update(restaurantId, map[string]interface{}{"menu": version.Menu})
+	if err != nil {
+		return models.Menu{}, err
+	}
+	return version.Menu, nil
+}
+
+func (m *MenuVersionService) Archive(restaurantId primitive.ObjectID, name string) error {
+	archived, err := m.repo.Archive(restaurantId, name)
+	if err != nil {
+		return err
+	}
+	if archived == 0 {
+		return ErrMenuVersionNotFound
+	}
+	return nil
+}
+
+// the latest version of every named menu competes, menus with windows win over
+// the ones always available and the last published breaks ties
+// availability windows are evaluated in the restaurant timezone
+func (m *MenuVersionService) CurrentMenu(restaurantId primitive.ObjectID, at time.Time) (models.MenuVersion, error) {
+	restaurant, err := m.restaurantRepo.FindOne(map[string]interface{}{"_id": restaurantId})
+	if err != nil {
+		return models.MenuVersion{}, err
+	}
+	at = at.In(restaurant.Schedule().Location())
+
+	versions, err := m.repo.FindMany(map[string]interface{}{"restaurantId": restaurantId})
+	if err != nil {
+		return models.MenuVersion{}, err
+	}
+
+	latest := map[string]models.MenuVersion{}
+	for _, version := range versions {
+		if current, ok := latest[version.Name]; !ok || version.Version > current.Version {
+			latest[version.Name] = version
+		}
+	}
+
+	var current *models.MenuVersion
+	for _, version := range latest {
+		if version.Archived || !version.IsAvailableAt(at) {
+			continue
+		}
+		version := version
+		if current == nil {
+			current = &version
+			continue
+		}
+
+		scheduled, currentScheduled := len(version.Windows) > 0, len(current.Windows) > 0
+		if scheduled && !currentScheduled || scheduled == currentScheduled && version.PublishedAt.After(current.PublishedAt) {
+			current = &version
+		}
+	}
+
+	if current == nil {
+		return models.MenuVersion{}, ErrNoMenuAvailable
+	}
+	current.Menu.MarkSoldOut(restaurant.ActiveUnavailable(at))
+	return *current, nil
+}
+
+func (m *MenuVersionService) PriceSelection(restaurantId primitive.ObjectID, versionId primitive.ObjectID, selection types.DishSelection) (types.PricedSelection, error) {
+	version, err := m.GetVersion(restaurantId, versionId)
+	if err != nil {
+		return types.PricedSelection{}, err
+	}
+
+	priced, err := priceMenuSelection(version.Menu, selection)
+	if err != nil {
+		return types.PricedSelection{}, err
+	}
+	priced.MenuVersionId = version.Id.Hex()
+	return priced, nil
+}
+
+// versions coming from another peer keep their ids, so orders still point to
+// them. The ones stored by a previous attempt are skipped
+func insertMenuVersions(repo repositories.MenuVersionRepositoryI, versions []models.MenuVersion) error {
+	for _, version := range versions {
+		_, err := repo.Insert(version)
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nicodeheza/peersEat/mocks"
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func initMenuVersionTest() (*MenuVersionService, *mocks.MenuVersionRepositoryMock, *mocks.RestaurantRepositoryMock, primitive.ObjectID) {
+	menuService, restaurantRepo, id, _ := initMenuTest()
+	repo := mocks.NewMenuVersionRepositoryMock()
+	return NewMenuVersionService(repo, restaurantRepo, menuService), repo, restaurantRepo, id
+}
+
+func TestPublishMenu(t *testing.T) {
+	service, repo, restaurantRepo, id := initMenuVersionTest()
+
+	first, err := service.Publish(id, types.PublishMenu{Name: "lunch"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	second, err := service.Publish(id, types.PublishMenu{Name: "lunch"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if first.Version != 1 || second.Version != 2 || first.Id == second.Id {
+		t.Errorf("expecting consecutive versions but got %v and %v", first.Version, second.Version)
+	}
+	if len(repo.Versions) != 2 || len(repo.Versions[0].Menu.Sections) != 2 {
+		t.Errorf("expecting the draft to be copied to every version but got %v", repo.Versions)
+	}
+
+	dish := first.Menu.Sections[0].Dishes[0]
+	priced, err := service.PriceSelection(id, first.Id, types.DishSelection{DishId: dish.Id.Hex()})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if priced.Total != dish.Price || priced.MenuVersionId != first.Id.Hex() {
+		t.Errorf("expecting the price from the version but got %v", priced)
+	}
+
+	restaurantRepo.Restaurants = append(restaurantRepo.Restaurants, models.Restaurant{Id: primitive.NewObjectID()})
+	if _, err := service.Publish(restaurantRepo.Restaurants[1].Id, types.PublishMenu{Name: "lunch"}); err != ErrEmptyMenu {
+		t.Errorf("expecting ErrEmptyMenu but got %v", err)
+	}
+}
+
+func TestCurrentMenu(t *testing.T) {
+	service, repo, _, id := initMenuVersionTest()
+	published := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	repo.Versions = []models.MenuVersion{
+		{Id: primitive.NewObjectID(), RestaurantId: id, Name: "all day", Version: 1, PublishedAt: published},
+		{Id: primitive.NewObjectID(), RestaurantId: id, Name: "breakfast", Version: 1, PublishedAt: published,
+			Windows: []models.AvailabilityWindow{{Start: "07:00", End: "11:00"}}},
+		{Id: primitive.NewObjectID(), RestaurantId: id, Name: "breakfast", Version: 2, PublishedAt: published.Add(time.Hour),
+			Windows: []models.AvailabilityWindow{{Start: "08:00", End: "11:00"}}},
+		{Id: primitive.NewObjectID(), RestaurantId: id, Name: "late night", Version: 1, PublishedAt: published,
+			Windows: []models.AvailabilityWindow{{Days: []time.Weekday{time.Friday}, Start: "22:00", End: "02:00"}}},
+	}
+
+	// 2023-06-02 is a Friday
+	tests := []struct {
+		At      time.Time
+		Name    string
+		Version int
+	}{
+		{time.Date(2023, 6, 2, 7, 30, 0, 0, time.UTC), "all day", 1},
+		{time.Date(2023, 6, 2, 9, 0, 0, 0, time.UTC), "breakfast", 2},
+		{time.Date(2023, 6, 2, 23, 0, 0, 0, time.UTC), "late night", 1},
+		{time.Date(2023, 6, 3, 1, 0, 0, 0, time.UTC), "late night", 1},
+		{time.Date(2023, 6, 4, 1, 0, 0, 0, time.UTC), "all day", 1},
+	}
+	for _, test := range tests {
+		current, err := service.CurrentMenu(id, test.At)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if current.Name != test.Name || current.Version != test.Version {
+			t.Errorf("at %v expecting %s v%d but got %s v%d", test.At, test.Name, test.Version, current.Name, current.Version)
+		}
+	}
+
+	if err := service.Archive(id, "all day"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := service.CurrentMenu(id, tests[0].At); err != ErrNoMenuAvailable {
+		t.Errorf("expecting ErrNoMenuAvailable after archiving but got %v", err)
+	}
+	if err := service.Archive(id, "dinner"); err != ErrMenuVersionNotFound {
+		t.Errorf("expecting ErrMenuVersionNotFound but got %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"github.com/nicodeheza/peersEat/repositories"
+)
+
+// MigrationServiceI rewrites the documents stored in older formats, it runs on boot
+// and does nothing once everything was migrated
+type MigrationServiceI interface {
+	MigrateMoney() error
+}
+
+type MigrationService struct {
+	restaurantRepo  repositories.RestaurantRepositoryI
+	menuVersionRepo repositories.MenuVersionRepositoryI
+}
+
+func NewMigrationService(restaurantRepo repositories.RestaurantRepositoryI, menuVersionRepo repositories.MenuVersionRepositoryI) *MigrationService {
+	return &MigrationService{restaurantRepo, menuVersionRepo}
+}
+
+// prices were stored as floats in major units, they become Money in the restaurant
+// currency. Delivery quotes are left to expire
+func (s *MigrationService) MigrateMoney() error {
+	restaurants, err := s.restaurantRepo.FindMany(map[string]interface{}{})
+	if err != nil {
+		return err
+	}
+
+	for _, restaurant := range restaurants {
+		currency := restaurant.CurrencyOrDefault()
+		updates := map[string]interface{}{}
+		if restaurant.Currency == "" {
+			updates["currency"] = currency
+		}
+		if restaurant.Menu.InCurrency(currency) {
+			updates["menu"] = restaurant.Menu
+		}
+		if restaurant.DeliveryCost.Currency == "" && !restaurant.DeliveryCost.IsZero() {
+			updates["deliveryCost"] = restaurant.DeliveryCost.In(currency)
+		}
+		if restaurant.DeliveryPricing != nil && restaurant.DeliveryPricing.InCurrency(currency) {
+			updates["deliveryPricing"] = restaurant.DeliveryPricing
+		}
+		if len(updates) > 0 {
+			err = s.restaurantRepo.Update(restaurant.Id, updates)
+			if err != nil {
+				return err
+			}
+		}
+
+		versions, err := s.menuVersionRepo.FindMany(map[string]interface{}{"restaurantId": restaurant.Id})
+		if err != nil {
+			return err
+		}
+		for _, version := range versions {
+			if version.Menu.InCurrency(currency) {
+				_, err = s.menuVersionRepo.MigrateMenuMoney(version.Id, version.Menu)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/nicodeheza/peersEat/mocks"
+	"github.com/nicodeheza/peersEat/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func usd(cents int64) models.Money {
+	return models.NewMoney(cents, "USD")
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	// 0.1 + 0.2 is not 0.3 with floats
+	total, err := usd(10).Add(usd(20))
+	if err != nil || total != usd(30) {
+		t.Errorf("expecting 0.30 USD but got %v %v", total, err)
+	}
+	if _, err := usd(10).Add(models.NewMoney(10, "EUR")); !errors.Is(err, models.ErrCurrencyMismatch) {
+		t.Errorf("expecting currency mismatch error but got %v", err)
+	}
+	if total, err := (models.Money{}).Add(usd(10)); err != nil || total != usd(10) {
+		t.Errorf("expecting zero money to add to any currency but got %v %v", total, err)
+	}
+	if change, err := usd(500).Sub(usd(650)); err != nil || change != usd(-150) {
+		t.Errorf("expecting -1.50 USD but got %v %v", change, err)
+	}
+	if cmp, err := usd(100).Cmp(usd(99)); err != nil || cmp != 1 {
+		t.Errorf("expecting 1 but got %v %v", cmp, err)
+	}
+	if product := usd(333).Mul(3); product != usd(999) {
+		t.Errorf("expecting 9.99 USD but got %v", product)
+	}
+	if product := usd(650).MulFloat(1.5); product != usd(975) {
+		t.Errorf("expecting 9.75 USD but got %v", product)
+	}
+	if product := usd(5).MulFloat(0.5); product != usd(3) {
+		t.Errorf("expecting half cents to round up but got %v", product)
+	}
+
+	amounts := map[string]models.Money{
+		"12.50 USD": models.MoneyFromMajor(12.5, "USD"),
+		"1250 JPY":  models.MoneyFromMajor(1250, "JPY"),
+		"1.250 KWD": models.MoneyFromMajor(1.25, "KWD"),
+	}
+	for expected, money := range amounts {
+		if money.String() != expected {
+			t.Errorf("expecting %v but got %v", expected, money)
+		}
+	}
+
+	if money, err := (models.Money{Amount: 1250}).As("JPY"); err != nil || money != models.NewMoney(13, "JPY") {
+		t.Errorf("expecting legacy amounts to be converted but got %v %v", money, err)
+	}
+	if _, err := usd(100).As("JPY"); !errors.Is(err, models.ErrCurrencyMismatch) {
+		t.Errorf("expecting currency mismatch error but got %v", err)
+	}
+}
+
+func TestMoneyCodecs(t *testing.T) {
+	var fromNumber, fromObject models.Money
+	if err := json.Unmarshal([]byte(`12.35`), &fromNumber); err != nil || fromNumber != (models.Money{Amount: 1235}) {
+		t.Errorf("expecting a legacy amount but got %v %v", fromNumber, err)
+	}
+	if err := json.Unmarshal([]byte(`{"amount":1235,"currency":"EUR"}`), &fromObject); err != nil || fromObject != models.NewMoney(1235, "EUR") {
+		t.Errorf("expecting 12.35 EUR but got %v %v", fromObject, err)
+	}
+
+	type legacyDish struct {
+		Price float64
+	}
+	type dish struct {
+		Price models.Money
+	}
+	raw, _ := bson.Marshal(legacyDish{19.99})
+	var decoded dish
+	if err := bson.Unmarshal(raw, &decoded); err != nil || decoded.Price != (models.Money{Amount: 1999}) {
+		t.Errorf("expecting a legacy amount but got %v %v", decoded, err)
+	}
+
+	raw, _ = bson.Marshal(dish{usd(1999)})
+	decoded = dish{}
+	if err := bson.Unmarshal(raw, &decoded); err != nil || decoded.Price != usd(1999) {
+		t.Errorf("expecting 19.99 USD but got %v %v", decoded, err)
+	}
+	var document bson.M
+	bson.Unmarshal(raw, &document)
+	if price, ok := document["price"].(bson.M); !ok || price["amount"] != int64(1999) || price["currency"] != "USD" {
+		t.Errorf("expecting the price to be stored as a document but got %v", document)
+	}
+}
+
+func TestMigrateMoney(t *testing.T) {
+	restaurantRepo := mocks.NewRestaurantRepositoryMock()
+	versionRepo := mocks.NewMenuVersionRepositoryMock()
+	service := NewMigrationService(restaurantRepo, versionRepo)
+
+	legacyMenu := func() models.Menu {
+		return models.Menu{Sections: []models.MenuSection{{Name: "mains", Dishes: []models.Dish{
+			{Name: "pizza", Price: models.Money{Amount: 1000}, OptionGroups: []models.OptionGroup{
+				{Name: "size", Options: []models.GroupOption{{Name: "large", PriceDelta: models.Money{Amount: 250}}}},
+			}},
+		}}}}
+	}
+	menu := legacyMenu()
+	legacy := models.Restaurant{
+		Id:              primitive.NewObjectID(),
+		Menu:            menu,
+		DeliveryCost:    models.Money{Amount: 300},
+		DeliveryPricing: &models.DeliveryPricing{BaseFee: models.Money{Amount: 200}},
+	}
+	migrated := models.Restaurant{Id: primitive.NewObjectID(), Currency: "USD", DeliveryCost: usd(300)}
+	restaurantRepo.Restaurants = []models.Restaurant{legacy, migrated}
+	versionRepo.Insert(models.MenuVersion{RestaurantId: legacy.Id, Menu: legacyMenu()})
+
+	if err := service.MigrateMoney(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(restaurantRepo.UpdateCalls) != 1 || restaurantRepo.UpdateCalls[0].Id != legacy.Id {
+		t.Fatalf("expecting only the legacy restaurant to be updated but got %v", restaurantRepo.UpdateCalls)
+	}
+	updates := restaurantRepo.UpdateCalls[0].Updates
+	migratedMenu := updates["menu"].(models.Menu)
+	if updates["currency"] != "USD" || updates["deliveryCost"] != usd(300) || updates["deliveryPricing"].(*models.DeliveryPricing).BaseFee != usd(200) {
+		t.Errorf("unexpected updates %v", updates)
+	}
+	if dish := migratedMenu.Sections[0].Dishes[0]; dish.Price != usd(1000) || dish.OptionGroups[0].Options[0].PriceDelta != usd(250) {
+		t.Errorf("expecting the menu prices in USD but got %v", dish)
+	}
+	if price := versionRepo.Versions[0].Menu.Sections[0].Dishes[0].Price; price != usd(1000) {
+		t.Errorf("expecting the published versions to be migrated but got %v", price)
+	}
+
+	// a migrated version is never rewritten
+	if migrated, err := versionRepo.MigrateMenuMoney(versionRepo.Versions[0].Id, legacyMenu()); err != nil || migrated {
+		t.Errorf("expecting a migrated version to be kept but got %v %v", migrated, err)
+	}
+	if price := versionRepo.Versions[0].Menu.Sections[0].Dishes[0].Price; price != usd(1000) {
+		t.Errorf("expecting the published prices to be kept but got %v", price)
+	}
+}
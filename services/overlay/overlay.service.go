@@ -0,0 +1,291 @@
+package overlay
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nicodeheza/peersEat/constants"
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/repositories"
+	"github.com/nicodeheza/peersEat/services/geo"
+	"github.com/nicodeheza/peersEat/utils"
+)
+
+// scopes ending with it are delivered to a single peer and not forwarded
+const TERMINAL_SCOPE = "*"
+
+// The overlay places peers in the geohash key space of their centers. Every
+// peer keeps a few contacts of each geohash subtree around its own hash, so
+// nearby prefixes are well known while far away ones are reached with a few
+// long range links. Lookups go one prefix closer on every hop and
+// broadcasts delegate each subtree to one of its contacts.
+type OverlayServiceI interface {
+	IsEnabled() bool
+	ShouldStore(self models.Peer, peer models.Peer) (bool, error)
+	ClosestPeers(target string, limit int) ([]models.Peer, error)
+	Lookup(self models.Peer, target string, limit int) ([]models.Peer, error)
+	BroadcastTargets(self models.Peer, scope string) (map[string]string, error)
+	AreaScopes(center models.GeoCoords, radius float64) []string
+}
+
+type OverlayService struct {
+	repo           repositories.PeerRepositoryI
+	geo            geo.GeoServiceI
+	fullTableLimit int64
+}
+
+func NewOverlayService(repo repositories.PeerRepositoryI, geo geo.GeoServiceI) *OverlayService {
+	var fullTableLimit int64 = constants.OVERLAY_FULL_TABLE_LIMIT
+	if limitStr := os.Getenv("OVERLAY_FULL_TABLE_LIMIT"); limitStr != "" {
+		limit, err := strconv.ParseInt(limitStr, 10, 64)
+		if err == nil && limit >= 0 {
+			fullTableLimit = limit
+		}
+	}
+	return &OverlayService{repo, geo, fullTableLimit}
+}
+
+// small networks keep the full peer table
+func (o *OverlayService) IsEnabled() bool {
+	count, err := o.repo.Count()
+	if err != nil {
+		return false
+	}
+	return count > o.fullTableLimit
+}
+
+// a peer is stored when its subtree still has room in the routing table
+func (o *OverlayService) ShouldStore(self models.Peer, peer models.Peer) (bool, error) {
+	if !o.IsEnabled() {
+		return true, nil
+	}
+
+	selfHash := utils.PeerGeohash(self)
+	peerHash := utils.PeerGeohash(peer)
+
+	level := utils.GeohashCommonPrefixLen(selfHash, peerHash)
+	if level >= len(peerHash) {
+		return true, nil
+	}
+
+	stored, err := o.repo.FindByGeohashPrefix(peerHash[:level+1], "", constants.OVERLAY_SUBTREE_CONTACTS)
+	if err != nil {
+		return false, err
+	}
+
+	return len(stored) < constants.OVERLAY_SUBTREE_CONTACTS, nil
+}
+
+// closest known peers to the target hash, the longest shared prefix first
+func (o *OverlayService) ClosestPeers(target string, limit int) ([]models.Peer, error) {
+	if limit <= 0 {
+		limit = constants.OVERLAY_LOOKUP_SIZE
+	}
+
+	result := []models.Peer{}
+	seen := map[string]bool{}
+
+	for length := len(target); length >= 0 && len(result) < limit; length-- {
+		peers, err := o.repo.FindByGeohashPrefix(target[:length], "", int64(limit))
+		if err != nil {
+			return nil, err
+		}
+		for _, peer := range peers {
+			if seen[peer.Url] {
+				continue
+			}
+			seen[peer.Url] = true
+			result = append(result, peer)
+		}
+	}
+
+	o.sortByCloseness(result, target)
+	if len(result) > limit {
+		result = result[:limit]
+	}
+
+	return result, nil
+}
+
+// iterative lookup, every hop asks the closest not yet queried peer for its
+// closest peers to the target until no closer peer is found
+func (o *OverlayService) Lookup(self models.Peer, target string, limit int) ([]models.Peer, error) {
+	if limit <= 0 {
+		limit = constants.OVERLAY_LOOKUP_SIZE
+	}
+
+	candidates, err := o.ClosestPeers(target, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	queried := map[string]bool{self.Url: true}
+
+	for hop := 0; hop < constants.OVERLAY_MAX_HOPS; hop++ {
+		var next *models.Peer
+		for i := range candidates {
+			if !queried[candidates[i].Url] {
+				next = &candidates[i]
+				break
+			}
+		}
+		if next == nil {
+			break
+		}
+		queried[next.Url] = true
+
+		remotePeers, err := o.getRemoteClosestPeers(next.Url, target, limit)
+		if err != nil {
+			continue
+		}
+
+		known := map[string]bool{}
+		for _, candidate := range candidates {
+			known[candidate.Url] = true
+		}
+		for _, peer := range remotePeers {
+			if !known[peer.Url] {
+				known[peer.Url] = true
+				candidates = append(candidates, peer)
+			}
+		}
+
+		o.sortByCloseness(candidates, target)
+		if len(candidates) > limit {
+			candidates = candidates[:limit]
+		}
+	}
+
+	return candidates, nil
+}
+
+// maps every peer that must receive a broadcast to the scope it is in charge
+// of. The peer responsible for scope delegates each subtree under it to one of
+// its contacts, so the whole scope is reached in a logarithmic number of hops.
+func (o *OverlayService) BroadcastTargets(self models.Peer, scope string) (map[string]string, error) {
+	targets := map[string]string{}
+	if strings.HasSuffix(scope, TERMINAL_SCOPE) {
+		return targets, nil
+	}
+
+	selfHash := utils.PeerGeohash(self)
+	delegated := map[string]bool{}
+
+	for level := len(scope); level < len(selfHash); level++ {
+		peers, err := o.repo.FindByGeohashPrefix(selfHash[:level], selfHash[:level+1], 0)
+		if err != nil {
+			return nil, err
+		}
+
+		o.sortByCloseness(peers, selfHash)
+		for _, peer := range peers {
+			peerHash := utils.PeerGeohash(peer)
+			if len(peerHash) <= level {
+				continue
+			}
+			subtree := peerHash[:level+1]
+			if delegated[subtree] {
+				continue
+			}
+			delegated[subtree] = true
+			targets[peer.Url] = subtree
+		}
+	}
+
+	// peers in the same cell can not be split anymore
+	sameCell, err := o.repo.FindByGeohashPrefix(selfHash, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, peer := range sameCell {
+		if peer.Url != self.Url {
+			targets[peer.Url] = selfHash + TERMINAL_SCOPE
+		}
+	}
+
+	return targets, nil
+}
+
+// geohash prefixes covering a circle, the cells are at least as big as the
+// circle so the center cell and its neighbors cover it
+func (o *OverlayService) AreaScopes(center models.GeoCoords, radius float64) []string {
+	length := utils.GEOHASH_PRECISION
+	for length > 0 {
+		width, height := utils.GeohashCellSize(length, center.Lat)
+		if width >= 2*radius && height >= 2*radius {
+			break
+		}
+		length--
+	}
+	if length == 0 {
+		return []string{""}
+	}
+
+	longDelta := radius / (111.320 * math.Max(math.Cos(center.Lat*math.Pi/180), 0.01))
+	latDelta := radius / 110.574
+
+	scopes := []string{}
+	seen := map[string]bool{}
+	for _, dLong := range []float64{-longDelta, 0, longDelta} {
+		for _, dLat := range []float64{-latDelta, 0, latDelta} {
+			point := models.GeoCoords{Long: center.Long + dLong, Lat: center.Lat + dLat}
+			scope := utils.Geohash(point, length)
+			if !seen[scope] {
+				seen[scope] = true
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+
+	return scopes
+}
+
+func (o *OverlayService) getRemoteClosestPeers(peerUrl string, target string, limit int) ([]models.Peer, error) {
+	url, err := url.Parse(peerUrl + "/peer/overlay/closest")
+	if err != nil {
+		return nil, err
+	}
+	query := url.Query()
+	query.Add("geohash", target)
+	query.Add("limit", strconv.Itoa(limit))
+	url.RawQuery = query.Encode()
+
+	resp, err := http.Get(url.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("peer responded with status %d", resp.StatusCode)
+	}
+
+	peers := []models.Peer{}
+	err = json.NewDecoder(resp.Body).Decode(&peers)
+	if err != nil {
+		return nil, err
+	}
+
+	return peers, nil
+}
+
+func (o *OverlayService) sortByCloseness(peers []models.Peer, target string) {
+	targetCenter := utils.GeohashCenter(target)
+	sort.SliceStable(peers, func(i, j int) bool {
+		prefixI := utils.GeohashCommonPrefixLen(utils.PeerGeohash(peers[i]), target)
+		prefixJ := utils.GeohashCommonPrefixLen(utils.PeerGeohash(peers[j]), target)
+		if prefixI != prefixJ {
+			return prefixI > prefixJ
+		}
+		distanceI := math.Abs(o.geo.GetCoordDistance(peers[i].Center, targetCenter))
+		distanceJ := math.Abs(o.geo.GetCoordDistance(peers[j].Center, targetCenter))
+		return distanceI < distanceJ
+	})
+}
@@ -0,0 +1,183 @@
+package overlay
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/nicodeheza/peersEat/mocks"
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/services/geo"
+	"github.com/nicodeheza/peersEat/utils"
+)
+
+func newPeer(url string, long, lat float64) models.Peer {
+	center := models.GeoCoords{Long: long, Lat: lat}
+	return models.Peer{Url: url, Center: center, Geohash: utils.Geohash(center, utils.GEOHASH_PRECISION)}
+}
+
+func initTest(limit string, peers []models.Peer) (*OverlayService, *mocks.PeerRepositoryMock) {
+	os.Setenv("OVERLAY_FULL_TABLE_LIMIT", limit)
+	defer os.Unsetenv("OVERLAY_FULL_TABLE_LIMIT")
+
+	repo := mocks.NewPeerRepository()
+	repo.Peers = peers
+	return NewOverlayService(repo, geo.NewGeo()), repo
+}
+
+func testPeers() []models.Peer {
+	return []models.Peer{
+		newPeer("http://palermo.com", -58.4246, -34.5875),
+		newPeer("http://recoleta.com", -58.3933, -34.5875),
+		newPeer("http://centro.com", -58.3816, -34.6037),
+		newPeer("http://montevideo.com", -56.1645, -34.9011),
+		newPeer("http://madrid.com", -3.7038, 40.4168),
+		newPeer("http://paris.com", 2.3522, 48.8566),
+		newPeer("http://tokyo.com", 139.6917, 35.6895),
+	}
+}
+
+func TestShouldStore(t *testing.T) {
+	peers := testPeers()
+	self := peers[0]
+	service, _ := initTest("100", peers)
+
+	keep, err := service.ShouldStore(self, newPeer("http://rome.com", 12.4964, 41.9028))
+	if err != nil || !keep {
+		t.Errorf("small networks should store every peer, gets %v %v", keep, err)
+	}
+
+	service, _ = initTest("2", peers)
+
+	// madrid is the only peer of the "e" subtree
+	keep, err = service.ShouldStore(self, newPeer("http://lisbon.com", -9.1393, 38.7223))
+	if err != nil || !keep {
+		t.Errorf("expect a subtree with room to be stored, gets %v %v", keep, err)
+	}
+
+	service, repo := initTest("2", peers)
+	repo.Peers = append(repo.Peers, newPeer("http://berlin.com", 13.4050, 52.5200))
+	keep, err = service.ShouldStore(self, newPeer("http://amsterdam.com", 4.9041, 52.3676))
+	if err != nil || keep {
+		t.Errorf("expect a full subtree to not store the peer, gets %v %v", keep, err)
+	}
+}
+
+func TestClosestPeers(t *testing.T) {
+	service, _ := initTest("2", testPeers())
+
+	target := utils.Geohash(models.GeoCoords{Long: -58.39, Lat: -34.59}, utils.GEOHASH_PRECISION)
+	peers, err := service.ClosestPeers(target, 3)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(peers) != 3 {
+		t.Fatalf("expect 3 peers but gets %d", len(peers))
+	}
+	if peers[0].Url != "http://recoleta.com" {
+		t.Errorf("expect recoleta to be the closest peer but gets %v", peers[0].Url)
+	}
+	for _, peer := range peers {
+		if !strings.HasPrefix(peer.Geohash, target[:3]) {
+			t.Errorf("expect %v to be in the target area", peer.Url)
+		}
+	}
+}
+
+func TestBroadcastTargets(t *testing.T) {
+	peers := testPeers()
+	service, _ := initTest("2", peers)
+
+	byUrl := map[string]models.Peer{}
+	for _, peer := range peers {
+		byUrl[peer.Url] = peer
+	}
+
+	received := map[string]int{}
+	type delivery struct {
+		url   string
+		scope string
+	}
+	queue := []delivery{{peers[0].Url, ""}}
+	received[peers[0].Url]++
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		targets, err := service.BroadcastTargets(byUrl[current.url], current.scope)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		for url, scope := range targets {
+			if !strings.HasPrefix(byUrl[url].Geohash, strings.TrimSuffix(scope, TERMINAL_SCOPE)) {
+				t.Errorf("%v is not inside its scope %v", url, scope)
+			}
+			received[url]++
+			queue = append(queue, delivery{url, scope})
+		}
+	}
+
+	for _, peer := range peers {
+		if received[peer.Url] != 1 {
+			t.Errorf("expect %v to receive the broadcast once but receives it %d times", peer.Url, received[peer.Url])
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	self := newPeer("http://tokyo.com", 139.6917, 35.6895)
+	madrid := newPeer("http://madrid.com", -3.7038, 40.4168)
+	palermo := newPeer("http://palermo.com", -58.4246, -34.5875)
+	service, _ := initTest("0", []models.Peer{self, madrid})
+
+	palermoJson, _ := json.Marshal([]models.Peer{palermo})
+	httpmock.RegisterResponder("GET", "http://madrid.com/peer/overlay/closest",
+		httpmock.NewStringResponder(200, string(palermoJson)))
+	httpmock.RegisterResponder("GET", "http://palermo.com/peer/overlay/closest",
+		httpmock.NewStringResponder(200, string(palermoJson)))
+
+	peers, err := service.Lookup(self, palermo.Geohash, 2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(peers) == 0 || peers[0].Url != palermo.Url {
+		t.Errorf("expect the lookup to find %v but gets %v", palermo.Url, peers)
+	}
+
+	calls := httpmock.GetCallCountInfo()
+	if calls[fmt.Sprintf("GET %s/peer/overlay/closest", palermo.Url)] != 1 {
+		t.Errorf("expect the closest peer to be queried once, calls: %v", calls)
+	}
+}
+
+func TestAreaScopes(t *testing.T) {
+	service, _ := initTest("0", nil)
+	center := models.GeoCoords{Long: -58.3816, Lat: -34.6037}
+
+	scopes := service.AreaScopes(center, 2)
+	if len(scopes) == 0 {
+		t.Fatal("expect at least one scope")
+	}
+	for _, scope := range scopes {
+		width, height := utils.GeohashCellSize(len(scope), center.Lat)
+		if width < 4 || height < 4 {
+			t.Errorf("scope %v is smaller than the area", scope)
+		}
+	}
+
+	centerHash := utils.Geohash(center, utils.GEOHASH_PRECISION)
+	var containsCenter bool
+	for _, scope := range scopes {
+		containsCenter = containsCenter || strings.HasPrefix(centerHash, scope)
+	}
+	if !containsCenter {
+		t.Errorf("expect a scope to contain the center, gets %v", scopes)
+	}
+}
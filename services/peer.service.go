@@ -5,18 +5,25 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"net/url"
 	"os"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/nicodeheza/peersEat/constants"
 	"github.com/nicodeheza/peersEat/events"
 	"github.com/nicodeheza/peersEat/models"
 	"github.com/nicodeheza/peersEat/repositories"
 	"github.com/nicodeheza/peersEat/services/geo"
+	"github.com/nicodeheza/peersEat/services/overlay"
 	"github.com/nicodeheza/peersEat/types"
+	"github.com/nicodeheza/peersEat/utils"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -31,6 +38,14 @@ type PeerServiceI interface {
 	GetInDeliveryAreaPeers(peer models.Peer) ([]models.Peer, error)
 	GetNewDeliveryArea(peerCenter, restaurantCoord models.GeoCoords, restaurantDeliveryRadius float64) float64
 	UpdateDeliveryArea(peer models.Peer, newDeliveryRadius float64) error
+	UpdateInfluenceArea(peer models.Peer, newInfluenceRadius float64) error
+	UpdateInfluenceZone(peer models.Peer, newInfluenceZone *models.GeoZone) error
+	GetNewDeliveryZone() (*models.GeoZone, error)
+	UpdateDeliveryZone(peer models.Peer, newDeliveryZone *models.GeoZone) error
+	RecomputeDeliveryArea() error
+	FindPeersServing(geoPoint models.GeoCoords, area string, follow bool) (types.ServingResponse, error)
+	ClosestPeers(geohash string, limit int) ([]models.Peer, error)
+	PropagateRestaurantStatus(restaurant models.Restaurant) error
 }
 
 type PeerService struct {
@@ -38,10 +53,11 @@ type PeerService struct {
 	geo            geo.GeoServiceI
 	restaurantRepo repositories.RestaurantRepositoryI
 	events         events.EventLoopI
+	overlay        overlay.OverlayServiceI
 }
 
-func NewPeerService(repository repositories.PeerRepositoryI, geo geo.GeoServiceI, restaurantRepo repositories.RestaurantRepositoryI, events events.EventLoopI) *PeerService {
-	return &PeerService{repository, geo, restaurantRepo, events}
+func NewPeerService(repository repositories.PeerRepositoryI, geo geo.GeoServiceI, restaurantRepo repositories.RestaurantRepositoryI, events events.EventLoopI, overlay overlay.OverlayServiceI) *PeerService {
+	return &PeerService{repository, geo, restaurantRepo, events, overlay}
 }
 
 func (p *PeerService) EnqueueEvent(event types.Event) {
@@ -55,14 +71,47 @@ func (p *PeerService) InitPeer() {
 	long, _ := strconv.ParseFloat(centerSlice[0], 64)
 	lat, _ := strconv.ParseFloat(centerSlice[1], 64)
 
+	influenceRadius := constants.INFLUENCE_RADIUS
+	if influenceSrt := os.Getenv("INFLUENCE_RADIUS"); influenceSrt != "" {
+		radius, err := strconv.ParseFloat(influenceSrt, 64)
+		if err != nil || radius <= 0 {
+			log.Fatal("invalid INFLUENCE_RADIUS")
+		}
+		influenceRadius = radius
+	}
+
 	selfPeer := models.Peer{
-		Url:     os.Getenv("HOST"),
-		Center:  models.GeoCoords{Long: long, Lat: lat},
-		City:    os.Getenv("CITY"),
-		Country: os.Getenv("COUNTRY"),
+		Url:             os.Getenv("HOST"),
+		Center:          models.GeoCoords{Long: long, Lat: lat},
+		City:            os.Getenv("CITY"),
+		Country:         os.Getenv("COUNTRY"),
+		InfluenceRadius: influenceRadius,
+		Currency:        models.DefaultCurrency(),
+	}
+
+	_, err := p.repo.Insert(selfPeer)
+	if err != nil {
+		// the peer was already installed, apply the configured radius if it changed
+		storedPeer, err := p.repo.GetSelf()
+		if err == nil && p.geo.GetInfluenceRadius(storedPeer) != influenceRadius {
+			err = p.UpdateInfluenceArea(storedPeer, influenceRadius)
+			if err != nil {
+				log.Println(err.Error())
+			}
+		}
+		if err == nil && storedPeer.Currency != selfPeer.Currency {
+			storedPeer.Currency = selfPeer.Currency
+			err = p.repo.Update(storedPeer, []string{"currency"})
+			if err != nil {
+				log.Println(err.Error())
+			}
+		}
 	}
 
-	p.repo.Insert(selfPeer)
+	err = p.repo.SetMissingGeohashes()
+	if err != nil {
+		log.Println(err.Error())
+	}
 
 	initialPeer := os.Getenv("INITIAL_PEER")
 
@@ -120,12 +169,10 @@ func (p *PeerService) InitPeer() {
 			}
 		}
 
-		sendTo, err := p.repo.GetAllUrls([]string{selfPeer.Url, initialPeer})
-		if err != nil {
-			log.Fatal("fail to get all peers")
-		}
-
-		event := events.NewAddPeerEvent(selfPeer, sendTo)
+		// the initial peer may only know a part of the network, so it broadcasts
+		// the new peer instead of using this peer list
+		event := events.NewAddPeerEvent(selfPeer, nil)
+		event.Broadcast = true
 
 		postBody, err := json.Marshal(event)
 		if err != nil {
@@ -154,6 +201,23 @@ func (p *PeerService) GetPeersUrlById(ids []primitive.ObjectID) ([]string, error
 	return p.repo.FindUrlsByIds(ids)
 }
 
+// in area peers keep the status so they can leave out restaurants that stopped
+// being active from federated searches
+func (p *PeerService) PropagateRestaurantStatus(restaurant models.Restaurant) error {
+	self, err := p.repo.GetSelf()
+	if err != nil {
+		return err
+	}
+	urls, err := p.GetPeersUrlById(self.InAreaPeers)
+	if err != nil {
+		return err
+	}
+
+	status := models.RemoteRestaurantStatus{RestaurantId: restaurant.Id, PeerUrl: self.Url, Status: restaurant.CurrentStatus(), UpdatedAt: time.Now()}
+	p.events.PropagateEvent(events.NewRestaurantStatusEvent(status, urls))
+	return nil
+}
+
 // update
 func (p *PeerService) HaveRestaurant(restaurantQuery map[string]interface{}) (bool, error) {
 	_, err := p.restaurantRepo.FindOne(restaurantQuery)
@@ -214,10 +278,6 @@ func (p *PeerService) GetInDeliveryAreaPeers(peer models.Peer) ([]models.Peer, e
 func (p *PeerService) UpdateDeliveryArea(peer models.Peer, newDeliveryRadius float64) error {
 	oldRadius := peer.DeliveryRadius
 	peer.DeliveryRadius = newDeliveryRadius
-	err := p.repo.Update(peer, []string{"deliveryRadius"})
-	if err != nil {
-		return err
-	}
 
 	peersToCheck := []models.Peer{}
 
@@ -246,19 +306,308 @@ func (p *PeerService) UpdateDeliveryArea(peer models.Peer, newDeliveryRadius flo
 		}
 	}
 
+	// a single write, a zero radius or an empty list have to be stored too
+	peer.InDeliveryAreaPeers = newInAreaPeersIds
+	_, err := p.repo.FindByUrlAndUpdate(peer.Url, map[string]interface{}{
+		"delivery_radius":        peer.DeliveryRadius,
+		"in_area_delivery_peers": peer.InDeliveryAreaPeers,
+	})
+	if err != nil {
+		return err
+	}
+
+	event := events.NewUpdateDeliveryAreaEvent(peer, nil)
+	return p.propagate(event, peer, math.Max(oldRadius, newDeliveryRadius))
+}
+
+func (p *PeerService) UpdateInfluenceArea(peer models.Peer, newInfluenceRadius float64) error {
+	oldRadius := p.influenceExtent(peer)
+	peer.InfluenceRadius = newInfluenceRadius
+	return p.propagateInfluenceArea(peer, math.Max(oldRadius, p.influenceExtent(peer)))
+}
+
+func (p *PeerService) UpdateInfluenceZone(peer models.Peer, newInfluenceZone *models.GeoZone) error {
+	oldRadius := p.influenceExtent(peer)
+	peer.InfluenceZone = newInfluenceZone
+	return p.propagateInfluenceArea(peer, math.Max(oldRadius, p.influenceExtent(peer)))
+}
+
+func (p *PeerService) propagateInfluenceArea(peer models.Peer, radius float64) error {
+	peersToCheck, err := p.repo.GetAll([]string{peer.Url})
+	if err != nil {
+		return err
+	}
+
+	newInAreaPeersIds := []primitive.ObjectID{}
+
+	for _, foragePeer := range peersToCheck {
+		if p.geo.AreInfluenceAreasOverlaying(peer, foragePeer) {
+			newInAreaPeersIds = append(newInAreaPeersIds, foragePeer.Id)
+		}
+	}
+
+	peer.InAreaPeers = newInAreaPeersIds
+	_, err = p.repo.FindByUrlAndUpdate(peer.Url, map[string]interface{}{
+		"influence_radius": peer.InfluenceRadius,
+		"influence_zone":   peer.InfluenceZone,
+		"in_area_peers":    peer.InAreaPeers,
+	})
+	if err != nil {
+		return err
+	}
+
+	event := events.NewUpdateInfluenceAreaEvent(peer, nil)
+	return p.propagate(event, peer, radius)
+}
+
+// restaurants without a zone are approximated by their delivery circle, so the
+// peer zone covers every restaurant once any of them defines a polygon
+func (p *PeerService) GetNewDeliveryZone() (*models.GeoZone, error) {
+	restaurants, err := p.restaurantRepo.FindMany(map[string]interface{}{})
+	if err != nil {
+		return nil, err
+	}
+
+	var haveZone bool
+	for _, restaurant := range restaurants {
+		if restaurant.DeliveryZone != nil {
+			haveZone = true
+			break
+		}
+	}
+	if !haveZone {
+		return nil, nil
+	}
+
+	zone := models.NewGeoZone()
+	for _, restaurant := range restaurants {
+		if restaurant.DeliveryZone != nil {
+			zone.Coordinates = append(zone.Coordinates, restaurant.DeliveryZone.Coordinates...)
+			continue
+		}
+		if restaurant.DeliveryRadius > 0 {
+			circle := p.geo.CircleToZone(restaurant.Coord, restaurant.DeliveryRadius)
+			zone.Coordinates = append(zone.Coordinates, circle.Coordinates...)
+		}
+	}
+
+	return zone, nil
+}
+
+func (p *PeerService) UpdateDeliveryZone(peer models.Peer, newDeliveryZone *models.GeoZone) error {
+	radius := math.Max(peer.DeliveryRadius, p.zoneExtent(peer.Center, peer.DeliveryZone))
+	peer.DeliveryZone = newDeliveryZone
+	radius = math.Max(radius, p.zoneExtent(peer.Center, newDeliveryZone))
+
+	peersToCheck, err := p.repo.GetAll([]string{peer.Url})
+	if err != nil {
+		return err
+	}
+
+	newInAreaPeersIds := []primitive.ObjectID{}
+
+	for _, foragePeer := range peersToCheck {
+		if p.geo.IsInDeliveryArea(peer, foragePeer) {
+			newInAreaPeersIds = append(newInAreaPeersIds, foragePeer.Id)
+		}
+	}
+
 	peer.InDeliveryAreaPeers = newInAreaPeersIds
-	err = p.repo.Update(peer, []string{"InDeliveryAreaPeers"})
+	_, err = p.repo.FindByUrlAndUpdate(peer.Url, map[string]interface{}{
+		"delivery_zone":          peer.DeliveryZone,
+		"in_area_delivery_peers": peer.InDeliveryAreaPeers,
+	})
 	if err != nil {
 		return err
 	}
 
-	urls, err := p.repo.GetAllUrls([]string{peer.Url})
+	event := events.NewUpdateDeliveryAreaEvent(peer, nil)
+	return p.propagate(event, peer, radius)
+}
+
+// small networks send the event to every known peer, with the overlay it is
+// broadcast to the cells around the peer. Neighbors are reached while their
+// areas are not bigger than the updated one.
+func (p *PeerService) propagate(event types.Event, peer models.Peer, radius float64) error {
+	if !p.overlay.IsEnabled() {
+		urls, err := p.repo.GetAllUrls([]string{peer.Url})
+		if err != nil {
+			return err
+		}
+		event.SendTo = urls
+		p.events.PropagateEvent(event)
+		return nil
+	}
+
+	for _, scope := range p.overlay.AreaScopes(peer.Center, 2*radius) {
+		scopedEvent := event
+		scopedEvent.Broadcast = true
+		scopedEvent.Scope = scope
+		p.events.PropagateEvent(scopedEvent)
+	}
+	return nil
+}
+
+func (p *PeerService) influenceExtent(peer models.Peer) float64 {
+	return math.Max(p.geo.GetInfluenceRadius(peer), p.zoneExtent(peer.Center, peer.InfluenceZone))
+}
+
+// distance from the center to the farthest vertex of the zone
+func (p *PeerService) zoneExtent(center models.GeoCoords, zone *models.GeoZone) float64 {
+	var extent float64
+	if zone == nil {
+		return extent
+	}
+	for _, polygon := range zone.Coordinates {
+		for _, ring := range polygon {
+			for _, position := range ring {
+				extent = math.Max(extent, math.Abs(p.geo.GetCoordDistance(center, position.ToCoords())))
+			}
+		}
+	}
+	return extent
+}
+
+// rebuilds the delivery area from every restaurant, the radius is the
+// furthest reach of all of them and it can shrink when restaurants leave the peer
+func (p *PeerService) RecomputeDeliveryArea() error {
+	selfPeer, err := p.GetLocalPeer()
 	if err != nil {
 		return err
 	}
 
-	event := events.NewUpdateDeliveryAreaEvent(peer, urls)
-	p.events.PropagateEvent(event)
+	restaurants, err := p.restaurantRepo.FindMany(map[string]interface{}{})
+	if err != nil {
+		return err
+	}
+
+	var newDeliveryArea float64
+	for _, restaurant := range restaurants {
+		newDeliveryArea = math.Max(newDeliveryArea, p.GetNewDeliveryArea(selfPeer.Center, restaurant.Coord, restaurant.DeliveryRadius))
+	}
+
+	if newDeliveryArea != selfPeer.DeliveryRadius {
+		err = p.UpdateDeliveryArea(selfPeer, newDeliveryArea)
+		if err != nil {
+			return err
+		}
+	}
+
+	newDeliveryZone, err := p.GetNewDeliveryZone()
+	if err != nil {
+		return err
+	}
+
+	if !reflect.DeepEqual(selfPeer.DeliveryZone, newDeliveryZone) {
+		return p.UpdateDeliveryZone(selfPeer, newDeliveryZone)
+	}
 
 	return nil
 }
+
+func (p *PeerService) FindPeersServing(geoPoint models.GeoCoords, area string, follow bool) (types.ServingResponse, error) {
+	self, err := p.GetLocalPeer()
+	if err != nil {
+		return types.ServingResponse{}, err
+	}
+
+	peers, err := p.servingCandidates(self, geoPoint)
+	if err != nil {
+		return types.ServingResponse{}, err
+	}
+
+	serving := []types.ServingPeer{}
+	for _, peer := range peers {
+		var isServing bool
+		if area == "influence" {
+			isServing = p.geo.IsInInfluenceArea(peer, geoPoint)
+		} else {
+			isServing = p.geo.IsInPeerDeliveryArea(peer, geoPoint)
+		}
+		if !isServing {
+			continue
+		}
+
+		serving = append(serving, types.ServingPeer{
+			Url:      peer.Url,
+			City:     peer.City,
+			Country:  peer.Country,
+			Center:   peer.Center,
+			Distance: math.Abs(p.geo.GetCoordDistance(peer.Center, geoPoint)),
+		})
+	}
+
+	sort.SliceStable(serving, func(i, j int) bool {
+		return serving[i].Distance < serving[j].Distance
+	})
+
+	response := types.ServingResponse{Peers: serving, Source: self.Url}
+
+	// the closest serving peer has the freshest data about its own area
+	if !follow || len(serving) == 0 || serving[0].Url == self.Url {
+		return response, nil
+	}
+
+	remote, err := p.getRemotePeersServing(serving[0].Url, geoPoint, area)
+	if err != nil {
+		log.Printf("failed to follow %v: %v\n", serving[0].Url, err.Error())
+		return response, nil
+	}
+
+	return remote, nil
+}
+
+// with the overlay only the peers around the point are known after a lookup
+func (p *PeerService) servingCandidates(self models.Peer, geoPoint models.GeoCoords) ([]models.Peer, error) {
+	if !p.overlay.IsEnabled() {
+		return p.repo.GetAll([]string{})
+	}
+
+	peers, err := p.overlay.Lookup(self, utils.Geohash(geoPoint, utils.GEOHASH_PRECISION), constants.OVERLAY_LOOKUP_SIZE)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, peer := range peers {
+		if peer.Url == self.Url {
+			return peers, nil
+		}
+	}
+	return append(peers, self), nil
+}
+
+func (p *PeerService) ClosestPeers(geohash string, limit int) ([]models.Peer, error) {
+	return p.overlay.ClosestPeers(geohash, limit)
+}
+
+func (p *PeerService) getRemotePeersServing(peerUrl string, geoPoint models.GeoCoords, area string) (types.ServingResponse, error) {
+	url, err := url.Parse(peerUrl + "/peer/serving")
+	if err != nil {
+		return types.ServingResponse{}, err
+	}
+	query := url.Query()
+	query.Add("long", strconv.FormatFloat(geoPoint.Long, 'f', -1, 64))
+	query.Add("lat", strconv.FormatFloat(geoPoint.Lat, 'f', -1, 64))
+	if area != "" {
+		query.Add("area", area)
+	}
+	url.RawQuery = query.Encode()
+
+	resp, err := http.Get(url.String())
+	if err != nil {
+		return types.ServingResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return types.ServingResponse{}, fmt.Errorf("peer responded with status %d", resp.StatusCode)
+	}
+
+	response := types.ServingResponse{}
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		return types.ServingResponse{}, err
+	}
+
+	return response, nil
+}
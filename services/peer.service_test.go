@@ -15,10 +15,16 @@ import (
 	"github.com/nicodeheza/peersEat/mocks"
 	"github.com/nicodeheza/peersEat/models"
 	"github.com/nicodeheza/peersEat/types"
+	"github.com/nicodeheza/peersEat/utils"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 func initTest() (*PeerService, *mocks.PeerRepositoryMock) {
+	service, repo, _ := initTestWithRestaurants()
+	return service, repo
+}
+
+func initTestWithRestaurants() (*PeerService, *mocks.PeerRepositoryMock, *mocks.RestaurantRepositoryMock) {
 	err := godotenv.Load("../.env")
 	if err != nil {
 		log.Fatal("Error loading .env file")
@@ -28,7 +34,8 @@ func initTest() (*PeerService, *mocks.PeerRepositoryMock) {
 	geo := mocks.NewGeo()
 	restaurantRepository := mocks.NewRestaurantRepositoryMock()
 	eventsLoop := mocks.NewEventLoopMock()
-	return NewPeerService(repo, geo, restaurantRepository, eventsLoop), repo
+	overlay := mocks.NewOverlayServiceMock()
+	return NewPeerService(repo, geo, restaurantRepository, eventsLoop, overlay), repo, restaurantRepository
 }
 
 func TestInitPeer(t *testing.T) {
@@ -137,3 +144,211 @@ func TestPeerHaveRestaurant(t *testing.T) {
 		t.Errorf("%s", res.Err.Error())
 	}
 }
+
+func TestUpdateInfluenceArea(t *testing.T) {
+	service, repo := initTest()
+	defer repo.ClearCalls()
+
+	peer, _ := repo.GetSelf()
+	peer.DeliveryRadius = 2
+
+	err := service.UpdateInfluenceArea(peer, 5)
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if !reflect.DeepEqual(repo.GetAllCalls[0], []string{peer.Url}) {
+		t.Errorf("expecting GetAll arg: %v\ngot: %v\n", []string{peer.Url}, repo.GetAllCalls[0])
+	}
+
+	update := repo.FindByUrlAndUpdateCalls[0]
+	if update.Url != peer.Url {
+		t.Errorf("expecting update url: %s\ngot: %s\n", peer.Url, update.Url)
+	}
+	if update.Updates["influence_radius"] != 5.0 {
+		t.Errorf("expecting influence_radius: 5\ngot: %v\n", update.Updates["influence_radius"])
+	}
+	inAreaPeers := update.Updates["in_area_peers"].([]primitive.ObjectID)
+	if len(inAreaPeers) != 3 {
+		t.Errorf("expecting 3 in area peers but got %d", len(inAreaPeers))
+	}
+}
+
+func TestUpdateDeliveryArea(t *testing.T) {
+	service, repo := initTest()
+	defer repo.ClearCalls()
+
+	peer, _ := repo.GetSelf()
+	peer.DeliveryRadius = 2
+
+	err := service.UpdateDeliveryArea(peer, 5)
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if len(repo.FindByUrlAndUpdateCalls) != 1 {
+		t.Fatalf("expecting a single update but got %d", len(repo.FindByUrlAndUpdateCalls))
+	}
+	update := repo.FindByUrlAndUpdateCalls[0]
+	if update.Url != peer.Url {
+		t.Errorf("expecting update url: %s\ngot: %s\n", peer.Url, update.Url)
+	}
+	if update.Updates["delivery_radius"] != 5.0 {
+		t.Errorf("expecting delivery_radius: 5\ngot: %v\n", update.Updates["delivery_radius"])
+	}
+	if _, ok := update.Updates["in_area_delivery_peers"].([]primitive.ObjectID); !ok {
+		t.Errorf("expecting in_area_delivery_peers to be updated, got: %v", update.Updates)
+	}
+}
+
+func TestRecomputeDeliveryArea(t *testing.T) {
+	service, repo, restaurantRepo := initTestWithRestaurants()
+	defer repo.ClearCalls()
+
+	// the last restaurant reaches less than the first one
+	restaurantRepo.Restaurants = []models.Restaurant{
+		{Coord: models.GeoCoords{Long: 1, Lat: 1}, DeliveryRadius: 70},
+		{Coord: models.GeoCoords{Long: 2, Lat: 2}, DeliveryRadius: 40},
+	}
+
+	err := service.RecomputeDeliveryArea()
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	peer, _ := repo.GetSelf()
+	expected := service.GetNewDeliveryArea(peer.Center, restaurantRepo.Restaurants[0].Coord, 70)
+	if len(repo.FindByUrlAndUpdateCalls) == 0 {
+		t.Fatal("expecting the delivery area to be updated")
+	}
+	if repo.FindByUrlAndUpdateCalls[0].Updates["delivery_radius"] != expected {
+		t.Errorf("expecting delivery_radius: %v\ngot: %v\n", expected, repo.FindByUrlAndUpdateCalls[0].Updates["delivery_radius"])
+	}
+}
+
+func TestGetNewDeliveryZone(t *testing.T) {
+	service, _, restaurantRepo := initTestWithRestaurants()
+
+	restaurantRepo.Restaurants = []models.Restaurant{
+		{Coord: models.GeoCoords{Long: 1, Lat: 1}, DeliveryRadius: 2},
+	}
+	zone, err := service.GetNewDeliveryZone()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if zone != nil {
+		t.Errorf("expecting no zone when restaurants only have a delivery radius, got: %v", zone)
+	}
+
+	restaurantZone := models.NewGeoZone(models.GeoPolygon{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}})
+	restaurantRepo.Restaurants = append(restaurantRepo.Restaurants,
+		models.Restaurant{Coord: models.GeoCoords{Long: 2, Lat: 2}, DeliveryZone: restaurantZone},
+		models.Restaurant{Coord: models.GeoCoords{Long: 3, Lat: 3}},
+	)
+	zone, err = service.GetNewDeliveryZone()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if zone == nil || len(zone.Coordinates) != 2 {
+		t.Fatalf("expecting a zone with 2 polygons, got: %v", zone)
+	}
+	if !reflect.DeepEqual(zone.Coordinates[1], restaurantZone.Coordinates[0]) {
+		t.Errorf("expecting restaurant polygon: %v\ngot: %v\n", restaurantZone.Coordinates[0], zone.Coordinates[1])
+	}
+}
+
+func TestFindPeersServing(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	service, repo := initTest()
+	defer repo.ClearCalls()
+
+	result, err := service.FindPeersServing(models.GeoCoords{Long: 1, Lat: 1}, "delivery", false)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(result.Peers) != 3 {
+		t.Errorf("expecting 3 serving peers but got %d", len(result.Peers))
+	}
+	if result.Source != os.Getenv("HOST") {
+		t.Errorf("expecting source %s but got %s", os.Getenv("HOST"), result.Source)
+	}
+
+	result, err = service.FindPeersServing(models.GeoCoords{Long: 5, Lat: 1}, "delivery", false)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(result.Peers) != 0 {
+		t.Errorf("expecting no serving peers but got %d", len(result.Peers))
+	}
+
+	httpmock.RegisterResponder("GET", "http://tests.com/peer/serving",
+		httpmock.NewStringResponder(200, `{"Peers":[{"Url":"http://fresh.com","Distance":0.5}],"Source":"http://tests.com"}`))
+
+	result, err = service.FindPeersServing(models.GeoCoords{Long: 1, Lat: 1}, "delivery", true)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if result.Source != "http://tests.com" || len(result.Peers) != 1 || result.Peers[0].Url != "http://fresh.com" {
+		t.Errorf("expecting response from the closest serving peer but got %v", result)
+	}
+}
+
+func TestPropagateWithOverlay(t *testing.T) {
+	service, repo := initTest()
+	defer repo.ClearCalls()
+	eventsLoop := mocks.NewEventLoopMock()
+	service.events = eventsLoop
+
+	peer, _ := repo.GetSelf()
+
+	err := service.UpdateInfluenceArea(peer, 3)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(repo.GetAllUrlsCalls) != 1 || len(eventsLoop.PropagateCalls) != 1 {
+		t.Fatalf("expect the event to be sent to all the peers, propagated: %v", eventsLoop.PropagateCalls)
+	}
+	if eventsLoop.PropagateCalls[0].Broadcast || len(eventsLoop.PropagateCalls[0].SendTo) != 4 {
+		t.Errorf("expect a full table event but gets %v", eventsLoop.PropagateCalls[0])
+	}
+
+	overlay := mocks.NewOverlayServiceMock()
+	overlay.Enabled = true
+	service.overlay = overlay
+	repo.ClearCalls()
+	eventsLoop.PropagateCalls = nil
+
+	err = service.UpdateInfluenceArea(peer, 3)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(repo.GetAllUrlsCalls) != 0 || len(eventsLoop.PropagateCalls) != 1 {
+		t.Fatalf("expect the event to be broadcast, propagated: %v", eventsLoop.PropagateCalls)
+	}
+	event := eventsLoop.PropagateCalls[0]
+	if !event.Broadcast || event.Scope != utils.Geohash(peer.Center, 4) || len(event.SendTo) != 0 {
+		t.Errorf("expect a scoped broadcast but gets %v", event)
+	}
+}
+
+func TestFindPeersServingWithOverlay(t *testing.T) {
+	service, repo := initTest()
+	defer repo.ClearCalls()
+
+	overlay := mocks.NewOverlayServiceMock()
+	overlay.Enabled = true
+	overlay.Peers = []models.Peer{{Url: "http://near.com", DeliveryRadius: 3}}
+	service.overlay = overlay
+
+	result, err := service.FindPeersServing(models.GeoCoords{Long: 1, Lat: 1}, "delivery", false)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(repo.GetAllCalls) != 0 || len(overlay.LookupCalls) != 1 {
+		t.Errorf("expect the overlay to be used instead of the full table")
+	}
+	if len(result.Peers) != 2 || result.Peers[0].Url != "http://near.com" && result.Peers[1].Url != "http://near.com" {
+		t.Errorf("expect the looked up peer and self to serve the point but gets %v", result.Peers)
+	}
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nicodeheza/peersEat/constants"
+	"github.com/nicodeheza/peersEat/repositories"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type PresenceServiceI interface {
+	Start()
+	Connect(id primitive.ObjectID) error
+	Disconnect(id primitive.ObjectID) error
+	Heartbeat(id primitive.ObjectID) error
+	HeartbeatInterval() time.Duration
+}
+
+type presence struct {
+	connections int
+	connected   bool
+	lastSeen    time.Time
+}
+
+// PresenceService tracks the restaurant apps listening to this peer, they hold
+// a stream open or post heartbeats. Restaurants that stay disconnected longer
+// than the grace period are paused until they come back
+type PresenceService struct {
+	repo        repositories.RestaurantRepositoryI
+	heartbeat   time.Duration
+	timeout     time.Duration
+	gracePeriod time.Duration
+	mutex       sync.Mutex
+	restaurants map[primitive.ObjectID]*presence
+}
+
+func NewPresenceService(repo repositories.RestaurantRepositoryI) *PresenceService {
+	heartbeat, _ := time.ParseDuration(constants.PRESENCE_HEARTBEAT)
+	timeout, _ := time.ParseDuration(constants.PRESENCE_TIMEOUT)
+	gracePeriod, _ := time.ParseDuration(constants.PRESENCE_GRACE_PERIOD)
+	if envGracePeriod, err := time.ParseDuration(os.Getenv("PRESENCE_GRACE_PERIOD")); err == nil && envGracePeriod > 0 {
+		gracePeriod = envGracePeriod
+	}
+
+	return &PresenceService{repo: repo, heartbeat: heartbeat, timeout: timeout, gracePeriod: gracePeriod, restaurants: map[primitive.ObjectID]*presence{}}
+}
+
+func (s *PresenceService) HeartbeatInterval() time.Duration {
+	return s.heartbeat
+}
+
+// connections don't survive a restart, the restaurants stored as connected get
+// the grace period from now to connect again
+func (s *PresenceService) Start() {
+	restaurants, err := s.repo.FindMany(map[string]interface{}{"isConnected": true})
+	if err != nil {
+		log.Println(err.Error())
+	}
+	for _, restaurant := range restaurants {
+		if !restaurant.IsConnected {
+			continue
+		}
+		if err := s.setDisconnected(restaurant.Id, time.Now()); err != nil {
+			log.Println(err.Error())
+		}
+	}
+
+	go func() {
+		for {
+			time.Sleep(s.heartbeat)
+			if err := s.sweep(time.Now()); err != nil {
+				log.Println(err.Error())
+			}
+		}
+	}()
+}
+
+func (s *PresenceService) get(id primitive.ObjectID) *presence {
+	state, ok := s.restaurants[id]
+	if !ok {
+		state = &presence{}
+		s.restaurants[id] = state
+	}
+	return state
+}
+
+func (s *PresenceService) Connect(id primitive.ObjectID) error {
+	s.mutex.Lock()
+	state := s.get(id)
+	state.connections++
+	state.lastSeen = time.Now()
+	wasConnected := state.connected
+	state.connected = true
+	s.mutex.Unlock()
+
+	if wasConnected {
+		return nil
+	}
+	return s.setConnected(id, state.lastSeen)
+}
+
+func (s *PresenceService) Disconnect(id primitive.ObjectID) error {
+	s.mutex.Lock()
+	state := s.get(id)
+	if state.connections > 0 {
+		state.connections--
+	}
+	state.lastSeen = time.Now()
+	disconnected := state.connections == 0 && state.connected
+	if disconnected {
+		state.connected = false
+	}
+	s.mutex.Unlock()
+
+	if !disconnected {
+		return nil
+	}
+	return s.setDisconnected(id, state.lastSeen)
+}
+
+func (s *PresenceService) Heartbeat(id primitive.ObjectID) error {
+	s.mutex.Lock()
+	state := s.get(id)
+	state.lastSeen = time.Now()
+	wasConnected := state.connected
+	state.connected = true
+	s.mutex.Unlock()
+
+	if wasConnected {
+		return nil
+	}
+	return s.setConnected(id, state.lastSeen)
+}
+
+// coming back resumes a paused restaurant
+func (s *PresenceService) setConnected(id primitive.ObjectID, at time.Time) error {
+	return s.repo.Update(id, map[string]interface{}{"isConnected": true, "isPaused": false, "lastSeen": at})
+}
+
+func (s *PresenceService) setDisconnected(id primitive.ObjectID, lastSeen time.Time) error {
+	return s.repo.Update(id, map[string]interface{}{"isConnected": false, "lastSeen": lastSeen})
+}
+
+// disconnects the restaurants that stopped sending heartbeats and pauses the ones
+// disconnected for longer than the grace period. Restaurants never seen don't
+// use an app and are not paused
+func (s *PresenceService) sweep(now time.Time) error {
+	s.mutex.Lock()
+	silent := map[primitive.ObjectID]time.Time{}
+	for id, state := range s.restaurants {
+		if state.connected && state.connections == 0 && now.Sub(state.lastSeen) > s.timeout {
+			state.connected = false
+			silent[id] = state.lastSeen
+		}
+	}
+	s.mutex.Unlock()
+
+	for id, lastSeen := range silent {
+		if err := s.setDisconnected(id, lastSeen); err != nil {
+			return err
+		}
+	}
+
+	cutoff := now.Add(-s.gracePeriod)
+	restaurants, err := s.repo.FindMany(map[string]interface{}{
+		"isConnected": map[string]interface{}{"$ne": true},
+		"isPaused":    map[string]interface{}{"$ne": true},
+		"lastSeen":    map[string]interface{}{"$lt": cutoff},
+	})
+	if err != nil {
+		return err
+	}
+	for _, restaurant := range restaurants {
+		if restaurant.IsConnected || restaurant.IsPaused || restaurant.LastSeen == nil || !restaurant.LastSeen.Before(cutoff) {
+			continue
+		}
+		if err := s.repo.Update(restaurant.Id, map[string]interface{}{"isPaused": true}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nicodeheza/peersEat/mocks"
+	"github.com/nicodeheza/peersEat/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestPresenceConnections(t *testing.T) {
+	repo := mocks.NewRestaurantRepositoryMock()
+	service := NewPresenceService(repo)
+	id := primitive.NewObjectID()
+
+	service.Connect(id)
+	service.Connect(id)
+	if len(repo.UpdateCalls) != 1 || repo.UpdateCalls[0].Updates["isConnected"] != true || repo.UpdateCalls[0].Updates["isPaused"] != false {
+		t.Fatalf("expecting a single connected update but got %v", repo.UpdateCalls)
+	}
+
+	service.Disconnect(id)
+	if len(repo.UpdateCalls) != 1 {
+		t.Errorf("expecting the restaurant to stay connected while a stream is open but got %v", repo.UpdateCalls)
+	}
+	service.Disconnect(id)
+	if len(repo.UpdateCalls) != 2 || repo.UpdateCalls[1].Updates["isConnected"] != false || repo.UpdateCalls[1].Updates["lastSeen"] == nil {
+		t.Errorf("expecting a disconnected update but got %v", repo.UpdateCalls)
+	}
+}
+
+func TestPresenceSweep(t *testing.T) {
+	repo := mocks.NewRestaurantRepositoryMock()
+	service := NewPresenceService(repo)
+	now := time.Now()
+
+	// sends heartbeats and stopped a minute ago
+	silentId := primitive.NewObjectID()
+	service.Heartbeat(silentId)
+	service.restaurants[silentId].lastSeen = now.Add(-time.Minute)
+
+	longGone := now.Add(-service.gracePeriod - time.Minute)
+	recent := now.Add(-time.Second)
+	gone := models.Restaurant{Id: primitive.NewObjectID(), LastSeen: &longGone}
+	justLeft := models.Restaurant{Id: primitive.NewObjectID(), LastSeen: &recent}
+	paused := models.Restaurant{Id: primitive.NewObjectID(), LastSeen: &longGone, IsPaused: true}
+	connected := models.Restaurant{Id: primitive.NewObjectID(), LastSeen: &longGone, IsConnected: true}
+	neverSeen := models.Restaurant{Id: primitive.NewObjectID()}
+	repo.Restaurants = []models.Restaurant{gone, justLeft, paused, connected, neverSeen}
+	repo.UpdateCalls = nil
+
+	if err := service.sweep(now); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []mocks.ExpectRestaurantUpdate{
+		{Id: silentId, Updates: map[string]interface{}{"isConnected": false, "lastSeen": now.Add(-time.Minute)}},
+		{Id: gone.Id, Updates: map[string]interface{}{"isPaused": true}},
+	}
+	if len(repo.UpdateCalls) != len(expected) {
+		t.Fatalf("expecting %v but got %v", expected, repo.UpdateCalls)
+	}
+	for i, call := range repo.UpdateCalls {
+		if call.Id != expected[i].Id || len(call.Updates) != len(expected[i].Updates) {
+			t.Errorf("expecting %v but got %v", expected[i], call)
+		}
+		for key, value := range expected[i].Updates {
+			if call.Updates[key] != value {
+				t.Errorf("expecting %v to be %v but got %v", key, value, call.Updates[key])
+			}
+		}
+	}
+
+	service.Heartbeat(gone.Id)
+	last := repo.UpdateCalls[len(repo.UpdateCalls)-1]
+	if last.Id != gone.Id || last.Updates["isPaused"] != false || last.Updates["isConnected"] != true {
+		t.Errorf("expecting the restaurant to resume when it comes back but got %v", last)
+	}
+}
@@ -0,0 +1,631 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/repositories"
+	"github.com/nicodeheza/peersEat/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrPromotionNotFound = errors.New("promotion not found")
+var ErrInvalidPromotion = errors.New("invalid promotion")
+var ErrPromotionCodeTaken = errors.New("the code is used by another promotion")
+var ErrPromotionUnavailable = errors.New("a promotion ran out while redeeming the cart, evaluate it again")
+var ErrInvalidCustomerToken = errors.New("invalid customer token")
+var ErrCustomerTokensDisabled = errors.New("customer tokens need a CUSTOMER_TOKEN_SECRET")
+
+// the scope is the restaurant managing the promotions, nil for the promotions of
+// the whole peer
+type PromotionServiceI interface {
+	Create(scope *primitive.ObjectID, data types.PromotionData) (models.Promotion, error)
+	List(scope *primitive.ObjectID) ([]models.Promotion, error)
+	Update(scope *primitive.ObjectID, id primitive.ObjectID, data types.PromotionData) (models.Promotion, error)
+	Delete(scope *primitive.ObjectID, id primitive.ObjectID) error
+	Evaluate(restaurantId primitive.ObjectID, cart types.Cart) (types.CartEvaluation, error)
+	Redeem(restaurantId primitive.ObjectID, cart types.Cart) (types.CartEvaluation, error)
+	IssueCustomerToken(customerId string) (string, error)
+}
+
+type PromotionService struct {
+	repo           repositories.PromotionRepositoryI
+	redemptionRepo repositories.RedemptionRepositoryI
+	restaurantRepo repositories.RestaurantRepositoryI
+	menuVersions   MenuVersionServiceI
+	delivery       DeliveryServiceI
+}
+
+func NewPromotionService(repo repositories.PromotionRepositoryI, redemptionRepo repositories.RedemptionRepositoryI, restaurantRepo repositories.RestaurantRepositoryI, menuVersions MenuVersionServiceI, delivery DeliveryServiceI) *PromotionService {
+	return &PromotionService{repo, redemptionRepo, restaurantRepo, menuVersions, delivery}
+}
+
+type promotionDiscount struct {
+	promotion models.Promotion
+	discount  models.Money
+}
+
+func inScope(promotion models.Promotion, scope *primitive.ObjectID) bool {
+	if promotion.RestaurantId == nil || scope == nil {
+		return promotion.RestaurantId == scope
+	}
+	return *promotion.RestaurantId == *scope
+}
+
+func (s *PromotionService) scopeCurrency(scope *primitive.ObjectID) (string, error) {
+	if scope == nil {
+		return models.DefaultCurrency(), nil
+	}
+	restaurant, err := s.restaurantRepo.FindOne(map[string]interface{}{"_id": *scope})
+	if err != nil {
+		return "", err
+	}
+	return restaurant.CurrencyOrDefault(), nil
+}
+
+func buildPromotion(data types.PromotionData, currency string) (models.Promotion, error) {
+	promotion := models.Promotion{
+		Name:               data.Name,
+		Code:               strings.ToUpper(data.Code),
+		Type:               data.Type,
+		Percent:            data.Percent,
+		Amount:             data.Amount,
+		MaxDiscount:        data.MaxDiscount,
+		BuyQuantity:        data.BuyQuantity,
+		GetQuantity:        data.GetQuantity,
+		MinSubtotal:        data.MinSubtotal,
+		NewCustomersOnly:   data.NewCustomersOnly,
+		MaxUses:            data.MaxUses,
+		MaxUsesPerCustomer: data.MaxUsesPerCustomer,
+		Stackable:          data.Stackable,
+	}
+	err := inCurrency(currency, &promotion.Amount, &promotion.MaxDiscount, &promotion.MinSubtotal)
+	if err != nil {
+		return models.Promotion{}, err
+	}
+
+	for _, hex := range data.DishIds {
+		id, err := primitive.ObjectIDFromHex(hex)
+		if err != nil {
+			return models.Promotion{}, fmt.Errorf("%w: invalid dish id %v", ErrInvalidPromotion, hex)
+		}
+		promotion.DishIds = append(promotion.DishIds, id)
+	}
+
+	if data.StartsAt != "" {
+		startsAt, _ := time.Parse(time.RFC3339, data.StartsAt)
+		promotion.StartsAt = &startsAt
+	}
+	if data.EndsAt != "" {
+		endsAt, _ := time.Parse(time.RFC3339, data.EndsAt)
+		promotion.EndsAt = &endsAt
+	}
+	if promotion.StartsAt != nil && promotion.EndsAt != nil && !promotion.EndsAt.After(*promotion.StartsAt) {
+		return models.Promotion{}, fmt.Errorf("%w: it ends before it starts", ErrInvalidPromotion)
+	}
+	for _, window := range data.Windows {
+		promotion.Windows = append(promotion.Windows, models.AvailabilityWindow{Days: window.Days, Start: window.Start, End: window.End})
+	}
+	return promotion, nil
+}
+
+// codes are unique among the promotions of the same scope
+func (s *PromotionService) checkCode(scope *primitive.ObjectID, promotion models.Promotion) error {
+	if promotion.Code == "" {
+		return nil
+	}
+	promotions, err := s.repo.FindByScope(scope)
+	if err != nil {
+		return err
+	}
+	for _, other := range promotions {
+		if other.Code == promotion.Code && other.Id != promotion.Id {
+			return ErrPromotionCodeTaken
+		}
+	}
+	return nil
+}
+
+func (s *PromotionService) Create(scope *primitive.ObjectID, data types.PromotionData) (models.Promotion, error) {
+	currency, err := s.scopeCurrency(scope)
+	if err != nil {
+		return models.Promotion{}, err
+	}
+	promotion, err := buildPromotion(data, currency)
+	if err != nil {
+		return models.Promotion{}, err
+	}
+	if err = s.checkCode(scope, promotion); err != nil {
+		return models.Promotion{}, err
+	}
+
+	promotion.RestaurantId = scope
+	promotion.CreatedAt = time.Now()
+	promotion.Id, err = s.repo.Insert(promotion)
+	if err != nil {
+		return models.Promotion{}, err
+	}
+	return promotion, nil
+}
+
+func (s *PromotionService) List(scope *primitive.ObjectID) ([]models.Promotion, error) {
+	return s.repo.FindByScope(scope)
+}
+
+func (s *PromotionService) findInScope(scope *primitive.ObjectID, id primitive.ObjectID) (models.Promotion, error) {
+	promotion, err := s.repo.FindOne(map[string]interface{}{"_id": id})
+	if err == mongo.ErrNoDocuments || err == nil && !inScope(promotion, scope) {
+		return models.Promotion{}, ErrPromotionNotFound
+	}
+	return promotion, err
+}
+
+// the uses counted so far are kept
+func (s *PromotionService) Update(scope *primitive.ObjectID, id primitive.ObjectID, data types.PromotionData) (models.Promotion, error) {
+	existing, err := s.findInScope(scope, id)
+	if err != nil {
+		return models.Promotion{}, err
+	}
+	currency, err := s.scopeCurrency(scope)
+	if err != nil {
+		return models.Promotion{}, err
+	}
+	promotion, err := buildPromotion(data, currency)
+	if err != nil {
+		return models.Promotion{}, err
+	}
+	promotion.Id = id
+	if err = s.checkCode(scope, promotion); err != nil {
+		return models.Promotion{}, err
+	}
+
+	promotion.RestaurantId = existing.RestaurantId
+	promotion.Uses = existing.Uses
+	promotion.CreatedAt = existing.CreatedAt
+	if err = s.repo.Update(promotion); err != nil {
+		return models.Promotion{}, err
+	}
+	return promotion, nil
+}
+
+func (s *PromotionService) Delete(scope *primitive.ObjectID, id primitive.ObjectID) error {
+	if _, err := s.findInScope(scope, id); err != nil {
+		return err
+	}
+	return s.repo.Delete(id)
+}
+
+// prices the cart with the menu served at its time and applies the promotions of
+// the restaurant and the peer, coupons only when their code was entered
+func (s *PromotionService) Evaluate(restaurantId primitive.ObjectID, cart types.Cart) (types.CartEvaluation, error) {
+	evaluation, _, err := s.evaluate(restaurantId, cart)
+	return evaluation, err
+}
+
+// also returns the delivery quote of the cart, nil without delivery
+func (s *PromotionService) evaluate(restaurantId primitive.ObjectID, cart types.Cart) (types.CartEvaluation, *models.DeliveryQuote, error) {
+	customerId, err := customerOf(cart.CustomerToken)
+	if err != nil {
+		return types.CartEvaluation{}, nil, err
+	}
+	restaurant, err := s.restaurantRepo.FindOne(map[string]interface{}{"_id": restaurantId})
+	if err != nil {
+		return types.CartEvaluation{}, nil, err
+	}
+	if !restaurant.IsActive() {
+		return types.CartEvaluation{}, nil, ErrRestaurantNotActive
+	}
+	if restaurant.IsPaused {
+		return types.CartEvaluation{}, nil, ErrRestaurantPaused
+	}
+
+	at := time.Now()
+	if cart.At != "" {
+		at, _ = time.Parse(time.RFC3339, cart.At)
+	}
+	if !restaurant.Schedule().IsOpenAt(at) {
+		return types.CartEvaluation{}, nil, ErrRestaurantClosed
+	}
+	currency := restaurant.CurrencyOrDefault()
+	zero := models.NewMoney(0, currency)
+	evaluation := types.CartEvaluation{
+		Lines:       []types.CartLine{},
+		Subtotal:    zero,
+		DeliveryFee: zero,
+		Discount:    zero,
+		Applied:     []types.AppliedPromotion{},
+		Rejected:    []types.RejectedPromotion{},
+	}
+
+	version, err := s.menuVersions.CurrentMenu(restaurantId, at)
+	if err != nil {
+		return types.CartEvaluation{}, nil, err
+	}
+	version.Menu.InCurrency(currency)
+	for _, item := range cart.Items {
+		priced, err := priceMenuSelection(version.Menu, item.DishSelection)
+		if err != nil {
+			return types.CartEvaluation{}, nil, err
+		}
+		priced.MenuVersionId = version.Id.Hex()
+		line := types.CartLine{Selection: priced, Quantity: item.Quantity, Total: priced.Total.Mul(int64(item.Quantity))}
+		if evaluation.Subtotal, err = evaluation.Subtotal.Add(line.Total); err != nil {
+			return types.CartEvaluation{}, nil, err
+		}
+		evaluation.Lines = append(evaluation.Lines, line)
+	}
+
+	// the fee depends on the subtotal, a quote for another cart has to be asked again
+	var quote *models.DeliveryQuote
+	if cart.DeliveryQuoteId != "" {
+		quoteId, _ := primitive.ObjectIDFromHex(cart.DeliveryQuoteId)
+		found, err := s.delivery.GetQuote(restaurantId, quoteId)
+		if err != nil {
+			return types.CartEvaluation{}, nil, err
+		}
+		if found.Subtotal.In(currency) != evaluation.Subtotal {
+			return types.CartEvaluation{}, nil, ErrQuoteMismatch
+		}
+		quote = &found
+		evaluation.DeliveryFee = quote.Fee.In(currency)
+	}
+
+	promotions, err := s.repo.FindApplicable(restaurantId)
+	if err != nil {
+		return types.CartEvaluation{}, nil, err
+	}
+	codes := map[string]bool{}
+	for _, code := range cart.Codes {
+		codes[strings.ToUpper(code)] = false
+	}
+
+	candidates := []promotionDiscount{}
+	for _, promotion := range promotions {
+		if promotion.Code != "" {
+			if _, entered := codes[promotion.Code]; !entered {
+				continue
+			}
+			codes[promotion.Code] = true
+		}
+
+		reason, err := s.ineligibility(promotion, restaurant, customerId, at)
+		if err != nil {
+			return types.CartEvaluation{}, nil, err
+		}
+		discount := zero
+		if reason == "" {
+			discount, reason = promotionDiscountOf(promotion, evaluation.Lines, evaluation.DeliveryFee)
+		}
+		if reason != "" {
+			evaluation.Rejected = append(evaluation.Rejected, rejectPromotion(promotion, reason))
+			continue
+		}
+		candidates = append(candidates, promotionDiscount{promotion, discount})
+	}
+
+	unknown := []string{}
+	for code, matched := range codes {
+		if !matched {
+			unknown = append(unknown, code)
+		}
+	}
+	sort.Strings(unknown)
+	for _, code := range unknown {
+		evaluation.Rejected = append(evaluation.Rejected, types.RejectedPromotion{Name: code, Reason: "unknown coupon code"})
+	}
+
+	applied, rejected := combinePromotions(candidates, evaluation.Subtotal, evaluation.DeliveryFee)
+	evaluation.Rejected = append(evaluation.Rejected, rejected...)
+	for _, promotion := range applied {
+		evaluation.Applied = append(evaluation.Applied, types.AppliedPromotion{
+			PromotionId: promotion.promotion.Id,
+			Name:        promotion.promotion.Name,
+			Type:        promotion.promotion.Type,
+			Discount:    promotion.discount,
+		})
+		if evaluation.Discount, err = evaluation.Discount.Add(promotion.discount); err != nil {
+			return types.CartEvaluation{}, nil, err
+		}
+	}
+
+	total, err := evaluation.Subtotal.Add(evaluation.DeliveryFee)
+	if err != nil {
+		return types.CartEvaluation{}, nil, err
+	}
+	evaluation.Total, err = total.Sub(evaluation.Discount)
+	if err != nil {
+		return types.CartEvaluation{}, nil, err
+	}
+	return evaluation, quote, nil
+}
+
+// evaluates the cart again and counts the uses of its promotions, the redemption
+// is what the order has to point to
+func (s *PromotionService) Redeem(restaurantId primitive.ObjectID, cart types.Cart) (types.CartEvaluation, error) {
+	// the order is taken now, a cart time would let it skip the schedule and the promotion dates
+	cart.At = ""
+	evaluation, quote, err := s.evaluate(restaurantId, cart)
+	if err != nil {
+		return types.CartEvaluation{}, err
+	}
+	customerId, _ := customerOf(cart.CustomerToken)
+
+	used := []primitive.ObjectID{}
+	release := func() {
+		for _, id := range used {
+			s.repo.Release(id)
+		}
+	}
+	for _, applied := range evaluation.Applied {
+		ok, err := s.repo.Use(applied.PromotionId)
+		if err != nil || !ok {
+			release()
+			if err != nil {
+				return types.CartEvaluation{}, err
+			}
+			return types.CartEvaluation{}, ErrPromotionUnavailable
+		}
+		used = append(used, applied.PromotionId)
+	}
+
+	reviewToken, err := newSecretToken()
+	if err != nil {
+		release()
+		return types.CartEvaluation{}, err
+	}
+	redemption := models.Redemption{
+		RestaurantId:    restaurantId,
+		CustomerId:      customerId,
+		PromotionIds:    used,
+		DeliveryQuote:   quote,
+		Discount:        evaluation.Discount,
+		Total:           evaluation.Total,
+		ReviewTokenHash: hashSecretToken(reviewToken),
+		CreatedAt:       time.Now(),
+	}
+	id, err := s.redemptionRepo.Insert(redemption)
+	if err != nil {
+		release()
+		return types.CartEvaluation{}, err
+	}
+	evaluation.RedemptionId = &id
+	evaluation.ReviewToken = reviewToken
+	return evaluation, nil
+}
+
+func rejectPromotion(promotion models.Promotion, reason string) types.RejectedPromotion {
+	id := promotion.Id
+	return types.RejectedPromotion{PromotionId: &id, Name: promotion.Name, Reason: reason}
+}
+
+// IssueCustomerToken signs the id of a customer the app of the peer owner signed in,
+// carts prove their customer with it
+func (s *PromotionService) IssueCustomerToken(customerId string) (string, error) {
+	secret := os.Getenv("CUSTOMER_TOKEN_SECRET")
+	if secret == "" {
+		return "", ErrCustomerTokensDisabled
+	}
+	return signCustomerToken(secret, customerId), nil
+}
+
+func signCustomerToken(secret string, customerId string) string {
+	id := base64.RawURLEncoding.EncodeToString([]byte(customerId))
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(id))
+	return id + "." + hex.EncodeToString(mac.Sum(nil))
+}
+
+// the customer the token was issued for, empty for carts without a token
+func customerOf(token string) (string, error) {
+	if token == "" {
+		return "", nil
+	}
+	secret := os.Getenv("CUSTOMER_TOKEN_SECRET")
+	id, _, found := strings.Cut(token, ".")
+	if secret == "" || !found {
+		return "", ErrInvalidCustomerToken
+	}
+	customerId, err := base64.RawURLEncoding.DecodeString(id)
+	if err != nil || !hmac.Equal([]byte(signCustomerToken(secret, string(customerId))), []byte(token)) {
+		return "", ErrInvalidCustomerToken
+	}
+	return string(customerId), nil
+}
+
+// tells why the promotion can't be used by the customer at the time, empty when it can
+func (s *PromotionService) ineligibility(promotion models.Promotion, restaurant models.Restaurant, customerId string, at time.Time) (string, error) {
+	if promotion.StartsAt != nil && at.Before(*promotion.StartsAt) {
+		return "the promotion hasn't started", nil
+	}
+	if promotion.EndsAt != nil && !at.Before(*promotion.EndsAt) {
+		return "the promotion has ended", nil
+	}
+	if len(promotion.Windows) > 0 {
+		local := at.In(restaurant.Schedule().Location())
+		active := false
+		for _, window := range promotion.Windows {
+			active = active || window.Contains(local)
+		}
+		if !active {
+			return "the promotion isn't available at this time", nil
+		}
+	}
+
+	currency := restaurant.CurrencyOrDefault()
+	for _, amount := range []models.Money{promotion.Amount, promotion.MaxDiscount, promotion.MinSubtotal} {
+		if _, err := amount.As(currency); err != nil {
+			return fmt.Sprintf("the promotion isn't available in %v", currency), nil
+		}
+	}
+
+	if promotion.MaxUses > 0 && promotion.Uses >= promotion.MaxUses {
+		return "the promotion reached its usage limit", nil
+	}
+	// the limits per customer need to know who the customer is
+	if (promotion.MaxUsesPerCustomer > 0 || promotion.NewCustomersOnly) && customerId == "" {
+		return "the promotion needs a customer token", nil
+	}
+	if promotion.MaxUsesPerCustomer > 0 {
+		uses, err := s.redemptionRepo.Count(map[string]interface{}{"customerId": customerId, "promotionIds": promotion.Id})
+		if err != nil {
+			return "", err
+		}
+		if uses >= int64(promotion.MaxUsesPerCustomer) {
+			return "the customer already used the promotion the maximum times", nil
+		}
+	}
+	// new at the restaurant, or at the peer for the promotions of the whole peer
+	if promotion.NewCustomersOnly {
+		query := map[string]interface{}{"customerId": customerId}
+		if promotion.RestaurantId != nil {
+			query["restaurantId"] = *promotion.RestaurantId
+		}
+		orders, err := s.redemptionRepo.Count(query)
+		if err != nil {
+			return "", err
+		}
+		if orders > 0 {
+			return "the promotion is only for new customers", nil
+		}
+	}
+	return "", nil
+}
+
+// the subtotal and the unit prices of the dishes the promotion applies to
+func eligibleDishes(promotion models.Promotion, lines []types.CartLine, currency string) (models.Money, []models.Money) {
+	subtotal := models.NewMoney(0, currency)
+	units := []models.Money{}
+	for _, line := range lines {
+		dishId, _ := primitive.ObjectIDFromHex(line.Selection.DishId)
+		if !promotion.Covers(dishId) {
+			continue
+		}
+		subtotal, _ = subtotal.Add(line.Total)
+		for i := uint(0); i < line.Quantity; i++ {
+			units = append(units, line.Selection.Total)
+		}
+	}
+	return subtotal, units
+}
+
+// the discount of the promotion on its own, or why it doesn't apply to the cart
+func promotionDiscountOf(promotion models.Promotion, lines []types.CartLine, deliveryFee models.Money) (models.Money, string) {
+	currency := deliveryFee.Currency
+	zero := models.NewMoney(0, currency)
+	subtotal, units := eligibleDishes(promotion, lines, currency)
+
+	if promotion.Type != models.PROMOTION_FREE_DELIVERY && subtotal.IsZero() {
+		return zero, "the cart has no dishes of the promotion"
+	}
+	if !promotion.MinSubtotal.IsZero() && subtotal.Amount < promotion.MinSubtotal.Amount {
+		return zero, fmt.Sprintf("the minimum subtotal is %v", promotion.MinSubtotal)
+	}
+
+	switch promotion.Type {
+	case models.PROMOTION_PERCENT:
+		discount := subtotal.MulFloat(promotion.Percent / 100)
+		if !promotion.MaxDiscount.IsZero() && discount.Amount > promotion.MaxDiscount.Amount {
+			discount = promotion.MaxDiscount.In(currency)
+		}
+		return discount, ""
+	case models.PROMOTION_FIXED:
+		if promotion.Amount.Amount > subtotal.Amount {
+			return subtotal, ""
+		}
+		return promotion.Amount.In(currency), ""
+	case models.PROMOTION_FREE_DELIVERY:
+		if deliveryFee.IsZero() {
+			return zero, "there is no delivery fee to discount"
+		}
+		return deliveryFee, ""
+	case models.PROMOTION_BUY_X_GET_Y:
+		group := promotion.BuyQuantity + promotion.GetQuantity
+		free := uint(len(units)) / group * promotion.GetQuantity
+		if free == 0 {
+			return zero, fmt.Sprintf("the cart needs %d dishes of the promotion", group)
+		}
+		// the cheapest dishes are the free ones
+		sort.SliceStable(units, func(i, j int) bool { return units[i].Amount < units[j].Amount })
+		discount := zero
+		for _, unit := range units[:free] {
+			discount, _ = discount.Add(unit)
+		}
+		return discount, ""
+	}
+	return zero, "unknown promotion type"
+}
+
+// applies the promotions biggest discount first, the dishes and the delivery can't
+// be discounted more than they cost
+func applyPromotions(candidates []promotionDiscount, subtotal models.Money, deliveryFee models.Money) ([]promotionDiscount, []types.RejectedPromotion, int64) {
+	sorted := append([]promotionDiscount{}, candidates...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].discount.Amount > sorted[j].discount.Amount })
+
+	applied := []promotionDiscount{}
+	rejected := []types.RejectedPromotion{}
+	dishesLeft, deliveryLeft, total := subtotal.Amount, deliveryFee.Amount, int64(0)
+	for _, candidate := range sorted {
+		left := &dishesLeft
+		if candidate.promotion.Type == models.PROMOTION_FREE_DELIVERY {
+			left = &deliveryLeft
+		}
+		if *left == 0 {
+			rejected = append(rejected, rejectPromotion(candidate.promotion, "there is nothing left to discount"))
+			continue
+		}
+		if candidate.discount.Amount > *left {
+			candidate.discount.Amount = *left
+		}
+		*left -= candidate.discount.Amount
+		total += candidate.discount.Amount
+		applied = append(applied, candidate)
+	}
+	return applied, rejected, total
+}
+
+// the stackable promotions are applied together and the others on their own, the
+// option with the biggest discount wins
+func combinePromotions(candidates []promotionDiscount, subtotal models.Money, deliveryFee models.Money) ([]promotionDiscount, []types.RejectedPromotion) {
+	stackable := []promotionDiscount{}
+	exclusive := []promotionDiscount{}
+	for _, candidate := range candidates {
+		if candidate.promotion.Stackable {
+			stackable = append(stackable, candidate)
+		} else {
+			exclusive = append(exclusive, candidate)
+		}
+	}
+
+	applied, rejected, best := applyPromotions(stackable, subtotal, deliveryFee)
+	var winner *promotionDiscount
+	for i := range exclusive {
+		alone, _, total := applyPromotions(exclusive[i:i+1], subtotal, deliveryFee)
+		if total > best {
+			applied, rejected, best = alone, []types.RejectedPromotion{}, total
+			winner = &exclusive[i]
+		}
+	}
+
+	if winner != nil {
+		for _, candidate := range candidates {
+			if candidate.promotion.Id != winner.promotion.Id {
+				rejected = append(rejected, rejectPromotion(candidate.promotion, fmt.Sprintf("%s can't be combined with other promotions", winner.promotion.Name)))
+			}
+		}
+		return applied, rejected
+	}
+	for _, candidate := range exclusive {
+		rejected = append(rejected, rejectPromotion(candidate.promotion, "the promotion can't be combined and a bigger discount was applied"))
+	}
+	return applied, rejected
+}
@@ -0,0 +1,358 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/nicodeheza/peersEat/mocks"
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type promotionTest struct {
+	service     *PromotionService
+	repo        *mocks.PromotionRepositoryMock
+	redemptions *mocks.RedemptionRepositoryMock
+	quotes      *mocks.DeliveryQuoteRepositoryMock
+	menus       *mocks.MenuVersionServiceMock
+	restaurant  models.Restaurant
+	burger      models.Dish
+	fries       models.Dish
+}
+
+func initPromotionTest() promotionTest {
+	restaurantRepo := mocks.NewRestaurantRepositoryMock()
+	// open the whole day, every day
+	weekly := []models.DaySchedule{}
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		weekly = append(weekly, models.DaySchedule{Day: day, Intervals: []models.OpeningInterval{{Open: "00:00", Close: "00:00"}}})
+	}
+	restaurant := models.Restaurant{Id: primitive.NewObjectID(), Currency: "USD", OpeningHours: &models.OpeningHours{Timezone: "UTC", Weekly: weekly}}
+	restaurantRepo.Restaurants = []models.Restaurant{restaurant}
+
+	burger := models.Dish{Id: primitive.NewObjectID(), Name: "burger", Price: usd(1000)}
+	fries := models.Dish{Id: primitive.NewObjectID(), Name: "fries", Price: usd(300)}
+	menuVersions := mocks.NewMenuVersionServiceMock()
+	menuVersions.Menus = map[primitive.ObjectID]models.Menu{
+		restaurant.Id: {Sections: []models.MenuSection{{Id: primitive.NewObjectID(), Name: "main", Dishes: []models.Dish{burger, fries}}}},
+	}
+
+	repo := mocks.NewPromotionRepositoryMock()
+	redemptions := mocks.NewRedemptionRepositoryMock()
+	quotes := mocks.NewDeliveryQuoteRepositoryMock()
+	delivery := NewDeliveryService(quotes, restaurantRepo, mocks.NewGeo())
+	service := NewPromotionService(repo, redemptions, restaurantRepo, menuVersions, delivery)
+	return promotionTest{service, repo, redemptions, quotes, menuVersions, restaurant, burger, fries}
+}
+
+func (p promotionTest) addPromotion(promotion models.Promotion) models.Promotion {
+	if promotion.RestaurantId == nil {
+		promotion.RestaurantId = &p.restaurant.Id
+	}
+	promotion.Id, _ = p.repo.Insert(promotion)
+	return promotion
+}
+
+// two burgers and three fries, 2900 cents
+func (p promotionTest) cart() types.Cart {
+	return types.Cart{
+		Items: []types.CartItem{
+			{DishSelection: types.DishSelection{DishId: p.burger.Id.Hex()}, Quantity: 2},
+			{DishSelection: types.DishSelection{DishId: p.fries.Id.Hex()}, Quantity: 3},
+		},
+	}
+}
+
+// quoted for the subtotal of cart()
+func (p promotionTest) withDelivery(cart types.Cart, fee int64) types.Cart {
+	id, _ := p.quotes.Insert(models.DeliveryQuote{RestaurantId: p.restaurant.Id, Subtotal: usd(2900), Fee: usd(fee), ExpiresAt: time.Now().Add(time.Hour)})
+	cart.DeliveryQuoteId = id.Hex()
+	return cart
+}
+
+func rejectionOf(evaluation types.CartEvaluation, name string) string {
+	for _, rejected := range evaluation.Rejected {
+		if rejected.Name == name {
+			return rejected.Reason
+		}
+	}
+	return ""
+}
+
+func TestPromotionDiscounts(t *testing.T) {
+	cases := []struct {
+		promotion models.Promotion
+		discount  int64
+	}{
+		{models.Promotion{Name: "percent", Type: models.PROMOTION_PERCENT, Percent: 10}, 290},
+		{models.Promotion{Name: "capped", Type: models.PROMOTION_PERCENT, Percent: 50, MaxDiscount: usd(1000)}, 1000},
+		{models.Promotion{Name: "fixed", Type: models.PROMOTION_FIXED, Amount: usd(500)}, 500},
+		{models.Promotion{Name: "free delivery", Type: models.PROMOTION_FREE_DELIVERY}, 400},
+		{models.Promotion{Name: "3x2", Type: models.PROMOTION_BUY_X_GET_Y, BuyQuantity: 2, GetQuantity: 1}, 300},
+	}
+	for _, c := range cases {
+		test := initPromotionTest()
+		test.addPromotion(c.promotion)
+
+		evaluation, err := test.service.Evaluate(test.restaurant.Id, test.withDelivery(test.cart(), 400))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if len(evaluation.Applied) != 1 || evaluation.Discount != usd(c.discount) {
+			t.Errorf("expecting %v to discount %v but got %v", c.promotion.Name, c.discount, evaluation)
+		}
+		if evaluation.Subtotal != usd(2900) || evaluation.DeliveryFee != usd(400) || evaluation.Total != usd(3300-c.discount) {
+			t.Errorf("unexpected totals for %v: %v", c.promotion.Name, evaluation)
+		}
+	}
+
+	// only the listed dishes are discounted
+	test := initPromotionTest()
+	test.addPromotion(models.Promotion{Name: "fries", Type: models.PROMOTION_PERCENT, Percent: 50, DishIds: []primitive.ObjectID{test.fries.Id}})
+	evaluation, _ := test.service.Evaluate(test.restaurant.Id, test.cart())
+	if evaluation.Discount != usd(450) {
+		t.Errorf("expecting only the fries to be discounted but got %v", evaluation.Discount)
+	}
+}
+
+func TestPromotionEligibility(t *testing.T) {
+	os.Setenv("CUSTOMER_TOKEN_SECRET", "secret")
+	defer os.Unsetenv("CUSTOMER_TOKEN_SECRET")
+	test := initPromotionTest()
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	test.addPromotion(models.Promotion{Name: "hidden", Code: "HIDDEN", Type: models.PROMOTION_FIXED, Amount: usd(100)})
+	test.addPromotion(models.Promotion{Name: "coupon", Code: "SAVE", Type: models.PROMOTION_FIXED, Amount: usd(100), Stackable: true})
+	test.addPromotion(models.Promotion{Name: "expired", Type: models.PROMOTION_FIXED, Amount: usd(100), EndsAt: &past})
+	test.addPromotion(models.Promotion{Name: "upcoming", Type: models.PROMOTION_FIXED, Amount: usd(100), StartsAt: &future})
+	test.addPromotion(models.Promotion{Name: "big orders", Type: models.PROMOTION_FIXED, Amount: usd(100), MinSubtotal: usd(5000)})
+	test.addPromotion(models.Promotion{Name: "sold out", Type: models.PROMOTION_FIXED, Amount: usd(100), MaxUses: 2, Uses: 2})
+	once := test.addPromotion(models.Promotion{Name: "once", Type: models.PROMOTION_FIXED, Amount: usd(100), MaxUsesPerCustomer: 1, Stackable: true})
+	test.addPromotion(models.Promotion{Name: "welcome", Type: models.PROMOTION_FIXED, Amount: usd(100), NewCustomersOnly: true, Stackable: true})
+	test.addPromotion(models.Promotion{Name: "no delivery", Type: models.PROMOTION_FREE_DELIVERY})
+	test.redemptions.Insert(models.Redemption{RestaurantId: test.restaurant.Id, CustomerId: "customer", PromotionIds: []primitive.ObjectID{once.Id}})
+
+	cart := test.cart()
+	cart.CustomerToken, _ = test.service.IssueCustomerToken("customer")
+	cart.Codes = []string{"save", "NOPE"}
+	evaluation, err := test.service.Evaluate(test.restaurant.Id, cart)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(evaluation.Applied) != 1 || evaluation.Applied[0].Name != "coupon" {
+		t.Errorf("expecting only the coupon to be applied but got %v", evaluation.Applied)
+	}
+	if rejectionOf(evaluation, "hidden") != "" {
+		t.Errorf("coupons not entered shouldn't be reported")
+	}
+	for _, name := range []string{"NOPE", "expired", "upcoming", "big orders", "sold out", "once", "welcome", "no delivery"} {
+		if rejectionOf(evaluation, name) == "" {
+			t.Errorf("expecting %v to be rejected with a reason", name)
+		}
+	}
+
+	// a customer without orders is new at the restaurant
+	cart.CustomerToken, _ = test.service.IssueCustomerToken("someone else")
+	evaluation, _ = test.service.Evaluate(test.restaurant.Id, cart)
+	if rejectionOf(evaluation, "welcome") != "" || rejectionOf(evaluation, "once") != "" {
+		t.Errorf("expecting the new customer promotions to apply to another customer")
+	}
+
+	// the customer can't be told from a cart without a token, or a forged one
+	cart.CustomerToken = ""
+	evaluation, _ = test.service.Evaluate(test.restaurant.Id, cart)
+	if rejectionOf(evaluation, "welcome") == "" || rejectionOf(evaluation, "once") == "" {
+		t.Errorf("expecting the promotions limited per customer to need a customer token")
+	}
+	cart.CustomerToken = signCustomerToken("other secret", "someone else")
+	if _, err := test.service.Evaluate(test.restaurant.Id, cart); err != ErrInvalidCustomerToken {
+		t.Errorf("expecting invalid customer token error but got %v", err)
+	}
+	os.Unsetenv("CUSTOMER_TOKEN_SECRET")
+	if _, err := test.service.IssueCustomerToken("customer"); err != ErrCustomerTokensDisabled {
+		t.Errorf("expecting no customer tokens without a secret but got %v", err)
+	}
+
+	// peer wide promotions don't apply in another currency
+	test.repo.Promotions = []models.Promotion{{Id: primitive.NewObjectID(), Name: "euros", Type: models.PROMOTION_FIXED, Amount: models.NewMoney(100, "EUR")}}
+	evaluation, _ = test.service.Evaluate(test.restaurant.Id, test.cart())
+	if len(evaluation.Applied) != 0 || rejectionOf(evaluation, "euros") == "" {
+		t.Errorf("expecting the promotion in another currency to be rejected but got %v", evaluation)
+	}
+}
+
+func TestPromotionStacking(t *testing.T) {
+	test := initPromotionTest()
+	test.addPromotion(models.Promotion{Name: "percent", Type: models.PROMOTION_PERCENT, Percent: 10, Stackable: true})
+	test.addPromotion(models.Promotion{Name: "fixed", Type: models.PROMOTION_FIXED, Amount: usd(200), Stackable: true})
+	exclusive := test.addPromotion(models.Promotion{Name: "exclusive", Type: models.PROMOTION_FIXED, Amount: usd(400)})
+
+	// 290 + 200 together beat 400 alone
+	evaluation, _ := test.service.Evaluate(test.restaurant.Id, test.cart())
+	if len(evaluation.Applied) != 2 || evaluation.Discount != usd(490) || rejectionOf(evaluation, "exclusive") == "" {
+		t.Errorf("expecting the stackable promotions to be applied but got %v", evaluation)
+	}
+
+	test.repo.Promotions[2].Amount = usd(600)
+	evaluation, _ = test.service.Evaluate(test.restaurant.Id, test.cart())
+	if len(evaluation.Applied) != 1 || evaluation.Applied[0].PromotionId != exclusive.Id || evaluation.Discount != usd(600) {
+		t.Errorf("expecting the exclusive promotion to be applied but got %v", evaluation)
+	}
+	if rejectionOf(evaluation, "percent") == "" || rejectionOf(evaluation, "fixed") == "" {
+		t.Errorf("expecting the stackable promotions to be rejected but got %v", evaluation.Rejected)
+	}
+
+	// the dishes are never discounted more than they cost
+	test.repo.Promotions[2].Stackable = true
+	test.repo.Promotions[2].Amount = usd(2800)
+	evaluation, _ = test.service.Evaluate(test.restaurant.Id, test.cart())
+	if evaluation.Discount != usd(2900) || evaluation.Total != usd(0) || rejectionOf(evaluation, "fixed") == "" {
+		t.Errorf("expecting the discount to be capped at the subtotal but got %v", evaluation)
+	}
+}
+
+func TestRedeemPromotions(t *testing.T) {
+	os.Setenv("CUSTOMER_TOKEN_SECRET", "secret")
+	defer os.Unsetenv("CUSTOMER_TOKEN_SECRET")
+	test := initPromotionTest()
+	limited := test.addPromotion(models.Promotion{Name: "limited", Type: models.PROMOTION_FIXED, Amount: usd(100), MaxUses: 1})
+
+	cart := test.cart()
+	cart.CustomerToken, _ = test.service.IssueCustomerToken("customer")
+	evaluation, err := test.service.Redeem(test.restaurant.Id, cart)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if evaluation.RedemptionId == nil || len(test.redemptions.Redemptions) != 1 {
+		t.Fatalf("expecting the redemption to be stored")
+	}
+	redemption := test.redemptions.Redemptions[0]
+	if len(redemption.PromotionIds) != 1 || redemption.PromotionIds[0] != limited.Id || redemption.Total != usd(2800) || redemption.CustomerId != "customer" {
+		t.Errorf("unexpected redemption %v", redemption)
+	}
+	if evaluation.ReviewToken == "" || redemption.ReviewTokenHash != hashSecretToken(evaluation.ReviewToken) {
+		t.Errorf("expecting only the hash of the review token to be stored but got %v", redemption.ReviewTokenHash)
+	}
+	if test.repo.Promotions[0].Uses != 1 {
+		t.Errorf("expecting the use to be counted")
+	}
+
+	evaluation, _ = test.service.Redeem(test.restaurant.Id, test.cart())
+	if len(evaluation.Applied) != 0 || rejectionOf(evaluation, "limited") == "" || evaluation.Total != usd(2900) {
+		t.Errorf("expecting the promotion to be used up but got %v", evaluation)
+	}
+
+	test.repo.Promotions = nil
+	test.service.restaurantRepo.(*mocks.RestaurantRepositoryMock).Restaurants[0].IsPaused = true
+	if _, err := test.service.Redeem(test.restaurant.Id, test.cart()); err != ErrRestaurantPaused {
+		t.Errorf("expecting a paused restaurant to not take carts but got %v", err)
+	}
+}
+
+func TestSoldOutCart(t *testing.T) {
+	test := initPromotionTest()
+	// the current menu comes with the dishes sold out at the cart time
+	test.menus.Menus[test.restaurant.Id].Sections[0].Dishes[1].SoldOut = true
+
+	var rulesError *OptionRulesError
+	if _, err := test.service.Evaluate(test.restaurant.Id, test.cart()); !errors.As(err, &rulesError) {
+		t.Errorf("expecting a sold out dish to be rejected but got %v", err)
+	}
+	if _, err := test.service.Redeem(test.restaurant.Id, test.cart()); !errors.As(err, &rulesError) {
+		t.Errorf("expecting a sold out dish to not be redeemed but got %v", err)
+	}
+	if len(test.redemptions.Redemptions) != 0 {
+		t.Errorf("expecting no redemption but got %v", test.redemptions.Redemptions)
+	}
+}
+
+func TestClosedRestaurantCart(t *testing.T) {
+	test := initPromotionTest()
+	restaurants := test.service.restaurantRepo.(*mocks.RestaurantRepositoryMock)
+	today := time.Now().UTC().Format(models.DATE_LAYOUT)
+	restaurants.Restaurants[0].OpeningHours.Exceptions = []models.ScheduleException{{Date: today, Note: "holiday"}}
+
+	cart := test.cart()
+	if _, err := test.service.Evaluate(test.restaurant.Id, cart); err != ErrRestaurantClosed {
+		t.Errorf("expecting a closed restaurant to not price carts but got %v", err)
+	}
+	if _, err := test.service.Redeem(test.restaurant.Id, cart); err != ErrRestaurantClosed {
+		t.Errorf("expecting a closed restaurant to not take orders but got %v", err)
+	}
+
+	// scheduled for when the restaurant opens again
+	cart.At = time.Now().UTC().AddDate(0, 0, 1).Format(time.RFC3339)
+	if _, err := test.service.Evaluate(test.restaurant.Id, cart); err != nil {
+		t.Errorf("expecting the cart to be priced for an open time but got %v", err)
+	}
+	// but the order is taken now
+	if _, err := test.service.Redeem(test.restaurant.Id, cart); err != ErrRestaurantClosed {
+		t.Errorf("expecting the cart time to be ignored when redeeming but got %v", err)
+	}
+}
+
+func TestCartDeliveryQuote(t *testing.T) {
+	test := initPromotionTest()
+
+	cart := test.withDelivery(test.cart(), 400)
+	evaluation, err := test.service.Redeem(test.restaurant.Id, cart)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	quote := test.redemptions.Redemptions[0].DeliveryQuote
+	if quote == nil || quote.Id.Hex() != cart.DeliveryQuoteId || quote.Fee != evaluation.DeliveryFee {
+		t.Errorf("expecting the redemption to keep the quote but got %v", quote)
+	}
+
+	cart.Items = cart.Items[:1]
+	if _, err := test.service.Evaluate(test.restaurant.Id, cart); err != ErrQuoteMismatch {
+		t.Errorf("expecting a quote for another subtotal to be rejected but got %v", err)
+	}
+}
+
+func TestManagePromotions(t *testing.T) {
+	test := initPromotionTest()
+	scope := &test.restaurant.Id
+
+	promotion, err := test.service.Create(scope, types.PromotionData{Name: "coupon", Code: "save10", Type: models.PROMOTION_PERCENT, Percent: 10})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if promotion.Code != "SAVE10" || promotion.RestaurantId == nil || *promotion.RestaurantId != test.restaurant.Id {
+		t.Errorf("unexpected promotion %v", promotion)
+	}
+
+	_, err = test.service.Create(scope, types.PromotionData{Name: "other", Code: "SAVE10", Type: models.PROMOTION_PERCENT, Percent: 5})
+	if err != ErrPromotionCodeTaken {
+		t.Errorf("expecting the code to be taken but got %v", err)
+	}
+	if _, err = test.service.Create(nil, types.PromotionData{Name: "peer", Code: "SAVE10", Type: models.PROMOTION_PERCENT, Percent: 5}); err != nil {
+		t.Errorf("expecting the peer to use the code but got %v", err)
+	}
+
+	_, err = test.service.Create(scope, types.PromotionData{Name: "backwards", Type: models.PROMOTION_FIXED, Amount: usd(100), StartsAt: "2024-05-02T00:00:00Z", EndsAt: "2024-05-01T00:00:00Z"})
+	if !errors.Is(err, ErrInvalidPromotion) {
+		t.Errorf("expecting an invalid promotion but got %v", err)
+	}
+
+	if _, err = test.service.Update(nil, promotion.Id, types.PromotionData{Name: "coupon", Type: models.PROMOTION_PERCENT, Percent: 20}); err != ErrPromotionNotFound {
+		t.Errorf("expecting the peer to not see the restaurant promotion but got %v", err)
+	}
+	updated, err := test.service.Update(scope, promotion.Id, types.PromotionData{Name: "coupon", Code: "SAVE10", Type: models.PROMOTION_PERCENT, Percent: 20})
+	if err != nil || updated.Percent != 20 {
+		t.Errorf("unexpected update %v %v", updated, err)
+	}
+
+	if err = test.service.Delete(nil, promotion.Id); err != ErrPromotionNotFound {
+		t.Errorf("expecting the peer to not delete the restaurant promotion but got %v", err)
+	}
+	if err = test.service.Delete(scope, promotion.Id); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if promotions, _ := test.service.List(scope); len(promotions) != 0 {
+		t.Errorf("expecting the promotion to be deleted but got %v", promotions)
+	}
+}
@@ -0,0 +1,634 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nicodeheza/peersEat/constants"
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/repositories"
+	"github.com/nicodeheza/peersEat/services/geo"
+	"github.com/nicodeheza/peersEat/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const REPLICATION_UPSERT = "upsert"
+const REPLICATION_DELETE = "delete"
+
+const PEER_URL_HEADER = "X-Peer-Url"
+const REPLICATION_SIGNATURE_HEADER = "X-Replication-Signature"
+
+var ErrReplicationUnauthorized = errors.New("replication unauthorized")
+var ErrReplicationReplay = errors.New("replication batch already applied")
+var ErrOriginAlive = errors.New("origin peer is alive")
+
+type ReplicationServiceI interface {
+	Start()
+	RestaurantChanged(id primitive.ObjectID)
+	Sync() error
+	GetHandshakeSecret(id string, token string) (string, error)
+	Subscribe(request types.ReplicationSubscribe) error
+	ReceiveBatch(from string, signature string, body []byte) error
+	GetReplica(id primitive.ObjectID) (models.Restaurant, error)
+	GetReplicas(origin string) ([]models.Restaurant, error)
+	Promote(origin string, force bool) ([]string, error)
+}
+
+type pendingHandshake struct {
+	token  string
+	secret string
+}
+
+type ReplicationService struct {
+	peerRepo        repositories.PeerRepositoryI
+	restaurantRepo  repositories.RestaurantRepositoryI
+	replicationRepo repositories.ReplicationRepositoryI
+	menuVersionRepo repositories.MenuVersionRepositoryI
+	staffRepo       repositories.StaffUserRepositoryI
+	geo             geo.GeoServiceI
+	buddyUrls       []string
+	buddyCount      int
+	interval        time.Duration
+	deadAfter       time.Duration
+	mutex           sync.Mutex
+	dirty           map[primitive.ObjectID]bool
+	handshakes      map[string]pendingHandshake
+	once            sync.Once
+}
+
+func NewReplicationService(
+	peerRepo repositories.PeerRepositoryI,
+	restaurantRepo repositories.RestaurantRepositoryI,
+	replicationRepo repositories.ReplicationRepositoryI,
+	menuVersionRepo repositories.MenuVersionRepositoryI,
+	staffRepo repositories.StaffUserRepositoryI,
+	geo geo.GeoServiceI,
+) *ReplicationService {
+	buddyUrls := []string{}
+	for _, buddyUrl := range strings.Split(os.Getenv("REPLICATION_BUDDIES"), ",") {
+		if buddyUrl = strings.TrimSpace(buddyUrl); buddyUrl != "" {
+			buddyUrls = append(buddyUrls, buddyUrl)
+		}
+	}
+
+	buddyCount := constants.REPLICATION_BUDDY_COUNT
+	if count, err := strconv.Atoi(os.Getenv("REPLICATION_BUDDY_COUNT")); err == nil && count >= 0 {
+		buddyCount = count
+	}
+
+	interval, _ := time.ParseDuration(constants.REPLICATION_INTERVAL)
+	if envInterval, err := time.ParseDuration(os.Getenv("REPLICATION_INTERVAL")); err == nil && envInterval > 0 {
+		interval = envInterval
+	}
+
+	deadAfter, _ := time.ParseDuration(constants.REPLICATION_DEAD_AFTER)
+	if envDeadAfter, err := time.ParseDuration(os.Getenv("REPLICATION_DEAD_AFTER")); err == nil && envDeadAfter > 0 {
+		deadAfter = envDeadAfter
+	}
+
+	return &ReplicationService{
+		peerRepo:        peerRepo,
+		restaurantRepo:  restaurantRepo,
+		replicationRepo: replicationRepo,
+		menuVersionRepo: menuVersionRepo,
+		staffRepo:       staffRepo,
+		geo:             geo,
+		buddyUrls:       buddyUrls,
+		buddyCount:      buddyCount,
+		interval:        interval,
+		deadAfter:       deadAfter,
+		dirty:           map[primitive.ObjectID]bool{},
+		handshakes:      map[string]pendingHandshake{},
+	}
+}
+
+func (r *ReplicationService) Start() {
+	r.once.Do(func() {
+		go func() {
+			for {
+				time.Sleep(r.interval)
+				if err := r.Sync(); err != nil {
+					log.Println(err.Error())
+				}
+			}
+		}()
+	})
+}
+
+func (r *ReplicationService) RestaurantChanged(id primitive.ObjectID) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.dirty[id] = true
+}
+
+func (r *ReplicationService) takeDirty() []primitive.ObjectID {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	ids := []primitive.ObjectID{}
+	for id := range r.dirty {
+		ids = append(ids, id)
+	}
+	r.dirty = map[primitive.ObjectID]bool{}
+	return ids
+}
+
+// new buddies and buddies that missed a batch receive a full snapshot, the rest
+// only the restaurants changed since the last sync. Empty batches work as heartbeats.
+func (r *ReplicationService) Sync() error {
+	self, err := r.peerRepo.GetSelf()
+	if err != nil {
+		return err
+	}
+
+	buddies, err := r.selectBuddies(self)
+	if err != nil {
+		return err
+	}
+
+	links, err := r.replicationRepo.FindLinks(models.REPLICATION_BUDDY)
+	if err != nil {
+		return err
+	}
+	linksByUrl := map[string]models.ReplicationLink{}
+	for _, link := range links {
+		linksByUrl[link.Url] = link
+	}
+
+	selected := map[string]bool{}
+	for _, buddy := range buddies {
+		selected[buddy] = true
+	}
+	for _, link := range links {
+		if !selected[link.Url] {
+			if err := r.replicationRepo.DeleteLink(link.Url, models.REPLICATION_BUDDY); err != nil {
+				log.Println(err.Error())
+			}
+		}
+	}
+
+	changedOps, err := r.changedOps(r.takeDirty())
+	if err != nil {
+		return err
+	}
+
+	var snapshotOps []types.ReplicationOp
+	for _, buddy := range buddies {
+		link, ok := linksByUrl[buddy]
+		if !ok {
+			link, err = r.subscribeBuddy(self, buddy)
+			if err != nil {
+				log.Printf("failed to subscribe buddy %v: %v\n", buddy, err.Error())
+				continue
+			}
+		}
+
+		batch := types.ReplicationBatch{Seq: time.Now().UnixNano(), Ops: changedOps}
+		if !link.Synced {
+			if snapshotOps == nil {
+				snapshotOps, err = r.snapshotOps()
+				if err != nil {
+					return err
+				}
+			}
+			batch.Full = true
+			batch.Ops = snapshotOps
+		}
+
+		err = r.sendBatch(self, link, batch)
+		link.Synced = err == nil
+		if err != nil {
+			log.Printf("failed to replicate to %v: %v\n", buddy, err.Error())
+		} else {
+			link.Seq = batch.Seq
+			link.LastSeen = time.Now()
+		}
+		if err := r.replicationRepo.UpsertLink(link); err != nil {
+			log.Println(err.Error())
+		}
+	}
+
+	return nil
+}
+
+// configured buddies first, otherwise the closest in area peers
+func (r *ReplicationService) selectBuddies(self models.Peer) ([]string, error) {
+	if len(r.buddyUrls) > 0 {
+		buddies := []string{}
+		for _, buddyUrl := range r.buddyUrls {
+			if buddyUrl != self.Url {
+				buddies = append(buddies, buddyUrl)
+			}
+		}
+		return buddies, nil
+	}
+
+	if r.buddyCount == 0 || len(self.InAreaPeers) == 0 {
+		return []string{}, nil
+	}
+
+	neighbors, err := r.peerRepo.GetManyByIds(self.InAreaPeers)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(neighbors, func(i, j int) bool {
+		return math.Abs(r.geo.GetCoordDistance(self.Center, neighbors[i].Center)) <
+			math.Abs(r.geo.GetCoordDistance(self.Center, neighbors[j].Center))
+	})
+
+	buddies := []string{}
+	for _, neighbor := range neighbors {
+		if len(buddies) == r.buddyCount {
+			break
+		}
+		if neighbor.Url != self.Url {
+			buddies = append(buddies, neighbor.Url)
+		}
+	}
+	return buddies, nil
+}
+
+func (r *ReplicationService) changedOps(ids []primitive.ObjectID) ([]types.ReplicationOp, error) {
+	ops := []types.ReplicationOp{}
+	for i, id := range ids {
+		restaurant, err := r.restaurantRepo.FindOne(map[string]interface{}{"_id": id})
+		if err == mongo.ErrNoDocuments {
+			ops = append(ops, types.ReplicationOp{Type: REPLICATION_DELETE, RestaurantId: id.Hex()})
+			continue
+		}
+		var versions []models.MenuVersion
+		var staff []models.StaffUser
+		if err == nil {
+			versions, err = r.menuVersionRepo.FindMany(map[string]interface{}{"restaurantId": id})
+		}
+		if err == nil {
+			staff, err = r.staffRepo.FindMany(id)
+		}
+		if err != nil {
+			// retried on the next sync
+			for _, pendingId := range ids[i:] {
+				r.RestaurantChanged(pendingId)
+			}
+			return nil, err
+		}
+		ops = append(ops, upsertOp(restaurant, versions, staff))
+	}
+	return ops, nil
+}
+
+func (r *ReplicationService) snapshotOps() ([]types.ReplicationOp, error) {
+	restaurants, err := r.restaurantRepo.FindMany(map[string]interface{}{})
+	if err != nil {
+		return nil, err
+	}
+
+	ops := []types.ReplicationOp{}
+	for _, restaurant := range restaurants {
+		versions, err := r.menuVersionRepo.FindMany(map[string]interface{}{"restaurantId": restaurant.Id})
+		if err != nil {
+			return nil, err
+		}
+		staff, err := r.staffRepo.FindMany(restaurant.Id)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, upsertOp(restaurant, versions, staff))
+	}
+	return ops, nil
+}
+
+func upsertOp(restaurant models.Restaurant, versions []models.MenuVersion, staff []models.StaffUser) types.ReplicationOp {
+	return types.ReplicationOp{
+		Type:         REPLICATION_UPSERT,
+		RestaurantId: restaurant.Id.Hex(),
+		Restaurant: &types.TransferredRestaurant{
+			Restaurant:   restaurant,
+			UserName:     restaurant.UserName,
+			Password:     restaurant.Password,
+			MenuVersions: versions,
+			StaffUsers:   transferStaffUsers(staff),
+		},
+	}
+}
+
+// the buddy pulls the secret back from this peer, so it knows who it is talking to
+func (r *ReplicationService) subscribeBuddy(self models.Peer, buddyUrl string) (models.ReplicationLink, error) {
+	secret, err := newHandoffToken()
+	if err != nil {
+		return models.ReplicationLink{}, err
+	}
+	token, err := newHandoffToken()
+	if err != nil {
+		return models.ReplicationLink{}, err
+	}
+	id := primitive.NewObjectID().Hex()
+
+	r.mutex.Lock()
+	r.handshakes[id] = pendingHandshake{token, secret}
+	r.mutex.Unlock()
+
+	defer func() {
+		r.mutex.Lock()
+		delete(r.handshakes, id)
+		r.mutex.Unlock()
+	}()
+
+	body, err := json.Marshal(types.ReplicationSubscribe{Id: id, Token: token, From: self.Url})
+	if err != nil {
+		return models.ReplicationLink{}, err
+	}
+
+	resp, err := http.Post(buddyUrl+"/peer/replication/subscribe", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		return models.ReplicationLink{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return models.ReplicationLink{}, fmt.Errorf("peer responded with status %d", resp.StatusCode)
+	}
+
+	link := models.ReplicationLink{Url: buddyUrl, Role: models.REPLICATION_BUDDY, Secret: secret}
+	return link, r.replicationRepo.UpsertLink(link)
+}
+
+func (r *ReplicationService) GetHandshakeSecret(id string, token string) (string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	handshake, ok := r.handshakes[id]
+	if !ok || subtle.ConstantTimeCompare([]byte(handshake.token), []byte(token)) != 1 {
+		return "", ErrHandoffNotFound
+	}
+	return handshake.secret, nil
+}
+
+func (r *ReplicationService) Subscribe(request types.ReplicationSubscribe) error {
+	self, err := r.peerRepo.GetSelf()
+	if err != nil {
+		return err
+	}
+
+	fromPeers, err := r.peerRepo.FindMany(map[string]interface{}{"url": request.From})
+	if err != nil {
+		return err
+	}
+	if len(fromPeers) == 0 || request.From == self.Url {
+		return ErrUnknownPeer
+	}
+
+	url, err := url.Parse(fmt.Sprintf("%s/peer/replication/handshake/%s", request.From, url.PathEscape(request.Id)))
+	if err != nil {
+		return err
+	}
+	query := url.Query()
+	query.Add("token", request.Token)
+	url.RawQuery = query.Encode()
+
+	resp, err := http.Get(url.String())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("peer responded with status %d", resp.StatusCode)
+	}
+
+	handshake := types.ReplicationHandshake{}
+	err = json.NewDecoder(resp.Body).Decode(&handshake)
+	if err != nil {
+		return err
+	}
+	if handshake.Secret == "" {
+		return ErrReplicationUnauthorized
+	}
+
+	return r.replicationRepo.UpsertLink(models.ReplicationLink{
+		Url:      request.From,
+		Role:     models.REPLICATION_ORIGIN,
+		Secret:   handshake.Secret,
+		Synced:   true,
+		LastSeen: time.Now(),
+	})
+}
+
+func (r *ReplicationService) sendBatch(self models.Peer, link models.ReplicationLink, batch types.ReplicationBatch) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, link.Url+"/peer/replication/stream", bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(PEER_URL_HEADER, self.Url)
+	req.Header.Set(REPLICATION_SIGNATURE_HEADER, signReplicationBatch(link.Secret, body))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("peer responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func signReplicationBatch(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (r *ReplicationService) ReceiveBatch(from string, signature string, body []byte) error {
+	link, err := r.replicationRepo.FindLink(from, models.REPLICATION_ORIGIN)
+	if err == mongo.ErrNoDocuments {
+		return ErrReplicationUnauthorized
+	}
+	if err != nil {
+		return err
+	}
+
+	expected := signReplicationBatch(link.Secret, body)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrReplicationUnauthorized
+	}
+
+	batch := types.ReplicationBatch{}
+	if err := json.Unmarshal(body, &batch); err != nil {
+		return err
+	}
+	if batch.Seq <= link.Seq {
+		return ErrReplicationReplay
+	}
+
+	ids := []primitive.ObjectID{}
+	for _, op := range batch.Ops {
+		id, err := primitive.ObjectIDFromHex(op.RestaurantId)
+		if err != nil {
+			return err
+		}
+
+		switch op.Type {
+		case REPLICATION_UPSERT:
+			if op.Restaurant == nil {
+				continue
+			}
+			restaurant := op.Restaurant.Restaurant
+			restaurant.Id = id
+			restaurant.UserName = op.Restaurant.UserName
+			restaurant.Password = op.Restaurant.Password
+			err = r.replicationRepo.UpsertReplica(models.RestaurantReplica{
+				Id:           id,
+				Origin:       from,
+				Restaurant:   restaurant,
+				MenuVersions: op.Restaurant.MenuVersions,
+				StaffUsers:   receiveStaffUsers(id, op.Restaurant.StaffUsers),
+				UpdatedAt:    time.Now(),
+			})
+			if err == repositories.ErrReplicaOtherOrigin {
+				log.Printf("%v rejected replica %v: %v\n", from, id.Hex(), err.Error())
+				continue
+			}
+			ids = append(ids, id)
+		case REPLICATION_DELETE:
+			err = r.replicationRepo.DeleteReplica(id, from)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if batch.Full {
+		if err := r.replicationRepo.DeleteReplicasNotIn(from, ids); err != nil {
+			return err
+		}
+	}
+
+	link.Seq = batch.Seq
+	link.LastSeen = time.Now()
+	return r.replicationRepo.UpsertLink(link)
+}
+
+// replicas are served read only, without credentials
+func (r *ReplicationService) GetReplica(id primitive.ObjectID) (models.Restaurant, error) {
+	replica, err := r.replicationRepo.FindReplica(id)
+	if err != nil {
+		return models.Restaurant{}, err
+	}
+	return readOnlyReplica(replica), nil
+}
+
+func (r *ReplicationService) GetReplicas(origin string) ([]models.Restaurant, error) {
+	replicas, err := r.replicationRepo.FindReplicasByOrigin(origin)
+	if err != nil {
+		return nil, err
+	}
+
+	restaurants := []models.Restaurant{}
+	for _, replica := range replicas {
+		restaurants = append(restaurants, readOnlyReplica(replica))
+	}
+	return restaurants, nil
+}
+
+func readOnlyReplica(replica models.RestaurantReplica) models.Restaurant {
+	restaurant := replica.Restaurant
+	restaurant.Id = replica.Id
+	restaurant.UserName = ""
+	restaurant.Password = ""
+	restaurant.SetupTokenHash = ""
+	restaurant.SetupExpiresAt = nil
+	return restaurant
+}
+
+// an origin is dead when it stopped sending heartbeats and does not answer
+func (r *ReplicationService) Promote(origin string, force bool) ([]string, error) {
+	link, err := r.replicationRepo.FindLink(origin, models.REPLICATION_ORIGIN)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrUnknownPeer
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !force && (time.Since(link.LastSeen) < r.deadAfter || isReachable(origin)) {
+		return nil, ErrOriginAlive
+	}
+
+	replicas, err := r.replicationRepo.FindReplicasByOrigin(origin)
+	if err != nil {
+		return nil, err
+	}
+
+	promoted := []string{}
+	for _, replica := range replicas {
+		restaurant := replica.Restaurant
+		restaurant.Id = replica.Id
+
+		// versions and staff go first, a failure leaves the replica to promote again
+		if err := insertMenuVersions(r.menuVersionRepo, replica.MenuVersions); err != nil {
+			log.Printf("failed to promote restaurant %v: %v\n", replica.Id.Hex(), err.Error())
+			continue
+		}
+		if err := upsertStaffUsers(r.staffRepo, replica.StaffUsers); err != nil {
+			log.Printf("failed to promote restaurant %v: %v\n", replica.Id.Hex(), err.Error())
+			continue
+		}
+
+		_, err := r.restaurantRepo.Insert(restaurant)
+		if err != nil {
+			if _, findErr := r.restaurantRepo.FindOne(map[string]interface{}{"_id": replica.Id}); findErr != nil {
+				log.Printf("failed to promote restaurant %v: %v\n", replica.Id.Hex(), err.Error())
+				continue
+			}
+		}
+		r.RestaurantChanged(replica.Id)
+
+		if err := r.replicationRepo.DeleteReplica(replica.Id, origin); err != nil {
+			log.Println(err.Error())
+		}
+		promoted = append(promoted, replica.Id.Hex())
+	}
+
+	if len(promoted) == len(replicas) {
+		err = r.replicationRepo.DeleteLink(origin, models.REPLICATION_ORIGIN)
+		if err != nil {
+			return promoted, err
+		}
+	}
+
+	return promoted, nil
+}
+
+func isReachable(peerUrl string) bool {
+	client := http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(peerUrl)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return true
+}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/joho/godotenv"
+	"github.com/nicodeheza/peersEat/mocks"
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/services/geo"
+	"github.com/nicodeheza/peersEat/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type replicationTest struct {
+	service         *ReplicationService
+	peerRepo        *mocks.PeerRepositoryMock
+	restaurantRepo  *mocks.RestaurantRepositoryMock
+	replicationRepo *mocks.ReplicationRepositoryMock
+	menuVersionRepo *mocks.MenuVersionRepositoryMock
+	staffRepo       *mocks.StaffUserRepositoryMock
+}
+
+func initReplicationTest() replicationTest {
+	err := godotenv.Load("../.env")
+	if err != nil {
+		log.Fatal("Error loading .env file")
+	}
+	os.Setenv("REPLICATION_BUDDIES", "http://buddy.com")
+
+	peerRepo := mocks.NewPeerRepository()
+	peerRepo.Peers = []models.Peer{{Id: primitive.NewObjectID(), Url: "http://origin.com", Center: eastOfCenter(0.02)}}
+
+	restaurantRepo := mocks.NewRestaurantRepositoryMock()
+	restaurantRepo.Restaurants = []models.Restaurant{
+		{Id: primitive.NewObjectID(), Name: "first", Coord: eastOfCenter(0.01), UserName: "user1", Password: "hash1"},
+		{Id: primitive.NewObjectID(), Name: "second", Coord: eastOfCenter(0.02), UserName: "user2", Password: "hash2"},
+	}
+
+	replicationRepo := mocks.NewReplicationRepositoryMock()
+	menuVersionRepo := mocks.NewMenuVersionRepositoryMock()
+	staffRepo := mocks.NewStaffUserRepositoryMock()
+	staffRepo.Users = []models.StaffUser{{Id: primitive.NewObjectID(), RestaurantId: restaurantRepo.Restaurants[0].Id, UserName: "waiter", Password: "staffHash"}}
+	service := NewReplicationService(peerRepo, restaurantRepo, replicationRepo, menuVersionRepo, staffRepo, geo.NewGeo())
+
+	return replicationTest{service, peerRepo, restaurantRepo, replicationRepo, menuVersionRepo, staffRepo}
+}
+
+func signedBatch(t *testing.T, secret string, batch types.ReplicationBatch) ([]byte, string) {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return body, signReplicationBatch(secret, body)
+}
+
+func TestReplicationSync(t *testing.T) {
+	test := initReplicationTest()
+	defer os.Unsetenv("REPLICATION_BUDDIES")
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	var secret string
+	httpmock.RegisterResponder("POST", "http://buddy.com/peer/replication/subscribe",
+		func(req *http.Request) (*http.Response, error) {
+			request := types.ReplicationSubscribe{}
+			json.NewDecoder(req.Body).Decode(&request)
+			if request.From != os.Getenv("HOST") {
+				return httpmock.NewStringResponse(http.StatusBadRequest, ""), nil
+			}
+			var err error
+			secret, err = test.service.GetHandshakeSecret(request.Id, request.Token)
+			if err != nil {
+				return httpmock.NewStringResponse(http.StatusNotFound, ""), nil
+			}
+			return httpmock.NewStringResponse(http.StatusOK, ""), nil
+		})
+
+	batches := []types.ReplicationBatch{}
+	httpmock.RegisterResponder("POST", "http://buddy.com/peer/replication/stream",
+		func(req *http.Request) (*http.Response, error) {
+			body, _ := io.ReadAll(req.Body)
+			if req.Header.Get(PEER_URL_HEADER) != os.Getenv("HOST") ||
+				req.Header.Get(REPLICATION_SIGNATURE_HEADER) != signReplicationBatch(secret, body) {
+				return httpmock.NewStringResponse(http.StatusUnauthorized, ""), nil
+			}
+			batch := types.ReplicationBatch{}
+			json.Unmarshal(body, &batch)
+			batches = append(batches, batch)
+			return httpmock.NewStringResponse(http.StatusOK, ""), nil
+		})
+
+	if err := test.service.Sync(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(batches) != 1 || !batches[0].Full || len(batches[0].Ops) != 2 {
+		t.Fatalf("expecting a full snapshot of both restaurants but got %v", batches)
+	}
+	op := batches[0].Ops[0]
+	if op.Type != REPLICATION_UPSERT || op.Restaurant == nil || op.Restaurant.UserName != "user1" || op.Restaurant.Password != "hash1" {
+		t.Errorf("expecting the snapshot to carry the credentials but got %v", op)
+	}
+	if staff := op.Restaurant.StaffUsers; len(staff) != 1 || staff[0].Password != "staffHash" {
+		t.Errorf("expecting the snapshot to carry the staff users but got %v", staff)
+	}
+
+	link, err := test.replicationRepo.FindLink("http://buddy.com", models.REPLICATION_BUDDY)
+	if err != nil || !link.Synced || link.Secret != secret {
+		t.Errorf("expecting a synced buddy link but got %v, %v", link, err)
+	}
+
+	deleted := primitive.NewObjectID()
+	test.service.RestaurantChanged(deleted)
+	if err := test.service.Sync(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(batches) != 2 || batches[1].Full || len(batches[1].Ops) != 1 {
+		t.Fatalf("expecting an incremental batch but got %v", batches)
+	}
+	if batches[1].Ops[0].Type != REPLICATION_DELETE || batches[1].Ops[0].RestaurantId != deleted.Hex() {
+		t.Errorf("expecting a delete op but got %v", batches[1].Ops[0])
+	}
+	if httpmock.GetCallCountInfo()["POST http://buddy.com/peer/replication/subscribe"] != 1 {
+		t.Errorf("expecting the buddy to be subscribed only once")
+	}
+}
+
+func TestReceiveBatch(t *testing.T) {
+	test := initReplicationTest()
+	defer os.Unsetenv("REPLICATION_BUDDIES")
+
+	test.replicationRepo.Links = []models.ReplicationLink{{Url: "http://origin.com", Role: models.REPLICATION_ORIGIN, Secret: "secret"}}
+	first := test.restaurantRepo.Restaurants[0]
+	second := test.restaurantRepo.Restaurants[1]
+
+	version := models.MenuVersion{Id: primitive.NewObjectID(), RestaurantId: first.Id, Name: "main", Version: 1}
+	body, signature := signedBatch(t, "secret", types.ReplicationBatch{Seq: 1, Full: true, Ops: []types.ReplicationOp{upsertOp(first, []models.MenuVersion{version}, test.staffRepo.Users)}})
+
+	if err := test.service.ReceiveBatch("http://unknown.com", signature, body); err != ErrReplicationUnauthorized {
+		t.Errorf("expecting unknown origins to be rejected but got %v", err)
+	}
+	if err := test.service.ReceiveBatch("http://origin.com", "bad", body); err != ErrReplicationUnauthorized {
+		t.Errorf("expecting bad signatures to be rejected but got %v", err)
+	}
+	if err := test.service.ReceiveBatch("http://origin.com", signature, body); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := test.service.ReceiveBatch("http://origin.com", signature, body); err != ErrReplicationReplay {
+		t.Errorf("expecting replays to be rejected but got %v", err)
+	}
+
+	replica, err := test.service.GetReplica(first.Id)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if replica.Name != "first" || replica.UserName != "" || replica.Password != "" {
+		t.Errorf("expecting a read only replica without credentials but got %v", replica)
+	}
+	if test.replicationRepo.Replicas[0].Restaurant.Password != "hash1" {
+		t.Errorf("expecting the stored replica to keep the credentials for promotion")
+	}
+	if versions := test.replicationRepo.Replicas[0].MenuVersions; len(versions) != 1 || versions[0].Id != version.Id {
+		t.Errorf("expecting the replica to keep the published menus but got %v", versions)
+	}
+	if staff := test.replicationRepo.Replicas[0].StaffUsers; len(staff) != 1 || staff[0].Password != "staffHash" || staff[0].RestaurantId != first.Id {
+		t.Errorf("expecting the replica to keep the staff users but got %v", staff)
+	}
+
+	body, signature = signedBatch(t, "secret", types.ReplicationBatch{Seq: 2, Full: true, Ops: []types.ReplicationOp{upsertOp(second, nil, nil)}})
+	if err := test.service.ReceiveBatch("http://origin.com", signature, body); err != nil {
+		t.Fatal(err.Error())
+	}
+	replicas, _ := test.service.GetReplicas("http://origin.com")
+	if len(replicas) != 1 || replicas[0].Id != second.Id {
+		t.Errorf("expecting a full batch to replace the replicas but got %v", replicas)
+	}
+
+	// another origin can not overwrite the replica
+	test.replicationRepo.Links = append(test.replicationRepo.Links, models.ReplicationLink{Url: "http://other.com", Role: models.REPLICATION_ORIGIN, Secret: "other"})
+	stolen := second
+	stolen.Name = "stolen"
+	body, signature = signedBatch(t, "other", types.ReplicationBatch{Seq: 1, Ops: []types.ReplicationOp{upsertOp(stolen, nil, nil), upsertOp(first, nil, nil)}})
+	if err := test.service.ReceiveBatch("http://other.com", signature, body); err != nil {
+		t.Fatal(err.Error())
+	}
+	replica, _ = test.service.GetReplica(second.Id)
+	if replica.Name != "second" {
+		t.Errorf("expecting the replica of origin to be kept but got %v", replica)
+	}
+	replicas, _ = test.service.GetReplicas("http://other.com")
+	if len(replicas) != 1 || replicas[0].Id != first.Id {
+		t.Errorf("expecting the other ops of the batch to be applied but got %v", replicas)
+	}
+}
+
+func TestPromote(t *testing.T) {
+	test := initReplicationTest()
+	defer os.Unsetenv("REPLICATION_BUDDIES")
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("GET", "http://origin.com", httpmock.NewStringResponder(http.StatusOK, ""))
+
+	restaurant := models.Restaurant{Id: primitive.NewObjectID(), Name: "replicated", UserName: "user", Password: "hash"}
+	test.restaurantRepo.Restaurants = []models.Restaurant{}
+	test.replicationRepo.Links = []models.ReplicationLink{{Url: "http://origin.com", Role: models.REPLICATION_ORIGIN, Secret: "secret", LastSeen: time.Now()}}
+	version := models.MenuVersion{Id: primitive.NewObjectID(), RestaurantId: restaurant.Id, Name: "main", Version: 1}
+	staff := models.StaffUser{Id: primitive.NewObjectID(), RestaurantId: restaurant.Id, UserName: "waiter", Password: "staffHash"}
+	test.staffRepo.Users = nil
+	test.replicationRepo.Replicas = []models.RestaurantReplica{{Id: restaurant.Id, Origin: "http://origin.com", Restaurant: restaurant, MenuVersions: []models.MenuVersion{version}, StaffUsers: []models.StaffUser{staff}}}
+
+	if _, err := test.service.Promote("http://unknown.com", false); err != ErrUnknownPeer {
+		t.Errorf("expecting ErrUnknownPeer but got %v", err)
+	}
+	if _, err := test.service.Promote("http://origin.com", false); err != ErrOriginAlive {
+		t.Errorf("expecting a recently seen origin to be alive but got %v", err)
+	}
+
+	test.replicationRepo.Links[0].LastSeen = time.Now().Add(-time.Hour)
+	if _, err := test.service.Promote("http://origin.com", false); err != ErrOriginAlive {
+		t.Errorf("expecting a reachable origin to be alive but got %v", err)
+	}
+
+	httpmock.RegisterResponder("GET", "http://origin.com", httpmock.NewErrorResponder(http.ErrServerClosed))
+	promoted, err := test.service.Promote("http://origin.com", false)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(promoted) != 1 || promoted[0] != restaurant.Id.Hex() {
+		t.Errorf("expecting the replica to be promoted but got %v", promoted)
+	}
+	if len(test.restaurantRepo.InsertCalls) != 1 || test.restaurantRepo.InsertCalls[0].Password != "hash" {
+		t.Errorf("expecting the restaurant to be inserted with its credentials but got %v", test.restaurantRepo.InsertCalls)
+	}
+	if len(test.menuVersionRepo.Versions) != 1 || test.menuVersionRepo.Versions[0].Id != version.Id {
+		t.Errorf("expecting the published menus to be promoted with their ids but got %v", test.menuVersionRepo.Versions)
+	}
+	if len(test.staffRepo.Users) != 1 || test.staffRepo.Users[0].Password != "staffHash" {
+		t.Errorf("expecting the staff users to be promoted with their credentials but got %v", test.staffRepo.Users)
+	}
+	if len(test.replicationRepo.Replicas) != 0 || len(test.replicationRepo.Links) != 0 {
+		t.Errorf("expecting the replicas and the origin link to be removed")
+	}
+	if !test.service.dirty[restaurant.Id] {
+		t.Errorf("expecting the promoted restaurant to be replicated to this peer buddies")
+	}
+}
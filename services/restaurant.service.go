@@ -2,43 +2,74 @@ package services
 
 import (
 	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"time"
 
+	"github.com/nicodeheza/peersEat/constants"
 	"github.com/nicodeheza/peersEat/models"
 	"github.com/nicodeheza/peersEat/repositories"
 	"github.com/nicodeheza/peersEat/services/geo"
+	"github.com/nicodeheza/peersEat/services/geocoder"
 	"github.com/nicodeheza/peersEat/types"
 	"github.com/nicodeheza/peersEat/utils"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type RestaurantService struct {
 	repo        repositories.RestaurantRepositoryI
 	authHelpers utils.AuthHelpersI
+	geocoder    geocoder.GeocoderI
 	geo         geo.GeoServiceI
+	staff       StaffServiceI
 }
 
 type RestaurantServiceI interface {
 	CompleteRestaurantInitialData(newRestaurant *models.Restaurant) (string, error)
 	AddNewRestaurant(newRestaurant models.Restaurant) (primitive.ObjectID, error)
+	NewSetupToken(id primitive.ObjectID) (string, error)
+	SetupAccount(data types.RestaurantSetupData) error
 	UpdateRestaurantUsernameAndPassword(id primitive.ObjectID, newPassword string, newUserNames string) error
 	Authenticate(password, userName string) (bool, string, error)
-	UpdateData(data types.RestaurantData) (bool, models.GeoCoords, float64, error)
+	UpdateData(id primitive.ObjectID, data types.RestaurantData) (bool, models.GeoCoords, float64, error)
+	GetById(id primitive.ObjectID) (models.Restaurant, error)
+	UpdateAddress(id primitive.ObjectID, data types.RestaurantAddressData, selfPeer models.Peer) (models.Restaurant, error)
+	OverrideCoord(id primitive.ObjectID, coord models.GeoCoords, selfPeer models.Peer) (models.Restaurant, error)
+	ClearCoordOverride(id primitive.ObjectID, selfPeer models.Peer) (models.Restaurant, error)
+	UpdateOpeningHours(id primitive.ObjectID, data types.OpeningHoursData) (models.OpeningHours, error)
+	IsOpenAt(id primitive.ObjectID, t time.Time) (bool, error)
+	NextOpening(id primitive.ObjectID, t time.Time) (time.Time, bool, error)
+	List(query types.RestaurantListQuery, at time.Time) ([]types.PublicRestaurant, error)
+	ChangeStatus(id primitive.ObjectID, status string, reason string, by string) (models.Restaurant, error)
 }
 
-func NewRestaurantService(repository repositories.RestaurantRepositoryI, authHelpers utils.AuthHelpersI, geo geo.GeoServiceI) *RestaurantService {
-	return &RestaurantService{repository, authHelpers, geo}
+var ErrOutOfArea = errors.New("restaurant out of area")
+var ErrInvalidOpeningHours = errors.New("invalid opening hours")
+var ErrInvalidStatusChange = errors.New("invalid status change")
+var ErrStatusReasonRequired = errors.New("a reason is required to reject or suspend a restaurant")
+var ErrRestaurantNotActive = errors.New("restaurant not active")
+var ErrRestaurantPaused = errors.New("restaurant paused, it's not connected")
+var ErrRestaurantClosed = errors.New("restaurant closed at that time")
+var ErrSetupTokenNotFound = errors.New("setup token not found")
+var ErrSetupTokenExpired = errors.New("setup token expired")
+var ErrAccountAlreadySetUp = errors.New("restaurant account already set up")
+
+func NewRestaurantService(repository repositories.RestaurantRepositoryI, authHelpers utils.AuthHelpersI, geocoder geocoder.GeocoderI, geo geo.GeoServiceI, staff StaffServiceI) *RestaurantService {
+	return &RestaurantService{repository, authHelpers, geocoder, geo, staff}
 }
 
 func (r *RestaurantService) CompleteRestaurantInitialData(newRestaurant *models.Restaurant) (string, error) {
-	coords, err := r.geo.GetAddressCoords(newRestaurant.Address, newRestaurant.City, newRestaurant.Country)
+	geocoded, err := r.geocoder.Geocode(newRestaurant.Address, newRestaurant.City, newRestaurant.Country)
 	if err != nil {
 		return "", err
 	}
 
-	newRestaurant.Coord = coords
+	newRestaurant.Coord = geocoded.Coord
 
-	newPassword := r.authHelpers.GetRandomPassword(20)
-	newHash, err := r.authHelpers.HashPasswords(newPassword)
+	token, err := newSecretToken()
 	if err != nil {
 		return "", err
 	}
@@ -47,14 +78,26 @@ func (r *RestaurantService) CompleteRestaurantInitialData(newRestaurant *models.
 		return "", err
 	}
 
-	newRestaurant.Password = newHash
+	// there is no password until the owner sets one with the setup token
+	expiresAt := time.Now().Add(constants.RESTAURANT_SETUP_TTL)
 	newRestaurant.UserName = newUserName
 	newRestaurant.IsFinalPassword = false
+	newRestaurant.SetupTokenHash = hashSecretToken(token)
+	newRestaurant.SetupExpiresAt = &expiresAt
+
+	// new restaurants are drafts until the peer owner approves them
+	newRestaurant.Status = models.RESTAURANT_DRAFT
+	newRestaurant.StatusHistory = []models.StatusChange{{To: models.RESTAURANT_DRAFT, By: models.STATUS_BY_PEER_OWNER, At: time.Now()}}
 
-	return newPassword, nil
+	return token, nil
 }
 
+// restaurants without a currency take the one of the peer
 func (r *RestaurantService) AddNewRestaurant(newRestaurant models.Restaurant) (primitive.ObjectID, error) {
+	newRestaurant.Currency = newRestaurant.CurrencyOrDefault()
+	newRestaurant.Menu.AssignIds()
+	newRestaurant.Menu.InCurrency(newRestaurant.Currency)
+	newRestaurant.DeliveryCost = newRestaurant.DeliveryCost.In(newRestaurant.Currency)
 	id, err := r.repo.Insert(newRestaurant)
 	if err != nil {
 		return id, err
@@ -62,7 +105,68 @@ func (r *RestaurantService) AddNewRestaurant(newRestaurant models.Restaurant) (p
 	return id, nil
 }
 
+// replaces the previous token, for owners that lost it or let it expire
+func (r *RestaurantService) NewSetupToken(id primitive.ObjectID) (string, error) {
+	restaurant, err := r.repo.FindOne(map[string]interface{}{"_id": id})
+	if err != nil {
+		return "", err
+	}
+	if restaurant.IsFinalPassword {
+		return "", ErrAccountAlreadySetUp
+	}
+
+	token, err := newSecretToken()
+	if err != nil {
+		return "", err
+	}
+	expiresAt := time.Now().Add(constants.RESTAURANT_SETUP_TTL)
+	err = r.repo.Update(id, map[string]interface{}{"setupTokenHash": hashSecretToken(token), "setupExpiresAt": expiresAt})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// the token works once, the restaurant logs in with the credentials set here
+func (r *RestaurantService) SetupAccount(data types.RestaurantSetupData) error {
+	restaurant, err := r.repo.FindOne(map[string]interface{}{"setupTokenHash": hashSecretToken(data.Token)})
+	if err == mongo.ErrNoDocuments || (err == nil && restaurant.IsFinalPassword) {
+		return ErrSetupTokenNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if restaurant.SetupExpiresAt == nil || time.Now().After(*restaurant.SetupExpiresAt) {
+		return ErrSetupTokenExpired
+	}
+
+	if err := r.staff.CheckUserName(data.UserName, restaurant.Id); err != nil {
+		return err
+	}
+
+	hash, err := r.authHelpers.HashPasswords(data.Password)
+	if err != nil {
+		return err
+	}
+	// the token is cleared with the same update, so two requests can't both use it
+	updated, err := r.repo.UpdateWhere(restaurant.Id,
+		map[string]interface{}{"setupTokenHash": restaurant.SetupTokenHash, "isFinalPassword": false},
+		map[string]interface{}{"userName": data.UserName, "password": hash, "isFinalPassword": true},
+		[]string{"setupTokenHash", "setupExpiresAt"},
+	)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return ErrSetupTokenNotFound
+	}
+	return nil
+}
+
 func (r *RestaurantService) UpdateRestaurantUsernameAndPassword(id primitive.ObjectID, newPassword string, newUserNames string) error {
+	if err := r.staff.CheckUserName(newUserNames, id); err != nil {
+		return err
+	}
 	hash, err := r.authHelpers.HashPasswords(newPassword)
 	if err != nil {
 		return err
@@ -84,36 +188,320 @@ func (r *RestaurantService) Authenticate(password, userName string) (bool, strin
 	return res, id, nil
 }
 
-func (r *RestaurantService) UpdateData(data types.RestaurantData) (bool, models.GeoCoords, float64, error) {
-
-	original, err := r.repo.FindOne(map[string]interface{}{"_id": data.Id})
+func (r *RestaurantService) UpdateData(id primitive.ObjectID, data types.RestaurantData) (bool, models.GeoCoords, float64, error) {
+	original, err := r.repo.FindOne(map[string]interface{}{"_id": id})
+	if err != nil {
+		return false, models.GeoCoords{}, 0, err
+	}
+	deliveryRadUpdated := !(original.DeliveryRadius == data.DeliveryRadius) ||
+		!reflect.DeepEqual(original.DeliveryZone, data.DeliveryZone)
+	deliveryCost, err := data.DeliveryCost.As(original.CurrencyOrDefault())
 	if err != nil {
 		return false, models.GeoCoords{}, 0, err
 	}
-	deliveryRadUpdated := !(original.DeliveryRadius == data.DeliveryRadius)
 
 	updates := make(map[string]interface{})
 	updates["name"] = data.Name
-	updates["ImageUrl"] = data.ImageUrl
+	updates["imageUrl"] = data.ImageUrl
 	updates["openTime"] = data.OpenTime
 	updates["closeTime"] = data.CloseTime
 	updates["phone"] = data.Phone
-	updates["deliveryCost"] = data.DeliveryCost
+	updates["deliveryCost"] = deliveryCost
 	updates["isDeliveryFixCost"] = data.IsDeliveryFixCost
 	updates["minDeliveryTime"] = data.MinDeliveryTime
 	updates["maxDeliveryTime"] = data.MaxDeliveryTime
 	updates["deliveryRadius"] = data.DeliveryRadius
+	updates["deliveryZone"] = data.DeliveryZone
+	updates["tags"] = data.Tags
 
-	id, err := primitive.ObjectIDFromHex(data.Id)
+	err = r.repo.Update(id, updates)
 	if err != nil {
 		return false, models.GeoCoords{}, 0, err
 	}
+
+	return deliveryRadUpdated, original.Coord, data.DeliveryRadius, nil
+}
+
+func (r *RestaurantService) GetById(id primitive.ObjectID) (models.Restaurant, error) {
+	return r.repo.FindOne(map[string]interface{}{"_id": id})
+}
+
+// a manually overridden coord is kept when the address changes
+func (r *RestaurantService) UpdateAddress(id primitive.ObjectID, data types.RestaurantAddressData, selfPeer models.Peer) (models.Restaurant, error) {
+	restaurant, err := r.GetById(id)
+	if err != nil {
+		return models.Restaurant{}, err
+	}
+
+	updates := map[string]interface{}{
+		"address": data.Address,
+		"city":    data.City,
+		"country": data.Country,
+	}
+
+	if !restaurant.IsCoordOverridden {
+		geocoded, err := r.geocoder.Geocode(data.Address, data.City, data.Country)
+		if err != nil {
+			return models.Restaurant{}, err
+		}
+		if !r.geo.IsInInfluenceArea(selfPeer, geocoded.Coord) {
+			return models.Restaurant{}, ErrOutOfArea
+		}
+		restaurant.Coord = geocoded.Coord
+		updates["coord"] = geocoded.Coord
+	}
+
 	err = r.repo.Update(id, updates)
 	if err != nil {
-		return false, models.GeoCoords{}, 0, err
+		return models.Restaurant{}, err
 	}
 
-	return deliveryRadUpdated, original.Coord, data.DeliveryRadius, nil
+	restaurant.Address = data.Address
+	restaurant.City = data.City
+	restaurant.Country = data.Country
+
+	return restaurant, nil
+}
+
+func (r *RestaurantService) OverrideCoord(id primitive.ObjectID, coord models.GeoCoords, selfPeer models.Peer) (models.Restaurant, error) {
+	if !r.geo.IsInInfluenceArea(selfPeer, coord) {
+		return models.Restaurant{}, ErrOutOfArea
+	}
+
+	restaurant, err := r.GetById(id)
+	if err != nil {
+		return models.Restaurant{}, err
+	}
+
+	err = r.repo.Update(id, map[string]interface{}{"coord": coord, "isCoordOverridden": true})
+	if err != nil {
+		return models.Restaurant{}, err
+	}
+
+	restaurant.Coord = coord
+	restaurant.IsCoordOverridden = true
+
+	return restaurant, nil
 }
 
-// update menu
+func (r *RestaurantService) ClearCoordOverride(id primitive.ObjectID, selfPeer models.Peer) (models.Restaurant, error) {
+	restaurant, err := r.GetById(id)
+	if err != nil {
+		return models.Restaurant{}, err
+	}
+
+	geocoded, err := r.geocoder.Geocode(restaurant.Address, restaurant.City, restaurant.Country)
+	if err != nil {
+		return models.Restaurant{}, err
+	}
+	if !r.geo.IsInInfluenceArea(selfPeer, geocoded.Coord) {
+		return models.Restaurant{}, ErrOutOfArea
+	}
+
+	err = r.repo.Update(id, map[string]interface{}{"coord": geocoded.Coord, "isCoordOverridden": false})
+	if err != nil {
+		return models.Restaurant{}, err
+	}
+
+	restaurant.Coord = geocoded.Coord
+	restaurant.IsCoordOverridden = false
+
+	return restaurant, nil
+}
+
+// replaces the opening hours, the intervals of a day can not overlap
+func (r *RestaurantService) UpdateOpeningHours(id primitive.ObjectID, data types.OpeningHoursData) (models.OpeningHours, error) {
+	hours := models.OpeningHours{Timezone: data.Timezone, Weekly: []models.DaySchedule{}}
+
+	days := map[time.Weekday][]models.OpeningInterval{}
+	for _, schedule := range data.Weekly {
+		days[schedule.Day] = append(days[schedule.Day], openingIntervals(schedule.Intervals)...)
+	}
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if len(days[day]) == 0 {
+			continue
+		}
+		if err := checkOverlaps(days[day]); err != nil {
+			return models.OpeningHours{}, fmt.Errorf("%w: %v %v", ErrInvalidOpeningHours, day, err.Error())
+		}
+		hours.Weekly = append(hours.Weekly, models.DaySchedule{Day: day, Intervals: days[day]})
+	}
+
+	dates := map[string]bool{}
+	for _, exception := range data.Exceptions {
+		if dates[exception.Date] {
+			return models.OpeningHours{}, fmt.Errorf("%w: repeated exception for %v", ErrInvalidOpeningHours, exception.Date)
+		}
+		dates[exception.Date] = true
+
+		intervals := openingIntervals(exception.Intervals)
+		if err := checkOverlaps(intervals); err != nil {
+			return models.OpeningHours{}, fmt.Errorf("%w: %v %v", ErrInvalidOpeningHours, exception.Date, err.Error())
+		}
+		hours.Exceptions = append(hours.Exceptions, models.ScheduleException{Date: exception.Date, Intervals: intervals, Note: exception.Note})
+	}
+
+	err := r.repo.Update(id, map[string]interface{}{"openingHours": hours})
+	if err != nil {
+		return models.OpeningHours{}, err
+	}
+	return hours, nil
+}
+
+func openingIntervals(data []types.OpeningIntervalData) []models.OpeningInterval {
+	intervals := []models.OpeningInterval{}
+	for _, interval := range data {
+		intervals = append(intervals, models.OpeningInterval{Open: interval.Open, Close: interval.Close})
+	}
+	return intervals
+}
+
+func checkOverlaps(intervals []models.OpeningInterval) error {
+	type span struct{ start, end int }
+	spans := []span{}
+	for _, interval := range intervals {
+		open, _ := time.Parse(models.CLOCK_LAYOUT, interval.Open)
+		closing, _ := time.Parse(models.CLOCK_LAYOUT, interval.Close)
+		start := open.Hour()*60 + open.Minute()
+		end := closing.Hour()*60 + closing.Minute()
+		if end <= start {
+			end += 24 * 60
+		}
+		spans = append(spans, span{start, end})
+	}
+
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	for i := 1; i < len(spans); i++ {
+		if spans[i].start < spans[i-1].end {
+			return errors.New("intervals overlap")
+		}
+	}
+	return nil
+}
+
+func (r *RestaurantService) IsOpenAt(id primitive.ObjectID, t time.Time) (bool, error) {
+	restaurant, err := r.GetById(id)
+	if err != nil {
+		return false, err
+	}
+	return restaurant.Schedule().IsOpenAt(t), nil
+}
+
+func (r *RestaurantService) NextOpening(id primitive.ObjectID, t time.Time) (time.Time, bool, error) {
+	restaurant, err := r.GetById(id)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	next, ok := restaurant.Schedule().NextOpening(t)
+	return next, ok, nil
+}
+
+// NewPublicRestaurant strips the credentials and adds the opening status at the given time
+func NewPublicRestaurant(restaurant models.Restaurant, at time.Time) types.PublicRestaurant {
+	schedule := restaurant.Schedule()
+	public := types.PublicRestaurant{
+		Id:                restaurant.Id,
+		Name:              restaurant.Name,
+		Address:           restaurant.Address,
+		City:              restaurant.City,
+		Country:           restaurant.Country,
+		Coord:             restaurant.Coord,
+		ImageUrl:          restaurant.ImageUrl,
+		Phone:             restaurant.Phone,
+		Rate:              restaurant.Rate,
+		Rating:            restaurant.Rate.Score(),
+		Currency:          restaurant.CurrencyOrDefault(),
+		DeliveryCost:      restaurant.DeliveryCost.In(restaurant.CurrencyOrDefault()),
+		IsDeliveryFixCost: restaurant.IsDeliveryFixCost,
+		MinDeliveryTime:   restaurant.MinDeliveryTime,
+		MaxDeliveryTime:   restaurant.MaxDeliveryTime,
+		DeliveryRadius:    restaurant.DeliveryRadius,
+		DeliveryZone:      restaurant.DeliveryZone,
+		OpeningHours:      schedule,
+		Tags:              restaurant.Tags,
+		Status:            restaurant.CurrentStatus(),
+		IsConnected:       restaurant.IsConnected,
+		IsPaused:          restaurant.IsPaused,
+		LastSeen:          restaurant.LastSeen,
+		IsOpen:            schedule.IsOpenAt(at),
+	}
+	if next, ok := schedule.NextOpening(at); ok {
+		public.NextOpening = &next
+	}
+	return public
+}
+
+// converts the amounts to the restaurant currency, amounts in another one are rejected
+func inCurrency(currency string, amounts ...*models.Money) error {
+	for _, amount := range amounts {
+		converted, err := amount.As(currency)
+		if err != nil {
+			return err
+		}
+		*amount = converted
+	}
+	return nil
+}
+
+// matches the whole city name ignoring case, any city when empty
+func cityFilter(city string) map[string]interface{} {
+	filter := map[string]interface{}{}
+	if city != "" {
+		filter["city"] = primitive.Regex{Pattern: "^" + regexp.QuoteMeta(city) + "$", Options: "i"}
+	}
+	return filter
+}
+
+// lists the restaurants of this peer, the city match is case insensitive
+func (r *RestaurantService) List(query types.RestaurantListQuery, at time.Time) ([]types.PublicRestaurant, error) {
+	restaurants, err := r.repo.FindMany(cityFilter(query.City))
+	if err != nil {
+		return nil, err
+	}
+
+	result := []types.PublicRestaurant{}
+	for _, restaurant := range restaurants {
+		if !restaurant.IsActive() {
+			continue
+		}
+		if query.Lat != nil && query.Long != nil {
+			point := models.GeoCoords{Lat: *query.Lat, Long: *query.Long}
+			if !r.geo.IsInRestaurantDeliveryArea(restaurant, point) {
+				continue
+			}
+		}
+
+		public := NewPublicRestaurant(restaurant, at)
+		if query.Open && (!public.IsOpen || public.IsPaused) {
+			continue
+		}
+		result = append(result, public)
+	}
+	return result, nil
+}
+
+// ChangeStatus moves the restaurant through its lifecycle keeping every change
+// with its reason
+func (r *RestaurantService) ChangeStatus(id primitive.ObjectID, status string, reason string, by string) (models.Restaurant, error) {
+	restaurant, err := r.GetById(id)
+	if err != nil {
+		return models.Restaurant{}, err
+	}
+
+	from := restaurant.CurrentStatus()
+	if !models.CanChangeStatus(from, status) {
+		return models.Restaurant{}, fmt.Errorf("%w: from %v to %v", ErrInvalidStatusChange, from, status)
+	}
+	rejected := from == models.RESTAURANT_PENDING && status == models.RESTAURANT_DRAFT
+	if reason == "" && (rejected || status == models.RESTAURANT_SUSPENDED) {
+		return models.Restaurant{}, ErrStatusReasonRequired
+	}
+
+	restaurant.Status = status
+	restaurant.StatusHistory = append(restaurant.StatusHistory, models.StatusChange{From: from, To: status, Reason: reason, By: by, At: time.Now()})
+	err = r.repo.Update(id, map[string]interface{}{"status": restaurant.Status, "statusHistory": restaurant.StatusHistory})
+	if err != nil {
+		return models.Restaurant{}, err
+	}
+	return restaurant, nil
+}
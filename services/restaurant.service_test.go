@@ -0,0 +1,349 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nicodeheza/peersEat/mocks"
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/types"
+	"github.com/nicodeheza/peersEat/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func initRestaurantTest() (*RestaurantService, *mocks.RestaurantRepositoryMock, *mocks.GeocoderMock) {
+	repo := mocks.NewRestaurantRepositoryMock()
+	geocoder := mocks.NewGeocoderMock()
+	staff := NewStaffService(mocks.NewStaffUserRepositoryMock(), repo, utils.NewAuthHelper())
+	return NewRestaurantService(repo, utils.NewAuthHelper(), geocoder, mocks.NewGeo(), staff), repo, geocoder
+}
+
+func TestUpdateAddress(t *testing.T) {
+	service, repo, geocoder := initRestaurantTest()
+	selfPeer := models.Peer{Url: "http://test.com"}
+
+	id := primitive.NewObjectID()
+	overriddenId := primitive.NewObjectID()
+	repo.Restaurants = []models.Restaurant{
+		{Id: id, Coord: models.GeoCoords{Long: 5, Lat: 5}},
+		{Id: overriddenId, Coord: models.GeoCoords{Long: 5, Lat: 5}, IsCoordOverridden: true},
+	}
+	data := types.RestaurantAddressData{Address: "new address", City: "test city", Country: "test country"}
+
+	restaurant, err := service.UpdateAddress(id, data, selfPeer)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if restaurant.Coord != (models.GeoCoords{Long: 1.1, Lat: 2.2}) {
+		t.Errorf("expecting geocoded coord but got %v", restaurant.Coord)
+	}
+	if repo.UpdateCalls[0].Updates["coord"] != restaurant.Coord {
+		t.Errorf("expecting coord to be saved, got updates: %v", repo.UpdateCalls[0].Updates)
+	}
+
+	restaurant, err = service.UpdateAddress(overriddenId, data, selfPeer)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if restaurant.Coord != (models.GeoCoords{Long: 5, Lat: 5}) {
+		t.Errorf("expecting overridden coord to be kept but got %v", restaurant.Coord)
+	}
+	if _, ok := repo.UpdateCalls[1].Updates["coord"]; ok {
+		t.Errorf("expecting overridden coord to not be updated, got updates: %v", repo.UpdateCalls[1].Updates)
+	}
+	if len(geocoder.Calls) != 1 {
+		t.Errorf("expecting geocoder to be called once but was called %d times", len(geocoder.Calls))
+	}
+}
+
+func TestOverrideCoord(t *testing.T) {
+	service, repo, _ := initRestaurantTest()
+	selfPeer := models.Peer{Url: "http://test.com"}
+
+	id := primitive.NewObjectID()
+	repo.Restaurants = []models.Restaurant{{Id: id}}
+
+	_, err := service.OverrideCoord(id, models.GeoCoords{Long: 0, Lat: 3}, selfPeer)
+	if err != ErrOutOfArea {
+		t.Errorf("expecting out of area error but got %v", err)
+	}
+
+	coord := models.GeoCoords{Long: 3, Lat: 3}
+	restaurant, err := service.OverrideCoord(id, coord, selfPeer)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !restaurant.IsCoordOverridden || restaurant.Coord != coord {
+		t.Errorf("expecting overridden coord %v but got %v", coord, restaurant)
+	}
+	if repo.UpdateCalls[0].Updates["isCoordOverridden"] != true {
+		t.Errorf("expecting override flag to be saved, got updates: %v", repo.UpdateCalls[0].Updates)
+	}
+}
+
+func TestOpeningHours(t *testing.T) {
+	service, repo, _ := initRestaurantTest()
+	location, _ := time.LoadLocation("America/Argentina/Buenos_Aires")
+
+	id := primitive.NewObjectID()
+	legacyId := primitive.NewObjectID()
+	repo.Restaurants = []models.Restaurant{
+		{Id: id, OpeningHours: &models.OpeningHours{
+			Timezone: "America/Argentina/Buenos_Aires",
+			Weekly: []models.DaySchedule{
+				{Day: time.Friday, Intervals: []models.OpeningInterval{{Open: "12:00", Close: "15:00"}, {Open: "20:00", Close: "02:00"}}},
+			},
+			Exceptions: []models.ScheduleException{{Date: "2024-05-17", Note: "holiday"}},
+		}},
+		{Id: legacyId, OpenTime: "9:00AM", CloseTime: "5:00PM"},
+	}
+
+	tests := []struct {
+		at     time.Time
+		isOpen bool
+		next   time.Time
+	}{
+		{time.Date(2024, 5, 10, 13, 0, 0, 0, location), true, time.Date(2024, 5, 10, 20, 0, 0, 0, location)},
+		{time.Date(2024, 5, 10, 16, 0, 0, 0, location), false, time.Date(2024, 5, 10, 20, 0, 0, 0, location)},
+		// the friday night interval ends on saturday
+		{time.Date(2024, 5, 11, 1, 30, 0, 0, location), true, time.Date(2024, 5, 24, 12, 0, 0, 0, location)},
+		// same instant in UTC
+		{time.Date(2024, 5, 10, 16, 0, 0, 0, time.UTC), true, time.Date(2024, 5, 10, 20, 0, 0, 0, location)},
+		// closed by the exception
+		{time.Date(2024, 5, 17, 13, 0, 0, 0, location), false, time.Date(2024, 5, 24, 12, 0, 0, 0, location)},
+	}
+
+	for _, test := range tests {
+		isOpen, err := service.IsOpenAt(id, test.at)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if isOpen != test.isOpen {
+			t.Errorf("at %v expecting open %v but got %v", test.at, test.isOpen, isOpen)
+		}
+		next, ok, err := service.NextOpening(id, test.at)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if !ok || !next.Equal(test.next) {
+			t.Errorf("at %v expecting next opening %v but got %v", test.at, test.next, next)
+		}
+	}
+
+	local := models.PeerLocation()
+	isOpen, _ := service.IsOpenAt(legacyId, time.Date(2024, 5, 10, 10, 0, 0, 0, local))
+	if !isOpen {
+		t.Error("expecting legacy hours to be open at 10:00")
+	}
+	isOpen, _ = service.IsOpenAt(legacyId, time.Date(2024, 5, 10, 18, 0, 0, 0, local))
+	if isOpen {
+		t.Error("expecting legacy hours to be closed at 18:00")
+	}
+}
+
+func TestUpdateOpeningHours(t *testing.T) {
+	service, repo, _ := initRestaurantTest()
+	id := primitive.NewObjectID()
+
+	data := types.OpeningHoursData{
+		Timezone: "Europe/Madrid",
+		Weekly: []types.DayScheduleData{
+			{Day: time.Monday, Intervals: []types.OpeningIntervalData{{Open: "12:00", Close: "16:00"}}},
+			{Day: time.Monday, Intervals: []types.OpeningIntervalData{{Open: "15:00", Close: "18:00"}}},
+		},
+	}
+	_, err := service.UpdateOpeningHours(id, data)
+	if !errors.Is(err, ErrInvalidOpeningHours) {
+		t.Errorf("expecting overlapping intervals to fail but got %v", err)
+	}
+
+	data.Weekly[1].Intervals[0].Open = "20:00"
+	data.Exceptions = []types.ScheduleExceptionData{{Date: "2024-12-25"}, {Date: "2024-12-25"}}
+	_, err = service.UpdateOpeningHours(id, data)
+	if !errors.Is(err, ErrInvalidOpeningHours) {
+		t.Errorf("expecting repeated exception dates to fail but got %v", err)
+	}
+
+	data.Exceptions = data.Exceptions[:1]
+	hours, err := service.UpdateOpeningHours(id, data)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(hours.Weekly) != 1 || len(hours.Weekly[0].Intervals) != 2 {
+		t.Errorf("expecting monday intervals to be merged but got %v", hours.Weekly)
+	}
+	if len(repo.UpdateCalls) != 1 || repo.UpdateCalls[0].Id != id {
+		t.Fatalf("expecting opening hours to be saved once but got %v", repo.UpdateCalls)
+	}
+	saved := repo.UpdateCalls[0].Updates["openingHours"].(models.OpeningHours)
+	if saved.Timezone != "Europe/Madrid" || len(saved.Exceptions) != 1 {
+		t.Errorf("unexpected saved opening hours %v", saved)
+	}
+}
+
+func TestListRestaurants(t *testing.T) {
+	service, repo, _ := initRestaurantTest()
+	location := models.PeerLocation()
+	at := time.Date(2024, 5, 10, 13, 0, 0, 0, location)
+
+	delivering := models.Restaurant{Id: primitive.NewObjectID(), DeliveryRadius: 1, OpenTime: "9:00AM", CloseTime: "5:00PM", UserName: "user", Password: "hash"}
+	closed := models.Restaurant{Id: primitive.NewObjectID(), DeliveryRadius: 1, OpenTime: "6:00PM", CloseTime: "11:00PM"}
+	farAway := models.Restaurant{Id: primitive.NewObjectID(), DeliveryRadius: 2, OpenTime: "9:00AM", CloseTime: "5:00PM"}
+	suspended := models.Restaurant{Id: primitive.NewObjectID(), DeliveryRadius: 1, OpenTime: "9:00AM", CloseTime: "5:00PM", Status: models.RESTAURANT_SUSPENDED}
+	repo.Restaurants = []models.Restaurant{delivering, closed, farAway, suspended}
+
+	lat, long := 1.0, 1.0
+	tests := []struct {
+		query    types.RestaurantListQuery
+		expected []primitive.ObjectID
+	}{
+		{types.RestaurantListQuery{}, []primitive.ObjectID{delivering.Id, closed.Id, farAway.Id}},
+		{types.RestaurantListQuery{Open: true}, []primitive.ObjectID{delivering.Id, farAway.Id}},
+		{types.RestaurantListQuery{Lat: &lat, Long: &long}, []primitive.ObjectID{delivering.Id, closed.Id}},
+		{types.RestaurantListQuery{Open: true, Lat: &lat, Long: &long}, []primitive.ObjectID{delivering.Id}},
+	}
+
+	for _, test := range tests {
+		restaurants, err := service.List(test.query, at)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if len(restaurants) != len(test.expected) {
+			t.Errorf("expecting %v restaurants but got %v", len(test.expected), len(restaurants))
+			continue
+		}
+		for i, restaurant := range restaurants {
+			if restaurant.Id != test.expected[i] {
+				t.Errorf("expecting restaurant %v but got %v", test.expected[i], restaurant.Id)
+			}
+		}
+	}
+
+	restaurants, _ := service.List(types.RestaurantListQuery{}, at)
+	body, _ := json.Marshal(restaurants[0])
+	if strings.Contains(string(body), "hash") || strings.Contains(string(body), "user") {
+		t.Errorf("expecting credentials to be stripped but got %s", body)
+	}
+}
+
+func TestChangeStatus(t *testing.T) {
+	service, repo, _ := initRestaurantTest()
+	restaurant := models.Restaurant{Id: primitive.NewObjectID(), Status: models.RESTAURANT_DRAFT, StatusHistory: []models.StatusChange{{To: models.RESTAURANT_DRAFT}}}
+	repo.Restaurants = []models.Restaurant{restaurant}
+
+	_, err := service.ChangeStatus(restaurant.Id, models.RESTAURANT_ACTIVE, "", models.STATUS_BY_PEER_OWNER)
+	if !errors.Is(err, ErrInvalidStatusChange) {
+		t.Errorf("expecting a draft can't be approved but got %v", err)
+	}
+
+	steps := []struct {
+		status string
+		reason string
+		err    error
+	}{
+		{models.RESTAURANT_PENDING, "", nil},
+		{models.RESTAURANT_DRAFT, "", ErrStatusReasonRequired},
+		{models.RESTAURANT_DRAFT, "missing menu", nil},
+		{models.RESTAURANT_PENDING, "", nil},
+		{models.RESTAURANT_ACTIVE, "", nil},
+		{models.RESTAURANT_SUSPENDED, "", ErrStatusReasonRequired},
+		{models.RESTAURANT_SUSPENDED, "health inspection", nil},
+		{models.RESTAURANT_ACTIVE, "", nil},
+		{models.RESTAURANT_CLOSED, "", nil},
+		{models.RESTAURANT_ACTIVE, "", ErrInvalidStatusChange},
+	}
+	for _, step := range steps {
+		changed, err := service.ChangeStatus(restaurant.Id, step.status, step.reason, models.STATUS_BY_PEER_OWNER)
+		if !errors.Is(err, step.err) {
+			t.Fatalf("expecting %v changing to %v but got %v", step.err, step.status, err)
+		}
+		if err == nil {
+			repo.Restaurants[0] = changed
+		}
+	}
+
+	history := repo.Restaurants[0].StatusHistory
+	if len(history) != 8 || history[2].Reason != "missing menu" || history[2].From != models.RESTAURANT_PENDING {
+		t.Errorf("unexpected status history %v", history)
+	}
+	last := repo.UpdateCalls[len(repo.UpdateCalls)-1]
+	if last.Updates["status"] != models.RESTAURANT_CLOSED {
+		t.Errorf("expecting the status to be stored but got %v", last.Updates)
+	}
+}
+
+func TestSetupAccount(t *testing.T) {
+	service, repo, _ := initRestaurantTest()
+
+	expired := time.Now().Add(-time.Minute)
+	pending := models.Restaurant{Id: primitive.NewObjectID(), UserName: "random.words.here"}
+	stale := models.Restaurant{Id: primitive.NewObjectID(), SetupTokenHash: hashSecretToken("stale"), SetupExpiresAt: &expired}
+	taken := models.Restaurant{Id: primitive.NewObjectID(), UserName: "taken", IsFinalPassword: true}
+	repo.Restaurants = []models.Restaurant{pending, stale, taken}
+	staffRepo := service.staff.(*StaffService).repo.(*mocks.StaffUserRepositoryMock)
+	staffRepo.Users = []models.StaffUser{{Id: primitive.NewObjectID(), RestaurantId: taken.Id, UserName: "kitchen"}}
+
+	if _, err := service.NewSetupToken(taken.Id); err != ErrAccountAlreadySetUp {
+		t.Errorf("expecting already set up error but got %v", err)
+	}
+	token, err := service.NewSetupToken(pending.Id)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	updates := repo.UpdateCalls[0].Updates
+	if updates["setupTokenHash"] != hashSecretToken(token) || updates["setupExpiresAt"] == nil {
+		t.Errorf("expecting only the token hash to be stored but got %v", updates)
+	}
+	expiresAt := updates["setupExpiresAt"].(time.Time)
+	repo.Restaurants[0].SetupTokenHash, repo.Restaurants[0].SetupExpiresAt = hashSecretToken(token), &expiresAt
+
+	tests := []struct {
+		data     types.RestaurantSetupData
+		expected error
+	}{
+		{types.RestaurantSetupData{Token: "unknown", UserName: "owner", Password: "password"}, ErrSetupTokenNotFound},
+		{types.RestaurantSetupData{Token: "stale", UserName: "owner", Password: "password"}, ErrSetupTokenExpired},
+		{types.RestaurantSetupData{Token: token, UserName: "taken", Password: "password"}, ErrUserNameTaken},
+		{types.RestaurantSetupData{Token: token, UserName: "kitchen", Password: "password"}, ErrUserNameTaken},
+		{types.RestaurantSetupData{Token: token, UserName: "owner", Password: "password"}, nil},
+		{types.RestaurantSetupData{Token: token, UserName: "other", Password: "password"}, ErrSetupTokenNotFound},
+	}
+	for _, test := range tests {
+		if err := service.SetupAccount(test.data); err != test.expected {
+			t.Errorf("%v: expecting %v but got %v", test.data.Token, test.expected, err)
+		}
+	}
+
+	setup := repo.UpdateCalls[len(repo.UpdateCalls)-1]
+	if setup.Id != pending.Id || setup.Updates["userName"] != "owner" || setup.Updates["isFinalPassword"] != true || repo.Restaurants[0].SetupTokenHash != "" {
+		t.Errorf("expecting the credentials to be set and the token cleared but got %v", setup)
+	}
+	if !utils.NewAuthHelper().CheckPassword("password", setup.Updates["password"].(string)) {
+		t.Errorf("expecting the password hash to be stored")
+	}
+}
+
+func TestUpdateRestaurantCredentials(t *testing.T) {
+	service, repo, _ := initRestaurantTest()
+	restaurant := models.Restaurant{Id: primitive.NewObjectID(), UserName: "owner", IsFinalPassword: true}
+	other := models.Restaurant{Id: primitive.NewObjectID(), UserName: "other", IsFinalPassword: true}
+	repo.Restaurants = []models.Restaurant{restaurant, other}
+	staffRepo := service.staff.(*StaffService).repo.(*mocks.StaffUserRepositoryMock)
+	staffRepo.Users = []models.StaffUser{{Id: primitive.NewObjectID(), RestaurantId: restaurant.Id, UserName: "kitchen"}}
+
+	// staff and restaurant accounts share the login, so their user names can't repeat
+	for _, userName := range []string{"other", "kitchen"} {
+		if err := service.UpdateRestaurantUsernameAndPassword(restaurant.Id, "password", userName); err != ErrUserNameTaken {
+			t.Errorf("%v: expecting %v but got %v", userName, ErrUserNameTaken, err)
+		}
+	}
+	if len(repo.UpdateCalls) != 0 {
+		t.Errorf("expecting no update but got %v", repo.UpdateCalls)
+	}
+	if err := service.UpdateRestaurantUsernameAndPassword(restaurant.Id, "password", "owner"); err != nil {
+		t.Errorf("expecting the restaurant to keep its user name but got %v", err)
+	}
+}
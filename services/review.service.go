@@ -0,0 +1,206 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/nicodeheza/peersEat/constants"
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/repositories"
+	"github.com/nicodeheza/peersEat/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrReviewNotFound = errors.New("review not found")
+var ErrUnknownDish = errors.New("dish not in the restaurant menu")
+var ErrOwnReview = errors.New("customers can't vote their own review")
+var ErrReviewNotAllowed = errors.New("only the customers of an order can review it")
+
+type ReviewServiceI interface {
+	Submit(restaurantId primitive.ObjectID, data types.ReviewData) (models.Review, error)
+	List(restaurantId primitive.ObjectID, query types.ReviewListQuery) (types.ReviewPage, error)
+	Reply(restaurantId primitive.ObjectID, reviewId primitive.ObjectID, data types.ReviewReplyData) (models.Review, error)
+	MarkHelpful(restaurantId primitive.ObjectID, reviewId primitive.ObjectID, reviewToken string) error
+}
+
+type ReviewService struct {
+	repo           repositories.ReviewRepositoryI
+	restaurantRepo repositories.RestaurantRepositoryI
+	redemptionRepo repositories.RedemptionRepositoryI
+	menuVersions   MenuVersionServiceI
+}
+
+func NewReviewService(repo repositories.ReviewRepositoryI, restaurantRepo repositories.RestaurantRepositoryI, redemptionRepo repositories.RedemptionRepositoryI, menuVersions MenuVersionServiceI) *ReviewService {
+	return &ReviewService{repo, restaurantRepo, redemptionRepo, menuVersions}
+}
+
+// the review token binds the review to a redeemed order and its customer, rated dishes
+// have to be in the current menu. The restaurant rate is updated with the difference,
+// a replaced review doesn't add a vote
+func (s *ReviewService) Submit(restaurantId primitive.ObjectID, data types.ReviewData) (models.Review, error) {
+	_, err := s.restaurantRepo.FindOne(map[string]interface{}{"_id": restaurantId})
+	if err != nil {
+		return models.Review{}, err
+	}
+
+	order, err := s.redemptionRepo.FindOne(map[string]interface{}{"restaurantId": restaurantId, "reviewTokenHash": hashSecretToken(data.ReviewToken)})
+	if err == mongo.ErrNoDocuments {
+		return models.Review{}, ErrReviewNotAllowed
+	}
+	if err != nil {
+		return models.Review{}, err
+	}
+
+	dishes := []models.DishRating{}
+	if len(data.Dishes) > 0 {
+		version, err := s.menuVersions.CurrentMenu(restaurantId, time.Now())
+		if err == ErrNoMenuAvailable {
+			return models.Review{}, ErrUnknownDish
+		}
+		if err != nil {
+			return models.Review{}, err
+		}
+		for _, rating := range data.Dishes {
+			dishId, _ := primitive.ObjectIDFromHex(rating.DishId)
+			if !version.Menu.HasDish(dishId) {
+				return models.Review{}, ErrUnknownDish
+			}
+			dishes = append(dishes, models.DishRating{DishId: dishId, Stars: rating.Stars})
+		}
+	}
+
+	now := time.Now()
+	orderId := order.Id.Hex()
+	existing, err := s.repo.FindOne(map[string]interface{}{"restaurantId": restaurantId, "orderId": orderId})
+	if err != nil && err != mongo.ErrNoDocuments {
+		return models.Review{}, err
+	}
+
+	if err == nil {
+		err = s.repo.Update(existing.Id, map[string]interface{}{
+			"customerName": data.CustomerName,
+			"stars":        data.Stars,
+			"text":         data.Text,
+			"dishes":       dishes,
+			"updatedAt":    now,
+		})
+		if err != nil {
+			return models.Review{}, err
+		}
+
+		if diff := int(data.Stars) - int(existing.Stars); diff != 0 {
+			if err = s.restaurantRepo.IncrementRate(restaurantId, diff, 0); err != nil {
+				return models.Review{}, err
+			}
+		}
+
+		existing.CustomerName = data.CustomerName
+		existing.Stars = data.Stars
+		existing.Text = data.Text
+		existing.Dishes = dishes
+		existing.UpdatedAt = now
+		return existing, nil
+	}
+
+	review := models.Review{
+		RestaurantId: restaurantId,
+		CustomerId:   order.CustomerId,
+		CustomerName: data.CustomerName,
+		OrderId:      orderId,
+		Stars:        data.Stars,
+		Text:         data.Text,
+		Dishes:       dishes,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	review.Id, err = s.repo.Insert(review)
+	if err != nil {
+		return models.Review{}, err
+	}
+
+	err = s.restaurantRepo.IncrementRate(restaurantId, int(data.Stars), 1)
+	return review, err
+}
+
+func (s *ReviewService) List(restaurantId primitive.ObjectID, query types.ReviewListQuery) (types.ReviewPage, error) {
+	if query.Sort == "" {
+		query.Sort = models.REVIEW_SORT_RECENT
+	}
+	if query.Page == 0 {
+		query.Page = 1
+	}
+	if query.PageSize == 0 {
+		query.PageSize = constants.REVIEW_PAGE_SIZE
+	}
+
+	restaurant, err := s.restaurantRepo.FindOne(map[string]interface{}{"_id": restaurantId})
+	if err != nil {
+		return types.ReviewPage{}, err
+	}
+
+	total, err := s.repo.Count(restaurantId)
+	if err != nil {
+		return types.ReviewPage{}, err
+	}
+	reviews, err := s.repo.FindPage(restaurantId, query.Sort, int64((query.Page-1)*query.PageSize), int64(query.PageSize))
+	if err != nil {
+		return types.ReviewPage{}, err
+	}
+
+	return types.ReviewPage{
+		Reviews:  reviews,
+		Total:    total,
+		Page:     query.Page,
+		PageSize: query.PageSize,
+		Rate:     restaurant.Rate,
+		Rating:   restaurant.Rate.Score(),
+	}, nil
+}
+
+func (s *ReviewService) findReview(restaurantId primitive.ObjectID, reviewId primitive.ObjectID) (models.Review, error) {
+	review, err := s.repo.FindOne(map[string]interface{}{"_id": reviewId, "restaurantId": restaurantId})
+	if err == mongo.ErrNoDocuments {
+		return models.Review{}, ErrReviewNotFound
+	}
+	return review, err
+}
+
+// a new reply replaces the previous one
+func (s *ReviewService) Reply(restaurantId primitive.ObjectID, reviewId primitive.ObjectID, data types.ReviewReplyData) (models.Review, error) {
+	review, err := s.findReview(restaurantId, reviewId)
+	if err != nil {
+		return models.Review{}, err
+	}
+
+	review.Reply = &models.ReviewReply{Text: data.Text, RepliedAt: time.Now()}
+	err = s.repo.Update(reviewId, map[string]interface{}{"reply": review.Reply})
+	if err != nil {
+		return models.Review{}, err
+	}
+	return review, nil
+}
+
+// only redeemed orders of the restaurant vote, each one counts once and the customer
+// of a review can't vote it with any of its orders
+func (s *ReviewService) MarkHelpful(restaurantId primitive.ObjectID, reviewId primitive.ObjectID, reviewToken string) error {
+	review, err := s.findReview(restaurantId, reviewId)
+	if err != nil {
+		return err
+	}
+
+	order, err := s.redemptionRepo.FindOne(map[string]interface{}{"restaurantId": restaurantId, "reviewTokenHash": hashSecretToken(reviewToken)})
+	if err == mongo.ErrNoDocuments {
+		return ErrReviewNotAllowed
+	}
+	if err != nil {
+		return err
+	}
+	orderId := order.Id.Hex()
+	if review.OrderId == orderId || (order.CustomerId != "" && review.CustomerId == order.CustomerId) {
+		return ErrOwnReview
+	}
+
+	_, err = s.repo.MarkHelpful(reviewId, orderId)
+	return err
+}
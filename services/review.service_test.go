@@ -0,0 +1,198 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nicodeheza/peersEat/mocks"
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type reviewTest struct {
+	service        *ReviewService
+	repo           *mocks.ReviewRepositoryMock
+	restaurantRepo *mocks.RestaurantRepositoryMock
+	redemptionRepo *mocks.RedemptionRepositoryMock
+	restaurant     models.Restaurant
+	dish           models.Dish
+}
+
+// the published menu has a ramen with an egg option, the draft only has a new dish
+func initReviewTest() reviewTest {
+	repo := mocks.NewReviewRepositoryMock()
+	restaurantRepo := mocks.NewRestaurantRepositoryMock()
+	redemptionRepo := mocks.NewRedemptionRepositoryMock()
+	versionRepo := mocks.NewMenuVersionRepositoryMock()
+	restaurant := models.Restaurant{
+		Id: primitive.NewObjectID(),
+		Menu: models.Menu{Sections: []models.MenuSection{
+			{Id: primitive.NewObjectID(), Dishes: []models.Dish{{Id: primitive.NewObjectID(), Name: "gyoza"}}},
+		}},
+	}
+	restaurantRepo.Restaurants = []models.Restaurant{restaurant}
+	dish := models.Dish{Id: primitive.NewObjectID(), Name: "ramen", Options: []models.DishOptions{{Id: primitive.NewObjectID(), Name: "egg"}}}
+	versionRepo.Versions = []models.MenuVersion{publishedMenu(restaurant.Id, dish)}
+
+	menuVersions := NewMenuVersionService(versionRepo, restaurantRepo, NewMenuService(restaurantRepo))
+	service := NewReviewService(repo, restaurantRepo, redemptionRepo, menuVersions)
+	return reviewTest{service, repo, restaurantRepo, redemptionRepo, restaurant, dish}
+}
+
+// redeems an order of the customer and returns its review token
+func (test reviewTest) order(customerId string) string {
+	token, _ := newSecretToken()
+	test.redemptionRepo.Insert(models.Redemption{RestaurantId: test.restaurant.Id, CustomerId: customerId, ReviewTokenHash: hashSecretToken(token)})
+	return token
+}
+
+func TestSubmitReview(t *testing.T) {
+	test := initReviewTest()
+	service, repo, restaurantRepo, restaurant := test.service, test.repo, test.restaurantRepo, test.restaurant
+	orderA, orderB := test.order("a"), test.order("b")
+
+	// only the customers of an order can review it
+	_, err := service.Submit(restaurant.Id, types.ReviewData{ReviewToken: strings.Repeat("0", 48), Stars: 5})
+	if err != ErrReviewNotAllowed {
+		t.Errorf("expecting review not allowed error but got %v", err)
+	}
+	other := models.Restaurant{Id: primitive.NewObjectID()}
+	restaurantRepo.Restaurants = append(restaurantRepo.Restaurants, other)
+	_, err = service.Submit(other.Id, types.ReviewData{ReviewToken: orderA, Stars: 5})
+	if err != ErrReviewNotAllowed {
+		t.Errorf("expecting the token not to review other restaurants but got %v", err)
+	}
+
+	// dishes come from the current menu, options and draft dishes are not rated
+	unknown := []string{primitive.NewObjectID().Hex(), test.dish.Options[0].Id.Hex(), restaurant.Menu.Sections[0].Dishes[0].Id.Hex()}
+	for _, dishId := range unknown {
+		_, err = service.Submit(restaurant.Id, types.ReviewData{ReviewToken: orderA, Stars: 4, Dishes: []types.DishRatingData{{DishId: dishId, Stars: 5}}})
+		if err != ErrUnknownDish {
+			t.Errorf("expecting unknown dish error for %v but got %v", dishId, err)
+		}
+	}
+
+	first, err := service.Submit(restaurant.Id, types.ReviewData{ReviewToken: orderA, Stars: 4, Text: "good", Dishes: []types.DishRatingData{{DishId: test.dish.Id.Hex(), Stars: 5}}})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if first.CustomerId != "a" || first.OrderId != test.redemptionRepo.Redemptions[0].Id.Hex() {
+		t.Errorf("expecting the review to take the order customer but got %v", first)
+	}
+	_, err = service.Submit(restaurant.Id, types.ReviewData{ReviewToken: orderB, Stars: 2})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	rate := restaurantRepo.Restaurants[0].Rate
+	if rate.Stars != 6 || rate.Votes != 2 {
+		t.Errorf("expecting 6 stars from 2 votes but got %v", rate)
+	}
+
+	// a new review of the same order replaces it
+	replaced, err := service.Submit(restaurant.Id, types.ReviewData{ReviewToken: orderA, Stars: 1, Text: "cold"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	rate = restaurantRepo.Restaurants[0].Rate
+	if rate.Stars != 3 || rate.Votes != 2 {
+		t.Errorf("expecting 3 stars from 2 votes but got %v", rate)
+	}
+	if replaced.Id != first.Id || len(repo.Reviews) != 2 || repo.Reviews[0].Text != "cold" || len(repo.Reviews[0].Dishes) != 0 {
+		t.Errorf("expecting the first review to be replaced but got %v", repo.Reviews)
+	}
+}
+
+func TestRateScore(t *testing.T) {
+	// one perfect vote stays close to the prior, many converge to the average
+	few := models.Rate{Stars: 5, Votes: 1}
+	many := models.Rate{Stars: 4500, Votes: 1000}
+	if score := (models.Rate{}).Score(); score != models.RATE_PRIOR_MEAN {
+		t.Errorf("expecting the prior mean without votes but got %v", score)
+	}
+	if score := few.Score(); score > 4 {
+		t.Errorf("expecting a single vote to be smoothed but got %v", score)
+	}
+	if score := many.Score(); score < 4.48 || score > 4.5 {
+		t.Errorf("expecting many votes to converge to 4.5 but got %v", score)
+	}
+}
+
+func TestListReviews(t *testing.T) {
+	test := initReviewTest()
+	service, repo, restaurant := test.service, test.repo, test.restaurant
+
+	now := time.Now()
+	own, voter, sameCustomer := test.order("old"), test.order("x"), test.order("old")
+	repo.Reviews = []models.Review{
+		{Id: primitive.NewObjectID(), RestaurantId: restaurant.Id, CustomerId: "old", OrderId: test.redemptionRepo.Redemptions[0].Id.Hex(), CreatedAt: now.Add(-48 * time.Hour)},
+		{Id: primitive.NewObjectID(), RestaurantId: restaurant.Id, CustomerId: "new", CreatedAt: now},
+		{Id: primitive.NewObjectID(), RestaurantId: restaurant.Id, CustomerId: "mid", CreatedAt: now.Add(-24 * time.Hour)},
+		{Id: primitive.NewObjectID(), RestaurantId: primitive.NewObjectID(), CustomerId: "other", CreatedAt: now},
+	}
+
+	err := service.MarkHelpful(restaurant.Id, repo.Reviews[0].Id, voter)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	service.MarkHelpful(restaurant.Id, repo.Reviews[0].Id, voter)
+	if repo.Reviews[0].Helpful != 1 {
+		t.Errorf("expecting an order to count once but got %v", repo.Reviews[0].Helpful)
+	}
+	if err = service.MarkHelpful(restaurant.Id, repo.Reviews[0].Id, strings.Repeat("0", 48)); err != ErrReviewNotAllowed {
+		t.Errorf("expecting only redeemed orders to vote but got %v", err)
+	}
+	if err = service.MarkHelpful(restaurant.Id, repo.Reviews[0].Id, own); err != ErrOwnReview {
+		t.Errorf("expecting own review error but got %v", err)
+	}
+	if err = service.MarkHelpful(restaurant.Id, repo.Reviews[0].Id, sameCustomer); err != ErrOwnReview {
+		t.Errorf("expecting the other orders of the reviewer to not vote but got %v", err)
+	}
+	if err = service.MarkHelpful(restaurant.Id, repo.Reviews[3].Id, voter); err != ErrReviewNotFound {
+		t.Errorf("expecting other restaurants reviews not to be found but got %v", err)
+	}
+
+	tests := []struct {
+		query    types.ReviewListQuery
+		expected []string
+	}{
+		{types.ReviewListQuery{}, []string{"new", "mid", "old"}},
+		{types.ReviewListQuery{Sort: models.REVIEW_SORT_HELPFUL}, []string{"old", "new", "mid"}},
+		{types.ReviewListQuery{Page: 2, PageSize: 2}, []string{"old"}},
+	}
+	for _, test := range tests {
+		page, err := service.List(restaurant.Id, test.query)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if page.Total != 3 || len(page.Reviews) != len(test.expected) {
+			t.Errorf("expecting %v of 3 reviews but got %v of %v", len(test.expected), len(page.Reviews), page.Total)
+			continue
+		}
+		for i, review := range page.Reviews {
+			if review.CustomerId != test.expected[i] {
+				t.Errorf("expecting %v at %v but got %v", test.expected[i], i, review.CustomerId)
+			}
+		}
+	}
+}
+
+func TestReplyReview(t *testing.T) {
+	test := initReviewTest()
+	service, repo, restaurant := test.service, test.repo, test.restaurant
+	review, _ := service.Submit(restaurant.Id, types.ReviewData{ReviewToken: test.order("a"), Stars: 3})
+
+	_, err := service.Reply(primitive.NewObjectID(), review.Id, types.ReviewReplyData{Text: "sorry"})
+	if err != ErrReviewNotFound {
+		t.Errorf("expecting other restaurants not to reply but got %v", err)
+	}
+
+	replied, err := service.Reply(restaurant.Id, review.Id, types.ReviewReplyData{Text: "thanks"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if replied.Reply == nil || repo.Reviews[0].Reply == nil || repo.Reviews[0].Reply.Text != "thanks" {
+		t.Errorf("expecting the reply to be saved but got %v", repo.Reviews[0].Reply)
+	}
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nicodeheza/peersEat/constants"
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/repositories"
+	"github.com/nicodeheza/peersEat/services/geo"
+	"github.com/nicodeheza/peersEat/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type SearchServiceI interface {
+	Search(query types.SearchQuery) (types.SearchResult, error)
+	LocalSearch(query types.SearchQuery) ([]types.SearchHit, error)
+}
+
+type SearchService struct {
+	restaurants    RestaurantServiceI
+	peers          PeerServiceI
+	geo            geo.GeoServiceI
+	remoteStatuses repositories.RemoteRestaurantStatusRepositoryI
+	menuVersions   MenuVersionServiceI
+	client         *http.Client
+}
+
+func NewSearchService(restaurants RestaurantServiceI, peers PeerServiceI, geo geo.GeoServiceI, remoteStatuses repositories.RemoteRestaurantStatusRepositoryI, menuVersions MenuVersionServiceI) *SearchService {
+	timeout, _ := time.ParseDuration(constants.SEARCH_PEER_TIMEOUT)
+	if envTimeout, err := time.ParseDuration(os.Getenv("SEARCH_PEER_TIMEOUT")); err == nil && envTimeout > 0 {
+		timeout = envTimeout
+	}
+
+	return &SearchService{restaurants, peers, geo, remoteStatuses, menuVersions, &http.Client{Timeout: timeout}}
+}
+
+type peerSearchResp struct {
+	url  string
+	hits []types.SearchHit
+	err  error
+}
+
+// searches this peer and every peer delivering into its area, peers that fail or
+// time out are reported and their restaurants left out
+func (s *SearchService) Search(query types.SearchQuery) (types.SearchResult, error) {
+	if query.At == "" {
+		query.At = time.Now().Format(time.RFC3339)
+	}
+
+	hits, err := s.LocalSearch(query)
+	if err != nil {
+		return types.SearchResult{}, err
+	}
+
+	self, err := s.peers.GetLocalPeer()
+	if err != nil {
+		return types.SearchResult{}, err
+	}
+	peers, err := s.peers.GetInDeliveryAreaPeers(self)
+	if err != nil {
+		return types.SearchResult{}, err
+	}
+
+	ch := make(chan peerSearchResp)
+	var wg sync.WaitGroup
+	for _, peer := range peers {
+		if peer.Url == self.Url {
+			continue
+		}
+		wg.Add(1)
+		go s.searchPeer(peer.Url, query, ch, &wg)
+	}
+
+	go func() {
+		wg.Wait()
+		close(ch)
+	}()
+
+	result := types.SearchResult{Restaurants: []types.SearchHit{}, FailedPeers: []string{}}
+	peerHits := []types.SearchHit{}
+	for resp := range ch {
+		if resp.err != nil {
+			result.FailedPeers = append(result.FailedPeers, resp.url)
+			continue
+		}
+		peerHits = append(peerHits, resp.hits...)
+	}
+	sort.Strings(result.FailedPeers)
+
+	peerHits, err = s.withoutInactive(peerHits)
+	if err != nil {
+		return types.SearchResult{}, err
+	}
+	hits = append(hits, peerHits...)
+
+	seen := map[primitive.ObjectID]bool{}
+	for _, hit := range hits {
+		if seen[hit.Restaurant.Id] {
+			continue
+		}
+		seen[hit.Restaurant.Id] = true
+		result.Restaurants = append(result.Restaurants, hit)
+	}
+	rankSearchHits(result.Restaurants)
+
+	return result, nil
+}
+
+// searches only the restaurants of this peer, it's what other peers ask for
+func (s *SearchService) LocalSearch(query types.SearchQuery) ([]types.SearchHit, error) {
+	at := time.Now()
+	if query.At != "" {
+		at, _ = time.Parse(time.RFC3339, query.At)
+	}
+
+	self, err := s.peers.GetLocalPeer()
+	if err != nil {
+		return nil, err
+	}
+
+	restaurants, err := s.restaurants.List(types.RestaurantListQuery{City: query.City, Open: query.Open, Lat: query.Lat, Long: query.Long}, at)
+	if err != nil {
+		return nil, err
+	}
+
+	point := models.GeoCoords{Lat: *query.Lat, Long: *query.Long}
+	filter := query.Filter()
+	hits := []types.SearchHit{}
+	for _, restaurant := range restaurants {
+		if !filter.IsEmpty() {
+			serves, err := s.servesDishes(restaurant.Id, filter, at)
+			if err != nil {
+				return nil, err
+			}
+			if !serves {
+				continue
+			}
+		}
+		hits = append(hits, types.SearchHit{
+			Restaurant: restaurant,
+			PeerUrl:    self.Url,
+			Distance:   s.geo.GetCoordDistance(restaurant.Coord, point),
+			Eta:        (restaurant.MinDeliveryTime + restaurant.MaxDeliveryTime) / 2,
+		})
+	}
+	return hits, nil
+}
+
+// tells if the menu served at the time has dishes passing the dietary filter
+func (s *SearchService) servesDishes(restaurantId primitive.ObjectID, filter models.DietaryFilter, at time.Time) (bool, error) {
+	version, err := s.menuVersions.CurrentMenu(restaurantId, at)
+	if err == ErrNoMenuAvailable {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return len(version.Menu.Filter(filter).Sections) > 0, nil
+}
+
+// drops the restaurants their peer announced are no longer active, in case the
+// peer still returns them
+func (s *SearchService) withoutInactive(hits []types.SearchHit) ([]types.SearchHit, error) {
+	ids := []primitive.ObjectID{}
+	for _, hit := range hits {
+		ids = append(ids, hit.Restaurant.Id)
+	}
+	statuses, err := s.remoteStatuses.FindMany(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	inactive := map[primitive.ObjectID]string{}
+	for _, status := range statuses {
+		if status.Status != models.RESTAURANT_ACTIVE {
+			inactive[status.RestaurantId] = status.PeerUrl
+		}
+	}
+
+	active := []types.SearchHit{}
+	for _, hit := range hits {
+		if peerUrl, ok := inactive[hit.Restaurant.Id]; ok && peerUrl == hit.PeerUrl {
+			continue
+		}
+		if hit.Restaurant.Status != "" && hit.Restaurant.Status != models.RESTAURANT_ACTIVE {
+			continue
+		}
+		active = append(active, hit)
+	}
+	return active, nil
+}
+
+func (s *SearchService) searchPeer(peerUrl string, query types.SearchQuery, ch chan<- peerSearchResp, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	searchUrl, err := url.Parse(peerUrl + "/peer/search/restaurants")
+	if err != nil {
+		ch <- peerSearchResp{url: peerUrl, err: err}
+		return
+	}
+	params := searchUrl.Query()
+	params.Add("lat", strconv.FormatFloat(*query.Lat, 'f', -1, 64))
+	params.Add("long", strconv.FormatFloat(*query.Long, 'f', -1, 64))
+	params.Add("at", query.At)
+	if query.City != "" {
+		params.Add("city", query.City)
+	}
+	if query.Open {
+		params.Add("open", "true")
+	}
+	if len(query.Include) > 0 {
+		params.Add("include", strings.Join(query.Include, ","))
+	}
+	if len(query.Exclude) > 0 {
+		params.Add("exclude", strings.Join(query.Exclude, ","))
+	}
+	if query.ExcludeTraces {
+		params.Add("excludeTraces", "true")
+	}
+	searchUrl.RawQuery = params.Encode()
+
+	resp, err := s.client.Get(searchUrl.String())
+	if err != nil {
+		ch <- peerSearchResp{url: peerUrl, err: err}
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		ch <- peerSearchResp{url: peerUrl, err: fmt.Errorf("search failed with status %v", resp.StatusCode)}
+		return
+	}
+
+	hits := []types.SearchHit{}
+	err = json.NewDecoder(resp.Body).Decode(&hits)
+	ch <- peerSearchResp{url: peerUrl, hits: hits, err: err}
+}
+
+func searchScore(hit types.SearchHit) float64 {
+	return hit.Restaurant.Rate.Score()*constants.SEARCH_RATING_WEIGHT -
+		hit.Distance*constants.SEARCH_DISTANCE_WEIGHT -
+		float64(hit.Eta)*constants.SEARCH_ETA_WEIGHT
+}
+
+func takingOrders(restaurant types.PublicRestaurant) bool {
+	return restaurant.IsOpen && !restaurant.IsPaused
+}
+
+// restaurants taking orders first, then by score
+func rankSearchHits(hits []types.SearchHit) {
+	sort.SliceStable(hits, func(i, j int) bool {
+		if takingOrders(hits[i].Restaurant) != takingOrders(hits[j].Restaurant) {
+			return takingOrders(hits[i].Restaurant)
+		}
+		return searchScore(hits[i]) > searchScore(hits[j])
+	})
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/nicodeheza/peersEat/mocks"
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSearch(t *testing.T) {
+	restaurants, repo, _ := initRestaurantTest()
+	peers := mocks.NewPeerServiceMock()
+	peers.InDeliveryAreaPeers = []models.Peer{{Url: "http://test.com"}, {Url: "http://near.com"}, {Url: "http://down.com"}, {Url: "http://slow.com"}}
+	remoteStatuses := mocks.NewRemoteRestaurantStatusRepositoryMock()
+	service := NewSearchService(restaurants, peers, mocks.NewGeo(), remoteStatuses, mocks.NewMenuVersionServiceMock())
+	service.client.Timeout = 50 * time.Millisecond
+
+	// the geo mock distance is the restaurant longitude
+	local := models.Restaurant{Id: primitive.NewObjectID(), Name: "local", DeliveryRadius: 1, Coord: models.GeoCoords{Long: 3000}, MinDeliveryTime: 20, MaxDeliveryTime: 40}
+	notDelivering := models.Restaurant{Id: primitive.NewObjectID(), Name: "far", DeliveryRadius: 2}
+	repo.Restaurants = []models.Restaurant{local, notDelivering}
+
+	remote := types.SearchHit{
+		Restaurant: types.PublicRestaurant{Id: primitive.NewObjectID(), Name: "remote", Rate: models.Rate{Stars: 45, Votes: 10}},
+		PeerUrl:    "http://near.com",
+		Distance:   1000,
+		Eta:        30,
+	}
+	duplicated := types.SearchHit{Restaurant: types.PublicRestaurant{Id: local.Id, Name: "local"}, PeerUrl: "http://near.com"}
+	// near.com announced it suspended this one but still returns it
+	suspended := types.SearchHit{Restaurant: types.PublicRestaurant{Id: primitive.NewObjectID(), Name: "suspended"}, PeerUrl: "http://near.com"}
+	remoteStatuses.Upsert(models.RemoteRestaurantStatus{RestaurantId: suspended.Restaurant.Id, PeerUrl: "http://near.com", Status: models.RESTAURANT_SUSPENDED})
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	var nearQuery map[string][]string
+	httpmock.RegisterResponder("GET", "http://near.com/peer/search/restaurants",
+		func(req *http.Request) (*http.Response, error) {
+			nearQuery = req.URL.Query()
+			return httpmock.NewJsonResponse(http.StatusOK, []types.SearchHit{remote, duplicated, suspended})
+		})
+	httpmock.RegisterResponder("GET", "http://down.com/peer/search/restaurants", httpmock.NewStringResponder(http.StatusInternalServerError, ""))
+	httpmock.RegisterResponder("GET", "http://slow.com/peer/search/restaurants",
+		func(req *http.Request) (*http.Response, error) {
+			time.Sleep(200 * time.Millisecond)
+			return httpmock.NewJsonResponse(http.StatusOK, []types.SearchHit{})
+		})
+
+	lat, long := 1.0, 2.0
+	result, err := service.Search(types.SearchQuery{Lat: &lat, Long: &long, City: "Rosario"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(result.Restaurants) != 2 {
+		t.Fatalf("expecting 2 restaurants but got %v", result.Restaurants)
+	}
+	if result.Restaurants[0].Restaurant.Id != remote.Restaurant.Id || result.Restaurants[1].Restaurant.Id != local.Id {
+		t.Errorf("expecting the better rated and closer restaurant first but got %v", result.Restaurants)
+	}
+	if result.Restaurants[1].PeerUrl != "http://test.com" || result.Restaurants[1].Eta != 30 || result.Restaurants[1].Distance != 3000 {
+		t.Errorf("unexpected local hit %v", result.Restaurants[1])
+	}
+	if len(result.FailedPeers) != 2 || result.FailedPeers[0] != "http://down.com" || result.FailedPeers[1] != "http://slow.com" {
+		t.Errorf("expecting down and slow peers to fail but got %v", result.FailedPeers)
+	}
+	if httpmock.GetCallCountInfo()["GET http://test.com/peer/search/restaurants"] != 0 {
+		t.Error("expecting the local peer not to be queried")
+	}
+	if nearQuery["lat"][0] != "1" || nearQuery["long"][0] != "2" || nearQuery["city"][0] != "Rosario" || nearQuery["at"][0] == "" {
+		t.Errorf("unexpected peer query %v", nearQuery)
+	}
+}
+
+func TestRankSearchHits(t *testing.T) {
+	closedBest := types.SearchHit{Restaurant: types.PublicRestaurant{Name: "closed", Rate: models.Rate{Stars: 5, Votes: 1}}}
+	near := types.SearchHit{Restaurant: types.PublicRestaurant{Name: "near", IsOpen: true}, Distance: 0.5, Eta: 20}
+	fast := types.SearchHit{Restaurant: types.PublicRestaurant{Name: "fast", IsOpen: true}, Distance: 0.5, Eta: 10}
+	// a single five star vote is not enough to beat the closer ones
+	lucky := types.SearchHit{Restaurant: types.PublicRestaurant{Name: "lucky", IsOpen: true, Rate: models.Rate{Stars: 5, Votes: 1}}, Distance: 1, Eta: 10}
+	rated := types.SearchHit{Restaurant: types.PublicRestaurant{Name: "rated", IsOpen: true, Rate: models.Rate{Stars: 50, Votes: 10}}, Distance: 1, Eta: 10}
+
+	hits := []types.SearchHit{closedBest, near, lucky, rated, fast}
+	rankSearchHits(hits)
+
+	expected := []string{"rated", "fast", "lucky", "near", "closed"}
+	for i, name := range expected {
+		if hits[i].Restaurant.Name != name {
+			t.Errorf("expecting %v at %v but got %v", name, i, hits[i].Restaurant.Name)
+		}
+	}
+}
+
+func TestLocalSearchDietary(t *testing.T) {
+	restaurants, repo, _ := initRestaurantTest()
+	menuVersions := mocks.NewMenuVersionServiceMock()
+	service := NewSearchService(restaurants, mocks.NewPeerServiceMock(), mocks.NewGeo(), mocks.NewRemoteRestaurantStatusRepositoryMock(), menuVersions)
+
+	veggie := models.Restaurant{Id: primitive.NewObjectID(), Name: "veggie", DeliveryRadius: 1}
+	grill := models.Restaurant{Id: primitive.NewObjectID(), Name: "grill", DeliveryRadius: 1}
+	noMenu := models.Restaurant{Id: primitive.NewObjectID(), Name: "no menu", DeliveryRadius: 1}
+	repo.Restaurants = []models.Restaurant{veggie, grill, noMenu}
+	menuVersions.Menus = map[primitive.ObjectID]models.Menu{
+		veggie.Id: {Sections: []models.MenuSection{{Dishes: []models.Dish{{Name: "tofu", Dietary: models.Dietary{DietaryLabels: []string{models.DIET_VEGAN}}}}}}},
+		grill.Id:  {Sections: []models.MenuSection{{Dishes: []models.Dish{{Name: "steak"}}}}},
+	}
+
+	lat, long := 1.0, 1.0
+	hits, err := service.LocalSearch(types.SearchQuery{Lat: &lat, Long: &long, Include: []string{models.DIET_VEGAN}})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(hits) != 1 || hits[0].Restaurant.Id != veggie.Id {
+		t.Errorf("expecting only the veggie restaurant but got %v", hits)
+	}
+
+	hits, _ = service.LocalSearch(types.SearchQuery{Lat: &lat, Long: &long})
+	if len(hits) != 3 {
+		t.Errorf("expecting every restaurant without filters but got %v", hits)
+	}
+}
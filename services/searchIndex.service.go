@@ -0,0 +1,115 @@
+package services
+
+import (
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/repositories"
+	"github.com/nicodeheza/peersEat/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// SearchIndexServiceI keeps the text search documents in sync, it listens to the same
+// writes that are replicated and the whole index is rebuilt on boot
+type SearchIndexServiceI interface {
+	RestaurantChanged(id primitive.ObjectID)
+	Reindex(restaurantId primitive.ObjectID) error
+	ReindexAll() error
+}
+
+type SearchIndexService struct {
+	restaurantRepo  repositories.RestaurantRepositoryI
+	menuVersionRepo repositories.MenuVersionRepositoryI
+	searchRepo      repositories.RestaurantSearchRepositoryI
+}
+
+func NewSearchIndexService(restaurantRepo repositories.RestaurantRepositoryI, menuVersionRepo repositories.MenuVersionRepositoryI, searchRepo repositories.RestaurantSearchRepositoryI) *SearchIndexService {
+	return &SearchIndexService{restaurantRepo, menuVersionRepo, searchRepo}
+}
+
+// a failed reindex leaves the document stale until the next write or boot
+func (s *SearchIndexService) RestaurantChanged(id primitive.ObjectID) {
+	if err := s.Reindex(id); err != nil {
+		log.Printf("failed to index restaurant %v: %v\n", id.Hex(), err.Error())
+	}
+}
+
+func (s *SearchIndexService) Reindex(restaurantId primitive.ObjectID) error {
+	restaurant, err := s.restaurantRepo.FindOne(map[string]interface{}{"_id": restaurantId})
+	if err == mongo.ErrNoDocuments {
+		return s.searchRepo.Delete(restaurantId)
+	}
+	if err != nil {
+		return err
+	}
+
+	versions, err := s.menuVersionRepo.FindMany(map[string]interface{}{"restaurantId": restaurantId})
+	if err != nil {
+		return err
+	}
+	return s.searchRepo.Upsert(newRestaurantSearch(restaurant, versions))
+}
+
+func (s *SearchIndexService) ReindexAll() error {
+	restaurants, err := s.restaurantRepo.FindMany(map[string]interface{}{})
+	if err != nil {
+		return err
+	}
+	for _, restaurant := range restaurants {
+		versions, err := s.menuVersionRepo.FindMany(map[string]interface{}{"restaurantId": restaurant.Id})
+		if err != nil {
+			return err
+		}
+		if err := s.searchRepo.Upsert(newRestaurantSearch(restaurant, versions)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// the current menu depends on the time, so the words of the latest version of every
+// named menu are indexed and the search matches the candidates against the current one
+func newRestaurantSearch(restaurant models.Restaurant, versions []models.MenuVersion) models.RestaurantSearch {
+	latest := map[string]models.MenuVersion{}
+	for _, version := range versions {
+		if current, ok := latest[version.Name]; !ok || version.Version > current.Version {
+			latest[version.Name] = version
+		}
+	}
+
+	texts := []string{restaurant.Name, strings.Join(restaurant.Tags, " ")}
+	for _, version := range latest {
+		if version.Archived {
+			continue
+		}
+		for _, section := range version.Menu.Sections {
+			for _, dish := range section.Dishes {
+				texts = append(texts, dish.Name, strings.Join(dish.Tags, " "), dish.Description)
+			}
+		}
+	}
+
+	words, keys := map[string]bool{}, map[string]bool{}
+	for _, word := range utils.Tokenize(strings.Join(texts, " ")) {
+		if words[word] {
+			continue
+		}
+		words[word] = true
+		for _, key := range deletionKeys(word, wordDeletions(word)) {
+			keys[key] = true
+		}
+	}
+	return models.RestaurantSearch{RestaurantId: restaurant.Id, City: restaurant.City, Words: sortedKeys(words), Keys: sortedKeys(keys)}
+}
+
+func sortedKeys(set map[string]bool) []string {
+	result := make([]string, 0, len(set))
+	for key := range set {
+		result = append(result, key)
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/nicodeheza/peersEat/mocks"
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSearchIndex(t *testing.T) {
+	service, index, restaurantRepo, versionRepo := initTextSearchTest()
+
+	pizzeria := models.Restaurant{Id: primitive.NewObjectID(), Name: "Don Pepe", City: "Rosario"}
+	restaurantRepo.Restaurants = []models.Restaurant{pizzeria}
+	margherita := models.Dish{Id: primitive.NewObjectID(), Name: "Margherita"}
+	versionRepo.Versions = []models.MenuVersion{publishedMenu(pizzeria.Id, margherita)}
+
+	found := func(q string, city string) bool {
+		result, err := service.Search(types.TextSearchQuery{Q: q, City: city})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		return len(result.Results) == 1
+	}
+
+	// restaurants are only searched once indexed
+	if found("margherita", "") {
+		t.Errorf("expecting no results before indexing")
+	}
+	index.RestaurantChanged(pizzeria.Id)
+
+	tests := []struct {
+		q     string
+		city  string
+		found bool
+	}{
+		{"margherita", "", true},
+		// two typos on a long word
+		{"margarita", "", true},
+		{"pepe margh", "rosario", true},
+		{"margherita", "cordoba", false},
+		{"calzone", "", false},
+	}
+	for _, test := range tests {
+		if found(test.q, test.city) != test.found {
+			t.Errorf("%q in %q: expecting found %v", test.q, test.city, test.found)
+		}
+	}
+
+	// a new version is searchable once indexed again
+	calzone := publishedMenu(pizzeria.Id, models.Dish{Id: primitive.NewObjectID(), Name: "Calzone"})
+	calzone.Version = 2
+	versionRepo.Versions = append(versionRepo.Versions, calzone)
+	index.RestaurantChanged(pizzeria.Id)
+	if !found("calzone", "") || found("margherita", "") {
+		t.Errorf("expecting only the dishes of the new version to match")
+	}
+
+	// removed restaurants leave the index
+	restaurantRepo.Restaurants = []models.Restaurant{}
+	index.RestaurantChanged(pizzeria.Id)
+	if documents := index.searchRepo.(*mocks.RestaurantSearchRepositoryMock).Documents; len(documents) != 0 {
+		t.Errorf("expecting the document to be deleted but got %v", documents)
+	}
+}
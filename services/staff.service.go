@@ -0,0 +1,242 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/nicodeheza/peersEat/constants"
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/repositories"
+	"github.com/nicodeheza/peersEat/types"
+	"github.com/nicodeheza/peersEat/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type StaffServiceI interface {
+	Authenticate(password, userName string) (bool, models.StaffUser, error)
+	Role(restaurantId primitive.ObjectID, staffId primitive.ObjectID) (string, error)
+	List(restaurantId primitive.ObjectID) ([]models.StaffUser, error)
+	Invite(restaurantId primitive.ObjectID, by models.StaffActor, data types.StaffInvitationData) (types.StaffInvitation, error)
+	AcceptInvitation(data types.AcceptInvitationData) (models.StaffUser, error)
+	ChangeRole(restaurantId primitive.ObjectID, by models.StaffActor, staffId primitive.ObjectID, role string) (models.StaffUser, error)
+	Remove(restaurantId primitive.ObjectID, by models.StaffActor, staffId primitive.ObjectID) error
+	UpdatePassword(staffId primitive.ObjectID, newPassword string) error
+	CheckUserName(userName string, restaurantId primitive.ObjectID) error
+}
+
+var ErrRoleNotAllowed = errors.New("role not allowed")
+var ErrStaffSelfChange = errors.New("staff users can't change their own role or remove themselves")
+var ErrInvitationNotFound = errors.New("invitation not found")
+var ErrInvitationExpired = errors.New("invitation expired")
+var ErrUserNameTaken = errors.New("user name already taken")
+
+type StaffService struct {
+	repo           repositories.StaffUserRepositoryI
+	restaurantRepo repositories.RestaurantRepositoryI
+	authHelpers    utils.AuthHelpersI
+}
+
+func NewStaffService(repo repositories.StaffUserRepositoryI, restaurantRepo repositories.RestaurantRepositoryI, authHelpers utils.AuthHelpersI) *StaffService {
+	return &StaffService{repo, restaurantRepo, authHelpers}
+}
+
+func (s *StaffService) Authenticate(password, userName string) (bool, models.StaffUser, error) {
+	user, err := s.repo.FindOne(map[string]interface{}{"userName": userName})
+	if err != nil {
+		return false, models.StaffUser{}, err
+	}
+	return s.authHelpers.CheckPassword(password, user.Password), user, nil
+}
+
+// the role is read on every request so changes and removals apply right away
+func (s *StaffService) Role(restaurantId primitive.ObjectID, staffId primitive.ObjectID) (string, error) {
+	user, err := s.repo.FindOne(map[string]interface{}{"_id": staffId, "restaurantId": restaurantId})
+	if err != nil {
+		return "", err
+	}
+	if user.IsPending {
+		return "", mongo.ErrNoDocuments
+	}
+	return user.Role, nil
+}
+
+func (s *StaffService) List(restaurantId primitive.ObjectID) ([]models.StaffUser, error) {
+	return s.repo.FindMany(restaurantId)
+}
+
+// only the hash of the token is stored
+func (s *StaffService) Invite(restaurantId primitive.ObjectID, by models.StaffActor, data types.StaffInvitationData) (types.StaffInvitation, error) {
+	if !models.CanAssignRole(by.Role, data.Role) {
+		return types.StaffInvitation{}, ErrRoleNotAllowed
+	}
+
+	token, err := newSecretToken()
+	if err != nil {
+		return types.StaffInvitation{}, err
+	}
+	now := time.Now()
+	expiresAt := now.Add(constants.STAFF_INVITE_TTL)
+	user := models.StaffUser{
+		RestaurantId:    restaurantId,
+		Name:            data.Name,
+		Role:            data.Role,
+		InviteTokenHash: hashSecretToken(token),
+		InviteExpiresAt: &expiresAt,
+		InvitedBy:       by.Id,
+		IsPending:       true,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	user.Id, err = s.repo.Insert(user)
+	if err != nil {
+		return types.StaffInvitation{}, err
+	}
+	return types.StaffInvitation{User: user, Token: token}, nil
+}
+
+func (s *StaffService) AcceptInvitation(data types.AcceptInvitationData) (models.StaffUser, error) {
+	user, err := s.repo.FindOne(map[string]interface{}{"inviteTokenHash": hashSecretToken(data.Token)})
+	if err == mongo.ErrNoDocuments || (err == nil && !user.IsPending) {
+		return models.StaffUser{}, ErrInvitationNotFound
+	}
+	if err != nil {
+		return models.StaffUser{}, err
+	}
+	if user.InviteExpiresAt != nil && time.Now().After(*user.InviteExpiresAt) {
+		return models.StaffUser{}, ErrInvitationExpired
+	}
+
+	if err := s.CheckUserName(data.UserName, primitive.NilObjectID); err != nil {
+		return models.StaffUser{}, err
+	}
+	hash, err := s.authHelpers.HashPasswords(data.Password)
+	if err != nil {
+		return models.StaffUser{}, err
+	}
+
+	set := map[string]interface{}{"userName": data.UserName, "password": hash, "isPending": false, "updatedAt": time.Now()}
+	if err := s.repo.Update(user.Id, set, []string{"inviteTokenHash", "inviteExpiresAt"}); err != nil {
+		return models.StaffUser{}, err
+	}
+	user.UserName = data.UserName
+	user.IsPending = false
+	user.InviteTokenHash = ""
+	user.InviteExpiresAt = nil
+	return user, nil
+}
+
+// staff and restaurant accounts log in with the same form, the restaurant account
+// of restaurantId can keep its own user name and staff users pass a nil id
+func (s *StaffService) CheckUserName(userName string, restaurantId primitive.ObjectID) error {
+	_, err := s.repo.FindOne(map[string]interface{}{"userName": userName})
+	if err == nil {
+		return ErrUserNameTaken
+	}
+	if err != mongo.ErrNoDocuments {
+		return err
+	}
+	restaurant, err := s.restaurantRepo.FindOne(map[string]interface{}{"userName": userName})
+	if err == nil && restaurant.Id != restaurantId {
+		return ErrUserNameTaken
+	}
+	if err != mongo.ErrNoDocuments {
+		return err
+	}
+	return nil
+}
+
+// the actor has to be able to assign both the current and the new role
+func (s *StaffService) ChangeRole(restaurantId primitive.ObjectID, by models.StaffActor, staffId primitive.ObjectID, role string) (models.StaffUser, error) {
+	user, err := s.managedUser(restaurantId, by, staffId)
+	if err != nil {
+		return models.StaffUser{}, err
+	}
+	if !models.CanAssignRole(by.Role, role) {
+		return models.StaffUser{}, ErrRoleNotAllowed
+	}
+
+	if err := s.repo.Update(user.Id, map[string]interface{}{"role": role, "updatedAt": time.Now()}, nil); err != nil {
+		return models.StaffUser{}, err
+	}
+	user.Role = role
+	return user, nil
+}
+
+func (s *StaffService) Remove(restaurantId primitive.ObjectID, by models.StaffActor, staffId primitive.ObjectID) error {
+	user, err := s.managedUser(restaurantId, by, staffId)
+	if err != nil {
+		return err
+	}
+	return s.repo.Delete(user.Id)
+}
+
+func (s *StaffService) managedUser(restaurantId primitive.ObjectID, by models.StaffActor, staffId primitive.ObjectID) (models.StaffUser, error) {
+	if by.Id == staffId {
+		return models.StaffUser{}, ErrStaffSelfChange
+	}
+	user, err := s.repo.FindOne(map[string]interface{}{"_id": staffId, "restaurantId": restaurantId})
+	if err != nil {
+		return models.StaffUser{}, err
+	}
+	if !models.CanAssignRole(by.Role, user.Role) {
+		return models.StaffUser{}, ErrRoleNotAllowed
+	}
+	return user, nil
+}
+
+func (s *StaffService) UpdatePassword(staffId primitive.ObjectID, newPassword string) error {
+	hash, err := s.authHelpers.HashPasswords(newPassword)
+	if err != nil {
+		return err
+	}
+	return s.repo.Update(staffId, map[string]interface{}{"password": hash, "updatedAt": time.Now()}, nil)
+}
+
+// tokens given once to their owner, only their hash is stored
+func newSecretToken() (string, error) {
+	bytes := make([]byte, 24)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+func hashSecretToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// the credentials travel apart, the json of the staff users leaves them out
+func transferStaffUsers(users []models.StaffUser) []types.TransferredStaffUser {
+	transferred := []types.TransferredStaffUser{}
+	for _, user := range users {
+		transferred = append(transferred, types.TransferredStaffUser{StaffUser: user, Password: user.Password, InviteTokenHash: user.InviteTokenHash})
+	}
+	return transferred
+}
+
+// the users always belong to the restaurant they came with
+func receiveStaffUsers(restaurantId primitive.ObjectID, transferred []types.TransferredStaffUser) []models.StaffUser {
+	users := []models.StaffUser{}
+	for _, received := range transferred {
+		user := received.StaffUser
+		user.RestaurantId = restaurantId
+		user.Password = received.Password
+		user.InviteTokenHash = received.InviteTokenHash
+		users = append(users, user)
+	}
+	return users
+}
+
+func upsertStaffUsers(repo repositories.StaffUserRepositoryI, users []models.StaffUser) error {
+	for _, user := range users {
+		if err := repo.Upsert(user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
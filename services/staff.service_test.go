@@ -0,0 +1,122 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nicodeheza/peersEat/mocks"
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/types"
+	"github.com/nicodeheza/peersEat/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestRolePermissions(t *testing.T) {
+	if !models.RoleCan(models.STAFF_KITCHEN, models.PERM_OPERATE) || models.RoleCan(models.STAFF_KITCHEN, models.PERM_EDIT_PRICING) {
+		t.Error("kitchen staff should toggle availability but not change delivery pricing")
+	}
+	if models.RoleCan(models.STAFF_READ_ONLY, models.PERM_OPERATE) || !models.RoleCan(models.STAFF_READ_ONLY, models.PERM_VIEW) {
+		t.Error("read only staff should only view")
+	}
+	if models.RoleCan(models.STAFF_MANAGER, models.PERM_MANAGE_ACCOUNT) || !models.RoleCan(models.STAFF_OWNER, models.PERM_MANAGE_ACCOUNT) {
+		t.Error("only owners should manage the restaurant account")
+	}
+	if models.RoleCan("unknown", models.PERM_VIEW) {
+		t.Error("unknown roles should not have permissions")
+	}
+}
+
+func TestStaffInvitation(t *testing.T) {
+	repo := mocks.NewStaffUserRepositoryMock()
+	restaurantRepo := mocks.NewRestaurantRepositoryMock()
+	restaurantRepo.Restaurants = []models.Restaurant{{Id: primitive.NewObjectID(), UserName: "taken.user.name"}}
+	service := NewStaffService(repo, restaurantRepo, utils.NewAuthHelper())
+	restaurantId := restaurantRepo.Restaurants[0].Id
+	owner := models.StaffActor{Role: models.STAFF_OWNER}
+
+	invitation, err := service.Invite(restaurantId, owner, types.StaffInvitationData{Name: "Ana", Role: models.STAFF_KITCHEN})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invitation.Token == "" || !invitation.User.IsPending || repo.Users[0].InviteTokenHash == invitation.Token {
+		t.Fatalf("expecting a pending user with a hashed token but got %v", repo.Users[0])
+	}
+	if _, err := service.Role(restaurantId, invitation.User.Id); err != mongo.ErrNoDocuments {
+		t.Errorf("pending users should not have a role but got %v", err)
+	}
+
+	if _, err := service.AcceptInvitation(types.AcceptInvitationData{Token: "wrong", UserName: "ana.kitchen", Password: "password123"}); err != ErrInvitationNotFound {
+		t.Errorf("expecting %v but got %v", ErrInvitationNotFound, err)
+	}
+	if _, err := service.AcceptInvitation(types.AcceptInvitationData{Token: invitation.Token, UserName: "taken.user.name", Password: "password123"}); err != ErrUserNameTaken {
+		t.Errorf("expecting %v but got %v", ErrUserNameTaken, err)
+	}
+
+	user, err := service.AcceptInvitation(types.AcceptInvitationData{Token: invitation.Token, UserName: "ana.kitchen", Password: "password123"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.IsPending || repo.Users[0].InviteTokenHash != "" || repo.Users[0].UserName != "ana.kitchen" {
+		t.Errorf("expecting an active user but got %v", repo.Users[0])
+	}
+	if _, err := service.AcceptInvitation(types.AcceptInvitationData{Token: invitation.Token, UserName: "other.name", Password: "password123"}); err != ErrInvitationNotFound {
+		t.Errorf("invitations should be accepted once but got %v", err)
+	}
+
+	isAuth, staff, err := service.Authenticate("password123", "ana.kitchen")
+	if err != nil || !isAuth || staff.Id != user.Id {
+		t.Errorf("expecting the staff user to log in but got %v %v %v", isAuth, staff, err)
+	}
+	if role, err := service.Role(restaurantId, user.Id); err != nil || role != models.STAFF_KITCHEN {
+		t.Errorf("expecting the kitchen role but got %v %v", role, err)
+	}
+
+	expired, _ := service.Invite(restaurantId, owner, types.StaffInvitationData{Name: "Bob", Role: models.STAFF_READ_ONLY})
+	past := time.Now().Add(-time.Hour)
+	repo.Users[1].InviteExpiresAt = &past
+	if _, err := service.AcceptInvitation(types.AcceptInvitationData{Token: expired.Token, UserName: "bob.reader", Password: "password123"}); err != ErrInvitationExpired {
+		t.Errorf("expecting %v but got %v", ErrInvitationExpired, err)
+	}
+}
+
+func TestStaffRoles(t *testing.T) {
+	repo := mocks.NewStaffUserRepositoryMock()
+	service := NewStaffService(repo, mocks.NewRestaurantRepositoryMock(), utils.NewAuthHelper())
+	restaurantId := primitive.NewObjectID()
+	manager := models.StaffUser{Id: primitive.NewObjectID(), RestaurantId: restaurantId, Role: models.STAFF_MANAGER}
+	otherManager := models.StaffUser{Id: primitive.NewObjectID(), RestaurantId: restaurantId, Role: models.STAFF_MANAGER}
+	kitchen := models.StaffUser{Id: primitive.NewObjectID(), RestaurantId: restaurantId, Role: models.STAFF_KITCHEN}
+	foreign := models.StaffUser{Id: primitive.NewObjectID(), RestaurantId: primitive.NewObjectID(), Role: models.STAFF_KITCHEN}
+	repo.Users = []models.StaffUser{manager, otherManager, kitchen, foreign}
+	asManager := models.StaffActor{Id: manager.Id, Role: models.STAFF_MANAGER}
+
+	if _, err := service.Invite(restaurantId, asManager, types.StaffInvitationData{Name: "Owner", Role: models.STAFF_OWNER}); err != ErrRoleNotAllowed {
+		t.Errorf("managers should not invite owners but got %v", err)
+	}
+	if _, err := service.ChangeRole(restaurantId, asManager, kitchen.Id, models.STAFF_MANAGER); err != ErrRoleNotAllowed {
+		t.Errorf("managers should not promote to manager but got %v", err)
+	}
+	if _, err := service.ChangeRole(restaurantId, asManager, otherManager.Id, models.STAFF_KITCHEN); err != ErrRoleNotAllowed {
+		t.Errorf("managers should not demote other managers but got %v", err)
+	}
+	if _, err := service.ChangeRole(restaurantId, asManager, manager.Id, models.STAFF_KITCHEN); err != ErrStaffSelfChange {
+		t.Errorf("expecting %v but got %v", ErrStaffSelfChange, err)
+	}
+	if err := service.Remove(restaurantId, asManager, foreign.Id); err != mongo.ErrNoDocuments {
+		t.Errorf("staff of other restaurants should not be found but got %v", err)
+	}
+
+	user, err := service.ChangeRole(restaurantId, asManager, kitchen.Id, models.STAFF_READ_ONLY)
+	if err != nil || user.Role != models.STAFF_READ_ONLY || repo.Users[2].Role != models.STAFF_READ_ONLY {
+		t.Errorf("expecting the user to be read only but got %v %v", user, err)
+	}
+
+	owner := models.StaffActor{Role: models.STAFF_OWNER}
+	if err := service.Remove(restaurantId, owner, otherManager.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Role(restaurantId, otherManager.Id); err != mongo.ErrNoDocuments {
+		t.Errorf("removed users should lose their role but got %v", err)
+	}
+}
@@ -0,0 +1,270 @@
+package services
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nicodeheza/peersEat/constants"
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/repositories"
+	"github.com/nicodeheza/peersEat/services/geo"
+	"github.com/nicodeheza/peersEat/types"
+	"github.com/nicodeheza/peersEat/utils"
+)
+
+// field weights, the best possible match is a restaurant name
+const restaurantNameWeight = 3.0
+const tagWeight = 2.0
+const dishNameWeight = 2.0
+const descriptionWeight = 1.0
+
+const exactMatchScore = 1.0
+const prefixMatchScore = 0.8
+const typoMatchScore = 0.6
+
+type TextSearchServiceI interface {
+	Search(query types.TextSearchQuery) (types.TextSearchResult, error)
+}
+
+// TextSearchService ranks the restaurants names and tags and their current menus,
+// mongo text indexes don't match typos or prefixes so the candidates come from the
+// search documents kept by the SearchIndexService
+type TextSearchService struct {
+	restaurantRepo repositories.RestaurantRepositoryI
+	searchRepo     repositories.RestaurantSearchRepositoryI
+	menuVersions   MenuVersionServiceI
+	geo            geo.GeoServiceI
+}
+
+func NewTextSearchService(restaurantRepo repositories.RestaurantRepositoryI, searchRepo repositories.RestaurantSearchRepositoryI, menuVersions MenuVersionServiceI, geo geo.GeoServiceI) *TextSearchService {
+	return &TextSearchService{restaurantRepo, searchRepo, menuVersions, geo}
+}
+
+type textField struct {
+	tokens []string
+	weight float64
+}
+
+type rankedTextHit struct {
+	hit   types.TextSearchHit
+	score float64
+}
+
+func (s *TextSearchService) Search(query types.TextSearchQuery) (types.TextSearchResult, error) {
+	if query.Page == 0 {
+		query.Page = 1
+	}
+	if query.PageSize == 0 {
+		query.PageSize = constants.TEXT_SEARCH_PAGE_SIZE
+	}
+	result := types.TextSearchResult{Results: []types.TextSearchHit{}, Page: query.Page, PageSize: query.PageSize}
+
+	terms := utils.Tokenize(query.Q)
+	if len(terms) == 0 {
+		return result, nil
+	}
+
+	searchTerms := make([]repositories.SearchTerm, len(terms))
+	for i, term := range terms {
+		searchTerms[i] = repositories.SearchTerm{Word: term, Keys: deletionKeys(term, typoAllowance(term)), Prefix: i == len(terms)-1}
+	}
+	ids, err := s.searchRepo.Match(query.City, searchTerms)
+	if err != nil {
+		return types.TextSearchResult{}, err
+	}
+	if len(ids) == 0 {
+		return result, nil
+	}
+	restaurants, err := s.restaurantRepo.FindMany(map[string]interface{}{"_id": map[string]interface{}{"$in": ids}})
+	if err != nil {
+		return types.TextSearchResult{}, err
+	}
+
+	now := time.Now()
+	filter := query.Filter()
+	ranked := []rankedTextHit{}
+	for _, restaurant := range restaurants {
+		if !restaurant.IsActive() {
+			continue
+		}
+		menu := models.Menu{}
+		version, err := s.menuVersions.CurrentMenu(restaurant.Id, now)
+		if err != nil && err != ErrNoMenuAvailable {
+			return types.TextSearchResult{}, err
+		}
+		if err == nil {
+			menu = version.Menu.Filter(filter)
+		}
+		if !filter.IsEmpty() && len(menu.Sections) == 0 {
+			continue
+		}
+
+		hit, ok := matchRestaurant(restaurant, menu, terms)
+		if !ok {
+			continue
+		}
+		hit.Restaurant = NewPublicRestaurant(restaurant, now)
+
+		score := hit.Relevance*constants.SEARCH_TEXT_WEIGHT + restaurant.Rate.Score()*constants.SEARCH_RATING_WEIGHT
+		if query.Lat != nil && query.Long != nil {
+			distance := s.geo.GetCoordDistance(restaurant.Coord, models.GeoCoords{Lat: *query.Lat, Long: *query.Long})
+			hit.Distance = &distance
+			score -= distance * constants.SEARCH_DISTANCE_WEIGHT
+		}
+		ranked = append(ranked, rankedTextHit{hit, score})
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].score > ranked[j].score })
+
+	result.Total = len(ranked)
+	// pages past the last one are empty, checked before multiplying so a huge page can't overflow
+	if query.Page-1 >= (len(ranked)+query.PageSize-1)/query.PageSize {
+		return result, nil
+	}
+	start := (query.Page - 1) * query.PageSize
+	for i := start; i < len(ranked) && i < start+query.PageSize; i++ {
+		result.Results = append(result.Results, ranked[i].hit)
+	}
+	return result, nil
+}
+
+// every term has to match the restaurant or one of its dishes, the dishes listed
+// are the ones matching the terms the restaurant itself doesn't
+func matchRestaurant(restaurant models.Restaurant, menu models.Menu, terms []string) (types.TextSearchHit, bool) {
+	restaurantFields := []textField{
+		{utils.Tokenize(restaurant.Name), restaurantNameWeight},
+		{utils.Tokenize(strings.Join(restaurant.Tags, " ")), tagWeight},
+	}
+	restaurantScores := make([]float64, len(terms))
+	best := make([]float64, len(terms))
+	for i, term := range terms {
+		restaurantScores[i] = fieldsScore(term, restaurantFields, i == len(terms)-1)
+		best[i] = restaurantScores[i]
+	}
+
+	type dishMatch struct {
+		dish  types.MatchedDish
+		score float64
+	}
+	dishes := []dishMatch{}
+	for _, section := range menu.Sections {
+		for _, dish := range section.Dishes {
+			dishFields := []textField{
+				{utils.Tokenize(dish.Name), dishNameWeight},
+				{utils.Tokenize(strings.Join(dish.Tags, " ")), tagWeight},
+				{utils.Tokenize(dish.Description), descriptionWeight},
+			}
+
+			matched, complete, total := false, true, 0.0
+			for i, term := range terms {
+				score := fieldsScore(term, dishFields, i == len(terms)-1)
+				if score > best[i] {
+					best[i] = score
+				}
+				if score > 0 {
+					matched = true
+					total += score
+				} else if restaurantScores[i] == 0 {
+					complete = false
+				}
+			}
+			if matched && complete {
+				dishes = append(dishes, dishMatch{types.MatchedDish{Id: dish.Id, Name: dish.Name}, total})
+			}
+		}
+	}
+
+	relevance := 0.0
+	for _, score := range best {
+		if score == 0 {
+			return types.TextSearchHit{}, false
+		}
+		relevance += score
+	}
+
+	sort.SliceStable(dishes, func(i, j int) bool { return dishes[i].score > dishes[j].score })
+	hit := types.TextSearchHit{Dishes: []types.MatchedDish{}, Relevance: relevance / float64(len(terms)) / restaurantNameWeight}
+	for _, match := range dishes {
+		hit.Dishes = append(hit.Dishes, match.dish)
+	}
+	return hit, true
+}
+
+func fieldsScore(term string, fields []textField, prefix bool) float64 {
+	best := 0.0
+	for _, field := range fields {
+		for _, token := range field.tokens {
+			if score := termScore(term, token, prefix) * field.weight; score > best {
+				best = score
+			}
+		}
+	}
+	return best
+}
+
+// the last term is matched as a prefix since the customer may still be typing it
+func termScore(term string, token string, prefix bool) float64 {
+	if term == token {
+		return exactMatchScore
+	}
+	if prefix && strings.HasPrefix(token, term) {
+		return prefixMatchScore
+	}
+
+	allowed := typoAllowance(term)
+	lengthDiff := len([]rune(term)) - len([]rune(token))
+	if lengthDiff < 0 {
+		lengthDiff = -lengthDiff
+	}
+	if allowed > 0 && lengthDiff <= allowed && utils.EditDistance(term, token) <= allowed {
+		return typoMatchScore
+	}
+	return 0
+}
+
+// short words must match exactly, "pie" is not a typo of "pho"
+func typoAllowance(term string) int {
+	length := len([]rune(term))
+	if length >= 8 {
+		return 2
+	}
+	if length >= 4 {
+		return 1
+	}
+	return 0
+}
+
+// the most typos of a term matching the word, terms can be up to two letters longer
+func wordDeletions(word string) int {
+	length := len([]rune(word))
+	if length >= 6 {
+		return 2
+	}
+	if length >= 3 {
+		return 1
+	}
+	return 0
+}
+
+// a term within the allowed typos of a word shares a key with it when both
+// have up to that many letters deleted
+func deletionKeys(word string, deletions int) []string {
+	keys := map[string]bool{word: true}
+	level := []string{word}
+	for i := 0; i < deletions; i++ {
+		next := []string{}
+		for _, key := range level {
+			runes := []rune(key)
+			for j := range runes {
+				deleted := string(runes[:j]) + string(runes[j+1:])
+				if deleted != "" && !keys[deleted] {
+					keys[deleted] = true
+					next = append(next, deleted)
+				}
+			}
+		}
+		level = next
+	}
+	return sortedKeys(keys)
+}
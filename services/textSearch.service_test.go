@@ -0,0 +1,131 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/nicodeheza/peersEat/mocks"
+	"github.com/nicodeheza/peersEat/models"
+	"github.com/nicodeheza/peersEat/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func initTextSearchTest() (*TextSearchService, *SearchIndexService, *mocks.RestaurantRepositoryMock, *mocks.MenuVersionRepositoryMock) {
+	restaurantRepo := mocks.NewRestaurantRepositoryMock()
+	versionRepo := mocks.NewMenuVersionRepositoryMock()
+	searchRepo := mocks.NewRestaurantSearchRepositoryMock()
+	menuVersions := NewMenuVersionService(versionRepo, restaurantRepo, NewMenuService(restaurantRepo))
+	index := NewSearchIndexService(restaurantRepo, versionRepo, searchRepo)
+	return NewTextSearchService(restaurantRepo, searchRepo, menuVersions, mocks.NewGeo()), index, restaurantRepo, versionRepo
+}
+
+func publishedMenu(restaurantId primitive.ObjectID, dishes ...models.Dish) models.MenuVersion {
+	return models.MenuVersion{
+		Id:           primitive.NewObjectID(),
+		RestaurantId: restaurantId,
+		Name:         "main",
+		Version:      1,
+		Menu:         models.Menu{Sections: []models.MenuSection{{Id: primitive.NewObjectID(), Name: "main", Dishes: dishes}}},
+		PublishedAt:  time.Now(),
+	}
+}
+
+func TestTextSearch(t *testing.T) {
+	service, index, restaurantRepo, versionRepo := initTextSearchTest()
+
+	ramenBar := models.Restaurant{Id: primitive.NewObjectID(), Name: "Ramen Bar", Coord: models.GeoCoords{Long: 4000}}
+	pizzeria := models.Restaurant{Id: primitive.NewObjectID(), Name: "Don Pepe", Tags: []string{"gluten free"}, Rate: models.Rate{Stars: 9, Votes: 2}}
+	noodles := models.Restaurant{Id: primitive.NewObjectID(), Name: "Noodle House"}
+	restaurantRepo.Restaurants = []models.Restaurant{ramenBar, pizzeria, noodles}
+
+	pizza := models.Dish{Id: primitive.NewObjectID(), Name: "Pizza muzzarella", Dietary: models.Dietary{Allergens: []string{models.ALLERGEN_MILK}}}
+	fugazza := models.Dish{Id: primitive.NewObjectID(), Name: "Fugazza", Tags: []string{"pizza"}}
+	empanada := models.Dish{Id: primitive.NewObjectID(), Name: "Empanada"}
+	shoyu := models.Dish{Id: primitive.NewObjectID(), Name: "Shoyu", Description: "ramen with soy broth"}
+	versionRepo.Versions = []models.MenuVersion{
+		publishedMenu(pizzeria.Id, pizza, fugazza, empanada),
+		publishedMenu(noodles.Id, shoyu),
+	}
+	if err := index.ReindexAll(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	tests := []struct {
+		q           string
+		restaurants []primitive.ObjectID
+		dishes      []primitive.ObjectID
+	}{
+		// restaurant name first, description matches rank lower
+		{"ramen", []primitive.ObjectID{ramenBar.Id, noodles.Id}, []primitive.ObjectID{}},
+		// typo
+		{"rameen", []primitive.ObjectID{ramenBar.Id, noodles.Id}, []primitive.ObjectID{}},
+		// prefix on the last word
+		{"ram", []primitive.ObjectID{ramenBar.Id, noodles.Id}, []primitive.ObjectID{}},
+		// terms split between the restaurant tags and the dishes
+		{"gluten free pizza", []primitive.ObjectID{pizzeria.Id}, []primitive.ObjectID{pizza.Id, fugazza.Id}},
+		{"gluten free sushi", []primitive.ObjectID{}, nil},
+		// short words need an exact match
+		{"pia", []primitive.ObjectID{}, nil},
+	}
+
+	for _, test := range tests {
+		result, err := service.Search(types.TextSearchQuery{Q: test.q})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if len(result.Results) != len(test.restaurants) || result.Total != len(test.restaurants) {
+			t.Errorf("%q: expecting %v restaurants but got %v", test.q, len(test.restaurants), result.Results)
+			continue
+		}
+		for i, hit := range result.Results {
+			if hit.Restaurant.Id != test.restaurants[i] {
+				t.Errorf("%q: expecting %v at %v but got %v", test.q, test.restaurants[i], i, hit.Restaurant.Name)
+			}
+		}
+		if test.dishes == nil {
+			continue
+		}
+		dishes := result.Results[0].Dishes
+		if len(dishes) != len(test.dishes) {
+			t.Errorf("%q: expecting %v dishes but got %v", test.q, len(test.dishes), dishes)
+			continue
+		}
+		for i, dish := range dishes {
+			if dish.Id != test.dishes[i] {
+				t.Errorf("%q: expecting dish %v at %v but got %v", test.q, test.dishes[i], i, dish.Name)
+			}
+		}
+	}
+
+	// the geo mock distance is the restaurant longitude, 4km pushes the ramen bar down
+	lat, long := 1.0, 1.0
+	result, err := service.Search(types.TextSearchQuery{Q: "ramen", Lat: &lat, Long: &long, Page: 1, PageSize: 1})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if result.Total != 2 || len(result.Results) != 1 || result.Results[0].Restaurant.Id != noodles.Id {
+		t.Errorf("expecting the closer restaurant in the first page but got %v", result)
+	}
+	if *result.Results[0].Distance != 0 {
+		t.Errorf("expecting distance 0 but got %v", *result.Results[0].Distance)
+	}
+	result, _ = service.Search(types.TextSearchQuery{Q: "ramen", Lat: &lat, Long: &long, Page: 2, PageSize: 1})
+	if len(result.Results) != 1 || result.Results[0].Restaurant.Id != ramenBar.Id {
+		t.Errorf("expecting the ramen bar in the second page but got %v", result.Results)
+	}
+	result, err = service.Search(types.TextSearchQuery{Q: "ramen", Page: math.MaxInt, PageSize: 50})
+	if err != nil || result.Total != 2 || len(result.Results) != 0 {
+		t.Errorf("expecting an empty page past the last one but got %v %v", result, err)
+	}
+
+	// dishes with the excluded allergens don't match, restaurants without dishes passing the filter are left out
+	result, _ = service.Search(types.TextSearchQuery{Q: "pizza", Exclude: []string{models.ALLERGEN_MILK}})
+	if len(result.Results) != 1 || len(result.Results[0].Dishes) != 1 || result.Results[0].Dishes[0].Id != fugazza.Id {
+		t.Errorf("expecting only the fugazza but got %v", result.Results)
+	}
+	result, _ = service.Search(types.TextSearchQuery{Q: "ramen", Include: []string{models.DIET_VEGAN}})
+	if len(result.Results) != 0 {
+		t.Errorf("expecting no vegan ramen but got %v", result.Results)
+	}
+}
@@ -1,6 +1,8 @@
 package validations
 
 import (
+	"reflect"
+
 	"github.com/go-playground/validator/v10"
 	"github.com/nicodeheza/peersEat/models"
 	"github.com/nicodeheza/peersEat/types"
@@ -14,12 +16,127 @@ type ValidateI interface {
 	ValidateRestaurant(restaurant models.Restaurant) []*ErrorResponse
 	ValidateEvent(event types.Event) []*ErrorResponse
 	ValidateRestaurantData(data types.RestaurantData) []*ErrorResponse
+	ValidateInfluenceRadiusData(data types.InfluenceRadiusData) []*ErrorResponse
+	ValidateInfluenceZoneData(data types.InfluenceZoneData) []*ErrorResponse
+	ValidateRestaurantAddressData(data types.RestaurantAddressData) []*ErrorResponse
+	ValidateCoordOverrideData(data types.CoordOverrideData) []*ErrorResponse
+	ValidateServingQuery(query types.ServingQuery) []*ErrorResponse
+	ValidateClosestPeersQuery(query types.ClosestPeersQuery) []*ErrorResponse
+	ValidateHandoffRequest(request types.HandoffRequest) []*ErrorResponse
+	ValidateHandoffOffer(offer types.HandoffOffer) []*ErrorResponse
+	ValidateReplicationSubscribe(request types.ReplicationSubscribe) []*ErrorResponse
+	ValidatePromoteRequest(request types.PromoteRequest) []*ErrorResponse
+	ValidateReplicasQuery(query types.ReplicasQuery) []*ErrorResponse
+	ValidateMenuSection(data types.MenuSectionData) []*ErrorResponse
+	ValidateMenuSectionUpdate(data types.MenuSectionUpdate) []*ErrorResponse
+	ValidateDish(data types.DishData) []*ErrorResponse
+	ValidateDishUpdate(data types.DishUpdate) []*ErrorResponse
+	ValidateDishOption(data types.DishOptionData) []*ErrorResponse
+	ValidateDishOptionUpdate(data types.DishOptionUpdate) []*ErrorResponse
+	ValidateMenuOrder(data types.MenuOrder) []*ErrorResponse
+	ValidateDishOptionGroups(data types.DishOptionGroups) []*ErrorResponse
+	ValidateDishSelection(data types.DishSelection) []*ErrorResponse
+	ValidatePublishMenu(data types.PublishMenu) []*ErrorResponse
+	ValidateCurrentMenuQuery(data types.CurrentMenuQuery) []*ErrorResponse
+	ValidateMenuFilterQuery(data types.MenuFilterQuery) []*ErrorResponse
+	ValidateItemAvailability(data types.ItemAvailability) []*ErrorResponse
+	ValidateOpeningHours(data types.OpeningHoursData) []*ErrorResponse
+	ValidateOpeningStatusQuery(data types.OpeningStatusQuery) []*ErrorResponse
+	ValidateRestaurantListQuery(data types.RestaurantListQuery) []*ErrorResponse
+	ValidateSearchQuery(data types.SearchQuery) []*ErrorResponse
+	ValidateTextSearchQuery(data types.TextSearchQuery) []*ErrorResponse
+	ValidateReview(data types.ReviewData) []*ErrorResponse
+	ValidateReviewReply(data types.ReviewReplyData) []*ErrorResponse
+	ValidateHelpfulVote(data types.HelpfulVote) []*ErrorResponse
+	ValidateReviewListQuery(data types.ReviewListQuery) []*ErrorResponse
+	ValidateDeliveryPricing(data types.DeliveryPricingData) []*ErrorResponse
+	ValidateDeliveryQuoteRequest(data types.DeliveryQuoteRequest) []*ErrorResponse
+	ValidateRemoteRestaurantStatus(status models.RemoteRestaurantStatus) []*ErrorResponse
+	ValidateStatusChange(data types.StatusChangeData) []*ErrorResponse
+	ValidateStaffInvitation(data types.StaffInvitationData) []*ErrorResponse
+	ValidateAcceptInvitation(data types.AcceptInvitationData) []*ErrorResponse
+	ValidateRestaurantSetup(data types.RestaurantSetupData) []*ErrorResponse
+	ValidateStaffRole(data types.StaffRoleData) []*ErrorResponse
+	ValidateStaffPassword(data types.StaffPasswordData) []*ErrorResponse
+	ValidatePromotion(data types.PromotionData) []*ErrorResponse
+	ValidateCart(data types.Cart) []*ErrorResponse
+	ValidateCustomerTokenRequest(data types.CustomerTokenRequest) []*ErrorResponse
 }
 
 func NewValidator(validate *validator.Validate) *Validate {
+	validate.RegisterStructValidation(validateGeoZone, models.GeoZone{})
+	validate.RegisterStructValidation(validateDietary, types.DietaryData{})
+	// the rules on money fields apply to the amount, the currency is checked against the restaurant one
+	validate.RegisterCustomTypeFunc(func(field reflect.Value) interface{} {
+		return field.Interface().(models.Money).Amount
+	}, models.Money{})
+	validate.RegisterValidation("allergen", func(fl validator.FieldLevel) bool {
+		return models.IsAllergen(fl.Field().String())
+	})
+	validate.RegisterValidation("dietary", func(fl validator.FieldLevel) bool {
+		return models.IsDietaryLabel(fl.Field().String())
+	})
 	return &Validate{validate}
 }
 
+func validateDietary(sl validator.StructLevel) {
+	data := sl.Current().Interface().(types.DietaryData)
+
+	contained := map[string]bool{}
+	for _, allergen := range data.Allergens {
+		contained[allergen] = true
+	}
+	for _, allergen := range data.MayContain {
+		if contained[allergen] {
+			sl.ReportError(data.MayContain, "MayContain", "MayContain", "contained", allergen)
+			return
+		}
+	}
+	for _, label := range data.DietaryLabels {
+		for _, allergen := range models.LABEL_EXCLUDED_ALLERGENS[label] {
+			if contained[allergen] {
+				sl.ReportError(data.DietaryLabels, "DietaryLabels", "DietaryLabels", "contradicts", allergen)
+				return
+			}
+		}
+	}
+}
+
+func validateGeoZone(sl validator.StructLevel) {
+	zone := sl.Current().Interface().(models.GeoZone)
+
+	if zone.Type != models.MULTI_POLYGON || len(zone.Coordinates) == 0 {
+		sl.ReportError(zone.Coordinates, "Coordinates", "Coordinates", "geozone", "")
+		return
+	}
+
+	for _, polygon := range zone.Coordinates {
+		if len(polygon) == 0 {
+			sl.ReportError(zone.Coordinates, "Coordinates", "Coordinates", "polygon", "")
+			return
+		}
+		for _, ring := range polygon {
+			if len(ring) < 4 {
+				sl.ReportError(zone.Coordinates, "Coordinates", "Coordinates", "ring", "4")
+				return
+			}
+			for _, position := range ring {
+				if len(position) != 2 ||
+					position[0] < -180 || position[0] > 180 ||
+					position[1] < -90 || position[1] > 90 {
+					sl.ReportError(zone.Coordinates, "Coordinates", "Coordinates", "position", "")
+					return
+				}
+			}
+			first, last := ring[0], ring[len(ring)-1]
+			if first[0] != last[0] || first[1] != last[1] {
+				sl.ReportError(zone.Coordinates, "Coordinates", "Coordinates", "closedring", "")
+				return
+			}
+		}
+	}
+}
+
 type ErrorResponse struct {
 	FailedField string
 	Tag         string
@@ -56,6 +173,231 @@ func (v *Validate) ValidateRestaurantData(data types.RestaurantData) []*ErrorRes
 	return v.getErrors(err)
 }
 
+func (v *Validate) ValidateInfluenceRadiusData(data types.InfluenceRadiusData) []*ErrorResponse {
+	err := v.validate.Struct(data)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateInfluenceZoneData(data types.InfluenceZoneData) []*ErrorResponse {
+	err := v.validate.Struct(data)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateRestaurantAddressData(data types.RestaurantAddressData) []*ErrorResponse {
+	err := v.validate.Struct(data)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateCoordOverrideData(data types.CoordOverrideData) []*ErrorResponse {
+	err := v.validate.Struct(data)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateServingQuery(query types.ServingQuery) []*ErrorResponse {
+	err := v.validate.Struct(query)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateClosestPeersQuery(query types.ClosestPeersQuery) []*ErrorResponse {
+	err := v.validate.Struct(query)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateHandoffRequest(request types.HandoffRequest) []*ErrorResponse {
+	err := v.validate.Struct(request)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateHandoffOffer(offer types.HandoffOffer) []*ErrorResponse {
+	err := v.validate.Struct(offer)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateReplicationSubscribe(request types.ReplicationSubscribe) []*ErrorResponse {
+	err := v.validate.Struct(request)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidatePromoteRequest(request types.PromoteRequest) []*ErrorResponse {
+	err := v.validate.Struct(request)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateReplicasQuery(query types.ReplicasQuery) []*ErrorResponse {
+	err := v.validate.Struct(query)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateMenuSection(data types.MenuSectionData) []*ErrorResponse {
+	err := v.validate.Struct(data)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateMenuSectionUpdate(data types.MenuSectionUpdate) []*ErrorResponse {
+	err := v.validate.Struct(data)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateDish(data types.DishData) []*ErrorResponse {
+	err := v.validate.Struct(data)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateDishUpdate(data types.DishUpdate) []*ErrorResponse {
+	err := v.validate.Struct(data)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateDishOption(data types.DishOptionData) []*ErrorResponse {
+	err := v.validate.Struct(data)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateDishOptionUpdate(data types.DishOptionUpdate) []*ErrorResponse {
+	err := v.validate.Struct(data)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateMenuOrder(data types.MenuOrder) []*ErrorResponse {
+	err := v.validate.Struct(data)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateDishOptionGroups(data types.DishOptionGroups) []*ErrorResponse {
+	err := v.validate.Struct(data)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateDishSelection(data types.DishSelection) []*ErrorResponse {
+	err := v.validate.Struct(data)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidatePublishMenu(data types.PublishMenu) []*ErrorResponse {
+	err := v.validate.Struct(data)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateCurrentMenuQuery(data types.CurrentMenuQuery) []*ErrorResponse {
+	err := v.validate.Struct(data)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateMenuFilterQuery(data types.MenuFilterQuery) []*ErrorResponse {
+	err := v.validate.Struct(data)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateItemAvailability(data types.ItemAvailability) []*ErrorResponse {
+	err := v.validate.Struct(data)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateOpeningHours(data types.OpeningHoursData) []*ErrorResponse {
+	err := v.validate.Struct(data)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateOpeningStatusQuery(data types.OpeningStatusQuery) []*ErrorResponse {
+	err := v.validate.Struct(data)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateRestaurantListQuery(data types.RestaurantListQuery) []*ErrorResponse {
+	err := v.validate.Struct(data)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateSearchQuery(data types.SearchQuery) []*ErrorResponse {
+	err := v.validate.Struct(data)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateTextSearchQuery(data types.TextSearchQuery) []*ErrorResponse {
+	err := v.validate.Struct(data)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateReview(data types.ReviewData) []*ErrorResponse {
+	err := v.validate.Struct(data)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateReviewReply(data types.ReviewReplyData) []*ErrorResponse {
+	err := v.validate.Struct(data)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateHelpfulVote(data types.HelpfulVote) []*ErrorResponse {
+	err := v.validate.Struct(data)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateReviewListQuery(data types.ReviewListQuery) []*ErrorResponse {
+	err := v.validate.Struct(data)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateDeliveryPricing(data types.DeliveryPricingData) []*ErrorResponse {
+	err := v.validate.Struct(data)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateDeliveryQuoteRequest(data types.DeliveryQuoteRequest) []*ErrorResponse {
+	err := v.validate.Struct(data)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateRemoteRestaurantStatus(status models.RemoteRestaurantStatus) []*ErrorResponse {
+	err := v.validate.Struct(status)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateStatusChange(data types.StatusChangeData) []*ErrorResponse {
+	err := v.validate.Struct(data)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateStaffInvitation(data types.StaffInvitationData) []*ErrorResponse {
+	err := v.validate.Struct(data)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateAcceptInvitation(data types.AcceptInvitationData) []*ErrorResponse {
+	err := v.validate.Struct(data)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateRestaurantSetup(data types.RestaurantSetupData) []*ErrorResponse {
+	err := v.validate.Struct(data)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateStaffRole(data types.StaffRoleData) []*ErrorResponse {
+	err := v.validate.Struct(data)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateStaffPassword(data types.StaffPasswordData) []*ErrorResponse {
+	err := v.validate.Struct(data)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidatePromotion(data types.PromotionData) []*ErrorResponse {
+	err := v.validate.Struct(data)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateCart(data types.Cart) []*ErrorResponse {
+	err := v.validate.Struct(data)
+	return v.getErrors(err)
+}
+
+func (v *Validate) ValidateCustomerTokenRequest(data types.CustomerTokenRequest) []*ErrorResponse {
+	err := v.validate.Struct(data)
+	return v.getErrors(err)
+}
+
 func (v *Validate) ValidateEvent(event types.Event) []*ErrorResponse {
 	err := v.validate.Struct(event)
 	return v.getErrors(err)
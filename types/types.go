@@ -1,6 +1,11 @@
 package types
 
-import "github.com/nicodeheza/peersEat/models"
+import (
+	"time"
+
+	"github.com/nicodeheza/peersEat/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 type PeerPresentationBody struct {
 	NewPeer models.Peer
@@ -11,6 +16,32 @@ type SendAllPeerQuery struct {
 	Excludes []string `query:"excludes"`
 }
 
+// pointers so a missing coordinate is not taken as 0
+type ServingQuery struct {
+	Long   *float64 `query:"long" validate:"required,gte=-180,lte=180"`
+	Lat    *float64 `query:"lat" validate:"required,gte=-90,lte=90"`
+	Area   string   `query:"area" validate:"omitempty,oneof=delivery influence"`
+	Follow bool     `query:"follow"`
+}
+
+type ClosestPeersQuery struct {
+	Geohash string `query:"geohash" validate:"required,alphanum,lowercase,max=12"`
+	Limit   int    `query:"limit" validate:"gte=0,lte=64"`
+}
+
+type ServingPeer struct {
+	Url      string
+	City     string
+	Country  string
+	Center   models.GeoCoords
+	Distance float64
+}
+
+type ServingResponse struct {
+	Peers  []ServingPeer
+	Source string
+}
+
 type ApiGeoCord struct{}
 
 type GetCordsResponse struct {
@@ -27,15 +58,38 @@ type GetCordsResponse struct {
 	Importance   float64
 }
 
+type GeocodeResult struct {
+	Coord       models.GeoCoords
+	Provider    string
+	Confidence  float64
+	BoundingBox []float64 `json:"BoundingBox,omitempty"` // minLong, minLat, maxLong, maxLat
+	DisplayName string
+}
+
+type GeoJsonFeatureCollection struct {
+	Features []struct {
+		Geometry struct {
+			Coordinates []float64
+		}
+		Properties struct {
+			Label      string    `json:"label"`
+			Name       string    `json:"name"`
+			Confidence float64   `json:"confidence"`
+			Extent     []float64 `json:"extent"`
+		}
+		Bbox []float64 `json:"bbox"`
+	}
+}
+
 type PeerHaveRestaurantResp struct {
 	Resp bool
 	Err  error
 }
 
+// the account is the one of the session
 type UpdateRestaurantPassword struct {
 	NewPassword string
 	NewUsername string
-	Id          string
 }
 
 type AuthReq struct {
@@ -43,22 +97,627 @@ type AuthReq struct {
 	UserName string
 }
 
+// the restaurant is the one of the session
 type RestaurantData struct {
-	Id                string  `validate:"required"`
-	Name              string  `validate:"required"`
-	ImageUrl          string  `validate:"required,url"`
-	OpenTime          string  `validate:"required,dateTime=3:04PM"`
-	CloseTime         string  `validate:"required,dateTime=3:04PM"`
-	Phone             string  `validate:"required,e164"`
-	DeliveryCost      float32 `validate:"required,gte=0"`
-	IsDeliveryFixCost bool    `validate:"required"`
-	MinDeliveryTime   uint    `validate:"required,gte=0"`
-	MaxDeliveryTime   uint    `validate:"required,gte=0"`
-	DeliveryRadius    float64 `validate:"required,gte=0"`
+	Name              string       `validate:"required"`
+	ImageUrl          string       `validate:"omitempty,url"`
+	OpenTime          string       `validate:"required,datetime=3:04PM"`
+	CloseTime         string       `validate:"required,datetime=3:04PM"`
+	Phone             string       `validate:"required,e164"`
+	DeliveryCost      models.Money `validate:"gte=0"`
+	IsDeliveryFixCost bool
+	MinDeliveryTime   uint
+	MaxDeliveryTime   uint
+	DeliveryRadius    float64 `validate:"gte=0"`
+	DeliveryZone      *models.GeoZone
+	Tags              []string `validate:"max=20,dive,min=1,max=40"`
+}
+
+type OpeningIntervalData struct {
+	Open  string `validate:"required,datetime=15:04"`
+	Close string `validate:"required,datetime=15:04"`
+}
+
+type DayScheduleData struct {
+	Day       time.Weekday          `validate:"gte=0,lte=6"`
+	Intervals []OpeningIntervalData `validate:"dive"`
+}
+
+// a date without intervals is closed
+type ScheduleExceptionData struct {
+	Date      string                `validate:"required,datetime=2006-01-02"`
+	Intervals []OpeningIntervalData `validate:"dive"`
+	Note      string                `validate:"max=140"`
+}
+
+type OpeningHoursData struct {
+	Timezone   string                  `validate:"omitempty,timezone"`
+	Weekly     []DayScheduleData       `validate:"dive"`
+	Exceptions []ScheduleExceptionData `validate:"dive"`
+}
+
+// At defaults to now
+type OpeningStatusQuery struct {
+	At string `query:"at" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+}
+
+type OpeningStatus struct {
+	Timezone     string
+	IsOpen       bool
+	NextOpening  *time.Time `json:"NextOpening,omitempty"`
+	OpeningHours models.OpeningHours
+}
+
+type RestaurantAddressData struct {
+	Address string `validate:"required"`
+	City    string `validate:"required"`
+	Country string `validate:"required"`
+}
+
+type CoordOverrideData struct {
+	Coord models.GeoCoords `validate:"required"`
+}
+
+type InfluenceRadiusData struct {
+	InfluenceRadius float64 `validate:"required,gt=0"`
+}
+
+type InfluenceZoneData struct {
+	InfluenceZone *models.GeoZone
+}
+
+type HandoffRequest struct {
+	Mode          string   `validate:"required,oneof=split merge"`
+	Target        string   `validate:"omitempty,url"`
+	RestaurantIds []string `validate:"dive,mongodb"`
+	DryRun        bool
+}
+
+type HandoffPlanItem struct {
+	RestaurantId string
+	Name         string
+	To           string
+}
+
+type HandoffResult struct {
+	Planned  []HandoffPlanItem
+	Moved    []HandoffPlanItem
+	Rejected []HandoffPlanItem
+	Errors   []string
+}
+
+// the receiving peer pulls the restaurants from From using the token
+type HandoffOffer struct {
+	Id    string `validate:"required"`
+	Token string `validate:"required"`
+	From  string `validate:"required,url"`
+	Mode  string `validate:"required,oneof=split merge"`
+}
+
+type HandoffAccept struct {
+	Accepted []string
+}
+
+// credentials travel apart from the restaurant document so the transfer does
+// not depend on how restaurants are serialized
+type TransferredRestaurant struct {
+	Restaurant   models.Restaurant
+	UserName     string
+	Password     string
+	MenuVersions []models.MenuVersion   `json:",omitempty"`
+	StaffUsers   []TransferredStaffUser `json:",omitempty"`
+}
+
+// the staff users travel with the credentials their json leaves out too
+type TransferredStaffUser struct {
+	StaffUser       models.StaffUser
+	Password        string
+	InviteTokenHash string
+}
+
+// the buddy pulls the replication secret from From using the token
+type ReplicationSubscribe struct {
+	Id    string `validate:"required"`
+	Token string `validate:"required"`
+	From  string `validate:"required,url"`
+}
+
+type ReplicationHandshake struct {
+	Secret string
+}
+
+type ReplicationOp struct {
+	Type         string
+	RestaurantId string
+	Restaurant   *TransferredRestaurant `json:",omitempty"`
+}
+
+// full batches replace every replica of the origin
+type ReplicationBatch struct {
+	Seq  int64
+	Full bool
+	Ops  []ReplicationOp
+}
+
+type PromoteRequest struct {
+	Origin string `validate:"required,url"`
+	Force  bool
+}
+
+type ReplicasQuery struct {
+	Origin string `query:"origin" validate:"required,url"`
+}
+
+type MenuSectionData struct {
+	Name string `validate:"required"`
+}
+
+type MenuSectionUpdate struct {
+	Name *string `validate:"omitempty,min=1"`
+}
+
+// an allergen is either contained or a trace, labels can't contradict the allergens
+type DietaryData struct {
+	Allergens     []string `validate:"max=14,unique,dive,allergen"`
+	MayContain    []string `validate:"max=14,unique,dive,allergen"`
+	DietaryLabels []string `validate:"max=4,unique,dive,dietary"`
+}
+
+func (d DietaryData) Dietary() models.Dietary {
+	return models.Dietary{Allergens: d.Allergens, MayContain: d.MayContain, DietaryLabels: d.DietaryLabels}
+}
+
+type DishOptionData struct {
+	Name        string `validate:"required"`
+	Description string
+	Price       models.Money `validate:"gte=0"`
+	DietaryData
+}
+
+// existing ids are kept so the entities stay stable when the groups are replaced
+type GroupOptionData struct {
+	Id          string `validate:"omitempty,hexadecimal,len=24"`
+	Name        string `validate:"required"`
+	Description string
+	PriceDelta  models.Money
+	IsDefault   bool
+	Groups      []OptionGroupData `validate:"dive"`
+	DietaryData
+}
+
+type OptionGroupData struct {
+	Id       string `validate:"omitempty,hexadecimal,len=24"`
+	Name     string `validate:"required"`
+	Min      uint
+	Max      uint
+	Required bool
+	Options  []GroupOptionData `validate:"required,min=1,dive"`
+}
+
+type DishOptionGroups struct {
+	Groups []OptionGroupData `validate:"dive"`
+}
+
+type DishData struct {
+	Name         string `validate:"required"`
+	Description  string
+	Price        models.Money      `validate:"gte=0"`
+	ImageUrl     string            `validate:"omitempty,url"`
+	Options      []DishOptionData  `validate:"dive"`
+	OptionGroups []OptionGroupData `validate:"dive"`
+	Tags         []string          `validate:"max=20,dive,min=1,max=40"`
+	DietaryData
+}
+
+// groups missing from the selection use their default options
+type GroupSelection struct {
+	GroupId string            `validate:"required,hexadecimal,len=24"`
+	Options []OptionSelection `validate:"dive"`
+}
+
+type OptionSelection struct {
+	OptionId string           `validate:"required,hexadecimal,len=24"`
+	Groups   []GroupSelection `validate:"dive"`
+}
+
+type DishSelection struct {
+	DishId  string           `validate:"required,hexadecimal,len=24"`
+	Options []string         `validate:"dive,hexadecimal,len=24"`
+	Groups  []GroupSelection `validate:"dive"`
+}
+
+type PricedOption struct {
+	Id         string
+	Name       string
+	PriceDelta models.Money
+}
+
+type PricedSelection struct {
+	DishId        string
+	MenuVersionId string `json:"MenuVersionId,omitempty"`
+	BasePrice     models.Money
+	Options       []PricedOption
+	Total         models.Money
+}
+
+type AvailabilityWindowData struct {
+	Days  []time.Weekday `validate:"dive,gte=0,lte=6"`
+	Start string         `validate:"required,datetime=15:04"`
+	End   string         `validate:"required,datetime=15:04"`
+}
+
+type PublishMenu struct {
+	Name    string                   `validate:"required,max=64"`
+	Windows []AvailabilityWindowData `validate:"dive"`
+}
+
+type MenuVersionsQuery struct {
+	Name string `query:"name"`
+}
+
+// without Until or Indefinite the item is available again at the next opening
+type ItemAvailability struct {
+	Available  bool
+	Until      string `validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	Indefinite bool
+}
+
+// PublicRestaurant is the restaurant served to customers, without credentials
+type PublicRestaurant struct {
+	Id                primitive.ObjectID  `json:"id"`
+	Name              string              `json:"name"`
+	Address           string              `json:"address"`
+	City              string              `json:"city"`
+	Country           string              `json:"country"`
+	Coord             models.GeoCoords    `json:"coord"`
+	ImageUrl          string              `json:"imageUrl,omitempty"`
+	Phone             string              `json:"phone,omitempty"`
+	Rate              models.Rate         `json:"rate"`
+	Rating            float64             `json:"rating"`
+	Currency          string              `json:"currency"`
+	DeliveryCost      models.Money        `json:"deliveryCost"`
+	IsDeliveryFixCost bool                `json:"isDeliveryFixCost"`
+	MinDeliveryTime   uint                `json:"minDeliveryTime,omitempty"`
+	MaxDeliveryTime   uint                `json:"maxDeliveryTime,omitempty"`
+	DeliveryRadius    float64             `json:"deliveryRadius,omitempty"`
+	DeliveryZone      *models.GeoZone     `json:"deliveryZone,omitempty"`
+	OpeningHours      models.OpeningHours `json:"openingHours"`
+	Tags              []string            `json:"tags,omitempty"`
+	Status            string              `json:"status"`
+	IsConnected       bool                `json:"isConnected"`
+	IsPaused          bool                `json:"isPaused"`
+	LastSeen          *time.Time          `json:"lastSeen,omitempty"`
+	IsOpen            bool                `json:"isOpen"`
+	NextOpening       *time.Time          `json:"nextOpening,omitempty"`
+	Menu              *models.MenuVersion `json:"menu,omitempty"`
+}
+
+// Lat and Long filter the restaurants delivering to that point
+type RestaurantListQuery struct {
+	City string   `query:"city" validate:"max=100"`
+	Open bool     `query:"open"`
+	Lat  *float64 `query:"lat" validate:"required_with=Long,omitempty,gte=-90,lte=90"`
+	Long *float64 `query:"long" validate:"required_with=Lat,omitempty,gte=-180,lte=180"`
+	At   string   `query:"at" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+}
+
+// At defaults to now, peers receive it so they all evaluate the same instant
+type SearchQuery struct {
+	Lat  *float64 `query:"lat" validate:"required,gte=-90,lte=90"`
+	Long *float64 `query:"long" validate:"required,gte=-180,lte=180"`
+	City string   `query:"city" validate:"max=100"`
+	Open bool     `query:"open"`
+	At   string   `query:"at" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	// dietary filters, restaurants need a dish passing them
+	Include       []string `query:"include" validate:"max=4,dive,dietary"`
+	Exclude       []string `query:"exclude" validate:"max=14,dive,allergen"`
+	ExcludeTraces bool     `query:"excludeTraces"`
+}
+
+func (q SearchQuery) Filter() models.DietaryFilter {
+	return models.DietaryFilter{Include: q.Include, Exclude: q.Exclude, ExcludeTraces: q.ExcludeTraces}
+}
+
+// Distance is in km and Eta in minutes
+type SearchHit struct {
+	Restaurant PublicRestaurant `json:"restaurant"`
+	PeerUrl    string           `json:"peerUrl"`
+	Distance   float64          `json:"distance"`
+	Eta        uint             `json:"eta"`
+}
+
+// FailedPeers did not answer in time, Restaurants are partial when not empty
+type SearchResult struct {
+	Restaurants []SearchHit `json:"restaurants"`
+	FailedPeers []string    `json:"failedPeers"`
+}
+
+// the last word of Q also matches as a prefix, Lat and Long rank closer restaurants first
+type TextSearchQuery struct {
+	Q        string   `query:"q" validate:"required,max=100"`
+	City     string   `query:"city" validate:"max=100"`
+	Lat      *float64 `query:"lat" validate:"required_with=Long,omitempty,gte=-90,lte=90"`
+	Long     *float64 `query:"long" validate:"required_with=Lat,omitempty,gte=-180,lte=180"`
+	Page     int      `query:"page" validate:"omitempty,gte=1,lte=1000"`
+	PageSize int      `query:"pageSize" validate:"omitempty,gte=1,lte=50"`
+	// dietary filters, only the dishes passing them are matched
+	Include       []string `query:"include" validate:"max=4,dive,dietary"`
+	Exclude       []string `query:"exclude" validate:"max=14,dive,allergen"`
+	ExcludeTraces bool     `query:"excludeTraces"`
+}
+
+func (q TextSearchQuery) Filter() models.DietaryFilter {
+	return models.DietaryFilter{Include: q.Include, Exclude: q.Exclude, ExcludeTraces: q.ExcludeTraces}
+}
+
+type MatchedDish struct {
+	Id   primitive.ObjectID `json:"id"`
+	Name string             `json:"name"`
+}
+
+// Relevance goes from 0 to 1, Distance is in km
+type TextSearchHit struct {
+	Restaurant PublicRestaurant `json:"restaurant"`
+	Dishes     []MatchedDish    `json:"dishes"`
+	Relevance  float64          `json:"relevance"`
+	Distance   *float64         `json:"distance,omitempty"`
+}
+
+type TextSearchResult struct {
+	Results  []TextSearchHit `json:"results"`
+	Total    int             `json:"total"`
+	Page     int             `json:"page"`
+	PageSize int             `json:"pageSize"`
+}
+
+type DishRatingData struct {
+	DishId string `validate:"required,hexadecimal,len=24"`
+	Stars  uint   `validate:"required,gte=1,lte=5"`
+}
+
+// ReviewToken is the one returned when the order was redeemed, a new review of the
+// same order replaces the previous one
+type ReviewData struct {
+	ReviewToken  string           `validate:"required,hexadecimal,len=48"`
+	CustomerName string           `validate:"max=60"`
+	Stars        uint             `validate:"required,gte=1,lte=5"`
+	Text         string           `validate:"max=2000"`
+	Dishes       []DishRatingData `validate:"max=50,dive"`
+}
+
+type ReviewReplyData struct {
+	Text string `validate:"required,max=2000"`
+}
+
+// ReviewToken is the one of a redeemed order of the restaurant, it votes as that order
+type HelpfulVote struct {
+	ReviewToken string `validate:"required,hexadecimal,len=48"`
+}
+
+// Sort is recent by default
+type ReviewListQuery struct {
+	Sort     string `query:"sort" validate:"omitempty,oneof=recent helpful"`
+	Page     int    `query:"page" validate:"omitempty,gte=1"`
+	PageSize int    `query:"pageSize" validate:"omitempty,gte=1,lte=50"`
+}
+
+// rejecting or suspending a restaurant requires a reason
+type StatusChangeData struct {
+	Reason string `validate:"max=500"`
+}
+
+type RestaurantStatus struct {
+	Status  string                `json:"status"`
+	History []models.StatusChange `json:"history"`
+}
+
+type StaffInvitationData struct {
+	Name string `validate:"required,max=60"`
+	Role string `validate:"required,oneof=owner manager kitchen readOnly"`
+}
+
+// the token is only shown once, the invited user needs it to set its credentials
+type StaffInvitation struct {
+	User  models.StaffUser `json:"user"`
+	Token string           `json:"token"`
+}
+
+type AcceptInvitationData struct {
+	Token    string `validate:"required,max=100"`
+	UserName string `validate:"required,min=4,max=60"`
+	Password string `validate:"required,min=8,max=72"`
+}
+
+// Token is the one given to the peer owner when the restaurant was added
+type RestaurantSetupData struct {
+	Token    string `validate:"required,max=100"`
+	UserName string `validate:"required,min=4,max=60"`
+	Password string `validate:"required,min=8,max=72"`
+}
+
+type StaffRoleData struct {
+	Role string `validate:"required,oneof=owner manager kitchen readOnly"`
+}
+
+type StaffPasswordData struct {
+	NewPassword string `validate:"required,min=8,max=72"`
+}
+
+// tiers must be sorted by UpToKm
+type DistanceTierData struct {
+	UpToKm float64      `validate:"gt=0"`
+	PerKm  models.Money `validate:"gte=0"`
+}
+
+type PeakWindowData struct {
+	AvailabilityWindowData
+	Multiplier float64 `validate:"gt=0,lte=10"`
+}
+
+type DeliveryPricingData struct {
+	BaseFee   models.Money       `validate:"gte=0"`
+	Tiers     []DistanceTierData `validate:"dive"`
+	MinOrder  models.Money       `validate:"gte=0"`
+	FreeAbove models.Money       `validate:"gte=0"`
+	Peaks     []PeakWindowData   `validate:"dive"`
+}
+
+// At is the delivery time, defaults to now
+type DeliveryQuoteRequest struct {
+	Lat      *float64     `validate:"required,gte=-90,lte=90"`
+	Long     *float64     `validate:"required,gte=-180,lte=180"`
+	Subtotal models.Money `validate:"gte=0"`
+	At       string       `validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+}
+
+// the amounts are in the currency of the restaurant, or of the peer for promotions
+// of the whole peer. Windows use the timezone of the restaurant of the cart
+type PromotionData struct {
+	Name               string       `validate:"required,max=80"`
+	Code               string       `validate:"omitempty,alphanum,min=3,max=30"`
+	Type               string       `validate:"required,oneof=percent fixed freeDelivery buyXGetY"`
+	Percent            float64      `validate:"required_if=Type percent,gte=0,lte=100"`
+	Amount             models.Money `validate:"required_if=Type fixed,gte=0"`
+	MaxDiscount        models.Money `validate:"gte=0"`
+	BuyQuantity        uint         `validate:"required_if=Type buyXGetY,lte=100"`
+	GetQuantity        uint         `validate:"required_if=Type buyXGetY,lte=100"`
+	DishIds            []string     `validate:"max=100,dive,hexadecimal,len=24"`
+	MinSubtotal        models.Money `validate:"gte=0"`
+	NewCustomersOnly   bool
+	StartsAt           string                   `validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	EndsAt             string                   `validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	Windows            []AvailabilityWindowData `validate:"dive"`
+	MaxUses            uint
+	MaxUsesPerCustomer uint
+	Stackable          bool
+}
+
+type CartItem struct {
+	DishSelection
+	Quantity uint `validate:"required,lte=100"`
+}
+
+// CustomerToken is issued by the peer to the app that signed the customer in, carts
+// without one don't get the promotions limited per customer. Codes are the coupons
+// entered by the customer, At defaults to now and only prices the cart, it is
+// always redeemed now
+type Cart struct {
+	CustomerToken   string     `validate:"max=300"`
+	Items           []CartItem `validate:"required,min=1,max=100,dive"`
+	DeliveryQuoteId string     `validate:"omitempty,hexadecimal,len=24"`
+	Codes           []string   `validate:"max=5,dive,min=1,max=30"`
+	At              string     `validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+}
+
+// the app of the peer owner asks a token for each customer it signs in
+type CustomerTokenRequest struct {
+	CustomerId string `validate:"required,max=100"`
+}
+
+type CustomerToken struct {
+	CustomerToken string `json:"customerToken"`
+}
+
+type CartLine struct {
+	Selection PricedSelection `json:"selection"`
+	Quantity  uint            `json:"quantity"`
+	Total     models.Money    `json:"total"`
+}
+
+type AppliedPromotion struct {
+	PromotionId primitive.ObjectID `json:"promotionId"`
+	Name        string             `json:"name"`
+	Type        string             `json:"type"`
+	Discount    models.Money       `json:"discount"`
+}
+
+// unknown coupons have no promotion id
+type RejectedPromotion struct {
+	PromotionId *primitive.ObjectID `json:"promotionId,omitempty"`
+	Name        string              `json:"name"`
+	Reason      string              `json:"reason"`
+}
+
+// CartEvaluation explains the price of a cart, the redemption id and the token to
+// review the order are set once it's redeemed
+type CartEvaluation struct {
+	Lines        []CartLine          `json:"lines"`
+	Subtotal     models.Money        `json:"subtotal"`
+	DeliveryFee  models.Money        `json:"deliveryFee"`
+	Discount     models.Money        `json:"discount"`
+	Total        models.Money        `json:"total"`
+	Applied      []AppliedPromotion  `json:"applied"`
+	Rejected     []RejectedPromotion `json:"rejected"`
+	RedemptionId *primitive.ObjectID `json:"redemptionId,omitempty"`
+	ReviewToken  string              `json:"reviewToken,omitempty"`
+}
+
+type ReviewPage struct {
+	Reviews  []models.Review `json:"reviews"`
+	Total    int64           `json:"total"`
+	Page     int             `json:"page"`
+	PageSize int             `json:"pageSize"`
+	Rate     models.Rate     `json:"rate"`
+	Rating   float64         `json:"rating"`
+}
+
+// Sizes has the url of every stored size, ImageUrl is the medium one
+type ImageUpload struct {
+	ImageUrl string            `json:"imageUrl"`
+	Sizes    map[string]string `json:"sizes"`
+}
+
+// At defaults to now
+// Include are dietary labels every dish must have and Exclude allergens they
+// can't contain, ExcludeTraces also leaves out the dishes that may contain them
+type MenuFilterQuery struct {
+	Include       []string `query:"include" validate:"max=4,dive,dietary"`
+	Exclude       []string `query:"exclude" validate:"max=14,dive,allergen"`
+	ExcludeTraces bool     `query:"excludeTraces"`
+}
+
+func (q MenuFilterQuery) Filter() models.DietaryFilter {
+	return models.DietaryFilter{Include: q.Include, Exclude: q.Exclude, ExcludeTraces: q.ExcludeTraces}
+}
+
+type CurrentMenuQuery struct {
+	At            string   `query:"at" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	Include       []string `query:"include" validate:"max=4,dive,dietary"`
+	Exclude       []string `query:"exclude" validate:"max=14,dive,allergen"`
+	ExcludeTraces bool     `query:"excludeTraces"`
+}
+
+func (q CurrentMenuQuery) Filter() models.DietaryFilter {
+	return models.DietaryFilter{Include: q.Include, Exclude: q.Exclude, ExcludeTraces: q.ExcludeTraces}
+}
+
+// nil fields are left unchanged, the dietary fields are replaced together
+type DishUpdate struct {
+	Name        *string `validate:"omitempty,min=1"`
+	Description *string
+	Price       *models.Money `validate:"omitempty,gte=0"`
+	ImageUrl    *string       `validate:"omitempty,url"`
+	Tags        *[]string     `validate:"omitempty,max=20,dive,min=1,max=40"`
+	*DietaryData
+}
+
+type DishOptionUpdate struct {
+	Name        *string `validate:"omitempty,min=1"`
+	Description *string
+	Price       *models.Money `validate:"omitempty,gte=0"`
+	*DietaryData
+}
+
+// every id of the reordered entities, in the new order
+type MenuOrder struct {
+	Ids []string `validate:"required,dive,hexadecimal,len=24"`
 }
 
 type Event struct {
 	Name    string      `validate:"required"`
 	Payload interface{} `validate:"required"`
 	SendTo  []string
+	// broadcasts are routed through the overlay to every peer in Scope
+	Broadcast bool
+	Scope     string
 }
@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"math"
+	"strings"
+
+	"github.com/nicodeheza/peersEat/models"
+)
+
+const GEOHASH_PRECISION = 8
+
+const geohashBase32 = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+func Geohash(coord models.GeoCoords, precision int) string {
+	minLat, maxLat := -90.0, 90.0
+	minLong, maxLong := -180.0, 180.0
+
+	var hash strings.Builder
+	bit, char := 0, 0
+	isLong := true
+
+	for hash.Len() < precision {
+		if isLong {
+			mid := (minLong + maxLong) / 2
+			if coord.Long >= mid {
+				char = char<<1 | 1
+				minLong = mid
+			} else {
+				char = char << 1
+				maxLong = mid
+			}
+		} else {
+			mid := (minLat + maxLat) / 2
+			if coord.Lat >= mid {
+				char = char<<1 | 1
+				minLat = mid
+			} else {
+				char = char << 1
+				maxLat = mid
+			}
+		}
+		isLong = !isLong
+
+		bit++
+		if bit == 5 {
+			hash.WriteByte(geohashBase32[char])
+			bit, char = 0, 0
+		}
+	}
+
+	return hash.String()
+}
+
+// returns the center of the geohash cell
+func GeohashCenter(hash string) models.GeoCoords {
+	minLat, maxLat := -90.0, 90.0
+	minLong, maxLong := -180.0, 180.0
+	isLong := true
+
+	for _, c := range hash {
+		index := strings.IndexRune(geohashBase32, c)
+		for i := 4; i >= 0; i-- {
+			bitSet := index>>i&1 == 1
+			if isLong {
+				mid := (minLong + maxLong) / 2
+				if bitSet {
+					minLong = mid
+				} else {
+					maxLong = mid
+				}
+			} else {
+				mid := (minLat + maxLat) / 2
+				if bitSet {
+					minLat = mid
+				} else {
+					maxLat = mid
+				}
+			}
+			isLong = !isLong
+		}
+	}
+
+	return models.GeoCoords{Long: (minLong + maxLong) / 2, Lat: (minLat + maxLat) / 2}
+}
+
+// approximated width and height in km of a geohash cell of the given length at a latitude
+func GeohashCellSize(length int, lat float64) (float64, float64) {
+	longBits := math.Ceil(float64(length*5) / 2)
+	latBits := math.Floor(float64(length*5) / 2)
+
+	width := 360 / math.Pow(2, longBits) * 111.320 * math.Cos(lat*math.Pi/180)
+	height := 180 / math.Pow(2, latBits) * 110.574
+
+	return math.Abs(width), height
+}
+
+// remote peers may not send the hash, it is always derivable from the center
+func PeerGeohash(peer models.Peer) string {
+	if peer.Geohash != "" {
+		return peer.Geohash
+	}
+	return Geohash(peer.Center, GEOHASH_PRECISION)
+}
+
+func GeohashCommonPrefixLen(hash1, hash2 string) int {
+	i := 0
+	for i < len(hash1) && i < len(hash2) && hash1[i] == hash2[i] {
+		i++
+	}
+	return i
+}
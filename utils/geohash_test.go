@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"math"
+	"testing"
+
+	"github.com/nicodeheza/peersEat/models"
+)
+
+func TestGeohash(t *testing.T) {
+	type Test struct {
+		Coord  models.GeoCoords
+		result string
+	}
+	tests := []Test{
+		{models.GeoCoords{Long: -58.3826948, Lat: -34.5992499}, "69y7pmrh"},
+		{models.GeoCoords{Long: 10.40744, Lat: 57.64911}, "u4pruydq"},
+	}
+
+	for _, test := range tests {
+		result := Geohash(test.Coord, GEOHASH_PRECISION)
+		if result != test.result {
+			t.Errorf("expect %s but gets %s for %v", test.result, result, test.Coord)
+		}
+
+		center := GeohashCenter(result)
+		if math.Abs(center.Long-test.Coord.Long) > 0.001 || math.Abs(center.Lat-test.Coord.Lat) > 0.001 {
+			t.Errorf("expect cell center %v to be close to %v", center, test.Coord)
+		}
+	}
+}
+
+func TestGeohashHelpers(t *testing.T) {
+	if GeohashCommonPrefixLen("69y7pkxf", "69y7qabc") != 4 {
+		t.Errorf("expect common prefix of 4 but gets %d", GeohashCommonPrefixLen("69y7pkxf", "69y7qabc"))
+	}
+
+	width, height := GeohashCellSize(5, 0)
+	if math.Abs(width-4.89) > 0.01 || math.Abs(height-4.89) > 0.1 {
+		t.Errorf("expect a 4.89km cell but gets %f x %f", width, height)
+	}
+}
@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+var accentReplacer = strings.NewReplacer(
+	"á", "a", "à", "a", "ä", "a", "â", "a", "ã", "a",
+	"é", "e", "è", "e", "ë", "e", "ê", "e",
+	"í", "i", "ì", "i", "ï", "i", "î", "i",
+	"ó", "o", "ò", "o", "ö", "o", "ô", "o", "õ", "o",
+	"ú", "u", "ù", "u", "ü", "u", "û", "u",
+	"ñ", "n", "ç", "c",
+)
+
+// Tokenize splits a text in lowercase words without accents
+func Tokenize(text string) []string {
+	text = accentReplacer.Replace(strings.ToLower(text))
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// EditDistance is the Damerau-Levenshtein distance counting adjacent transpositions as one edit
+func EditDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	rows := make([][]int, len(s)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(t)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			distance := rows[i-1][j-1] + cost
+			if rows[i-1][j]+1 < distance {
+				distance = rows[i-1][j] + 1
+			}
+			if rows[i][j-1]+1 < distance {
+				distance = rows[i][j-1] + 1
+			}
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] && rows[i-2][j-2]+1 < distance {
+				distance = rows[i-2][j-2] + 1
+			}
+			rows[i][j] = distance
+		}
+	}
+	return rows[len(s)][len(t)]
+}
@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tokens := Tokenize("Empanadas de Jamón y Queso, ¡gluten-free!")
+	expected := []string{"empanadas", "de", "jamon", "y", "queso", "gluten", "free"}
+	if !reflect.DeepEqual(tokens, expected) {
+		t.Errorf("expect %v but gets %v", expected, tokens)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		distance int
+	}{
+		{"ramen", "ramen", 0},
+		{"ramen", "ramn", 1},
+		{"pizza", "pziza", 1},
+		{"pizza", "pasta", 3},
+		{"", "sushi", 5},
+		{"ñoquis", "noquis", 1},
+	}
+
+	for _, test := range tests {
+		if distance := EditDistance(test.a, test.b); distance != test.distance {
+			t.Errorf("expect %d but gets %d for %s and %s", test.distance, distance, test.a, test.b)
+		}
+	}
+}